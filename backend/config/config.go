@@ -1,150 +1,394 @@
-package config
-
-import (
-	"fmt"
-	"log"
-	"os"
-	"path/filepath"
-	"strings"
-
-	"github.com/spf13/viper"
-)
-
-// Config 应用配置结构
-type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	LLM      LLMConfig      `mapstructure:"llm"`
-	Log      LogConfig      `mapstructure:"log"`
-}
-
-// ServerConfig 服务器配置
-type ServerConfig struct {
-	Host      string `mapstructure:"host"`
-	Port      string `mapstructure:"port"`
-	JWTSecret string `mapstructure:"jwt_secret"`
-}
-
-// DatabaseConfig 数据库配置
-type DatabaseConfig struct {
-	Driver   string `mapstructure:"driver"`
-	Host     string `mapstructure:"host"`
-	Port     string `mapstructure:"port"`
-	User     string `mapstructure:"user"`
-	Password string `mapstructure:"password"`
-	DBName   string `mapstructure:"dbname"`
-}
-
-// RedisConfig Redis配置
-type RedisConfig struct {
-	Host     string `mapstructure:"host"`
-	Port     string `mapstructure:"port"`
-	Password string `mapstructure:"password"`
-	DB       int    `mapstructure:"db"`
-}
-
-// LLMConfig LLM服务配置
-type LLMConfig struct {
-	Host string `mapstructure:"host"`
-	Port string `mapstructure:"port"`
-}
-
-// LogConfig 日志配置
-type LogConfig struct {
-	Level string `mapstructure:"level"`
-	Path  string `mapstructure:"path"`
-}
-
-var cfg *Config
-
-// LoadConfig 加载配置
-func LoadConfig(configPath string) (*Config, error) {
-	// 创建新的viper实例
-	v := viper.New()
-
-	// 设置配置文件名和路径
-	v.SetConfigName("config")
-
-	if configPath != "" {
-		// 使用指定的配置路径
-		v.AddConfigPath(configPath)
-	} else {
-		// 默认配置路径
-		v.AddConfigPath("./config")
-		v.AddConfigPath(".")
-	}
-
-	// 设置配置文件类型
-	v.SetConfigType("yaml")
-
-	// 从环境变量读取
-	v.AutomaticEnv()
-	v.SetEnvPrefix("APP")
-	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-
-	// 读取配置文件
-	if err := v.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("读取配置文件失败: %w", err)
-	}
-
-	// 解析到配置结构
-	config := &Config{}
-	if err := v.Unmarshal(config); err != nil {
-		return nil, fmt.Errorf("解析配置失败: %w", err)
-	}
-
-	// 设置全局配置
-	cfg = config
-
-	return config, nil
-}
-
-// GetConfig 获取全局配置
-func GetConfig() *Config {
-	if cfg == nil {
-		var err error
-		cfg, err = LoadConfig("")
-		if err != nil {
-			log.Fatalf("无法加载配置: %v", err)
-		}
-	}
-	return cfg
-}
-
-// 获取数据库DSN
-func (c *DatabaseConfig) GetDSN() string {
-	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		c.User, c.Password, c.Host, c.Port, c.DBName)
-}
-
-// 获取Redis地址
-func (c *RedisConfig) GetAddr() string {
-	return fmt.Sprintf("%s:%s", c.Host, c.Port)
-}
-
-// 获取LLM服务地址
-func (c *LLMConfig) GetAddr() string {
-	return fmt.Sprintf("%s:%s", c.Host, c.Port)
-}
-
-// 初始化日志目录
-func (c *LogConfig) InitLogDir() error {
-	if c.Path == "" {
-		c.Path = "./logs"
-	}
-
-	// 创建日志目录
-	if _, err := os.Stat(c.Path); os.IsNotExist(err) {
-		if err := os.MkdirAll(c.Path, 0755); err != nil {
-			return fmt.Errorf("创建日志目录失败: %w", err)
-		}
-	}
-
-	return nil
-}
-
-// 获取日志文件路径
-func (c *LogConfig) GetLogFilePath() string {
-	return filepath.Join(c.Path, "app.log")
-}
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Config 应用配置结构
+type Config struct {
+	Server        ServerConfig        `mapstructure:"server"`
+	Database      DatabaseConfig      `mapstructure:"database"`
+	Redis         RedisConfig         `mapstructure:"redis"`
+	Auth          AuthConfig          `mapstructure:"auth"`
+	LLM           LLMConfig           `mapstructure:"llm"`
+	Log           LogConfig           `mapstructure:"log"`
+	Metrics       MetricsConfig       `mapstructure:"metrics"`
+	RateLimit     RateLimitConfig     `mapstructure:"rate_limit"`
+	Quota         QuotaConfig         `mapstructure:"quota"`
+	ResponseCache ResponseCacheConfig `mapstructure:"response_cache"`
+	SemanticCache SemanticCacheConfig `mapstructure:"semantic_cache"`
+	Knowledge     KnowledgeConfig     `mapstructure:"knowledge"`
+	Attachments   AttachmentConfig    `mapstructure:"attachments"`
+	Tools         ToolsConfig         `mapstructure:"tools"`
+	Moderation    ModerationConfig    `mapstructure:"moderation"`
+	Redaction     RedactionConfig     `mapstructure:"redaction"`
+	Safety        SafetyConfig        `mapstructure:"safety"`
+	Mail          MailConfig          `mapstructure:"mail"`
+	SSO           SSOConfig           `mapstructure:"sso"`
+	Realtime      RealtimeConfig      `mapstructure:"realtime"`
+}
+
+// ServerConfig 服务器配置
+type ServerConfig struct {
+	Host              string `mapstructure:"host"`
+	Port              string `mapstructure:"port"`
+	JWTSecret         string `mapstructure:"jwt_secret"`
+	AdminUserIDs      []uint `mapstructure:"admin_user_ids"`      // 启动时授予管理员角色的用户ID
+	TrustProxyHeaders bool   `mapstructure:"trust_proxy_headers"` // 部署在反向代理之后时开启，从X-Real-IP或X-Forwarded-For获取客户端IP
+}
+
+// AuthConfig 登录令牌配置
+type AuthConfig struct {
+	AccessTokenTTL  time.Duration         `mapstructure:"access_token_ttl"`  // 访问令牌有效期
+	RefreshTokenTTL time.Duration         `mapstructure:"refresh_token_ttl"` // 刷新令牌有效期，每次刷新都会轮换
+	PasswordPolicy  PasswordPolicyConfig  `mapstructure:"password_policy"`
+	PasswordReset   PasswordResetConfig   `mapstructure:"password_reset"`
+	LoginProtection LoginProtectionConfig `mapstructure:"login_protection"`
+}
+
+// LoginProtectionConfig 登录防暴力破解配置
+type LoginProtectionConfig struct {
+	Enabled         bool          `mapstructure:"enabled"`
+	MaxUserFailures int64         `mapstructure:"max_user_failures"` // 同一用户名失败达到该次数后锁定
+	MaxIPFailures   int64         `mapstructure:"max_ip_failures"`   // 同一IP失败达到该次数后锁定
+	FailureWindow   time.Duration `mapstructure:"failure_window"`    // 失败次数的统计窗口
+	BaseDelay       time.Duration `mapstructure:"base_delay"`        // 失败后的等待时间，每次失败翻倍
+	MaxDelay        time.Duration `mapstructure:"max_delay"`
+	LockoutDuration time.Duration `mapstructure:"lockout_duration"`
+}
+
+// PasswordPolicyConfig 密码强度策略，注册、修改和重置密码时检查
+type PasswordPolicyConfig struct {
+	MinLength        int  `mapstructure:"min_length"`
+	RequireLetter    bool `mapstructure:"require_letter"`
+	RequireDigit     bool `mapstructure:"require_digit"`
+	RequireSymbol    bool `mapstructure:"require_symbol"`
+	DisallowUsername bool `mapstructure:"disallow_username"` // 密码中不能包含用户名
+}
+
+// PasswordResetConfig 重置密码配置
+type PasswordResetConfig struct {
+	TokenTTL time.Duration `mapstructure:"token_ttl"` // 重置令牌有效期
+	URL      string        `mapstructure:"url"`       // 前端重置密码页面地址，令牌作为token参数附加
+}
+
+// RealtimeConfig 实时推送配置
+type RealtimeConfig struct {
+	Backplane    bool          `mapstructure:"backplane"` // 通过Redis发布订阅把事件转发给其他后端实例上的连接
+	Channel      string        `mapstructure:"channel"`
+	ReplayBuffer int           `mapstructure:"replay_buffer"` // 每个用户保留的最近事件数，用于断线重连后补发，为0时不保留
+	ReplayTTL    time.Duration `mapstructure:"replay_ttl"`    // 用户没有新事件时缓冲区的保留时间，仅开启backplane时使用
+
+	TicketTTL             time.Duration `mapstructure:"ticket_ttl"`               // WebSocket连接凭证的有效期
+	AllowedOrigins        []string      `mapstructure:"allowed_origins"`          // 允许建立WebSocket连接的来源，为空时只允许同域
+	MaxConnectionsPerUser int           `mapstructure:"max_connections_per_user"` // 每个用户在单个实例上的最大连接数，为0时不限制
+}
+
+// SSOConfig 单点登录配置
+type SSOConfig struct {
+	Enabled     bool                `mapstructure:"enabled"`
+	StateTTL    time.Duration       `mapstructure:"state_ttl"`    // 发起登录到回调的最长时间
+	FrontendURL string              `mapstructure:"frontend_url"` // 前端接收令牌的页面，令牌放在URL片段中
+	Providers   []SSOProviderConfig `mapstructure:"providers"`
+}
+
+// SSOProviderConfig OIDC身份提供方配置
+type SSOProviderConfig struct {
+	Name         string   `mapstructure:"name"` // 用于回调地址 /api/auth/sso/{name}/callback
+	DisplayName  string   `mapstructure:"display_name"`
+	Issuer       string   `mapstructure:"issuer"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	RedirectURL  string   `mapstructure:"redirect_url"`
+	Scopes       []string `mapstructure:"scopes"`
+	AutoCreate   bool     `mapstructure:"auto_create"`   // 首次登录时自动创建本地用户
+	LinkByEmail  bool     `mapstructure:"link_by_email"` // 首次登录时关联邮箱相同的本地用户，双方的邮箱都必须已验证
+}
+
+// MailConfig 邮件发送配置
+type MailConfig struct {
+	Sender string     `mapstructure:"sender"` // log 只写日志，file 保存为.eml文件，smtp 通过SMTP服务器发送
+	Dir    string     `mapstructure:"dir"`    // file模式下邮件保存目录
+	SMTP   SMTPConfig `mapstructure:"smtp"`
+}
+
+// SMTPConfig SMTP服务器配置
+type SMTPConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     string `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	From     string `mapstructure:"from"`
+}
+
+// DatabaseConfig 数据库配置
+type DatabaseConfig struct {
+	Driver   string `mapstructure:"driver"`
+	Host     string `mapstructure:"host"`
+	Port     string `mapstructure:"port"`
+	User     string `mapstructure:"user"`
+	Password string `mapstructure:"password"`
+	DBName   string `mapstructure:"dbname"`
+}
+
+// RedisConfig Redis配置
+type RedisConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     string `mapstructure:"port"`
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
+}
+
+// LLMConfig LLM服务配置
+type LLMConfig struct {
+	Host  string `mapstructure:"host"`
+	Port  string `mapstructure:"port"`
+	Model string `mapstructure:"model"` // 模型服务未返回模型名称时使用
+}
+
+// LogConfig 日志配置
+type LogConfig struct {
+	Level string `mapstructure:"level"`
+	Path  string `mapstructure:"path"`
+}
+
+// MetricsConfig Prometheus指标配置
+type MetricsConfig struct {
+	Enabled bool   `mapstructure:"enabled"` // 在 /metrics 输出指标
+	Token   string `mapstructure:"token"`   // 不为空时抓取请求需携带 Authorization: Bearer <token>
+}
+
+// RateLimitConfig 请求限流配置
+type RateLimitConfig struct {
+	Enabled           bool  `mapstructure:"enabled"`
+	RequestsPerMinute int64 `mapstructure:"requests_per_minute"`
+}
+
+// QuotaConfig token配额配置
+type QuotaConfig struct {
+	Enabled     bool                  `mapstructure:"enabled"`
+	DefaultPlan string                `mapstructure:"default_plan"`
+	Plans       map[string]PlanConfig `mapstructure:"plans"`
+}
+
+// PlanConfig 套餐配额，0表示不限制
+type PlanConfig struct {
+	DailyTokens   int64 `mapstructure:"daily_tokens"`
+	MonthlyTokens int64 `mapstructure:"monthly_tokens"`
+}
+
+// ResponseCacheConfig 相同提示词的响应缓存配置
+type ResponseCacheConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	TTL            time.Duration `mapstructure:"ttl"`
+	MaxTemperature float32       `mapstructure:"max_temperature"` // 温度高于该值的请求不缓存
+}
+
+// SemanticCacheConfig 语义缓存配置
+type SemanticCacheConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	Threshold  float32       `mapstructure:"threshold"` // 余弦相似度阈值
+	TTL        time.Duration `mapstructure:"ttl"`
+	Store      string        `mapstructure:"store"`       // memory 或 redis
+	MaxEntries int           `mapstructure:"max_entries"` // 条目上限，写入时超出的最早条目被删除
+}
+
+// KnowledgeConfig 知识库检索增强配置
+type KnowledgeConfig struct {
+	Enabled       bool    `mapstructure:"enabled"`
+	Store         string  `mapstructure:"store"`         // memory 或 redis
+	ChunkSize     int     `mapstructure:"chunk_size"`    // 每个片段的最大字符数
+	ChunkOverlap  int     `mapstructure:"chunk_overlap"` // 相邻片段重叠的字符数
+	TopK          int     `mapstructure:"top_k"`
+	MinScore      float32 `mapstructure:"min_score"` // 低于该相似度的片段不注入提示词
+	MaxUploadSize int64   `mapstructure:"max_upload_size"`
+}
+
+// AttachmentConfig 聊天附件配置
+type AttachmentConfig struct {
+	Enabled        bool     `mapstructure:"enabled"`
+	MaxFileSize    int64    `mapstructure:"max_file_size"`
+	MaxPromptChars int      `mapstructure:"max_prompt_chars"` // 提示词中附件内容的总字符数
+	Store          string   `mapstructure:"store"`            // local 或 s3
+	LocalPath      string   `mapstructure:"local_path"`
+	S3             S3Config `mapstructure:"s3"`
+}
+
+// S3Config S3兼容对象存储配置
+type S3Config struct {
+	Endpoint     string `mapstructure:"endpoint"`
+	Region       string `mapstructure:"region"`
+	Bucket       string `mapstructure:"bucket"`
+	AccessKey    string `mapstructure:"access_key"`
+	SecretKey    string `mapstructure:"secret_key"`
+	UsePathStyle bool   `mapstructure:"use_path_style"` // MinIO等通常需要开启
+}
+
+// ToolsConfig 工具调用配置
+type ToolsConfig struct {
+	Enabled       bool                `mapstructure:"enabled"`
+	MaxIterations int                 `mapstructure:"max_iterations"` // 单次对话中模型最多请求工具的轮数
+	Timeout       time.Duration       `mapstructure:"timeout"`        // 单次工具执行的超时时间
+	TimeZone      string              `mapstructure:"time_zone"`      // 日期时间工具使用的时区
+	Personas      map[string][]string `mapstructure:"personas"`       // 各角色默认启用的工具
+}
+
+// ModerationConfig 内容审核配置
+type ModerationConfig struct {
+	Enabled        bool              `mapstructure:"enabled"`
+	FailOpen       bool              `mapstructure:"fail_open"`       // 检查器出错时放行，否则拒绝请求
+	RefusalMessage string            `mapstructure:"refusal_message"` // 模型回复被拦截时返回给用户的内容
+	Blocklists     []BlocklistConfig `mapstructure:"blocklists"`
+	PII            map[string]string `mapstructure:"pii"` // 个人信息类型(id_card、phone、email、bank_card)到审核动作
+	Classifier     ClassifierConfig  `mapstructure:"classifier"`
+}
+
+// BlocklistConfig 黑名单文件配置
+type BlocklistConfig struct {
+	Type     string `mapstructure:"type"` // keyword 或 regex
+	File     string `mapstructure:"file"` // 每行一个关键词或正则
+	Category string `mapstructure:"category"`
+	Action   string `mapstructure:"action"` // block、redact 或 flag
+}
+
+// ClassifierConfig 内容安全分类模型配置，模型由LLM服务加载
+type ClassifierConfig struct {
+	Enabled   bool     `mapstructure:"enabled"`
+	Threshold float32  `mapstructure:"threshold"` // 类别得分不低于该值时视为命中
+	Action    string   `mapstructure:"action"`    // block 或 flag
+	Labels    []string `mapstructure:"labels"`    // 需要处理的类别，为空时处理所有类别
+}
+
+// RedactionConfig 个人信息屏蔽策略配置
+type RedactionConfig struct {
+	Types          []string `mapstructure:"types"`           // 检测的个人信息类型: id_card、phone、email、bank_card、name
+	NameDictionary string   `mapstructure:"name_dictionary"` // 姓名词典文件，每行一个姓名
+	Mask           string   `mapstructure:"mask"`            // placeholder 替换为类型占位符，partial 保留首尾部分字符
+	Logs           bool     `mapstructure:"logs"`            // 写日志前屏蔽
+	Storage        bool     `mapstructure:"storage"`         // 保存消息及缓存前屏蔽
+	Exports        bool     `mapstructure:"exports"`         // 导出会话时屏蔽
+}
+
+// SafetyConfig 医疗安全规则配置
+type SafetyConfig struct {
+	Enabled bool               `mapstructure:"enabled"`
+	Rules   []SafetyRuleConfig `mapstructure:"rules"` // 按顺序评估，同类规则的提示按顺序插入
+}
+
+// SafetyRuleConfig 单条医疗安全规则
+type SafetyRuleConfig struct {
+	Name     string   `mapstructure:"name"`
+	Kind     string   `mapstructure:"kind"`     // escalation 在回复前插入提示，disclaimer 在回复后追加声明
+	Keywords []string `mapstructure:"keywords"` // disclaimer规则为空时对所有回复生效
+	Message  string   `mapstructure:"message"`
+}
+
+var cfg *Config
+
+// LoadConfig 加载配置
+func LoadConfig(configPath string) (*Config, error) {
+	// 创建新的viper实例
+	v := viper.New()
+
+	// 设置配置文件名和路径
+	v.SetConfigName("config")
+
+	if configPath != "" {
+		// 使用指定的配置路径
+		v.AddConfigPath(configPath)
+	} else {
+		// 默认配置路径
+		v.AddConfigPath("./config")
+		v.AddConfigPath(".")
+	}
+
+	// 设置配置文件类型
+	v.SetConfigType("yaml")
+
+	// 从环境变量读取
+	v.AutomaticEnv()
+	v.SetEnvPrefix("APP")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+
+	// 读取配置文件
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	// 解析到配置结构
+	config := &Config{}
+	if err := v.Unmarshal(config); err != nil {
+		return nil, fmt.Errorf("解析配置失败: %w", err)
+	}
+
+	// 设置全局配置
+	cfg = config
+
+	return config, nil
+}
+
+// GetConfig 获取全局配置
+func GetConfig() *Config {
+	if cfg == nil {
+		var err error
+		cfg, err = LoadConfig("")
+		if err != nil {
+			log.Fatalf("无法加载配置: %v", err)
+		}
+	}
+	return cfg
+}
+
+// 获取数据库DSN
+func (c *DatabaseConfig) GetDSN() string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		c.User, c.Password, c.Host, c.Port, c.DBName)
+}
+
+// 获取Redis地址
+func (c *RedisConfig) GetAddr() string {
+	return fmt.Sprintf("%s:%s", c.Host, c.Port)
+}
+
+// 获取LLM服务地址
+func (c *LLMConfig) GetAddr() string {
+	return fmt.Sprintf("%s:%s", c.Host, c.Port)
+}
+
+// 获取用户套餐配额，未配置的套餐使用默认套餐
+func (c *QuotaConfig) GetPlan(name string) (string, PlanConfig) {
+	if name != "" {
+		if plan, ok := c.Plans[name]; ok {
+			return name, plan
+		}
+	}
+	return c.DefaultPlan, c.Plans[c.DefaultPlan]
+}
+
+// 初始化日志目录
+func (c *LogConfig) InitLogDir() error {
+	if c.Path == "" {
+		c.Path = "./logs"
+	}
+
+	// 创建日志目录
+	if _, err := os.Stat(c.Path); os.IsNotExist(err) {
+		if err := os.MkdirAll(c.Path, 0755); err != nil {
+			return fmt.Errorf("创建日志目录失败: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// 获取日志文件路径
+func (c *LogConfig) GetLogFilePath() string {
+	return filepath.Join(c.Path, "app.log")
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"chat-llama/internal/service"
+	"chat-llama/internal/storage"
+)
+
+// AdminHandler 处理管理相关请求
+type AdminHandler struct {
+	chatService *service.ChatService
+	userStorage *storage.UserStorage
+	authService *service.AuthService
+	audit       service.AuditRecorder
+}
+
+// NewAdminHandler 创建管理处理程序
+func NewAdminHandler(chatService *service.ChatService, userStorage *storage.UserStorage, authService *service.AuthService, audit service.AuditRecorder) *AdminHandler {
+	return &AdminHandler{
+		chatService: chatService,
+		userStorage: userStorage,
+		authService: authService,
+		audit:       audit,
+	}
+}
+
+// parsePagination 解析分页参数，默认每页20条，最多100条
+func parsePagination(r *http.Request) (offset, limit int) {
+	limit = 20
+	if value, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && value > 0 && value <= 100 {
+		limit = value
+	}
+	if value, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && value > 0 {
+		offset = value
+	}
+	return offset, limit
+}
+
+// ListSemanticCache 分页查看语义缓存条目
+func (h *AdminHandler) ListSemanticCache(w http.ResponseWriter, r *http.Request) {
+	semanticCache := h.chatService.SemanticCache()
+	if semanticCache == nil {
+		ErrorResponse(w, http.StatusNotFound, "语义缓存未启用")
+		return
+	}
+
+	offset, limit := parsePagination(r)
+	entries, total, err := semanticCache.List(r.Context(), offset, limit)
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "获取语义缓存失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, map[string]interface{}{
+		"entries": entries,
+		"total":   total,
+		"stats":   semanticCache.Stats(),
+	})
+}
+
+// EvictSemanticCacheEntry 删除单个语义缓存条目
+func (h *AdminHandler) EvictSemanticCacheEntry(w http.ResponseWriter, r *http.Request) {
+	semanticCache := h.chatService.SemanticCache()
+	if semanticCache == nil {
+		ErrorResponse(w, http.StatusNotFound, "语义缓存未启用")
+		return
+	}
+
+	// 从上下文获取条目ID
+	entryID, ok := r.Context().Value("id").(string)
+	if !ok || entryID == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的条目ID")
+		return
+	}
+
+	if err := semanticCache.Evict(r.Context(), entryID); err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "删除缓存条目失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, nil)
+}
+
+// ClearSemanticCache 清空语义缓存
+func (h *AdminHandler) ClearSemanticCache(w http.ResponseWriter, r *http.Request) {
+	semanticCache := h.chatService.SemanticCache()
+	if semanticCache == nil {
+		ErrorResponse(w, http.StatusNotFound, "语义缓存未启用")
+		return
+	}
+
+	if err := semanticCache.Clear(r.Context()); err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "清空语义缓存失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, nil)
+}
+
+// GetResponseCacheStats 获取响应缓存的命中统计
+func (h *AdminHandler) GetResponseCacheStats(w http.ResponseWriter, r *http.Request) {
+	stats := h.chatService.ResponseCacheStats()
+	if stats == nil {
+		ErrorResponse(w, http.StatusNotFound, "响应缓存未启用")
+		return
+	}
+
+	SuccessResponse(w, stats)
+}
+
+// ListUsers 分页查询用户，q匹配用户名或邮箱，role按角色过滤
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	offset, limit := parsePagination(r)
+	query := r.URL.Query()
+
+	users, total, err := h.userStorage.ListUsers(query.Get("q"), query.Get("role"), offset, limit)
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "获取用户列表失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, map[string]interface{}{
+		"users": users,
+		"total": total,
+	})
+}
+
+// UpdateUserRole 修改用户角色，用户已签发的令牌随即失效
+func (h *AdminHandler) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	adminID := r.Context().Value("userID").(uint)
+	user, ok := h.targetUser(w, r, adminID)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := ParseJSON(r, &req); err != nil || !service.ValidRole(req.Role) {
+		ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidRole.Error())
+		return
+	}
+
+	if err := h.userStorage.UpdateUserRole(user.ID, req.Role); err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "修改角色失败: "+err.Error())
+		return
+	}
+	if err := h.authService.LogoutAll(r.Context(), user.ID); err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "撤销登录会话失败: "+err.Error())
+		return
+	}
+
+	h.record(r, service.AuditAdminUserRole, user.ID, map[string]interface{}{
+		"from": user.Role,
+		"to":   req.Role,
+	})
+
+	SuccessResponse(w, nil)
+}
+
+// UpdateUserStatus 停用或启用账号，停用后用户所有设备上的登录失效
+func (h *AdminHandler) UpdateUserStatus(w http.ResponseWriter, r *http.Request) {
+	adminID := r.Context().Value("userID").(uint)
+	user, ok := h.targetUser(w, r, adminID)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Disabled bool `json:"disabled"`
+	}
+	if err := ParseJSON(r, &req); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
+		return
+	}
+
+	if err := h.userStorage.SetUserDisabled(user.ID, req.Disabled); err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "修改账号状态失败: "+err.Error())
+		return
+	}
+
+	action := service.AuditAdminUserEnable
+	if req.Disabled {
+		action = service.AuditAdminUserDisable
+		if err := h.authService.LogoutAll(r.Context(), user.ID); err != nil {
+			ErrorResponse(w, http.StatusInternalServerError, "撤销登录会话失败: "+err.Error())
+			return
+		}
+	}
+	h.record(r, action, user.ID, nil)
+
+	SuccessResponse(w, nil)
+}
+
+// GetConversation 查看任意用户的会话及消息，用于滥用审查
+func (h *AdminHandler) GetConversation(w http.ResponseWriter, r *http.Request) {
+	conversationID, ok := r.Context().Value("id").(string)
+	if !ok || conversationID == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的会话ID")
+		return
+	}
+
+	conversation, err := h.chatService.ReviewConversation(conversationID)
+	if err != nil {
+		ErrorResponse(w, http.StatusNotFound, "获取会话失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, conversation)
+}
+
+// targetUser 获取路由参数指定的用户，管理员不能修改自己的角色和状态
+func (h *AdminHandler) targetUser(w http.ResponseWriter, r *http.Request, adminID uint) (*storage.User, bool) {
+	id, _ := r.Context().Value("id").(string)
+	userID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "无效的用户ID")
+		return nil, false
+	}
+	if uint(userID) == adminID {
+		ErrorResponse(w, http.StatusBadRequest, "不能修改自己的账号")
+		return nil, false
+	}
+
+	user, err := h.userStorage.GetUserByID(uint(userID))
+	if err != nil {
+		ErrorResponse(w, http.StatusNotFound, "用户不存在")
+		return nil, false
+	}
+
+	return user, true
+}
+
+// record 记录针对用户的管理操作
+func (h *AdminHandler) record(r *http.Request, action string, targetUserID uint, detail map[string]interface{}) {
+	if h.audit == nil {
+		return
+	}
+
+	adminID, _ := r.Context().Value("userID").(uint)
+	h.audit.Record(r.Context(), &service.AuditEvent{
+		Action:     action,
+		ActorID:    adminID,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(targetUserID), 10),
+		IP:         ClientIP(r),
+		UserAgent:  r.UserAgent(),
+		Detail:     detail,
+		CreatedAt:  time.Now(),
+	})
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"chat-llama/internal/service"
+)
+
+// APIKeyHandler 处理API密钥相关请求
+type APIKeyHandler struct {
+	apiKeyService *service.APIKeyService
+}
+
+// NewAPIKeyHandler 创建API密钥处理程序
+func NewAPIKeyHandler(apiKeyService *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// 创建API密钥请求
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"` // 为空时永不过期
+}
+
+// 创建API密钥响应，明文密钥只返回这一次
+type CreateAPIKeyResponse struct {
+	*service.APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKey 创建API密钥
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	// 从上下文获取用户ID
+	userID := r.Context().Value("userID").(uint)
+
+	var req CreateAPIKeyRequest
+	if err := ParseJSON(r, &req); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
+		return
+	}
+
+	key, secret, err := h.apiKeyService.Create(userID, currentOrgID(r), req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAPIKeySettings) {
+			ErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		ErrorResponse(w, http.StatusInternalServerError, "创建API密钥失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, CreateAPIKeyResponse{
+		APIKey: key,
+		Key:    secret,
+	})
+}
+
+// ListAPIKeys 获取当前用户的API密钥
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	// 从上下文获取用户ID
+	userID := r.Context().Value("userID").(uint)
+
+	keys, err := h.apiKeyService.List(userID)
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "获取API密钥失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, keys)
+}
+
+// RevokeAPIKey 撤销当前用户的API密钥
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	// 从上下文获取用户ID和密钥ID
+	userID := r.Context().Value("userID").(uint)
+	keyID, ok := r.Context().Value("id").(string)
+	if !ok || keyID == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的API密钥ID")
+		return
+	}
+
+	if err := h.apiKeyService.Revoke(userID, keyID); err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			ErrorResponse(w, http.StatusNotFound, err.Error())
+			return
+		}
+		ErrorResponse(w, http.StatusInternalServerError, "撤销API密钥失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, nil)
+}
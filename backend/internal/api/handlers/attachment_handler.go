@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"chat-llama/internal/service"
+)
+
+// AttachmentHandler 处理聊天附件相关请求
+type AttachmentHandler struct {
+	attachmentService *service.AttachmentService
+}
+
+// NewAttachmentHandler 创建附件处理程序，attachmentService为nil时表示附件功能未启用
+func NewAttachmentHandler(attachmentService *service.AttachmentService) *AttachmentHandler {
+	return &AttachmentHandler{
+		attachmentService: attachmentService,
+	}
+}
+
+// UploadAttachment 上传附件，返回的附件ID在聊天请求的attachment_ids中使用
+func (h *AttachmentHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	if h.attachmentService == nil {
+		ErrorResponse(w, http.StatusNotFound, "附件功能未启用")
+		return
+	}
+
+	// 从上下文获取用户ID
+	userID := r.Context().Value("userID").(uint)
+
+	// 为multipart边界等内容预留少量空间
+	maxSize := h.attachmentService.MaxFileSize()
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+64<<10)
+	if err := r.ParseMultipartForm(maxSize); err != nil {
+		ErrorResponse(w, http.StatusRequestEntityTooLarge, "文件过大或格式错误")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "缺少上传文件")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "读取上传文件失败")
+		return
+	}
+
+	attachment, err := h.attachmentService.Upload(r.Context(), userID, header.Filename, header.Header.Get("Content-Type"), data)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAttachmentTooLarge):
+			ErrorResponse(w, http.StatusRequestEntityTooLarge, err.Error())
+		case errors.Is(err, service.ErrUnsupportedAttachment):
+			ErrorResponse(w, http.StatusUnsupportedMediaType, err.Error())
+		default:
+			ErrorResponse(w, http.StatusBadRequest, "上传附件失败: "+err.Error())
+		}
+		return
+	}
+
+	SuccessResponse(w, attachment)
+}
+
+// GetAttachment 获取附件信息
+func (h *AttachmentHandler) GetAttachment(w http.ResponseWriter, r *http.Request) {
+	if h.attachmentService == nil {
+		ErrorResponse(w, http.StatusNotFound, "附件功能未启用")
+		return
+	}
+
+	// 从上下文获取用户ID和附件ID
+	userID := r.Context().Value("userID").(uint)
+	attachmentID, ok := r.Context().Value("id").(string)
+	if !ok || attachmentID == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的附件ID")
+		return
+	}
+
+	attachment, err := h.attachmentService.Get(userID, attachmentID)
+	if err != nil {
+		ErrorResponse(w, http.StatusNotFound, "获取附件失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, attachment)
+}
+
+// DownloadAttachment 下载附件原始文件
+func (h *AttachmentHandler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	if h.attachmentService == nil {
+		ErrorResponse(w, http.StatusNotFound, "附件功能未启用")
+		return
+	}
+
+	// 从上下文获取用户ID和附件ID
+	userID := r.Context().Value("userID").(uint)
+	attachmentID, ok := r.Context().Value("id").(string)
+	if !ok || attachmentID == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的附件ID")
+		return
+	}
+
+	attachment, reader, err := h.attachmentService.Open(r.Context(), userID, attachmentID)
+	if err != nil {
+		ErrorResponse(w, http.StatusNotFound, "获取附件失败: "+err.Error())
+		return
+	}
+	defer reader.Close()
+
+	// 内容类型由提取的格式决定，禁止浏览器嗅探，避免上传的文件被当作HTML执行
+	w.Header().Set("Content-Type", h.attachmentService.DownloadContentType(attachment))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	io.Copy(w, reader)
+}
+
+// DeleteAttachment 删除尚未发送的附件
+func (h *AttachmentHandler) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	if h.attachmentService == nil {
+		ErrorResponse(w, http.StatusNotFound, "附件功能未启用")
+		return
+	}
+
+	// 从上下文获取用户ID和附件ID
+	userID := r.Context().Value("userID").(uint)
+	attachmentID, ok := r.Context().Value("id").(string)
+	if !ok || attachmentID == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的附件ID")
+		return
+	}
+
+	if err := h.attachmentService.Delete(r.Context(), userID, attachmentID); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "删除附件失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, nil)
+}
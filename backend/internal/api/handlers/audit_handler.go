@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"chat-llama/internal/service"
+)
+
+// AuditHandler 处理审计日志的查询和导出
+type AuditHandler struct {
+	auditLog *service.AuditLog
+}
+
+// NewAuditHandler 创建审计日志处理程序
+func NewAuditHandler(auditLog *service.AuditLog) *AuditHandler {
+	return &AuditHandler{
+		auditLog: auditLog,
+	}
+}
+
+// ListEvents 分页查询审计事件，可按action、actor_id、target_type、target_id和时间范围过滤
+func (h *AuditHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	offset, limit := parsePagination(r)
+	events, total, err := h.auditLog.List(filter, offset, limit)
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "获取审计事件失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, map[string]interface{}{
+		"events": events,
+		"total":  total,
+	})
+}
+
+// ExportEvents 以JSONL格式导出符合条件的审计事件，每行一个事件，按时间顺序
+func (h *AuditHandler) ExportEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-events-%s.jsonl"`, time.Now().Format("20060102150405")))
+
+	// 响应头发出后无法再返回错误响应，导出中断时只能记录日志
+	encoder := json.NewEncoder(w)
+	if err := h.auditLog.Export(filter, func(event *service.AuditEvent) error {
+		return encoder.Encode(event)
+	}); err != nil {
+		logger.Errorf("导出审计事件失败: %v", err)
+	}
+}
+
+// parseAuditFilter 解析审计事件的查询条件，时间使用RFC3339格式
+func parseAuditFilter(r *http.Request) (service.AuditFilter, error) {
+	query := r.URL.Query()
+	filter := service.AuditFilter{
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+		TargetID:   query.Get("target_id"),
+	}
+
+	if value := query.Get("actor_id"); value != "" {
+		actorID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return filter, errors.New("无效的actor_id")
+		}
+		filter.ActorID = uint(actorID)
+	}
+	if value := query.Get("from"); value != "" {
+		from, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, errors.New("无效的from，应为RFC3339格式")
+		}
+		filter.From = from
+	}
+	if value := query.Get("to"); value != "" {
+		to, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, errors.New("无效的to，应为RFC3339格式")
+		}
+		filter.To = to
+	}
+
+	return filter, nil
+}
+
+// recordAudit 补全请求信息后记录审计事件，未指定操作者时使用当前登录用户
+func recordAudit(audit service.AuditRecorder, r *http.Request, event *service.AuditEvent) {
+	if audit == nil {
+		return
+	}
+
+	if event.ActorID == 0 {
+		event.ActorID, _ = r.Context().Value("userID").(uint)
+	}
+	event.IP = ClientIP(r)
+	event.UserAgent = r.UserAgent()
+	event.CreatedAt = time.Now()
+	audit.Record(r.Context(), event)
+}
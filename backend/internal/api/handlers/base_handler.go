@@ -1,44 +1,67 @@
-package handlers
-
-import (
-	"encoding/json"
-	"net/http"
-)
-
-// 响应结构
-type Response struct {
-	Code    int         `json:"code"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
-}
-
-// 成功响应
-func SuccessResponse(w http.ResponseWriter, data interface{}) {
-	resp := Response{
-		Code:    200,
-		Message: "成功",
-		Data:    data,
-	}
-	
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(resp)
-}
-
-// 错误响应
-func ErrorResponse(w http.ResponseWriter, statusCode int, message string) {
-	resp := Response{
-		Code:    statusCode,
-		Message: message,
-	}
-	
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(resp)
-}
-
-// JSON请求解析
-func ParseJSON(r *http.Request, dest interface{}) error {
-	decoder := json.NewDecoder(r.Body)
-	return decoder.Decode(dest)
-} 
\ No newline at end of file
+package handlers
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// 响应结构
+type Response struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// 成功响应
+func SuccessResponse(w http.ResponseWriter, data interface{}) {
+	resp := Response{
+		Code:    200,
+		Message: "成功",
+		Data:    data,
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// 错误响应
+func ErrorResponse(w http.ResponseWriter, statusCode int, message string) {
+	resp := Response{
+		Code:    statusCode,
+		Message: message,
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// JSON请求解析
+func ParseJSON(r *http.Request, dest interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	return decoder.Decode(dest)
+} 
+
+// TrustProxyHeaders 是否信任反向代理设置的X-Real-IP和X-Forwarded-For头，直接对外提供服务时必须为false
+var TrustProxyHeaders bool
+
+// ClientIP 获取客户端IP
+func ClientIP(r *http.Request) string {
+	if TrustProxyHeaders {
+		if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+			return ip
+		}
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
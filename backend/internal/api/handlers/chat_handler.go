@@ -1,148 +1,278 @@
-package handlers
-
-import (
-	"encoding/json"
-	"log"
-	"net/http"
-	"strings"
-
-	"chat-llama/internal/service"
-
-	"github.com/gorilla/mux"
-)
-
-// ChatHandler 处理聊天相关请求
-type ChatHandler struct {
-	chatService *service.ChatService
-}
-
-// NewChatHandler 创建聊天处理程序
-func NewChatHandler(chatService *service.ChatService) *ChatHandler {
-	return &ChatHandler{
-		chatService: chatService,
-	}
-}
-
-// GetConversations 获取用户的所有会话
-func (h *ChatHandler) GetConversations(w http.ResponseWriter, r *http.Request) {
-	// 从上下文获取用户ID
-	userID := r.Context().Value("userID").(uint)
-
-	// 获取会话列表
-	conversations, err := h.chatService.GetConversations(userID)
-	if err != nil {
-		ErrorResponse(w, http.StatusInternalServerError, "获取会话失败: "+err.Error())
-		return
-	}
-
-	SuccessResponse(w, conversations)
-}
-
-// GetConversationHistory 获取会话历史消息
-func (h *ChatHandler) GetConversationHistory(w http.ResponseWriter, r *http.Request) {
-	// 手动解析URL路径
-	pathParts := strings.Split(r.URL.Path, "/")
-	var conversationID string
-	if len(pathParts) >= 3 {
-		conversationID = pathParts[len(pathParts)-2] // 倒数第二个元素应该是ID
-	}
-
-	log.Printf("解析的会话ID: %s", conversationID)
-
-	// 从上下文获取用户ID
-	userID := r.Context().Value("userID").(uint)
-
-	// 获取会话历史
-	messages, err := h.chatService.GetConversationHistory(userID, conversationID)
-	if err != nil {
-		ErrorResponse(w, http.StatusInternalServerError, "获取会话历史失败: "+err.Error())
-		return
-	}
-
-	SuccessResponse(w, messages)
-}
-
-// DeleteConversation 删除会话
-func (h *ChatHandler) DeleteConversation(w http.ResponseWriter, r *http.Request) {
-	// 从上下文获取用户ID
-	userID := r.Context().Value("userID").(uint)
-
-	// 从上下文获取会话ID (而不是从mux.Vars)
-	conversationID, ok := r.Context().Value("id").(string)
-	if !ok || conversationID == "" {
-		ErrorResponse(w, http.StatusBadRequest, "无效的会话ID")
-		return
-	}
-
-	// 删除会话
-	err := h.chatService.DeleteConversation(userID, conversationID)
-	if err != nil {
-		ErrorResponse(w, http.StatusInternalServerError, "删除会话失败: "+err.Error())
-		return
-	}
-
-	SuccessResponse(w, nil)
-}
-
-// UpdateConversationTitle 更新会话标题
-func (h *ChatHandler) UpdateConversationTitle(w http.ResponseWriter, r *http.Request) {
-	// 从上下文获取用户ID
-	userID := r.Context().Value("userID").(uint)
-
-	// 从URL获取会话ID
-	vars := mux.Vars(r)
-	conversationID := vars["id"]
-
-	// 解析请求体
-	var req struct {
-		Title string `json:"title"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
-		return
-	}
-
-	// 获取会话确认所有权
-	conversation, err := h.chatService.GetConversation(conversationID)
-	if err != nil {
-		ErrorResponse(w, http.StatusInternalServerError, "获取会话失败: "+err.Error())
-		return
-	}
-
-	if conversation.UserID != userID {
-		ErrorResponse(w, http.StatusForbidden, "无权修改此会话")
-		return
-	}
-
-	// 更新标题
-	if err := h.chatService.UpdateConversationTitle(conversationID, req.Title); err != nil {
-		ErrorResponse(w, http.StatusInternalServerError, "更新会话标题失败: "+err.Error())
-		return
-	}
-
-	SuccessResponse(w, nil)
-}
-
-// Chat 处理聊天请求
-func (h *ChatHandler) Chat(w http.ResponseWriter, r *http.Request) {
-	// 从上下文获取用户ID
-	userID := r.Context().Value("userID").(uint)
-
-	// 解析请求
-	var chatReq service.ChatRequest
-	if err := json.NewDecoder(r.Body).Decode(&chatReq); err != nil {
-		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
-		return
-	}
-
-	// 发送聊天请求
-	ctx := r.Context()
-	response, err := h.chatService.Chat(ctx, userID, &chatReq)
-	if err != nil {
-		ErrorResponse(w, http.StatusInternalServerError, "处理聊天请求失败: "+err.Error())
-		return
-	}
-
-	SuccessResponse(w, response)
-}
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"chat-llama/internal/service"
+	"chat-llama/internal/storage"
+	"chat-llama/pkg/pii"
+)
+
+// ChatHandler 处理聊天相关请求
+type ChatHandler struct {
+	chatService *service.ChatService
+	userStorage *storage.UserStorage
+	audit       service.AuditRecorder
+}
+
+// NewChatHandler 创建聊天处理程序
+func NewChatHandler(chatService *service.ChatService, userStorage *storage.UserStorage, audit service.AuditRecorder) *ChatHandler {
+	return &ChatHandler{
+		chatService: chatService,
+		userStorage: userStorage,
+		audit:       audit,
+	}
+}
+
+// GetConversations 获取用户的所有会话
+func (h *ChatHandler) GetConversations(w http.ResponseWriter, r *http.Request) {
+	// 从上下文获取用户ID
+	userID := r.Context().Value("userID").(uint)
+
+	// 获取当前组织内的会话列表
+	conversations, err := h.chatService.GetConversations(userID, currentOrgID(r))
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "获取会话失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, conversations)
+}
+
+// GetConversationHistory 获取会话历史消息
+func (h *ChatHandler) GetConversationHistory(w http.ResponseWriter, r *http.Request) {
+	// 手动解析URL路径
+	pathParts := strings.Split(r.URL.Path, "/")
+	var conversationID string
+	if len(pathParts) >= 3 {
+		conversationID = pathParts[len(pathParts)-2] // 倒数第二个元素应该是ID
+	}
+
+	log.Printf("解析的会话ID: %s", conversationID)
+
+	// 从上下文获取用户ID
+	userID := r.Context().Value("userID").(uint)
+
+	// 获取会话历史
+	messages, err := h.chatService.GetConversationHistory(userID, currentOrgID(r), conversationID)
+	if err != nil {
+		conversationErrorResponse(w, "获取会话历史失败", err)
+		return
+	}
+
+	SuccessResponse(w, messages)
+}
+
+// ExportConversation 导出会话，format为markdown时返回Markdown文本，否则返回JSON
+func (h *ChatHandler) ExportConversation(w http.ResponseWriter, r *http.Request) {
+	// 从上下文获取用户ID和会话ID
+	userID := r.Context().Value("userID").(uint)
+	conversationID, ok := r.Context().Value("id").(string)
+	if !ok || conversationID == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的会话ID")
+		return
+	}
+
+	export, err := h.chatService.ExportConversation(userID, currentOrgID(r), conversationID)
+	if err != nil {
+		conversationErrorResponse(w, "导出会话失败", err)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	h.record(r, service.AuditConversationExport, conversationID, map[string]interface{}{
+		"format":   format,
+		"messages": len(export.Messages),
+	})
+
+	if format != "markdown" {
+		SuccessResponse(w, export)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="conversation-%s.md"`, conversationID))
+	w.Write([]byte(renderConversationMarkdown(export)))
+}
+
+// exportRoleNames 导出时各角色的显示名称
+var exportRoleNames = map[string]string{
+	"user":      "用户",
+	"assistant": "助手",
+	"tool":      "工具",
+}
+
+// renderConversationMarkdown 将导出的会话渲染为Markdown
+func renderConversationMarkdown(export *service.ConversationExport) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", export.Conversation.Title)
+	fmt.Fprintf(&sb, "导出时间: %s\n\n", export.ExportedAt.Format("2006-01-02 15:04:05"))
+
+	for _, msg := range export.Messages {
+		role := exportRoleNames[msg.Role]
+		if msg.Role == "tool" && msg.ToolName != "" {
+			role += " " + msg.ToolName
+		}
+		fmt.Fprintf(&sb, "## %s (%s)\n\n", role, msg.CreatedAt.Format("2006-01-02 15:04:05"))
+		sb.WriteString(msg.Content)
+		sb.WriteString("\n\n")
+	}
+
+	return sb.String()
+}
+
+// DeleteConversation 删除会话
+func (h *ChatHandler) DeleteConversation(w http.ResponseWriter, r *http.Request) {
+	// 从上下文获取用户ID
+	userID := r.Context().Value("userID").(uint)
+
+	// 从上下文获取会话ID (而不是从mux.Vars)
+	conversationID, ok := r.Context().Value("id").(string)
+	if !ok || conversationID == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的会话ID")
+		return
+	}
+
+	// 删除会话
+	err := h.chatService.DeleteConversation(r.Context(), userID, currentOrgID(r), conversationID)
+	if err != nil {
+		conversationErrorResponse(w, "删除会话失败", err)
+		return
+	}
+	h.record(r, service.AuditConversationDelete, conversationID, nil)
+
+	SuccessResponse(w, nil)
+}
+
+// UpdateConversationTitle 更新会话标题
+func (h *ChatHandler) UpdateConversationTitle(w http.ResponseWriter, r *http.Request) {
+	// 从上下文获取用户ID
+	userID := r.Context().Value("userID").(uint)
+
+	// 从上下文获取会话ID
+	conversationID, ok := r.Context().Value("id").(string)
+	if !ok || conversationID == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的会话ID")
+		return
+	}
+
+	// 解析请求体
+	var req struct {
+		Title string `json:"title"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
+		return
+	}
+
+	// 检查会话权限并更新标题
+	if err := h.chatService.UpdateConversationTitle(r.Context(), userID, currentOrgID(r), conversationID, req.Title); err != nil {
+		conversationErrorResponse(w, "更新会话标题失败", err)
+		return
+	}
+	h.record(r, service.AuditConversationTitle, conversationID, map[string]interface{}{
+		"title": pii.ForLog(req.Title),
+	})
+
+	SuccessResponse(w, nil)
+}
+
+// GetTools 获取可用的工具及角色
+func (h *ChatHandler) GetTools(w http.ResponseWriter, r *http.Request) {
+	catalog, err := h.chatService.ToolCatalog(currentOrgID(r))
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "获取工具失败: "+err.Error())
+		return
+	}
+	if catalog == nil {
+		ErrorResponse(w, http.StatusNotFound, "工具调用未启用")
+		return
+	}
+
+	SuccessResponse(w, catalog)
+}
+
+// UpdateConversationTools 设置会话的角色及启用的工具
+func (h *ChatHandler) UpdateConversationTools(w http.ResponseWriter, r *http.Request) {
+	// 从上下文获取用户ID
+	userID := r.Context().Value("userID").(uint)
+
+	// 从上下文获取会话ID
+	conversationID, ok := r.Context().Value("id").(string)
+	if !ok || conversationID == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的会话ID")
+		return
+	}
+
+	// 解析请求体，tools为null时按角色设定
+	var req struct {
+		Persona string   `json:"persona"`
+		Tools   []string `json:"tools"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
+		return
+	}
+
+	if err := h.chatService.UpdateConversationTools(userID, currentOrgID(r), conversationID, req.Persona, req.Tools); err != nil {
+		if errors.Is(err, service.ErrInvalidToolSettings) {
+			ErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		conversationErrorResponse(w, "更新会话工具失败", err)
+		return
+	}
+
+	SuccessResponse(w, nil)
+}
+
+// Chat 处理聊天请求
+func (h *ChatHandler) Chat(w http.ResponseWriter, r *http.Request) {
+	// 从上下文获取用户ID
+	userID := r.Context().Value("userID").(uint)
+
+	// 解析请求
+	var chatReq service.ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&chatReq); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
+		return
+	}
+
+	// 发送聊天请求
+	ctx := r.Context()
+	response, err := h.chatService.Chat(ctx, userID, currentOrgID(r), &chatReq)
+	if err != nil {
+		if errors.Is(err, service.ErrQuotaExceeded) {
+			ErrorResponse(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrModelNotAllowed) || errors.Is(err, service.ErrConversationForbidden) {
+			ErrorResponse(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrInvalidAttachment) || errors.Is(err, service.ErrInvalidToolSettings) || errors.Is(err, service.ErrContentBlocked) {
+			ErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		ErrorResponse(w, http.StatusInternalServerError, "处理聊天请求失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, response)
+}
+
+// record 记录针对会话的操作
+func (h *ChatHandler) record(r *http.Request, action, conversationID string, detail map[string]interface{}) {
+	recordAudit(h.audit, r, &service.AuditEvent{
+		Action:     action,
+		TargetType: "conversation",
+		TargetID:   conversationID,
+		Detail:     detail,
+	})
+}
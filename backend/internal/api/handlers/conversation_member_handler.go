@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"chat-llama/internal/service"
+)
+
+// ListConversationMembers 获取会话的成员
+func (h *ChatHandler) ListConversationMembers(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(uint)
+	conversationID, ok := r.Context().Value("id").(string)
+	if !ok || conversationID == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的会话ID")
+		return
+	}
+
+	members, err := h.chatService.ConversationMembers(userID, currentOrgID(r), conversationID)
+	if err != nil {
+		conversationErrorResponse(w, "获取会话成员失败", err)
+		return
+	}
+
+	SuccessResponse(w, members)
+}
+
+// ShareConversation 按用户名把会话共享给其他用户
+func (h *ChatHandler) ShareConversation(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(uint)
+	orgID := currentOrgID(r)
+	conversationID, ok := r.Context().Value("id").(string)
+	if !ok || conversationID == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的会话ID")
+		return
+	}
+
+	var req struct {
+		Username string `json:"username"`
+		Role     string `json:"role"`
+	}
+	if err := ParseJSON(r, &req); err != nil || req.Username == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
+		return
+	}
+	if req.Role == "" {
+		req.Role = service.ConversationRoleViewer
+	}
+
+	// 先检查操作者是会话所有者，避免借此探测用户名是否存在
+	if err := h.chatService.CheckConversationAccess(userID, orgID, conversationID, service.ConversationRoleOwner); err != nil {
+		conversationErrorResponse(w, "共享会话失败", err)
+		return
+	}
+
+	member, err := h.userStorage.GetUserByUsername(req.Username)
+	if err != nil {
+		ErrorResponse(w, http.StatusNotFound, "用户不存在")
+		return
+	}
+
+	if err := h.chatService.ShareConversation(userID, orgID, conversationID, member.ID, req.Role); err != nil {
+		conversationErrorResponse(w, "共享会话失败", err)
+		return
+	}
+	h.record(r, service.AuditConversationShare, conversationID, map[string]interface{}{
+		"user_id": member.ID,
+		"role":    req.Role,
+	})
+
+	SuccessResponse(w, nil)
+}
+
+// UpdateConversationMember 修改会话成员的权限
+func (h *ChatHandler) UpdateConversationMember(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(uint)
+	conversationID, ok := r.Context().Value("id").(string)
+	if !ok || conversationID == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的会话ID")
+		return
+	}
+	memberID, ok := pathMemberID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := ParseJSON(r, &req); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
+		return
+	}
+
+	if err := h.chatService.UpdateConversationMember(userID, currentOrgID(r), conversationID, memberID, req.Role); err != nil {
+		conversationErrorResponse(w, "修改会话成员失败", err)
+		return
+	}
+	h.record(r, service.AuditConversationMember, conversationID, map[string]interface{}{
+		"user_id": memberID,
+		"role":    req.Role,
+	})
+
+	SuccessResponse(w, nil)
+}
+
+// RemoveConversationMember 移除会话成员，成员可以移除自己以退出共享
+func (h *ChatHandler) RemoveConversationMember(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(uint)
+	conversationID, ok := r.Context().Value("id").(string)
+	if !ok || conversationID == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的会话ID")
+		return
+	}
+	memberID, ok := pathMemberID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.chatService.UnshareConversation(userID, currentOrgID(r), conversationID, memberID); err != nil {
+		conversationErrorResponse(w, "移除会话成员失败", err)
+		return
+	}
+	h.record(r, service.AuditConversationUnshare, conversationID, map[string]interface{}{
+		"user_id": memberID,
+	})
+
+	SuccessResponse(w, nil)
+}
+
+// conversationErrorResponse 按会话权限错误返回对应的状态码
+func conversationErrorResponse(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, service.ErrConversationForbidden):
+		ErrorResponse(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrInvalidShare), errors.Is(err, service.ErrInvalidToolSettings):
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+	default:
+		ErrorResponse(w, http.StatusInternalServerError, message+": "+err.Error())
+	}
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"chat-llama/internal/service"
+)
+
+// KnowledgeHandler 处理知识库相关请求
+type KnowledgeHandler struct {
+	knowledgeService *service.KnowledgeService
+	maxUploadSize    int64
+}
+
+// NewKnowledgeHandler 创建知识库处理程序，knowledgeService为nil时表示知识库未启用
+func NewKnowledgeHandler(knowledgeService *service.KnowledgeService, maxUploadSize int64) *KnowledgeHandler {
+	if maxUploadSize <= 0 {
+		maxUploadSize = 5 << 20
+	}
+
+	return &KnowledgeHandler{
+		knowledgeService: knowledgeService,
+		maxUploadSize:    maxUploadSize,
+	}
+}
+
+// 上传文档请求
+type UploadDocumentRequest struct {
+	Title   string `json:"title"`
+	Format  string `json:"format"` // text、markdown 或 pdf(从PDF提取的文本)
+	Content string `json:"content"`
+}
+
+// formatFromFilename 根据文件扩展名推断文档格式
+func formatFromFilename(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".md", ".markdown":
+		return service.DocumentFormatMarkdown
+	default:
+		return service.DocumentFormatText
+	}
+}
+
+// UploadDocument 上传文档到当前组织的知识库，支持multipart文件上传或JSON正文
+func (h *KnowledgeHandler) UploadDocument(w http.ResponseWriter, r *http.Request) {
+	if h.knowledgeService == nil {
+		ErrorResponse(w, http.StatusNotFound, "知识库未启用")
+		return
+	}
+
+	// 从上下文获取用户ID
+	userID := r.Context().Value("userID").(uint)
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadSize)
+
+	var req UploadDocumentRequest
+	var source string
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(h.maxUploadSize); err != nil {
+			ErrorResponse(w, http.StatusBadRequest, "文件过大或格式错误")
+			return
+		}
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			ErrorResponse(w, http.StatusBadRequest, "缺少上传文件")
+			return
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		if err != nil {
+			ErrorResponse(w, http.StatusBadRequest, "读取上传文件失败")
+			return
+		}
+
+		source = header.Filename
+		req.Title = r.FormValue("title")
+		req.Format = r.FormValue("format")
+		req.Content = string(data)
+		if req.Format == "" {
+			req.Format = formatFromFilename(source)
+		}
+	} else if err := ParseJSON(r, &req); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
+		return
+	}
+
+	if !utf8.ValidString(req.Content) {
+		ErrorResponse(w, http.StatusBadRequest, "文档必须是UTF-8编码的文本")
+		return
+	}
+
+	doc, err := h.knowledgeService.AddDocument(r.Context(), userID, currentOrgID(r), req.Title, source, req.Format, req.Content)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "添加文档失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, doc)
+}
+
+// ListDocuments 获取当前组织的知识库文档列表
+func (h *KnowledgeHandler) ListDocuments(w http.ResponseWriter, r *http.Request) {
+	if h.knowledgeService == nil {
+		ErrorResponse(w, http.StatusNotFound, "知识库未启用")
+		return
+	}
+
+	docs, err := h.knowledgeService.ListDocuments(currentOrgID(r))
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "获取文档列表失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, docs)
+}
+
+// GetDocument 获取文档详情及其片段
+func (h *KnowledgeHandler) GetDocument(w http.ResponseWriter, r *http.Request) {
+	if h.knowledgeService == nil {
+		ErrorResponse(w, http.StatusNotFound, "知识库未启用")
+		return
+	}
+
+	// 从上下文获取文档ID
+	documentID, ok := r.Context().Value("id").(string)
+	if !ok || documentID == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的文档ID")
+		return
+	}
+
+	doc, chunks, err := h.knowledgeService.GetDocumentChunks(currentOrgID(r), documentID)
+	if err != nil {
+		ErrorResponse(w, http.StatusNotFound, "获取文档失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, map[string]interface{}{
+		"document": doc,
+		"chunks":   chunks,
+	})
+}
+
+// DeleteDocument 删除知识库文档
+func (h *KnowledgeHandler) DeleteDocument(w http.ResponseWriter, r *http.Request) {
+	if h.knowledgeService == nil {
+		ErrorResponse(w, http.StatusNotFound, "知识库未启用")
+		return
+	}
+
+	// 从上下文获取文档ID
+	documentID, ok := r.Context().Value("id").(string)
+	if !ok || documentID == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的文档ID")
+		return
+	}
+
+	if err := h.knowledgeService.DeleteDocument(r.Context(), currentOrgID(r), documentID); err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "删除文档失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, nil)
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"chat-llama/internal/service"
+)
+
+// ModerationHandler 处理内容审核队列相关请求
+type ModerationHandler struct {
+	moderationService *service.ModerationService
+}
+
+// NewModerationHandler 创建内容审核处理程序，moderationService为nil时表示内容审核未启用
+func NewModerationHandler(moderationService *service.ModerationService) *ModerationHandler {
+	return &ModerationHandler{
+		moderationService: moderationService,
+	}
+}
+
+// 人工审核请求
+type ReviewMessageRequest struct {
+	Status string `json:"status"` // approved 或 rejected
+	Note   string `json:"note"`
+}
+
+// ListQueue 分页查看审核队列，可按status过滤
+func (h *ModerationHandler) ListQueue(w http.ResponseWriter, r *http.Request) {
+	if h.moderationService == nil {
+		ErrorResponse(w, http.StatusNotFound, "内容审核未启用")
+		return
+	}
+
+	offset, limit := parsePagination(r)
+	messages, total, err := h.moderationService.ListQueue(r.URL.Query().Get("status"), offset, limit)
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "获取审核队列失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, map[string]interface{}{
+		"messages": messages,
+		"total":    total,
+	})
+}
+
+// ReviewMessage 记录消息的人工审核结果
+func (h *ModerationHandler) ReviewMessage(w http.ResponseWriter, r *http.Request) {
+	if h.moderationService == nil {
+		ErrorResponse(w, http.StatusNotFound, "内容审核未启用")
+		return
+	}
+
+	// 从上下文获取用户ID和消息ID
+	reviewerID := r.Context().Value("userID").(uint)
+	messageID, ok := r.Context().Value("id").(string)
+	if !ok || messageID == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的消息ID")
+		return
+	}
+
+	var req ReviewMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "无效的请求格式")
+		return
+	}
+
+	if err := h.moderationService.Review(messageID, reviewerID, req.Status, req.Note); err != nil {
+		if errors.Is(err, service.ErrInvalidReviewStatus) {
+			ErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		ErrorResponse(w, http.StatusInternalServerError, "记录审核结果失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, map[string]string{
+		"message": "审核结果已记录",
+	})
+}
@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"chat-llama/internal/service"
+	"chat-llama/internal/storage"
+)
+
+// OrgHandler 处理组织相关请求
+type OrgHandler struct {
+	orgService   *service.OrganizationService
+	authService  *service.AuthService
+	quotaService *service.QuotaService
+	userStorage  *storage.UserStorage
+	audit        service.AuditRecorder
+}
+
+// NewOrgHandler 创建组织处理程序
+func NewOrgHandler(orgService *service.OrganizationService, authService *service.AuthService, quotaService *service.QuotaService, userStorage *storage.UserStorage, audit service.AuditRecorder) *OrgHandler {
+	return &OrgHandler{
+		orgService:   orgService,
+		authService:  authService,
+		quotaService: quotaService,
+		userStorage:  userStorage,
+		audit:        audit,
+	}
+}
+
+// currentOrgID 获取请求所在的组织，0表示个人空间
+func currentOrgID(r *http.Request) uint {
+	orgID, _ := r.Context().Value("orgID").(uint)
+	return orgID
+}
+
+// ListOrganizations 获取当前用户加入的组织及当前所在的组织
+func (h *OrgHandler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(uint)
+
+	memberships, err := h.orgService.ListForUser(userID)
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "获取组织失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, map[string]interface{}{
+		"organizations": memberships,
+		"current":       currentOrgID(r),
+	})
+}
+
+// SwitchOrganization 将当前登录会话切换到组织并重新签发令牌，org_id为0时切换回个人空间
+func (h *OrgHandler) SwitchOrganization(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(uint)
+	sessionID, _ := r.Context().Value("sessionID").(string)
+
+	var req struct {
+		OrgID uint `json:"org_id"`
+	}
+	if err := ParseJSON(r, &req); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
+		return
+	}
+
+	tokens, err := h.authService.SwitchOrganization(r.Context(), userID, sessionID, req.OrgID)
+	if err != nil {
+		h.errorResponse(w, "切换组织失败", err)
+		return
+	}
+
+	SuccessResponse(w, tokens)
+}
+
+// GetOrganization 获取组织详情及当前用户的角色
+func (h *OrgHandler) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(uint)
+	orgID, ok := pathOrgID(w, r)
+	if !ok {
+		return
+	}
+
+	org, membership, err := h.orgService.Get(userID, orgID)
+	if err != nil {
+		h.errorResponse(w, "获取组织失败", err)
+		return
+	}
+
+	SuccessResponse(w, map[string]interface{}{
+		"organization": org,
+		"role":         membership.Role,
+	})
+}
+
+// UpdateSettings 修改组织的模型、角色及配额设置
+func (h *OrgHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(uint)
+	orgID, ok := pathOrgID(w, r)
+	if !ok {
+		return
+	}
+
+	var settings service.OrgSettings
+	if err := ParseJSON(r, &settings); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
+		return
+	}
+
+	if err := h.orgService.UpdateSettings(userID, orgID, settings); err != nil {
+		h.errorResponse(w, "修改组织设置失败", err)
+		return
+	}
+
+	h.record(r, service.AuditOrgSettings, orgID, map[string]interface{}{
+		"settings": settings,
+	})
+
+	SuccessResponse(w, nil)
+}
+
+// ListMembers 获取组织成员
+func (h *OrgHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(uint)
+	orgID, ok := pathOrgID(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := h.orgService.Members(userID, orgID)
+	if err != nil {
+		h.errorResponse(w, "获取组织成员失败", err)
+		return
+	}
+
+	SuccessResponse(w, members)
+}
+
+// AddMember 按用户名添加组织成员
+func (h *OrgHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(uint)
+	orgID, ok := pathOrgID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Username string `json:"username"`
+		Role     string `json:"role"`
+	}
+	if err := ParseJSON(r, &req); err != nil || req.Username == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
+		return
+	}
+	if req.Role == "" {
+		req.Role = service.OrgRoleMember
+	}
+
+	// 先检查操作者的组织角色，避免非管理员借此探测用户名是否存在
+	if err := h.orgService.CheckRole(userID, orgID, service.OrgRoleAdmin); err != nil {
+		h.errorResponse(w, "添加成员失败", err)
+		return
+	}
+
+	member, err := h.userStorage.GetUserByUsername(req.Username)
+	if err != nil {
+		ErrorResponse(w, http.StatusNotFound, "用户不存在")
+		return
+	}
+
+	if err := h.orgService.AddMember(userID, orgID, member.ID, req.Role); err != nil {
+		h.errorResponse(w, "添加成员失败", err)
+		return
+	}
+
+	h.record(r, service.AuditOrgMember, orgID, map[string]interface{}{
+		"user_id": member.ID,
+		"role":    req.Role,
+	})
+
+	SuccessResponse(w, nil)
+}
+
+// UpdateMemberRole 修改成员的组织角色
+func (h *OrgHandler) UpdateMemberRole(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(uint)
+	orgID, ok := pathOrgID(w, r)
+	if !ok {
+		return
+	}
+	memberID, ok := pathMemberID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := ParseJSON(r, &req); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
+		return
+	}
+
+	if err := h.orgService.UpdateMemberRole(userID, orgID, memberID, req.Role); err != nil {
+		h.errorResponse(w, "修改成员角色失败", err)
+		return
+	}
+
+	h.record(r, service.AuditOrgMember, orgID, map[string]interface{}{
+		"user_id": memberID,
+		"role":    req.Role,
+	})
+
+	SuccessResponse(w, nil)
+}
+
+// RemoveMember 移除组织成员，成员可以移除自己以退出组织
+func (h *OrgHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(uint)
+	orgID, ok := pathOrgID(w, r)
+	if !ok {
+		return
+	}
+	memberID, ok := pathMemberID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.orgService.RemoveMember(userID, orgID, memberID); err != nil {
+		h.errorResponse(w, "移除成员失败", err)
+		return
+	}
+
+	h.record(r, service.AuditOrgMember, orgID, map[string]interface{}{
+		"user_id": memberID,
+		"removed": true,
+	})
+
+	SuccessResponse(w, nil)
+}
+
+// GetUsage 获取组织当前的总用量，需要组织管理员角色
+func (h *OrgHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(uint)
+	orgID, ok := pathOrgID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.orgService.CheckRole(userID, orgID, service.OrgRoleAdmin); err != nil {
+		h.errorResponse(w, "获取组织用量失败", err)
+		return
+	}
+
+	usage, err := h.quotaService.GetOrgUsage(r.Context(), orgID)
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "获取组织用量失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, usage)
+}
+
+// AdminListOrganizations 获取所有组织
+func (h *OrgHandler) AdminListOrganizations(w http.ResponseWriter, r *http.Request) {
+	orgs, err := h.orgService.List()
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "获取组织失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, orgs)
+}
+
+// AdminCreateOrganization 创建组织并指定所有者
+func (h *OrgHandler) AdminCreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name    string `json:"name"`
+		OwnerID uint   `json:"owner_id"`
+	}
+	if err := ParseJSON(r, &req); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
+		return
+	}
+
+	if _, err := h.userStorage.GetUserByID(req.OwnerID); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "所有者不存在")
+		return
+	}
+
+	org, err := h.orgService.Create(req.Name, req.OwnerID)
+	if err != nil {
+		h.errorResponse(w, "创建组织失败", err)
+		return
+	}
+
+	h.record(r, service.AuditOrgCreate, org.ID, map[string]interface{}{
+		"name":     org.Name,
+		"owner_id": req.OwnerID,
+	})
+
+	SuccessResponse(w, org)
+}
+
+// errorResponse 按组织服务的错误类型返回对应的状态码
+func (h *OrgHandler) errorResponse(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, service.ErrOrgNotFound), errors.Is(err, service.ErrSessionNotFound):
+		ErrorResponse(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrOrgForbidden), errors.Is(err, service.ErrAccountDisabled):
+		ErrorResponse(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrInvalidOrgSettings), errors.Is(err, service.ErrLastOwner):
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+	default:
+		ErrorResponse(w, http.StatusInternalServerError, message+": "+err.Error())
+	}
+}
+
+// record 记录组织管理操作
+func (h *OrgHandler) record(r *http.Request, action string, orgID uint, detail map[string]interface{}) {
+	if h.audit == nil {
+		return
+	}
+
+	userID, _ := r.Context().Value("userID").(uint)
+	h.audit.Record(r.Context(), &service.AuditEvent{
+		Action:     action,
+		ActorID:    userID,
+		TargetType: "organization",
+		TargetID:   strconv.FormatUint(uint64(orgID), 10),
+		IP:         ClientIP(r),
+		UserAgent:  r.UserAgent(),
+		Detail:     detail,
+		CreatedAt:  time.Now(),
+	})
+}
+
+// pathOrgID 解析路由参数中的组织ID
+func pathOrgID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, _ := r.Context().Value("id").(string)
+	orgID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || orgID == 0 {
+		ErrorResponse(w, http.StatusBadRequest, "无效的组织ID")
+		return 0, false
+	}
+	return uint(orgID), true
+}
+
+// pathMemberID 解析路由参数中的成员用户ID
+func pathMemberID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, _ := r.Context().Value("memberID").(string)
+	memberID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "无效的用户ID")
+		return 0, false
+	}
+	return uint(memberID), true
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"chat-llama/internal/service"
+	"chat-llama/pkg/oidc"
+)
+
+// ssoStateCookie 保存发起登录的浏览器的state，回调时必须与URL中的state一致
+const (
+	ssoStateCookie     = "sso_state"
+	ssoStateCookiePath = "/api/auth/sso/"
+)
+
+// SSOHandler 处理单点登录相关请求
+type SSOHandler struct {
+	ssoService  *service.SSOService
+	audit       service.AuditRecorder
+	frontendURL string
+}
+
+// NewSSOHandler 创建单点登录处理程序，ssoService为nil时单点登录未启用。
+// frontendURL为前端接收令牌的页面，令牌放在URL片段中；为空时回调直接返回JSON
+func NewSSOHandler(ssoService *service.SSOService, audit service.AuditRecorder, frontendURL string) *SSOHandler {
+	return &SSOHandler{
+		ssoService:  ssoService,
+		audit:       audit,
+		frontendURL: frontendURL,
+	}
+}
+
+// ListProviders 获取可用的身份提供方
+func (h *SSOHandler) ListProviders(w http.ResponseWriter, r *http.Request) {
+	if h.ssoService == nil {
+		SuccessResponse(w, []service.SSOProviderInfo{})
+		return
+	}
+
+	SuccessResponse(w, h.ssoService.Providers())
+}
+
+// Login 跳转到身份提供方的授权页面
+func (h *SSOHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if h.ssoService == nil {
+		ErrorResponse(w, http.StatusNotFound, "单点登录未启用")
+		return
+	}
+
+	provider, _ := r.Context().Value("provider").(string)
+	authURL, state, err := h.ssoService.Begin(r.Context(), provider)
+	if err != nil {
+		if errors.Is(err, service.ErrSSOProviderNotFound) {
+			ErrorResponse(w, http.StatusNotFound, err.Error())
+			return
+		}
+		ErrorResponse(w, http.StatusBadGateway, "发起单点登录失败: "+err.Error())
+		return
+	}
+
+	// 把state绑定到当前浏览器，SameSite=Lax允许身份提供方跳转回来时携带
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoStateCookie,
+		Value:    state,
+		Path:     ssoStateCookiePath,
+		MaxAge:   int(h.ssoService.StateTTL().Seconds()),
+		HttpOnly: true,
+		Secure:   secureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback 处理身份提供方的回调并签发令牌
+func (h *SSOHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	if h.ssoService == nil {
+		ErrorResponse(w, http.StatusNotFound, "单点登录未启用")
+		return
+	}
+
+	query := r.URL.Query()
+	provider, _ := r.Context().Value("provider").(string)
+
+	// 无论结果如何都清除state Cookie，每次登录只能使用一次
+	browserState := ""
+	if cookie, err := r.Cookie(ssoStateCookie); err == nil {
+		browserState = cookie.Value
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoStateCookie,
+		Path:     ssoStateCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	if idpError := query.Get("error"); idpError != "" {
+		h.recordFailure(r, provider, idpError)
+		h.fail(w, r, http.StatusUnauthorized, "身份提供方拒绝了登录: "+idpError)
+		return
+	}
+
+	tokens, err := h.ssoService.Complete(r.Context(), provider, query.Get("code"), query.Get("state"), browserState, service.ClientInfo{
+		UserAgent: r.UserAgent(),
+		IP:        ClientIP(r),
+	})
+	if err != nil {
+		h.recordFailure(r, provider, err.Error())
+		switch {
+		case errors.Is(err, service.ErrSSOProviderNotFound):
+			h.fail(w, r, http.StatusNotFound, err.Error())
+		case errors.Is(err, service.ErrSSOInvalidState), errors.Is(err, oidc.ErrInvalidIDToken):
+			h.fail(w, r, http.StatusUnauthorized, err.Error())
+		case errors.Is(err, service.ErrSSOAccountNotLinked), errors.Is(err, service.ErrAccountDisabled):
+			h.fail(w, r, http.StatusForbidden, err.Error())
+		default:
+			logger.Errorf("单点登录失败: %v", err)
+			h.fail(w, r, http.StatusBadGateway, "单点登录失败")
+		}
+		return
+	}
+	recordAudit(h.audit, r, &service.AuditEvent{
+		Action:     service.AuditLogin,
+		ActorID:    tokens.UserID,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(tokens.UserID), 10),
+		Detail:     map[string]interface{}{"method": "sso", "provider": provider},
+	})
+
+	if h.frontendURL == "" {
+		SuccessResponse(w, tokens)
+		return
+	}
+
+	fragment := url.Values{
+		"token":         {tokens.AccessToken},
+		"refresh_token": {tokens.RefreshToken},
+		"expires_in":    {strconv.FormatInt(tokens.ExpiresIn, 10)},
+	}
+	http.Redirect(w, r, h.frontendURL+"#"+fragment.Encode(), http.StatusFound)
+}
+
+// fail 返回错误，配置了前端页面时跳转并在URL片段中携带错误信息
+func (h *SSOHandler) fail(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if h.frontendURL == "" {
+		ErrorResponse(w, status, message)
+		return
+	}
+
+	fragment := url.Values{"error": {message}}
+	http.Redirect(w, r, h.frontendURL+"#"+fragment.Encode(), http.StatusFound)
+}
+
+// recordFailure 记录失败的单点登录，此时无法确定用户
+func (h *SSOHandler) recordFailure(r *http.Request, provider, reason string) {
+	recordAudit(h.audit, r, &service.AuditEvent{
+		Action:     service.AuditLoginFailed,
+		TargetType: "sso_provider",
+		TargetID:   provider,
+		Detail:     map[string]interface{}{"method": "sso", "reason": reason},
+	})
+}
+
+// secureRequest 判断请求是否通过HTTPS到达，用于设置Cookie的Secure属性
+func secureRequest(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	return TrustProxyHeaders && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
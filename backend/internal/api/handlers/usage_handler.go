@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"chat-llama/internal/service"
+	"chat-llama/pkg/ratelimit"
+)
+
+// UsageHandler 处理用量查询请求
+type UsageHandler struct {
+	chatService  *service.ChatService
+	quotaService *service.QuotaService
+	limiter      *ratelimit.Limiter
+}
+
+// NewUsageHandler 创建用量处理程序
+func NewUsageHandler(chatService *service.ChatService, quotaService *service.QuotaService, limiter *ratelimit.Limiter) *UsageHandler {
+	return &UsageHandler{
+		chatService:  chatService,
+		quotaService: quotaService,
+		limiter:      limiter,
+	}
+}
+
+// UsageResponse 用量响应
+type UsageResponse struct {
+	*service.Usage
+	RequestsPerMinute int64 `json:"requests_per_minute"` // 0表示不限制
+}
+
+// GetUsage 获取当前用户的token用量和限额
+func (h *UsageHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	// 从上下文获取用户ID
+	userID := r.Context().Value("userID").(uint)
+
+	usage, err := h.quotaService.GetUsage(r.Context(), userID)
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "获取用量失败: "+err.Error())
+		return
+	}
+
+	resp := UsageResponse{Usage: usage}
+	if h.limiter != nil {
+		resp.RequestsPerMinute = h.limiter.Limit()
+	}
+
+	SuccessResponse(w, resp)
+}
+
+// GetUsageHistory 获取当前用户在当前组织内按模型和日期汇总的用量，days参数默认30天，最多366天
+func (h *UsageHandler) GetUsageHistory(w http.ResponseWriter, r *http.Request) {
+	// 从上下文获取用户ID
+	userID := r.Context().Value("userID").(uint)
+
+	days := 30
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > 366 {
+			ErrorResponse(w, http.StatusBadRequest, "无效的days参数")
+			return
+		}
+		days = parsed
+	}
+
+	stats, err := h.chatService.GetUsageStats(userID, currentOrgID(r), days)
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "获取用量统计失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, stats)
+}
@@ -1,153 +1,571 @@
-package handlers
-
-import (
-	"net/http"
-	"strings"
-	"time"
-
-	"chat-llama/internal/storage"
-
-	"github.com/dgrijalva/jwt-go"
-	"github.com/sirupsen/logrus"
-)
-
-var logger = logrus.New()
-
-// UserHandler 处理用户相关请求
-type UserHandler struct {
-	userStorage *storage.UserStorage
-	jwtSecret   []byte
-}
-
-// NewUserHandler 创建用户处理程序
-func NewUserHandler(userStorage *storage.UserStorage, jwtSecret string) *UserHandler {
-	return &UserHandler{
-		userStorage: userStorage,
-		jwtSecret:   []byte(jwtSecret),
-	}
-}
-
-// 登录请求
-type LoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-}
-
-// 登录响应
-type LoginResponse struct {
-	Token string      `json:"token"`
-	User  interface{} `json:"user"`
-}
-
-// 注册请求
-type RegisterRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-}
-
-// JWT Claims
-type Claims struct {
-	UserID uint `json:"user_id"`
-	jwt.StandardClaims
-}
-
-// Register 用户注册
-func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
-	var req RegisterRequest
-	if err := ParseJSON(r, &req); err != nil {
-		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
-		return
-	}
-
-	// 验证输入
-	if req.Username == "" || req.Password == "" {
-		ErrorResponse(w, http.StatusBadRequest, "用户名和密码不能为空")
-		return
-	}
-
-	// 创建用户
-	user, err := h.userStorage.CreateUser(req.Username, req.Password)
-	if err != nil {
-		ErrorResponse(w, http.StatusInternalServerError, "注册失败: "+err.Error())
-		return
-	}
-
-	// 返回成功响应
-	SuccessResponse(w, map[string]interface{}{
-		"id":       user.ID,
-		"username": user.Username,
-	})
-}
-
-// Login 用户登录
-func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
-	var req LoginRequest
-	if err := ParseJSON(r, &req); err != nil {
-		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
-		return
-	}
-
-	// 记录输入的密码(调试用)
-	logger.Printf("登录尝试 - 用户名: %s, 密码: %s", req.Username, maskPassword(req.Password))
-
-	// 验证用户名和密码
-	user, err := h.userStorage.VerifyPassword(req.Username, req.Password)
-	if err != nil {
-		ErrorResponse(w, http.StatusUnauthorized, "用户名或密码错误")
-		return
-	}
-
-	// 生成JWT令牌
-	expirationTime := time.Now().Add(24 * time.Hour)
-	claims := &Claims{
-		UserID: user.ID,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
-			IssuedAt:  time.Now().Unix(),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(h.jwtSecret)
-	if err != nil {
-		ErrorResponse(w, http.StatusInternalServerError, "生成令牌失败")
-		return
-	}
-
-	// 返回令牌和用户信息
-	SuccessResponse(w, LoginResponse{
-		Token: tokenString,
-		User: map[string]interface{}{
-			"id":       user.ID,
-			"username": user.Username,
-		},
-	})
-}
-
-// GetProfile 获取用户信息
-func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
-	// 从上下文获取用户ID
-	userID := r.Context().Value("userID").(uint)
-
-	// 查询用户信息
-	user, err := h.userStorage.GetUserByID(userID)
-	if err != nil {
-		ErrorResponse(w, http.StatusInternalServerError, "获取用户信息失败")
-		return
-	}
-
-	// 返回用户信息
-	SuccessResponse(w, map[string]interface{}{
-		"id":         user.ID,
-		"username":   user.Username,
-		"created_at": user.CreatedAt,
-	})
-}
-
-// 遮盖密码，只显示长度和前两个字符(如果存在)
-func maskPassword(password string) string {
-	if len(password) <= 2 {
-		return strings.Repeat("*", len(password))
-	}
-	return password[:2] + strings.Repeat("*", len(password)-2)
-}
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"chat-llama/internal/service"
+	"chat-llama/internal/storage"
+	mailer "chat-llama/pkg/mail"
+	"chat-llama/pkg/password"
+
+	"github.com/sirupsen/logrus"
+)
+
+var logger = logrus.New()
+
+// PasswordOptions 密码策略及重置密码配置
+type PasswordOptions struct {
+	Policy        password.Policy
+	ResetTokenTTL time.Duration // 重置令牌有效期
+	ResetURL      string        // 前端重置密码页面地址，令牌作为token参数附加，为空时邮件中只包含令牌
+}
+
+// UserHandler 处理用户相关请求
+type UserHandler struct {
+	userStorage *storage.UserStorage
+	authService *service.AuthService
+	mailSender  mailer.Sender
+	loginGuard  *service.LoginGuard
+	audit       service.AuditRecorder
+	options     PasswordOptions
+}
+
+// NewUserHandler 创建用户处理程序，loginGuard为nil时不限制登录失败次数
+func NewUserHandler(userStorage *storage.UserStorage, authService *service.AuthService, mailSender mailer.Sender, loginGuard *service.LoginGuard, audit service.AuditRecorder, options PasswordOptions) *UserHandler {
+	if options.ResetTokenTTL <= 0 {
+		options.ResetTokenTTL = time.Hour
+	}
+
+	return &UserHandler{
+		userStorage: userStorage,
+		authService: authService,
+		mailSender:  mailSender,
+		loginGuard:  loginGuard,
+		audit:       audit,
+		options:     options,
+	}
+}
+
+// 登录请求
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// 登录响应
+type LoginResponse struct {
+	Token        string      `json:"token"`
+	RefreshToken string      `json:"refresh_token"`
+	ExpiresIn    int64       `json:"expires_in"` // 访问令牌有效期，单位秒
+	User         interface{} `json:"user"`
+}
+
+// 刷新令牌请求
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// 退出登录请求
+type LogoutRequest struct {
+	All bool `json:"all"` // 退出所有设备上的登录
+}
+
+// 注册请求
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email"` // 可选，用于找回密码
+}
+
+// 修改密码请求
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// 忘记密码请求，用户名和邮箱二选一
+type ForgotPasswordRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// 重置密码请求
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// Register 用户注册
+func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
+	var req RegisterRequest
+	if err := ParseJSON(r, &req); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
+		return
+	}
+
+	// 验证输入
+	if req.Username == "" || req.Password == "" {
+		ErrorResponse(w, http.StatusBadRequest, "用户名和密码不能为空")
+		return
+	}
+	if req.Email != "" && !validEmail(req.Email) {
+		ErrorResponse(w, http.StatusBadRequest, "无效的邮箱地址")
+		return
+	}
+	if err := h.options.Policy.Validate(req.Password, req.Username); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 创建用户
+	user, err := h.userStorage.CreateUser(req.Username, req.Password, req.Email)
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "注册失败: "+err.Error())
+		return
+	}
+	recordAudit(h.audit, r, &service.AuditEvent{
+		Action:     service.AuditRegister,
+		ActorID:    user.ID,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+	})
+
+	// 返回成功响应
+	SuccessResponse(w, map[string]interface{}{
+		"id":       user.ID,
+		"username": user.Username,
+	})
+}
+
+// Login 用户登录
+func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if err := ParseJSON(r, &req); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
+		return
+	}
+
+	if req.Username == "" || req.Password == "" {
+		ErrorResponse(w, http.StatusBadRequest, "用户名和密码不能为空")
+		return
+	}
+
+	client := service.ClientInfo{
+		UserAgent: r.UserAgent(),
+		IP:        ClientIP(r),
+	}
+
+	// 失败次数过多时暂时拒绝，无论用户是否存在都返回相同的提示
+	if h.loginGuard != nil {
+		if wait, err := h.loginGuard.Check(r.Context(), req.Username, client.IP); err != nil {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			ErrorResponse(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+	}
+
+	// 验证用户名和密码
+	user, err := h.userStorage.VerifyPassword(req.Username, req.Password)
+	if err != nil {
+		if h.loginGuard != nil {
+			h.loginGuard.RecordFailure(r.Context(), req.Username, client)
+		}
+		recordAudit(h.audit, r, &service.AuditEvent{
+			Action:     service.AuditLoginFailed,
+			TargetType: "username",
+			TargetID:   req.Username,
+			Detail:     map[string]interface{}{"method": "password"},
+		})
+		ErrorResponse(w, http.StatusUnauthorized, "用户名或密码错误")
+		return
+	}
+	if h.loginGuard != nil {
+		h.loginGuard.RecordSuccess(r.Context(), req.Username)
+	}
+
+	// 创建会话并签发令牌
+	tokens, err := h.authService.Login(r.Context(), user.ID, client)
+	if err != nil {
+		if errors.Is(err, service.ErrAccountDisabled) {
+			recordAudit(h.audit, r, &service.AuditEvent{
+				Action:     service.AuditLoginFailed,
+				ActorID:    user.ID,
+				TargetType: "user",
+				TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+				Detail:     map[string]interface{}{"method": "password", "reason": "disabled"},
+			})
+			ErrorResponse(w, http.StatusForbidden, err.Error())
+			return
+		}
+		ErrorResponse(w, http.StatusInternalServerError, "生成令牌失败")
+		return
+	}
+	recordAudit(h.audit, r, &service.AuditEvent{
+		Action:     service.AuditLogin,
+		ActorID:    user.ID,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+		Detail:     map[string]interface{}{"method": "password"},
+	})
+
+	// 返回令牌和用户信息
+	SuccessResponse(w, LoginResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User: map[string]interface{}{
+			"id":       user.ID,
+			"username": user.Username,
+			"role":     user.Role,
+		},
+	})
+}
+
+// Refresh 使用刷新令牌换取新的令牌
+func (h *UserHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := ParseJSON(r, &req); err != nil || req.RefreshToken == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
+		return
+	}
+
+	tokens, err := h.authService.Refresh(r.Context(), req.RefreshToken, ClientIP(r))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefreshToken) {
+			ErrorResponse(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrAccountDisabled) {
+			ErrorResponse(w, http.StatusForbidden, err.Error())
+			return
+		}
+		ErrorResponse(w, http.StatusInternalServerError, "刷新令牌失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, tokens)
+}
+
+// Logout 退出当前会话，all为true时退出所有会话
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	// 从上下文获取用户ID和会话ID
+	userID := r.Context().Value("userID").(uint)
+	sessionID := r.Context().Value("sessionID").(string)
+
+	// 请求体可以为空
+	var req LogoutRequest
+	if r.ContentLength > 0 {
+		if err := ParseJSON(r, &req); err != nil {
+			ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
+			return
+		}
+	}
+
+	var err error
+	if req.All {
+		err = h.authService.LogoutAll(r.Context(), userID)
+	} else {
+		err = h.authService.Logout(r.Context(), sessionID)
+	}
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "退出登录失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, nil)
+}
+
+// 登录会话信息
+type SessionInfo struct {
+	*service.Session
+	Current bool `json:"current"` // 是否为发起请求的会话
+}
+
+// GetSessions 获取当前用户的登录会话
+func (h *UserHandler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	// 从上下文获取用户ID和会话ID
+	userID := r.Context().Value("userID").(uint)
+	sessionID := r.Context().Value("sessionID").(string)
+
+	sessions, err := h.authService.ListSessions(userID)
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "获取登录会话失败: "+err.Error())
+		return
+	}
+
+	result := make([]*SessionInfo, len(sessions))
+	for i, session := range sessions {
+		result[i] = &SessionInfo{
+			Session: session,
+			Current: session.ID == sessionID,
+		}
+	}
+
+	SuccessResponse(w, result)
+}
+
+// RevokeSession 撤销当前用户的指定登录会话
+func (h *UserHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	// 从上下文获取用户ID和会话ID
+	userID := r.Context().Value("userID").(uint)
+	sessionID, ok := r.Context().Value("id").(string)
+	if !ok || sessionID == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的会话ID")
+		return
+	}
+
+	if err := h.authService.RevokeSession(r.Context(), userID, sessionID); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			ErrorResponse(w, http.StatusNotFound, err.Error())
+			return
+		}
+		ErrorResponse(w, http.StatusInternalServerError, "撤销登录会话失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, nil)
+}
+
+// GetProfile 获取用户信息
+func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	// 从上下文获取用户ID
+	userID := r.Context().Value("userID").(uint)
+
+	// 查询用户信息
+	user, err := h.userStorage.GetUserByID(userID)
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+
+	// 返回用户信息
+	SuccessResponse(w, map[string]interface{}{
+		"id":         user.ID,
+		"username":   user.Username,
+		"email":      user.Email,
+		"role":       user.Role,
+		"created_at": user.CreatedAt,
+	})
+}
+
+// ChangePassword 修改当前用户的密码，需要验证当前密码，修改后其他设备上的登录失效
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	// 从上下文获取用户ID和会话ID
+	userID := r.Context().Value("userID").(uint)
+	sessionID := r.Context().Value("sessionID").(string)
+
+	var req ChangePasswordRequest
+	if err := ParseJSON(r, &req); err != nil || req.CurrentPassword == "" || req.NewPassword == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
+		return
+	}
+
+	user, err := h.userStorage.GetUserByID(userID)
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+
+	// 验证当前密码
+	if _, err := h.userStorage.VerifyPassword(user.Username, req.CurrentPassword); err != nil {
+		ErrorResponse(w, http.StatusForbidden, "当前密码错误")
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		ErrorResponse(w, http.StatusBadRequest, "新密码不能与当前密码相同")
+		return
+	}
+	if err := h.options.Policy.Validate(req.NewPassword, user.Username); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.userStorage.UpdatePassword(userID, req.NewPassword); err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "修改密码失败: "+err.Error())
+		return
+	}
+
+	// 保留当前会话，撤销其他会话
+	if err := h.authService.LogoutOthers(r.Context(), userID, sessionID); err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "撤销其他登录会话失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, nil)
+}
+
+// ForgotPassword 向用户的邮箱发送重置密码令牌。
+// 为避免泄露用户是否存在，无论用户是否存在或是否设置了邮箱都返回成功
+func (h *UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	if err := ParseJSON(r, &req); err != nil || (req.Username == "" && req.Email == "") {
+		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
+		return
+	}
+
+	var user *storage.User
+	var err error
+	if req.Email != "" {
+		user, err = h.userStorage.GetUserByEmail(req.Email)
+	} else {
+		user, err = h.userStorage.GetUserByUsername(req.Username)
+	}
+
+	if err == nil && user.Email != "" {
+		token, expiresAt, err := h.createResetToken(user.ID, 0)
+		if err != nil {
+			logger.Errorf("生成重置密码令牌失败: %v", err)
+		} else {
+			// 异步发送，避免响应时间暴露用户是否存在
+			go h.sendResetMail(user, token, expiresAt)
+		}
+	}
+
+	SuccessResponse(w, nil)
+}
+
+// ResetPassword 使用重置令牌设置新密码，成功后用户所有设备上的登录失效
+func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if err := ParseJSON(r, &req); err != nil || req.Token == "" || req.NewPassword == "" {
+		ErrorResponse(w, http.StatusBadRequest, "无效的请求参数")
+		return
+	}
+
+	// 先校验令牌和新密码，密码不符合要求时令牌仍可再次使用
+	tokenHash := hashResetToken(req.Token)
+	userID, err := h.userStorage.GetPasswordResetToken(tokenHash)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "重置密码失败: "+err.Error())
+		return
+	}
+
+	user, err := h.userStorage.GetUserByID(userID)
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "获取用户信息失败")
+		return
+	}
+	if err := h.options.Policy.Validate(req.NewPassword, user.Username); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.userStorage.ResetPasswordWithToken(tokenHash, req.NewPassword); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "重置密码失败: "+err.Error())
+		return
+	}
+
+	if err := h.authService.LogoutAll(r.Context(), userID); err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "撤销登录会话失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, nil)
+}
+
+// AdminResetPassword 管理员为用户生成重置密码令牌，用户设置了邮箱时同时发送邮件
+func (h *UserHandler) AdminResetPassword(w http.ResponseWriter, r *http.Request) {
+	adminID := r.Context().Value("userID").(uint)
+
+	// 从上下文获取用户ID
+	id, _ := r.Context().Value("id").(string)
+	userID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		ErrorResponse(w, http.StatusBadRequest, "无效的用户ID")
+		return
+	}
+
+	user, err := h.userStorage.GetUserByID(uint(userID))
+	if err != nil {
+		ErrorResponse(w, http.StatusNotFound, "用户不存在")
+		return
+	}
+
+	token, expiresAt, err := h.createResetToken(user.ID, adminID)
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "生成重置令牌失败: "+err.Error())
+		return
+	}
+
+	emailed := false
+	if user.Email != "" {
+		if err := h.sendResetMail(user, token, expiresAt); err == nil {
+			emailed = true
+		}
+	}
+
+	SuccessResponse(w, map[string]interface{}{
+		"token":      token,
+		"expires_at": expiresAt,
+		"emailed":    emailed,
+	})
+}
+
+// createResetToken 生成重置密码令牌，数据库中只保存哈希
+func (h *UserHandler) createResetToken(userID, createdBy uint) (string, time.Time, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	expiresAt := time.Now().Add(h.options.ResetTokenTTL)
+
+	if err := h.userStorage.CreatePasswordResetToken(userID, hashResetToken(token), expiresAt, createdBy); err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// sendResetMail 发送重置密码邮件
+func (h *UserHandler) sendResetMail(user *storage.User, token string, expiresAt time.Time) error {
+	if h.mailSender == nil {
+		return errors.New("未配置邮件发送")
+	}
+
+	link := token
+	if h.options.ResetURL != "" {
+		separator := "?"
+		if strings.Contains(h.options.ResetURL, "?") {
+			separator = "&"
+		}
+		link = h.options.ResetURL + separator + "token=" + url.QueryEscape(token)
+	}
+
+	body := fmt.Sprintf("%s，您好：\n\n我们收到了重置您账号密码的请求。请使用以下链接或令牌设置新密码：\n\n%s\n\n令牌将于 %s 失效，且只能使用一次。如果这不是您本人的操作，请忽略此邮件。\n",
+		user.Username, link, expiresAt.Format("2006-01-02 15:04:05"))
+
+	err := h.mailSender.Send(context.Background(), &mailer.Message{
+		To:      user.Email,
+		Subject: "重置密码",
+		Body:    body,
+	})
+	if err != nil {
+		logger.Errorf("发送重置密码邮件失败: user=%d, err=%v", user.ID, err)
+	}
+	return err
+}
+
+// hashResetToken 计算重置令牌的SHA-256哈希
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// validEmail 检查是否为不带显示名称的邮箱地址
+func validEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email
+}
@@ -1,282 +1,555 @@
-package handlers
-
-import (
-	"context"
-	"encoding/json"
-	"log"
-	"net/http"
-	"time"
-
-	"chat-llama/internal/service"
-
-	"github.com/gorilla/websocket"
-)
-
-const (
-	// 写入超时
-	writeWait = 10 * time.Second
-
-	// 读取超时
-	pongWait = 60 * time.Second
-
-	// Ping 间隔
-	pingPeriod = (pongWait * 9) / 10
-
-	// 消息大小限制
-	maxMessageSize = 512 * 1024
-)
-
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		// 允许所有来源，生产环境应该检查来源
-		return true
-	},
-}
-
-// 消息类型
-const (
-	TypeChat    = "chat"
-	TypeHistory = "history"
-	TypeError   = "error"
-)
-
-// WebSocketMessage WebSocket消息结构
-type WebSocketMessage struct {
-	Type    string          `json:"type"`
-	Content json.RawMessage `json:"content"`
-}
-
-// WebSocketClient WebSocket客户端
-type WebSocketClient struct {
-	conn        *websocket.Conn
-	userID      uint
-	send        chan []byte
-	chatService *service.ChatService
-}
-
-// NewWebSocketClient 创建新的WebSocket客户端
-func NewWebSocketClient(conn *websocket.Conn, userID uint, chatService *service.ChatService) *WebSocketClient {
-	return &WebSocketClient{
-		conn:        conn,
-		userID:      userID,
-		send:        make(chan []byte, 256),
-		chatService: chatService,
-	}
-}
-
-// WebSocketHandler 处理WebSocket连接
-type WebSocketHandler struct {
-	chatService *service.ChatService
-}
-
-// NewWebSocketHandler 创建新的WebSocket处理程序
-func NewWebSocketHandler(chatService *service.ChatService) *WebSocketHandler {
-	return &WebSocketHandler{
-		chatService: chatService,
-	}
-}
-
-// HandleWebSocket 处理WebSocket连接请求
-func (h *WebSocketHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	// 从上下文获取用户ID
-	userID := r.Context().Value("userID").(uint)
-
-	// 升级HTTP连接为WebSocket
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Printf("WebSocket升级失败: %v", err)
-		return
-	}
-
-	// 创建客户端
-	client := NewWebSocketClient(conn, userID, h.chatService)
-
-	// 启动读写协程
-	go client.writePump()
-	go client.readPump()
-}
-
-// readPump 从WebSocket连接读取消息
-func (c *WebSocketClient) readPump() {
-	defer func() {
-		c.conn.Close()
-	}()
-
-	c.conn.SetReadLimit(maxMessageSize)
-	c.conn.SetReadDeadline(time.Now().Add(pongWait))
-	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(pongWait))
-		return nil
-	})
-
-	for {
-		_, message, err := c.conn.ReadMessage()
-		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket错误: %v", err)
-			}
-			break
-		}
-
-		// 处理接收到的消息
-		c.handleMessage(message)
-	}
-}
-
-// writePump 向WebSocket连接发送消息
-func (c *WebSocketClient) writePump() {
-	ticker := time.NewTicker(pingPeriod)
-	defer func() {
-		ticker.Stop()
-		c.conn.Close()
-	}()
-
-	for {
-		select {
-		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				// 通道已关闭
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
-
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
-			}
-			w.Write(message)
-
-			// 添加队列中的消息
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
-			}
-
-			if err := w.Close(); err != nil {
-				return
-			}
-		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				return
-			}
-		}
-	}
-}
-
-// handleMessage 处理接收到的消息
-func (c *WebSocketClient) handleMessage(data []byte) {
-	// 解析消息
-	var msg WebSocketMessage
-	if err := json.Unmarshal(data, &msg); err != nil {
-		c.sendError("无效的消息格式")
-		return
-	}
-
-	// 根据消息类型处理
-	switch msg.Type {
-	case TypeChat:
-		var chatReq service.ChatRequest
-		if err := json.Unmarshal(msg.Content, &chatReq); err != nil {
-			c.sendError("无效的聊天请求")
-			return
-		}
-
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-
-		// 处理聊天请求
-		resp, err := c.chatService.Chat(ctx, c.userID, &chatReq)
-		if err != nil {
-			c.sendError("处理聊天请求失败: " + err.Error())
-			return
-		}
-
-		// 发送响应
-		c.sendResponse(TypeChat, resp)
-
-	case TypeHistory:
-		var historyReq struct {
-			ConversationID string `json:"conversation_id"`
-		}
-		if err := json.Unmarshal(msg.Content, &historyReq); err != nil {
-			c.sendError("无效的历史请求")
-			return
-		}
-
-		// 获取会话历史
-		messages, err := c.chatService.GetConversationHistory(c.userID, historyReq.ConversationID)
-		if err != nil {
-			c.sendError("获取会话历史失败: " + err.Error())
-			return
-		}
-
-		// 发送响应
-		c.sendResponse(TypeHistory, messages)
-
-	default:
-		c.sendError("不支持的消息类型: " + msg.Type)
-	}
-}
-
-// sendResponse 发送响应
-func (c *WebSocketClient) sendResponse(msgType string, data interface{}) {
-	resp := WebSocketMessage{
-		Type: msgType,
-	}
-
-	// 序列化内容
-	content, err := json.Marshal(data)
-	if err != nil {
-		c.sendError("序列化响应失败")
-		return
-	}
-	resp.Content = content
-
-	// 序列化完整消息
-	message, err := json.Marshal(resp)
-	if err != nil {
-		c.sendError("序列化消息失败")
-		return
-	}
-
-	select {
-	case c.send <- message:
-	default:
-		// 发送缓冲区已满，关闭连接
-		c.conn.Close()
-	}
-}
-
-// sendError 发送错误消息
-func (c *WebSocketClient) sendError(errMsg string) {
-	resp := WebSocketMessage{
-		Type: TypeError,
-	}
-
-	// 序列化错误内容
-	content, err := json.Marshal(map[string]string{"message": errMsg})
-	if err != nil {
-		log.Printf("序列化错误消息失败: %v", err)
-		return
-	}
-	resp.Content = content
-
-	// 序列化完整消息
-	message, err := json.Marshal(resp)
-	if err != nil {
-		log.Printf("序列化消息失败: %v", err)
-		return
-	}
-
-	select {
-	case c.send <- message:
-	default:
-		// 发送缓冲区已满，关闭连接
-		c.conn.Close()
-	}
-}
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"chat-llama/internal/service"
+	"chat-llama/pkg/metrics"
+	"chat-llama/pkg/ratelimit"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// 写入超时
+	writeWait = 10 * time.Second
+
+	// 读取超时
+	pongWait = 60 * time.Second
+
+	// Ping 间隔
+	pingPeriod = (pongWait * 9) / 10
+
+	// 消息大小限制
+	maxMessageSize = 512 * 1024
+
+	// 令牌过期时关闭连接使用的状态码，客户端收到后刷新令牌并重新连接
+	closeTokenExpired = 4001
+)
+
+// wsConnections 当前实例上已建立的WebSocket连接数
+var wsConnections = metrics.NewGauge("chat_llama_websocket_connections", "当前已建立的WebSocket连接数")
+
+// 消息类型
+const (
+	TypeChat       = "chat"
+	TypeHistory    = "history"
+	TypeResume     = "resume" // 客户端发送最后收到的事件序号，服务端补发之后的事件
+	TypeError      = "error"
+	TypeToolCall   = service.ToolEventCall   // 模型发起工具调用
+	TypeToolResult = service.ToolEventResult // 工具执行结果
+
+	// 以下事件推送给用户在所有设备上的连接，包括共享会话的其他成员，消息中带有序号
+	TypeMessage             = service.EventMessage             // 会话中的新消息
+	TypeTitle               = service.EventTitle               // 会话标题已修改
+	TypeConversationDeleted = service.EventConversationDeleted // 会话已删除
+	TypeGeneration          = service.EventGeneration          // 回复生成的进度
+)
+
+// errScopeReadHistory API密钥没有读取会话的权限
+const errScopeReadHistory = "API密钥无权读取会话"
+
+// WebSocketMessage WebSocket消息结构
+type WebSocketMessage struct {
+	Type    string          `json:"type"`
+	Seq     uint64          `json:"seq,omitempty"` // 推送事件的序号，对请求的直接响应没有序号
+	Content json.RawMessage `json:"content"`
+}
+
+// WebSocketClient WebSocket客户端
+type WebSocketClient struct {
+	conn        *websocket.Conn
+	userID      uint
+	orgID       uint      // 建立连接时所在的组织
+	scopes      []string  // 通过API密钥建立的连接为密钥的权限范围，登录令牌建立的连接为nil
+	expiresAt   time.Time // 建立连接所用令牌的过期时间，零值表示不过期
+	send        chan []byte
+	chatService *service.ChatService
+	hub         *service.EventHub
+	events      *service.Subscription // 为nil时不接收推送事件
+	limiter     *ratelimit.Limiter
+}
+
+// NewWebSocketClient 使用兑换的连接凭证创建WebSocket客户端，hub不为nil且允许读取会话时订阅推送给该用户的事件
+func NewWebSocketClient(conn *websocket.Conn, ticket *service.WebSocketTicket, chatService *service.ChatService, hub *service.EventHub, limiter *ratelimit.Limiter) *WebSocketClient {
+	client := &WebSocketClient{
+		conn:        conn,
+		userID:      ticket.UserID,
+		orgID:       ticket.OrgID,
+		scopes:      ticket.Scopes,
+		expiresAt:   ticket.ExpiresAt,
+		send:        make(chan []byte, 256),
+		chatService: chatService,
+		hub:         hub,
+		limiter:     limiter,
+	}
+	// 推送的事件包含会话消息，与HTTP接口一样需要读取会话的权限
+	if hub != nil && client.hasScope(service.ScopeReadHistory) {
+		client.events = hub.Subscribe(ticket.UserID)
+	}
+	return client
+}
+
+// hasScope 检查连接是否拥有权限范围，登录令牌建立的连接不受限制
+func (c *WebSocketClient) hasScope(scope string) bool {
+	return c.scopes == nil || service.HasScope(c.scopes, scope)
+}
+
+// WebSocketOptions WebSocket连接的来源和数量限制
+type WebSocketOptions struct {
+	AllowedOrigins        []string // 允许的来源，为空时只允许与请求同域的来源，*表示允许所有来源
+	MaxConnectionsPerUser int      // 每个用户在当前实例上的最大连接数，为0时不限制
+}
+
+// WebSocketHandler 处理WebSocket连接
+type WebSocketHandler struct {
+	chatService *service.ChatService
+	events      *service.EventHub
+	tickets     *service.WebSocketTicketService
+	limiter     *ratelimit.Limiter
+	options     WebSocketOptions
+	upgrader    websocket.Upgrader
+	connections map[uint]int // 用户ID到当前连接数
+	mutex       sync.Mutex
+}
+
+// NewWebSocketHandler 创建新的WebSocket处理程序，events为nil时不推送其他成员的消息
+func NewWebSocketHandler(chatService *service.ChatService, events *service.EventHub, tickets *service.WebSocketTicketService, limiter *ratelimit.Limiter, options WebSocketOptions) *WebSocketHandler {
+	h := &WebSocketHandler{
+		chatService: chatService,
+		events:      events,
+		tickets:     tickets,
+		limiter:     limiter,
+		options:     options,
+		connections: make(map[uint]int),
+	}
+	h.upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     h.checkOrigin,
+	}
+	return h
+}
+
+// IssueTicket 为当前登录令牌或API密钥签发一次性的WebSocket连接凭证
+func (h *WebSocketHandler) IssueTicket(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(uint)
+	sessionID, _ := r.Context().Value("sessionID").(string)
+	expiresAt, _ := r.Context().Value("tokenExpiresAt").(time.Time)
+
+	// API密钥签发的凭证记录密钥的权限范围，连接中的请求按相同范围检查
+	var scopes []string
+	if apiKeyScopes, isAPIKey := r.Context().Value("apiKeyScopes").([]string); isAPIKey {
+		scopes = append([]string{}, apiKeyScopes...)
+	}
+
+	ticket, err := h.tickets.Issue(r.Context(), &service.WebSocketTicket{
+		UserID:    userID,
+		SessionID: sessionID,
+		OrgID:     currentOrgID(r),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, "签发连接凭证失败: "+err.Error())
+		return
+	}
+
+	SuccessResponse(w, map[string]interface{}{
+		"ticket":     ticket,
+		"expires_in": int64(h.tickets.TTL().Seconds()),
+	})
+}
+
+// HandleWebSocket 处理WebSocket连接请求，使用URL参数ticket中的一次性凭证认证
+func (h *WebSocketHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	ticket, err := h.tickets.Redeem(r.Context(), r.URL.Query().Get("ticket"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTicket) {
+			ErrorResponse(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		log.Printf("兑换连接凭证失败: %v", err)
+		ErrorResponse(w, http.StatusServiceUnavailable, "暂时无法验证连接凭证")
+		return
+	}
+
+	// 检查用户的连接数
+	if !h.acquire(ticket.UserID) {
+		ErrorResponse(w, http.StatusTooManyRequests, "连接数已达上限，请关闭其他窗口后重试")
+		return
+	}
+
+	// 升级HTTP连接为WebSocket，来源不在允许列表中时升级失败
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		h.release(ticket.UserID)
+		log.Printf("WebSocket升级失败: %v", err)
+		return
+	}
+
+	// 创建客户端
+	client := NewWebSocketClient(conn, ticket, h.chatService, h.events, h.limiter)
+	wsConnections.Inc()
+
+	// 启动读写协程，连接关闭后释放连接数
+	go client.writePump()
+	go func() {
+		client.readPump()
+		h.release(ticket.UserID)
+		wsConnections.Dec()
+	}()
+	if client.events != nil {
+		go client.eventPump()
+	}
+}
+
+// checkOrigin 检查浏览器发起连接的来源，非浏览器客户端没有Origin头时允许
+func (h *WebSocketHandler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	if len(h.options.AllowedOrigins) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+
+	for _, allowed := range h.options.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	log.Printf("拒绝来源为 %s 的WebSocket连接", origin)
+	return false
+}
+
+// acquire 占用用户的一个连接数，达到上限时返回false
+func (h *WebSocketHandler) acquire(userID uint) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.options.MaxConnectionsPerUser > 0 && h.connections[userID] >= h.options.MaxConnectionsPerUser {
+		return false
+	}
+	h.connections[userID]++
+	return true
+}
+
+// release 释放用户的一个连接数
+func (h *WebSocketHandler) release(userID uint) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.connections[userID]--
+	if h.connections[userID] <= 0 {
+		delete(h.connections, userID)
+	}
+}
+
+// readPump 从WebSocket连接读取消息
+func (c *WebSocketClient) readPump() {
+	defer func() {
+		if c.events != nil {
+			c.events.Close()
+		}
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("WebSocket错误: %v", err)
+			}
+			break
+		}
+
+		// 处理接收到的消息
+		c.handleMessage(message)
+	}
+}
+
+// eventPump 转发推送给用户的事件，只转发当前组织内的会话事件，订阅关闭后退出
+func (c *WebSocketClient) eventPump() {
+	for event := range c.events.Events() {
+		if event.OrgID != c.orgID {
+			continue
+		}
+		c.sendEvent(event)
+	}
+}
+
+// writePump 向WebSocket连接发送消息
+func (c *WebSocketClient) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+
+	// 令牌过期时关闭连接
+	var expired <-chan time.Time
+	if !c.expiresAt.IsZero() {
+		timer := time.NewTimer(time.Until(c.expiresAt))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case <-expired:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(closeTokenExpired, "令牌已过期"))
+			return
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// 通道已关闭
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+
+			w, err := c.conn.NextWriter(websocket.TextMessage)
+			if err != nil {
+				return
+			}
+			w.Write(message)
+
+			// 添加队列中的消息
+			n := len(c.send)
+			for i := 0; i < n; i++ {
+				w.Write([]byte{'\n'})
+				w.Write(<-c.send)
+			}
+
+			if err := w.Close(); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleMessage 处理接收到的消息
+func (c *WebSocketClient) handleMessage(data []byte) {
+	// 解析消息
+	var msg WebSocketMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		c.sendError("无效的消息格式")
+		return
+	}
+
+	// 根据消息类型处理
+	switch msg.Type {
+	case TypeChat:
+		var chatReq service.ChatRequest
+		if err := json.Unmarshal(msg.Content, &chatReq); err != nil {
+			c.sendError("无效的聊天请求")
+			return
+		}
+
+		// 每条聊天消息都计入用户的请求频率
+		if !c.allowRequest() {
+			c.sendError("请求过于频繁，请稍后再试")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		// 实时推送工具调用过程
+		ctx = service.WithToolEventHandler(ctx, func(event *service.ToolEvent) {
+			c.sendResponse(event.Type, event)
+		})
+
+		// 本连接通过聊天响应拿到新消息，不再重复推送
+		if c.events != nil {
+			ctx = service.WithEventOrigin(ctx, c.events.ID)
+		}
+
+		// 处理聊天请求
+		resp, err := c.chatService.Chat(ctx, c.userID, c.orgID, &chatReq)
+		if err != nil {
+			c.sendError("处理聊天请求失败: " + err.Error())
+			return
+		}
+
+		// 发送响应
+		c.sendResponse(TypeChat, resp)
+
+	case TypeHistory:
+		if !c.hasScope(service.ScopeReadHistory) {
+			c.sendError(errScopeReadHistory)
+			return
+		}
+
+		var historyReq struct {
+			ConversationID string `json:"conversation_id"`
+		}
+		if err := json.Unmarshal(msg.Content, &historyReq); err != nil {
+			c.sendError("无效的历史请求")
+			return
+		}
+
+		// 获取会话历史
+		messages, err := c.chatService.GetConversationHistory(c.userID, c.orgID, historyReq.ConversationID)
+		if err != nil {
+			c.sendError("获取会话历史失败: " + err.Error())
+			return
+		}
+
+		// 发送响应
+		c.sendResponse(TypeHistory, messages)
+
+	case TypeResume:
+		if !c.hasScope(service.ScopeReadHistory) {
+			c.sendError(errScopeReadHistory)
+			return
+		}
+
+		var resumeReq struct {
+			LastSeq uint64 `json:"last_seq"`
+		}
+		if err := json.Unmarshal(msg.Content, &resumeReq); err != nil {
+			c.sendError("无效的重连请求")
+			return
+		}
+		c.resume(resumeReq.LastSeq)
+
+	default:
+		c.sendError("不支持的消息类型: " + msg.Type)
+	}
+}
+
+// allowRequest 检查用户请求频率
+func (c *WebSocketClient) allowRequest() bool {
+	if c.limiter == nil {
+		return true
+	}
+
+	result, err := c.limiter.Allow(context.Background(), fmt.Sprintf("%d", c.userID))
+	if err != nil {
+		log.Printf("限流检查失败: %v", err)
+		return true
+	}
+
+	return result.Allowed
+}
+
+// resume 补发序号大于lastSeq的事件，最后发送当前最新的序号
+// 补发期间新到达的事件可能与补发的事件重复，客户端按序号去重；生成中的回复在补发后继续通过推送事件送达
+func (c *WebSocketClient) resume(lastSeq uint64) {
+	if c.hub == nil {
+		c.sendError(service.ErrReplayUnavailable.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	replay, err := c.hub.Replay(ctx, c.userID, lastSeq)
+	if err != nil {
+		c.sendError("断线重连失败: " + err.Error())
+		return
+	}
+
+	for _, event := range replay.Events {
+		if event.OrgID == c.orgID {
+			c.sendEvent(event)
+		}
+	}
+	c.sendResponse(TypeResume, replay)
+}
+
+// sendEvent 发送带序号的推送事件
+func (c *WebSocketClient) sendEvent(event *service.Event) {
+	c.sendMessage(event.Type, event.Seq, event)
+}
+
+// sendResponse 发送响应
+func (c *WebSocketClient) sendResponse(msgType string, data interface{}) {
+	c.sendMessage(msgType, 0, data)
+}
+
+// sendMessage 序列化并发送消息，seq为0时不带序号
+func (c *WebSocketClient) sendMessage(msgType string, seq uint64, data interface{}) {
+	resp := WebSocketMessage{
+		Type: msgType,
+		Seq:  seq,
+	}
+
+	// 序列化内容
+	content, err := json.Marshal(data)
+	if err != nil {
+		c.sendError("序列化响应失败")
+		return
+	}
+	resp.Content = content
+
+	// 序列化完整消息
+	message, err := json.Marshal(resp)
+	if err != nil {
+		c.sendError("序列化消息失败")
+		return
+	}
+
+	select {
+	case c.send <- message:
+	default:
+		// 发送缓冲区已满，关闭连接
+		c.conn.Close()
+	}
+}
+
+// sendError 发送错误消息
+func (c *WebSocketClient) sendError(errMsg string) {
+	resp := WebSocketMessage{
+		Type: TypeError,
+	}
+
+	// 序列化错误内容
+	content, err := json.Marshal(map[string]string{"message": errMsg})
+	if err != nil {
+		log.Printf("序列化错误消息失败: %v", err)
+		return
+	}
+	resp.Content = content
+
+	// 序列化完整消息
+	message, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("序列化消息失败: %v", err)
+		return
+	}
+
+	select {
+	case c.send <- message:
+	default:
+		// 发送缓冲区已满，关闭连接
+		c.conn.Close()
+	}
+}
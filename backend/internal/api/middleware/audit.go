@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"chat-llama/internal/api/handlers"
+	"chat-llama/internal/service"
+)
+
+// AuditMiddleware 审计中间件，记录管理接口的每一次请求，需在JWT中间件之后使用
+type AuditMiddleware struct {
+	recorder service.AuditRecorder
+}
+
+// NewAuditMiddleware 创建审计中间件
+func NewAuditMiddleware(recorder service.AuditRecorder) *AuditMiddleware {
+	return &AuditMiddleware{
+		recorder: recorder,
+	}
+}
+
+// Middleware 中间件处理函数
+func (m *AuditMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 包装ResponseWriter以记录状态码
+		rw := &responseWriter{
+			ResponseWriter: w,
+			statusCode:     http.StatusOK,
+		}
+
+		next.ServeHTTP(rw, r)
+
+		userID, _ := r.Context().Value("userID").(uint)
+		m.recorder.Record(r.Context(), &service.AuditEvent{
+			Action:    service.AuditAdminRequest,
+			ActorID:   userID,
+			IP:        handlers.ClientIP(r),
+			UserAgent: r.UserAgent(),
+			Detail: map[string]interface{}{
+				"method": r.Method,
+				"path":   r.URL.Path,
+				"status": rw.statusCode,
+			},
+			CreatedAt: time.Now(),
+		})
+	})
+}
@@ -1,85 +1,115 @@
-package middleware
-
-import (
-	"context"
-	"net/http"
-	"strings"
-
-	"chat-llama/internal/api/handlers"
-
-	"github.com/dgrijalva/jwt-go"
-)
-
-// JWTMiddleware JWT认证中间件
-type JWTMiddleware struct {
-	jwtSecret []byte
-}
-
-// NewJWTMiddleware 创建JWT中间件
-func NewJWTMiddleware(jwtSecret string) *JWTMiddleware {
-	return &JWTMiddleware{
-		jwtSecret: []byte(jwtSecret),
-	}
-}
-
-// Middleware 中间件处理函数
-func (m *JWTMiddleware) Middleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// 从请求头或URL参数获取令牌
-		tokenString := extractToken(r)
-		if tokenString == "" {
-			handlers.ErrorResponse(w, http.StatusUnauthorized, "需要认证")
-			return
-		}
-
-		// 解析JWT令牌
-		claims := &handlers.Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return m.jwtSecret, nil
-		})
-
-		// 处理令牌错误
-		if err != nil {
-			if err == jwt.ErrSignatureInvalid {
-				handlers.ErrorResponse(w, http.StatusUnauthorized, "无效的令牌签名")
-				return
-			}
-			handlers.ErrorResponse(w, http.StatusUnauthorized, "无效或过期的令牌")
-			return
-		}
-
-		// 验证令牌
-		if !token.Valid {
-			handlers.ErrorResponse(w, http.StatusUnauthorized, "无效的令牌")
-			return
-		}
-
-		// 将用户ID添加到请求上下文
-		ctx := context.WithValue(r.Context(), "userID", claims.UserID)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
-}
-
-// extractToken 从请求中提取token
-func extractToken(r *http.Request) string {
-	// 从Authorization头部获取
-	bearerToken := r.Header.Get("Authorization")
-	strArr := strings.Split(bearerToken, " ")
-	if len(strArr) == 2 {
-		return strArr[1]
-	}
-
-	// 从URL参数获取
-	token := r.URL.Query().Get("token")
-	if token != "" {
-		return token
-	}
-
-	// 从Cookie获取
-	cookie, err := r.Cookie("token")
-	if err == nil {
-		return cookie.Value
-	}
-
-	return ""
-}
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"chat-llama/internal/api/handlers"
+	"chat-llama/internal/service"
+)
+
+// JWTMiddleware 认证中间件，同时接受JWT和以sk-开头的API密钥
+type JWTMiddleware struct {
+	authService   *service.AuthService
+	apiKeyService *service.APIKeyService
+}
+
+// NewJWTMiddleware 创建认证中间件，apiKeyService为nil时不接受API密钥
+func NewJWTMiddleware(authService *service.AuthService, apiKeyService *service.APIKeyService) *JWTMiddleware {
+	return &JWTMiddleware{
+		authService:   authService,
+		apiKeyService: apiKeyService,
+	}
+}
+
+// Middleware 中间件处理函数
+func (m *JWTMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 从请求头或Cookie获取令牌
+		tokenString := extractToken(r)
+		if tokenString == "" {
+			handlers.ErrorResponse(w, http.StatusUnauthorized, "需要认证")
+			return
+		}
+
+		if m.apiKeyService != nil && strings.HasPrefix(tokenString, service.APIKeyPrefix) {
+			m.authenticateAPIKey(w, r, next, tokenString)
+			return
+		}
+
+		// 验证令牌签名、有效期及会话是否已撤销
+		claims, err := m.authService.ParseAccessToken(r.Context(), tokenString)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidToken) {
+				handlers.ErrorResponse(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+			log.Printf("验证令牌失败: %v", err)
+			handlers.ErrorResponse(w, http.StatusServiceUnavailable, "暂时无法验证令牌")
+			return
+		}
+
+		m.authService.Touch(claims.SessionID, handlers.ClientIP(r))
+
+		// 将用户ID、会话ID、角色及当前组织添加到请求上下文
+		ctx := context.WithValue(r.Context(), "userID", claims.UserID)
+		ctx = context.WithValue(ctx, "sessionID", claims.SessionID)
+		ctx = context.WithValue(ctx, "role", claims.Role)
+		ctx = context.WithValue(ctx, "orgID", claims.OrgID)
+		ctx = context.WithValue(ctx, "orgRole", claims.OrgRole)
+		ctx = context.WithValue(ctx, "tokenExpiresAt", time.Unix(claims.ExpiresAt, 0))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticateAPIKey 验证API密钥，上下文中的apiKeyScopes限制可访问的接口
+func (m *JWTMiddleware) authenticateAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, secret string) {
+	key, role, err := m.apiKeyService.Authenticate(r.Context(), secret)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAPIKey) {
+			handlers.ErrorResponse(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrAccountDisabled) {
+			handlers.ErrorResponse(w, http.StatusForbidden, err.Error())
+			return
+		}
+		log.Printf("验证API密钥失败: %v", err)
+		handlers.ErrorResponse(w, http.StatusServiceUnavailable, "暂时无法验证API密钥")
+		return
+	}
+
+	// API密钥没有登录会话，sessionID为空，只能访问创建时所在组织的数据
+	ctx := context.WithValue(r.Context(), "userID", key.UserID)
+	ctx = context.WithValue(ctx, "sessionID", "")
+	ctx = context.WithValue(ctx, "role", role)
+	ctx = context.WithValue(ctx, "orgID", key.OrgID)
+	ctx = context.WithValue(ctx, "apiKeyID", key.ID)
+	ctx = context.WithValue(ctx, "apiKeyScopes", key.Scopes)
+	if key.ExpiresAt != nil {
+		ctx = context.WithValue(ctx, "tokenExpiresAt", *key.ExpiresAt)
+	}
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// extractToken 从请求头或Cookie中提取token，不接受URL参数以免令牌出现在日志和浏览记录中
+func extractToken(r *http.Request) string {
+	// 从Authorization头部获取
+	bearerToken := r.Header.Get("Authorization")
+	strArr := strings.Split(bearerToken, " ")
+	if len(strArr) == 2 {
+		return strArr[1]
+	}
+
+	// 从Cookie获取
+	cookie, err := r.Cookie("token")
+	if err == nil {
+		return cookie.Value
+	}
+
+	return ""
+}
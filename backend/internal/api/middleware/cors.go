@@ -1,32 +1,33 @@
-package middleware
-
-import (
-	"net/http"
-)
-
-// CORSMiddleware CORS中间件
-type CORSMiddleware struct{}
-
-// NewCORSMiddleware 创建CORS中间件
-func NewCORSMiddleware() *CORSMiddleware {
-	return &CORSMiddleware{}
-}
-
-// Middleware 中间件处理函数
-func (m *CORSMiddleware) Middleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// 设置CORS头
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Content-Length, X-Requested-With")
-
-		// 处理预检请求
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		// 继续处理请求
-		next.ServeHTTP(w, r)
-	})
-}
+package middleware
+
+import (
+	"net/http"
+)
+
+// CORSMiddleware CORS中间件
+type CORSMiddleware struct{}
+
+// NewCORSMiddleware 创建CORS中间件
+func NewCORSMiddleware() *CORSMiddleware {
+	return &CORSMiddleware{}
+}
+
+// Middleware 中间件处理函数
+func (m *CORSMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 设置CORS头
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Content-Length, X-Requested-With, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
+
+		// 处理预检请求
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		// 继续处理请求
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,53 +1,55 @@
-package middleware
-
-import (
-	"log"
-	"net/http"
-	"time"
-)
-
-// LoggerMiddleware 日志中间件
-type LoggerMiddleware struct{}
-
-// NewLoggerMiddleware 创建日志中间件
-func NewLoggerMiddleware() *LoggerMiddleware {
-	return &LoggerMiddleware{}
-}
-
-// Middleware 中间件处理函数
-func (m *LoggerMiddleware) Middleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-
-		// 包装ResponseWriter以记录状态码
-		rw := &responseWriter{
-			ResponseWriter: w,
-			statusCode:     http.StatusOK,
-		}
-
-		// 处理请求
-		next.ServeHTTP(rw, r)
-
-		// 记录请求信息
-		duration := time.Since(start)
-		log.Printf("[%s] %s %s %d %v",
-			r.Method,
-			r.RequestURI,
-			r.RemoteAddr,
-			rw.statusCode,
-			duration,
-		)
-	})
-}
-
-// responseWriter 包装http.ResponseWriter以记录状态码
-type responseWriter struct {
-	http.ResponseWriter
-	statusCode int
-}
-
-// WriteHeader 覆盖WriteHeader以记录状态码
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
-	rw.ResponseWriter.WriteHeader(code)
-}
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"chat-llama/pkg/pii"
+)
+
+// LoggerMiddleware 日志中间件
+type LoggerMiddleware struct{}
+
+// NewLoggerMiddleware 创建日志中间件
+func NewLoggerMiddleware() *LoggerMiddleware {
+	return &LoggerMiddleware{}
+}
+
+// Middleware 中间件处理函数
+func (m *LoggerMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		// 包装ResponseWriter以记录状态码
+		rw := &responseWriter{
+			ResponseWriter: w,
+			statusCode:     http.StatusOK,
+		}
+
+		// 处理请求
+		next.ServeHTTP(rw, r)
+
+		// 记录请求信息
+		duration := time.Since(start)
+		log.Printf("[%s] %s %s %d %v",
+			r.Method,
+			pii.ForLog(r.RequestURI),
+			r.RemoteAddr,
+			rw.statusCode,
+			duration,
+		)
+	})
+}
+
+// responseWriter 包装http.ResponseWriter以记录状态码
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+// WriteHeader 覆盖WriteHeader以记录状态码
+func (rw *responseWriter) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"chat-llama/pkg/metrics"
+)
+
+var (
+	httpRequests = metrics.NewCounter("chat_llama_http_requests_total",
+		"HTTP请求数", "method", "route", "status")
+	httpRequestDuration = metrics.NewHistogram("chat_llama_http_request_duration_seconds",
+		"HTTP请求处理耗时", metrics.DefaultBuckets, "method", "route", "status")
+)
+
+// knownMethods 作为指标标签的请求方法，其余方法统一记为other，避免任意方法名导致标签数量无限增长
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// methodLabel 将请求方法映射到固定的标签集合
+func methodLabel(method string) string {
+	if knownMethods[method] {
+		return method
+	}
+	return "other"
+}
+
+// MetricsMiddleware 指标中间件，按路由和状态码统计请求数和耗时
+type MetricsMiddleware struct {
+	route string
+}
+
+// NewMetricsMiddleware 创建指标中间件，route为匹配的路由模板，未匹配任何路由时为空
+func NewMetricsMiddleware(route string) *MetricsMiddleware {
+	// 使用路由模板而不是实际路径，避免路径参数导致标签数量无限增长
+	if route == "" {
+		route = "unmatched"
+	}
+	return &MetricsMiddleware{
+		route: route,
+	}
+}
+
+// Middleware 中间件处理函数
+func (m *MetricsMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		// 包装ResponseWriter以记录状态码
+		rw := &responseWriter{
+			ResponseWriter: w,
+			statusCode:     http.StatusOK,
+		}
+
+		next.ServeHTTP(rw, r)
+
+		// 经由Gin包装时处理程序直接写入原始的ResponseWriter，从中获取实际的状态码
+		statusCode := rw.statusCode
+		if sw, ok := w.(interface{ Status() int }); ok {
+			statusCode = sw.Status()
+		}
+		status := strconv.Itoa(statusCode)
+		method := methodLabel(r.Method)
+		httpRequests.Inc(method, m.route, status)
+		httpRequestDuration.Observe(time.Since(start).Seconds(), method, m.route, status)
+	})
+}
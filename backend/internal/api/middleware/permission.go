@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+
+	"chat-llama/internal/api/handlers"
+	"chat-llama/internal/service"
+)
+
+// PermissionMiddleware 权限检查中间件，需在JWT中间件之后使用
+type PermissionMiddleware struct {
+	permission string
+}
+
+// NewPermissionMiddleware 创建权限检查中间件，请求用户的角色需拥有permission
+func NewPermissionMiddleware(permission string) *PermissionMiddleware {
+	return &PermissionMiddleware{
+		permission: permission,
+	}
+}
+
+// Middleware 中间件处理函数
+func (m *PermissionMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, _ := r.Context().Value("role").(string)
+		if !service.HasPermission(role, m.permission) {
+			handlers.ErrorResponse(w, http.StatusForbidden, "权限不足")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
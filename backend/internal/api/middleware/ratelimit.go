@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"chat-llama/internal/api/handlers"
+	"chat-llama/pkg/ratelimit"
+)
+
+// RateLimitMiddleware 按用户限制每分钟请求数的中间件，需在JWT中间件之后使用
+type RateLimitMiddleware struct {
+	limiter *ratelimit.Limiter
+}
+
+// NewRateLimitMiddleware 创建限流中间件
+func NewRateLimitMiddleware(limiter *ratelimit.Limiter) *RateLimitMiddleware {
+	return &RateLimitMiddleware{
+		limiter: limiter,
+	}
+}
+
+// Middleware 中间件处理函数
+func (m *RateLimitMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 未启用限流或无法识别用户时直接放行
+		userID, ok := r.Context().Value("userID").(uint)
+		if m.limiter == nil || !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		result, err := m.limiter.Allow(r.Context(), fmt.Sprintf("%d", userID))
+		if err != nil {
+			// 计数存储不可用时放行，避免影响正常使用
+			log.Printf("限流检查失败: %v", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// 设置限流响应头
+		w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(result.Limit, 10))
+		w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+		w.Header().Set("X-RateLimit-Reset", strconv.Itoa(int(result.ResetIn.Seconds())))
+
+		if !result.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(result.ResetIn.Seconds())+1))
+			handlers.ErrorResponse(w, http.StatusTooManyRequests, "请求过于频繁，请稍后再试")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
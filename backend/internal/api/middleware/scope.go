@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+
+	"chat-llama/internal/api/handlers"
+	"chat-llama/internal/service"
+)
+
+// ScopeMiddleware API密钥权限范围检查中间件，需在认证中间件之后使用。
+// 通过登录令牌认证的请求不受限制
+type ScopeMiddleware struct {
+	scope string
+}
+
+// NewScopeMiddleware 创建权限范围检查中间件，scope为空时只允许登录令牌访问
+func NewScopeMiddleware(scope string) *ScopeMiddleware {
+	return &ScopeMiddleware{
+		scope: scope,
+	}
+}
+
+// Middleware 中间件处理函数
+func (m *ScopeMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scopes, isAPIKey := r.Context().Value("apiKeyScopes").([]string)
+		if isAPIKey && (m.scope == "" || !service.HasScope(scopes, m.scope)) {
+			handlers.ErrorResponse(w, http.StatusForbidden, "API密钥无权访问该接口")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,140 +1,471 @@
-package api
-
-import (
-	"context"
-	"net/http"
-
-	"chat-llama/config"
-	"chat-llama/internal/api/handlers"
-	"chat-llama/internal/api/middleware"
-	"chat-llama/internal/service"
-	"chat-llama/internal/storage"
-
-	"github.com/gin-gonic/gin"
-)
-
-// Router API路由器
-type Router struct {
-	engine      *gin.Engine
-	chatService *service.ChatService
-	userStorage *storage.UserStorage
-}
-
-// NewRouter 创建新路由器
-func NewRouter(chatService *service.ChatService, userStorage *storage.UserStorage) *Router {
-	// 设置Gin为发布模式
-	gin.SetMode(gin.ReleaseMode)
-	engine := gin.New()
-
-	return &Router{
-		engine:      engine,
-		chatService: chatService,
-		userStorage: userStorage,
-	}
-}
-
-// Setup 设置路由
-func (r *Router) Setup() http.Handler {
-	// 获取配置
-	cfg := config.GetConfig()
-
-	// 创建处理程序
-	userHandler := handlers.NewUserHandler(r.userStorage, cfg.Server.JWTSecret)
-	chatHandler := handlers.NewChatHandler(r.chatService)
-	wsHandler := handlers.NewWebSocketHandler(r.chatService)
-
-	// 创建中间件包装器
-	jwtMiddleware := func(c *gin.Context) {
-		middleware.NewJWTMiddleware(cfg.Server.JWTSecret).Middleware(
-			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				c.Request = r
-				c.Next()
-			}),
-		).ServeHTTP(c.Writer, c.Request)
-
-		if c.IsAborted() {
-			return
-		}
-	}
-
-	loggerMiddleware := func(c *gin.Context) {
-		middleware.NewLoggerMiddleware().Middleware(
-			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				c.Next()
-			}),
-		).ServeHTTP(c.Writer, c.Request)
-	}
-
-	corsMiddleware := func(c *gin.Context) {
-		middleware.NewCORSMiddleware().Middleware(
-			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				if c.Request.Method == "OPTIONS" {
-					c.AbortWithStatus(http.StatusOK)
-					return
-				}
-				c.Next()
-			}),
-		).ServeHTTP(c.Writer, c.Request)
-	}
-
-	// 应用全局中间件
-	r.engine.Use(loggerMiddleware)
-	r.engine.Use(corsMiddleware)
-	r.engine.Use(gin.Recovery()) // 添加Gin的Recovery中间件处理panic
-
-	// API路由组
-	api := r.engine.Group("/api")
-
-	// 无需认证的路由
-	auth := api.Group("/auth")
-	{
-		auth.POST("/register", gin.WrapF(userHandler.Register))
-		auth.POST("/login", gin.WrapF(userHandler.Login))
-	}
-
-	// 需要认证的API路由
-	protected := api.Group("")
-	protected.Use(jwtMiddleware)
-	{
-		// 用户相关路由
-		user := protected.Group("/user")
-		{
-			user.GET("/profile", gin.WrapF(userHandler.GetProfile))
-		}
-
-		// 聊天相关路由
-		protected.GET("/conversations", gin.WrapF(chatHandler.GetConversations))
-		protected.GET("/conversations/:id/messages", func(c *gin.Context) {
-			// 提取参数并设置到请求上下文
-			id := c.Param("id")
-			r := c.Request
-			ctx := context.WithValue(r.Context(), "conversationID", id)
-			c.Request = r.WithContext(ctx)
-
-			// 调用原始处理程序
-			chatHandler.GetConversationHistory(c.Writer, c.Request)
-		})
-		protected.DELETE("/conversations/:id", func(c *gin.Context) {
-			// 提取参数并设置到请求上下文
-			id := c.Param("id")
-			r := c.Request
-			ctx := context.WithValue(r.Context(), "id", id)
-			c.Request = r.WithContext(ctx)
-
-			// 调用原始处理程序
-			chatHandler.DeleteConversation(c.Writer, c.Request)
-		})
-		protected.PUT("/conversations/:id/title", gin.WrapF(chatHandler.UpdateConversationTitle))
-		protected.POST("/chat", gin.WrapF(chatHandler.Chat))
-
-		// WebSocket路由
-		protected.GET("/ws", gin.WrapF(wsHandler.HandleWebSocket))
-	}
-
-	return r.engine
-}
-
-// ServeHTTP 实现http.Handler接口
-func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	r.engine.ServeHTTP(w, req)
-}
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+
+	"chat-llama/config"
+	"chat-llama/internal/api/handlers"
+	"chat-llama/internal/api/middleware"
+	"chat-llama/internal/service"
+	"chat-llama/internal/storage"
+	"chat-llama/pkg/mail"
+	"chat-llama/pkg/metrics"
+	"chat-llama/pkg/password"
+	"chat-llama/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Router API路由器
+type Router struct {
+	engine            *gin.Engine
+	chatService       *service.ChatService
+	events            *service.EventHub
+	wsTickets         *service.WebSocketTicketService
+	userStorage       *storage.UserStorage
+	quotaService      *service.QuotaService
+	knowledgeService  *service.KnowledgeService
+	attachmentService *service.AttachmentService
+	moderationService *service.ModerationService
+	authService       *service.AuthService
+	apiKeyService     *service.APIKeyService
+	ssoService        *service.SSOService
+	orgService        *service.OrganizationService
+	mailSender        mail.Sender
+	loginGuard        *service.LoginGuard
+	audit             *service.AuditLog
+	limiter           *ratelimit.Limiter
+}
+
+// NewRouter 创建新路由器，limiter为nil时不限制请求频率，ssoService为nil时单点登录未启用，loginGuard为nil时不限制登录失败次数，knowledgeService、attachmentService、moderationService为nil时对应功能未启用
+func NewRouter(chatService *service.ChatService, events *service.EventHub, wsTickets *service.WebSocketTicketService, userStorage *storage.UserStorage, authService *service.AuthService, apiKeyService *service.APIKeyService, ssoService *service.SSOService, orgService *service.OrganizationService, quotaService *service.QuotaService, knowledgeService *service.KnowledgeService, attachmentService *service.AttachmentService, moderationService *service.ModerationService, mailSender mail.Sender, loginGuard *service.LoginGuard, audit *service.AuditLog, limiter *ratelimit.Limiter) *Router {
+	// 设置Gin为发布模式
+	gin.SetMode(gin.ReleaseMode)
+	engine := gin.New()
+
+	return &Router{
+		engine:            engine,
+		chatService:       chatService,
+		events:            events,
+		wsTickets:         wsTickets,
+		userStorage:       userStorage,
+		quotaService:      quotaService,
+		knowledgeService:  knowledgeService,
+		attachmentService: attachmentService,
+		moderationService: moderationService,
+		authService:       authService,
+		apiKeyService:     apiKeyService,
+		ssoService:        ssoService,
+		orgService:        orgService,
+		mailSender:        mailSender,
+		loginGuard:        loginGuard,
+		audit:             audit,
+		limiter:           limiter,
+	}
+}
+
+// Setup 设置路由
+func (r *Router) Setup() http.Handler {
+	// 获取配置
+	cfg := config.GetConfig()
+
+	handlers.TrustProxyHeaders = cfg.Server.TrustProxyHeaders
+
+	// 创建处理程序
+	userHandler := handlers.NewUserHandler(r.userStorage, r.authService, r.mailSender, r.loginGuard, r.audit, handlers.PasswordOptions{
+		Policy: password.Policy{
+			MinLength:        cfg.Auth.PasswordPolicy.MinLength,
+			RequireLetter:    cfg.Auth.PasswordPolicy.RequireLetter,
+			RequireDigit:     cfg.Auth.PasswordPolicy.RequireDigit,
+			RequireSymbol:    cfg.Auth.PasswordPolicy.RequireSymbol,
+			DisallowUsername: cfg.Auth.PasswordPolicy.DisallowUsername,
+		},
+		ResetTokenTTL: cfg.Auth.PasswordReset.TokenTTL,
+		ResetURL:      cfg.Auth.PasswordReset.URL,
+	})
+	chatHandler := handlers.NewChatHandler(r.chatService, r.userStorage, r.audit)
+	wsHandler := handlers.NewWebSocketHandler(r.chatService, r.events, r.wsTickets, r.limiter, handlers.WebSocketOptions{
+		AllowedOrigins:        cfg.Realtime.AllowedOrigins,
+		MaxConnectionsPerUser: cfg.Realtime.MaxConnectionsPerUser,
+	})
+	usageHandler := handlers.NewUsageHandler(r.chatService, r.quotaService, r.limiter)
+	adminHandler := handlers.NewAdminHandler(r.chatService, r.userStorage, r.authService, r.audit)
+	knowledgeHandler := handlers.NewKnowledgeHandler(r.knowledgeService, cfg.Knowledge.MaxUploadSize)
+	attachmentHandler := handlers.NewAttachmentHandler(r.attachmentService)
+	moderationHandler := handlers.NewModerationHandler(r.moderationService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(r.apiKeyService)
+	ssoHandler := handlers.NewSSOHandler(r.ssoService, r.audit, cfg.SSO.FrontendURL)
+	auditHandler := handlers.NewAuditHandler(r.audit)
+	orgHandler := handlers.NewOrgHandler(r.orgService, r.authService, r.quotaService, r.userStorage, r.audit)
+
+	// 创建中间件包装器，认证失败时中止后续处理程序
+	jwtMiddleware := func(c *gin.Context) {
+		passed := false
+		middleware.NewJWTMiddleware(r.authService, r.apiKeyService).Middleware(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				passed = true
+				c.Request = r
+				c.Next()
+			}),
+		).ServeHTTP(c.Writer, c.Request)
+
+		if !passed {
+			c.Abort()
+		}
+	}
+
+	// API密钥权限范围检查未通过时中止后续处理程序
+	requireScope := func(scope string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			passed := false
+			middleware.NewScopeMiddleware(scope).Middleware(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					passed = true
+					c.Next()
+				}),
+			).ServeHTTP(c.Writer, c.Request)
+
+			if !passed {
+				c.Abort()
+			}
+		}
+	}
+
+	// 超出限流时中止后续处理程序
+	rateLimitMiddleware := func(c *gin.Context) {
+		passed := false
+		middleware.NewRateLimitMiddleware(r.limiter).Middleware(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				passed = true
+				c.Next()
+			}),
+		).ServeHTTP(c.Writer, c.Request)
+
+		if !passed {
+			c.Abort()
+		}
+	}
+
+	// 权限检查未通过时中止后续处理程序
+	requirePermission := func(permission string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			passed := false
+			middleware.NewPermissionMiddleware(permission).Middleware(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					passed = true
+					c.Next()
+				}),
+			).ServeHTTP(c.Writer, c.Request)
+
+			if !passed {
+				c.Abort()
+			}
+		}
+	}
+
+	auditMiddleware := func(c *gin.Context) {
+		middleware.NewAuditMiddleware(r.audit).Middleware(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.Next()
+			}),
+		).ServeHTTP(c.Writer, c.Request)
+	}
+
+	requestIDMiddleware := func(c *gin.Context) {
+		middleware.NewRequestIDMiddleware().Middleware(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.Request = r
+				c.Next()
+			}),
+		).ServeHTTP(c.Writer, c.Request)
+	}
+
+	metricsMiddleware := func(c *gin.Context) {
+		middleware.NewMetricsMiddleware(c.FullPath()).Middleware(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.Next()
+			}),
+		).ServeHTTP(c.Writer, c.Request)
+	}
+
+	loggerMiddleware := func(c *gin.Context) {
+		middleware.NewLoggerMiddleware().Middleware(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.Next()
+			}),
+		).ServeHTTP(c.Writer, c.Request)
+	}
+
+	corsMiddleware := func(c *gin.Context) {
+		middleware.NewCORSMiddleware().Middleware(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if c.Request.Method == "OPTIONS" {
+					c.AbortWithStatus(http.StatusOK)
+					return
+				}
+				c.Next()
+			}),
+		).ServeHTTP(c.Writer, c.Request)
+	}
+
+	// 应用全局中间件
+	r.engine.Use(requestIDMiddleware)
+	r.engine.Use(metricsMiddleware)
+	r.engine.Use(loggerMiddleware)
+	r.engine.Use(corsMiddleware)
+	r.engine.Use(gin.Recovery()) // 添加Gin的Recovery中间件处理panic
+
+	// Prometheus指标
+	if cfg.Metrics.Enabled {
+		metricsHandler := metrics.Handler()
+		r.engine.GET("/metrics", func(c *gin.Context) {
+			if cfg.Metrics.Token != "" && subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte("Bearer "+cfg.Metrics.Token)) != 1 {
+				handlers.ErrorResponse(c.Writer, http.StatusUnauthorized, "无效的指标访问令牌")
+				c.Abort()
+				return
+			}
+			metricsHandler.ServeHTTP(c.Writer, c.Request)
+		})
+	}
+
+	// API路由组
+	api := r.engine.Group("/api")
+
+	// 无需认证的路由
+	auth := api.Group("/auth")
+	{
+		auth.POST("/register", gin.WrapF(userHandler.Register))
+		auth.POST("/login", gin.WrapF(userHandler.Login))
+		auth.POST("/refresh", gin.WrapF(userHandler.Refresh))
+		auth.POST("/logout", jwtMiddleware, requireScope(""), gin.WrapF(userHandler.Logout))
+		auth.POST("/password/forgot", gin.WrapF(userHandler.ForgotPassword))
+		auth.POST("/password/reset", gin.WrapF(userHandler.ResetPassword))
+
+		// 单点登录
+		auth.GET("/sso/providers", gin.WrapF(ssoHandler.ListProviders))
+		auth.GET("/sso/:provider/login", func(c *gin.Context) {
+			ctx := context.WithValue(c.Request.Context(), "provider", c.Param("provider"))
+			ssoHandler.Login(c.Writer, c.Request.WithContext(ctx))
+		})
+		auth.GET("/sso/:provider/callback", func(c *gin.Context) {
+			ctx := context.WithValue(c.Request.Context(), "provider", c.Param("provider"))
+			ssoHandler.Callback(c.Writer, c.Request.WithContext(ctx))
+		})
+	}
+
+	// WebSocket连接，使用 POST /api/ws/ticket 签发的一次性凭证认证
+	api.GET("/ws", gin.WrapF(wsHandler.HandleWebSocket))
+
+	// 需要认证的API路由
+	protected := api.Group("")
+	protected.Use(jwtMiddleware)
+	protected.Use(rateLimitMiddleware)
+	{
+		// 用户相关路由，只允许登录令牌访问
+		user := protected.Group("/user", requireScope(""))
+		{
+			user.GET("/profile", gin.WrapF(userHandler.GetProfile))
+			user.PUT("/password", gin.WrapF(userHandler.ChangePassword))
+			user.GET("/usage", gin.WrapF(usageHandler.GetUsage))
+			user.GET("/usage/history", gin.WrapF(usageHandler.GetUsageHistory))
+			user.GET("/sessions", gin.WrapF(userHandler.GetSessions))
+			user.DELETE("/sessions/:id", func(c *gin.Context) {
+				ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+				userHandler.RevokeSession(c.Writer, c.Request.WithContext(ctx))
+			})
+			user.GET("/api-keys", gin.WrapF(apiKeyHandler.ListAPIKeys))
+			user.POST("/api-keys", gin.WrapF(apiKeyHandler.CreateAPIKey))
+			user.DELETE("/api-keys/:id", func(c *gin.Context) {
+				ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+				apiKeyHandler.RevokeAPIKey(c.Writer, c.Request.WithContext(ctx))
+			})
+		}
+
+		// 组织相关路由，只允许登录令牌访问
+		orgs := protected.Group("/orgs", requireScope(""))
+		{
+			orgs.GET("", gin.WrapF(orgHandler.ListOrganizations))
+			orgs.POST("/switch", gin.WrapF(orgHandler.SwitchOrganization))
+			orgs.GET("/:id", func(c *gin.Context) {
+				ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+				orgHandler.GetOrganization(c.Writer, c.Request.WithContext(ctx))
+			})
+			orgs.PUT("/:id/settings", func(c *gin.Context) {
+				ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+				orgHandler.UpdateSettings(c.Writer, c.Request.WithContext(ctx))
+			})
+			orgs.GET("/:id/usage", func(c *gin.Context) {
+				ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+				orgHandler.GetUsage(c.Writer, c.Request.WithContext(ctx))
+			})
+			orgs.GET("/:id/members", func(c *gin.Context) {
+				ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+				orgHandler.ListMembers(c.Writer, c.Request.WithContext(ctx))
+			})
+			orgs.POST("/:id/members", func(c *gin.Context) {
+				ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+				orgHandler.AddMember(c.Writer, c.Request.WithContext(ctx))
+			})
+			orgs.PUT("/:id/members/:userID", func(c *gin.Context) {
+				ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+				ctx = context.WithValue(ctx, "memberID", c.Param("userID"))
+				orgHandler.UpdateMemberRole(c.Writer, c.Request.WithContext(ctx))
+			})
+			orgs.DELETE("/:id/members/:userID", func(c *gin.Context) {
+				ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+				ctx = context.WithValue(ctx, "memberID", c.Param("userID"))
+				orgHandler.RemoveMember(c.Writer, c.Request.WithContext(ctx))
+			})
+		}
+
+		// 聊天相关路由
+		protected.GET("/conversations", requireScope(service.ScopeReadHistory), gin.WrapF(chatHandler.GetConversations))
+		protected.GET("/conversations/:id/messages", requireScope(service.ScopeReadHistory), func(c *gin.Context) {
+			// 提取参数并设置到请求上下文
+			id := c.Param("id")
+			r := c.Request
+			ctx := context.WithValue(r.Context(), "conversationID", id)
+			c.Request = r.WithContext(ctx)
+
+			// 调用原始处理程序
+			chatHandler.GetConversationHistory(c.Writer, c.Request)
+		})
+		protected.DELETE("/conversations/:id", requireScope(service.ScopeChat), func(c *gin.Context) {
+			// 提取参数并设置到请求上下文
+			id := c.Param("id")
+			r := c.Request
+			ctx := context.WithValue(r.Context(), "id", id)
+			c.Request = r.WithContext(ctx)
+
+			// 调用原始处理程序
+			chatHandler.DeleteConversation(c.Writer, c.Request)
+		})
+		protected.GET("/conversations/:id/export", requireScope(service.ScopeReadHistory), func(c *gin.Context) {
+			ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+			chatHandler.ExportConversation(c.Writer, c.Request.WithContext(ctx))
+		})
+		protected.PUT("/conversations/:id/title", requireScope(service.ScopeChat), func(c *gin.Context) {
+			ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+			chatHandler.UpdateConversationTitle(c.Writer, c.Request.WithContext(ctx))
+		})
+		protected.PUT("/conversations/:id/tools", requireScope(service.ScopeChat), func(c *gin.Context) {
+			ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+			chatHandler.UpdateConversationTools(c.Writer, c.Request.WithContext(ctx))
+		})
+		protected.GET("/conversations/:id/members", requireScope(service.ScopeReadHistory), func(c *gin.Context) {
+			ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+			chatHandler.ListConversationMembers(c.Writer, c.Request.WithContext(ctx))
+		})
+		protected.POST("/conversations/:id/members", requireScope(service.ScopeChat), func(c *gin.Context) {
+			ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+			chatHandler.ShareConversation(c.Writer, c.Request.WithContext(ctx))
+		})
+		protected.PUT("/conversations/:id/members/:userID", requireScope(service.ScopeChat), func(c *gin.Context) {
+			ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+			ctx = context.WithValue(ctx, "memberID", c.Param("userID"))
+			chatHandler.UpdateConversationMember(c.Writer, c.Request.WithContext(ctx))
+		})
+		protected.DELETE("/conversations/:id/members/:userID", requireScope(service.ScopeChat), func(c *gin.Context) {
+			ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+			ctx = context.WithValue(ctx, "memberID", c.Param("userID"))
+			chatHandler.RemoveConversationMember(c.Writer, c.Request.WithContext(ctx))
+		})
+		protected.POST("/chat", requireScope(service.ScopeChat), gin.WrapF(chatHandler.Chat))
+		protected.GET("/tools", requireScope(service.ScopeReadHistory), gin.WrapF(chatHandler.GetTools))
+
+		// 附件相关路由
+		protected.POST("/attachments", requireScope(service.ScopeChat), gin.WrapF(attachmentHandler.UploadAttachment))
+		protected.GET("/attachments/:id", requireScope(service.ScopeChat), func(c *gin.Context) {
+			ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+			attachmentHandler.GetAttachment(c.Writer, c.Request.WithContext(ctx))
+		})
+		protected.GET("/attachments/:id/content", requireScope(service.ScopeChat), func(c *gin.Context) {
+			ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+			attachmentHandler.DownloadAttachment(c.Writer, c.Request.WithContext(ctx))
+		})
+		protected.DELETE("/attachments/:id", requireScope(service.ScopeChat), func(c *gin.Context) {
+			ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+			attachmentHandler.DeleteAttachment(c.Writer, c.Request.WithContext(ctx))
+		})
+
+		// WebSocket连接凭证，连接本身使用凭证认证
+		protected.POST("/ws/ticket", requireScope(service.ScopeChat), gin.WrapF(wsHandler.IssueTicket))
+
+		// 管理相关路由，每个请求都记录审计事件
+		admin := protected.Group("/admin")
+		admin.Use(auditMiddleware, requireScope(service.ScopeAdmin))
+		{
+			cacheAdmin := admin.Group("/semantic-cache", requirePermission(service.PermissionManageCache))
+			cacheAdmin.GET("", gin.WrapF(adminHandler.ListSemanticCache))
+			cacheAdmin.DELETE("", gin.WrapF(adminHandler.ClearSemanticCache))
+			cacheAdmin.DELETE("/:id", func(c *gin.Context) {
+				// 提取参数并设置到请求上下文
+				ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+				adminHandler.EvictSemanticCacheEntry(c.Writer, c.Request.WithContext(ctx))
+			})
+			admin.GET("/response-cache/stats", requirePermission(service.PermissionManageCache), gin.WrapF(adminHandler.GetResponseCacheStats))
+
+			// 知识库管理
+			knowledgeAdmin := admin.Group("/knowledge", requirePermission(service.PermissionManageKnowledge))
+			knowledgeAdmin.GET("/documents", gin.WrapF(knowledgeHandler.ListDocuments))
+			knowledgeAdmin.POST("/documents", gin.WrapF(knowledgeHandler.UploadDocument))
+			knowledgeAdmin.GET("/documents/:id", func(c *gin.Context) {
+				ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+				knowledgeHandler.GetDocument(c.Writer, c.Request.WithContext(ctx))
+			})
+			knowledgeAdmin.DELETE("/documents/:id", func(c *gin.Context) {
+				ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+				knowledgeHandler.DeleteDocument(c.Writer, c.Request.WithContext(ctx))
+			})
+
+			// 内容审核队列
+			moderationAdmin := admin.Group("/moderation", requirePermission(service.PermissionReviewModeration))
+			moderationAdmin.GET("/queue", gin.WrapF(moderationHandler.ListQueue))
+			moderationAdmin.POST("/messages/:id/review", func(c *gin.Context) {
+				ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+				moderationHandler.ReviewMessage(c.Writer, c.Request.WithContext(ctx))
+			})
+
+			// 滥用审查
+			admin.GET("/conversations/:id", requirePermission(service.PermissionReviewConversations), func(c *gin.Context) {
+				ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+				adminHandler.GetConversation(c.Writer, c.Request.WithContext(ctx))
+			})
+
+			// 审计日志
+			auditAdmin := admin.Group("/audit-events", requirePermission(service.PermissionViewAudit))
+			auditAdmin.GET("", gin.WrapF(auditHandler.ListEvents))
+			auditAdmin.GET("/export", gin.WrapF(auditHandler.ExportEvents))
+
+			// 组织管理
+			orgAdmin := admin.Group("/organizations", requirePermission(service.PermissionManageOrganizations))
+			orgAdmin.GET("", gin.WrapF(orgHandler.AdminListOrganizations))
+			orgAdmin.POST("", gin.WrapF(orgHandler.AdminCreateOrganization))
+
+			// 用户管理
+			userAdmin := admin.Group("/users", requirePermission(service.PermissionManageUsers))
+			userAdmin.GET("", gin.WrapF(adminHandler.ListUsers))
+			userAdmin.PUT("/:id/role", func(c *gin.Context) {
+				ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+				adminHandler.UpdateUserRole(c.Writer, c.Request.WithContext(ctx))
+			})
+			userAdmin.PUT("/:id/status", func(c *gin.Context) {
+				ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+				adminHandler.UpdateUserStatus(c.Writer, c.Request.WithContext(ctx))
+			})
+			userAdmin.POST("/:id/password-reset", func(c *gin.Context) {
+				ctx := context.WithValue(c.Request.Context(), "id", c.Param("id"))
+				userHandler.AdminResetPassword(c.Writer, c.Request.WithContext(ctx))
+			})
+		}
+	}
+
+	return r.engine
+}
+
+// ServeHTTP 实现http.Handler接口
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.engine.ServeHTTP(w, req)
+}
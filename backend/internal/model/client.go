@@ -1,72 +1,173 @@
-package model
-
-import (
-	"context"
-	"fmt"
-	"log"
-	"time"
-
-	pb "chat-llama/internal/model/proto"
-
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
-)
-
-// LLMClient 封装了与 LLM 服务通信的客户端
-type LLMClient struct {
-	client pb.LLMServiceClient
-	conn   *grpc.ClientConn
-}
-
-// NewLLMClient 创建一个新的 LLM 客户端
-// serverAddr 格式为 "host:port"，例如 "localhost:50051"
-func NewLLMClient(serverAddr string) (*LLMClient, error) {
-	// 创建到服务器的连接
-	conn, err := grpc.NewClient(serverAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		return nil, fmt.Errorf("无法连接到 LLM 服务: %v", err)
-	}
-
-	// 创建 gRPC 客户端
-	client := pb.NewLLMServiceClient(conn)
-	return &LLMClient{
-		client: client,
-		conn:   conn,
-	}, nil
-}
-
-// Close 关闭 gRPC 连接
-func (c *LLMClient) Close() error {
-	if c.conn != nil {
-		return c.conn.Close()
-	}
-	return nil
-}
-
-// GenerateResponse 调用 LLM 服务生成响应
-func (c *LLMClient) GenerateResponse(ctx context.Context, prompt string, temperature float32, maxNewTokens int32, topK int32) (string, error) {
-	// 创建带超时的上下文
-	timeoutCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
-	// 创建请求
-	req := &pb.GenerateRequest{
-		Prompt:       prompt,
-		Temperature:  temperature,
-		MaxNewTokens: maxNewTokens,
-		TopK:         topK,
-	}
-
-	// 调用 gRPC 服务
-	log.Printf("向 LLM 服务发送请求：prompt=%s, temperature=%.2f, maxNewTokens=%d, topK=%d",
-		prompt, temperature, maxNewTokens, topK)
-
-	resp, err := c.client.Generate(timeoutCtx, req)
-	if err != nil {
-		log.Printf("调用 Generate 时出错: %v", err)
-		return "", err
-	}
-
-	log.Printf("收到 LLM 服务响应：%s", resp.Response)
-	return resp.Response, nil
-}
+package model
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	pb "chat-llama/internal/model/proto"
+	"chat-llama/pkg/metrics"
+	"chat-llama/pkg/pii"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// llmRequestDuration LLM 服务调用耗时，按方法和结果统计
+var llmRequestDuration = metrics.NewHistogram("chat_llama_llm_request_duration_seconds",
+	"LLM服务调用耗时", []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30}, "method", "status")
+
+// observeRequest 记录一次 LLM 服务调用的耗时
+func observeRequest(method string, start time.Time, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	llmRequestDuration.Observe(time.Since(start).Seconds(), method, status)
+}
+
+// LLMClient 封装了与 LLM 服务通信的客户端
+type LLMClient struct {
+	client pb.LLMServiceClient
+	conn   *grpc.ClientConn
+}
+
+// NewLLMClient 创建一个新的 LLM 客户端
+// serverAddr 格式为 "host:port"，例如 "localhost:50051"
+func NewLLMClient(serverAddr string) (*LLMClient, error) {
+	// 创建到服务器的连接
+	conn, err := grpc.NewClient(serverAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("无法连接到 LLM 服务: %v", err)
+	}
+
+	// 创建 gRPC 客户端
+	client := pb.NewLLMServiceClient(conn)
+	return &LLMClient{
+		client: client,
+		conn:   conn,
+	}, nil
+}
+
+// Close 关闭 gRPC 连接
+func (c *LLMClient) Close() error {
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
+
+// GenerateResult 模型生成结果及用量信息
+type GenerateResult struct {
+	Response         string
+	PromptTokens     int32
+	CompletionTokens int32
+	Latency          time.Duration
+	Model            string
+}
+
+// GenerateResponse 调用 LLM 服务生成响应
+func (c *LLMClient) GenerateResponse(ctx context.Context, prompt string, temperature float32, maxNewTokens int32, topK int32) (*GenerateResult, error) {
+	// 创建带超时的上下文
+	timeoutCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// 创建请求
+	req := &pb.GenerateRequest{
+		Prompt:       prompt,
+		Temperature:  temperature,
+		MaxNewTokens: maxNewTokens,
+		TopK:         topK,
+	}
+
+	// 调用 gRPC 服务
+	log.Printf("向 LLM 服务发送请求：prompt=%s, temperature=%.2f, maxNewTokens=%d, topK=%d",
+		pii.ForLog(prompt), temperature, maxNewTokens, topK)
+
+	start := time.Now()
+	resp, err := c.client.Generate(timeoutCtx, req)
+	observeRequest("generate", start, err)
+	if err != nil {
+		log.Printf("调用 Generate 时出错: %v", err)
+		return nil, err
+	}
+
+	// 旧版本模型服务不返回耗时，使用客户端测量值
+	latency := time.Duration(resp.LatencyMs) * time.Millisecond
+	if latency == 0 {
+		latency = time.Since(start)
+	}
+
+	log.Printf("收到 LLM 服务响应：%s (prompt_tokens=%d, completion_tokens=%d, latency=%v)",
+		pii.ForLog(resp.Response), resp.PromptTokens, resp.CompletionTokens, latency)
+
+	return &GenerateResult{
+		Response:         resp.Response,
+		PromptTokens:     resp.PromptTokens,
+		CompletionTokens: resp.CompletionTokens,
+		Latency:          latency,
+		Model:            resp.Model,
+	}, nil
+}
+
+// Embed 调用 LLM 服务计算文本向量，返回的向量与 texts 一一对应
+func (c *LLMClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	// 创建带超时的上下文
+	timeoutCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := c.client.Embed(timeoutCtx, &pb.EmbedRequest{Texts: texts})
+	observeRequest("embed", start, err)
+	if err != nil {
+		log.Printf("调用 Embed 时出错: %v", err)
+		return nil, err
+	}
+
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("向量数量不匹配: 期望 %d, 实际 %d", len(texts), len(resp.Embeddings))
+	}
+
+	vectors := make([][]float32, len(resp.Embeddings))
+	for i, embedding := range resp.Embeddings {
+		vectors[i] = embedding.Values
+	}
+
+	return vectors, nil
+}
+
+// ClassifyLabel 内容安全分类结果
+type ClassifyLabel struct {
+	Label string
+	Score float32
+}
+
+// Classify 调用 LLM 服务对文本做内容安全分类
+func (c *LLMClient) Classify(ctx context.Context, text string) ([]ClassifyLabel, error) {
+	// 创建带超时的上下文
+	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := c.client.Classify(timeoutCtx, &pb.ClassifyRequest{Text: text})
+	observeRequest("classify", start, err)
+	if err != nil {
+		log.Printf("调用 Classify 时出错: %v", err)
+		return nil, err
+	}
+
+	labels := make([]ClassifyLabel, len(resp.Labels))
+	for i, label := range resp.Labels {
+		labels[i] = ClassifyLabel{
+			Label: label.Label,
+			Score: label.Score,
+		}
+	}
+
+	return labels, nil
+}
@@ -91,10 +91,14 @@ func (x *GenerateRequest) GetTopK() int32 {
 }
 
 type GenerateResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Response      string                 `protobuf:"bytes,1,opt,name=response,proto3" json:"response,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Response         string                 `protobuf:"bytes,1,opt,name=response,proto3" json:"response,omitempty"`
+	PromptTokens     int32                  `protobuf:"varint,2,opt,name=prompt_tokens,json=promptTokens,proto3" json:"prompt_tokens,omitempty"`             // 提示词token数
+	CompletionTokens int32                  `protobuf:"varint,3,opt,name=completion_tokens,json=completionTokens,proto3" json:"completion_tokens,omitempty"` // 生成内容token数
+	LatencyMs        int64                  `protobuf:"varint,4,opt,name=latency_ms,json=latencyMs,proto3" json:"latency_ms,omitempty"`                      // 生成耗时(毫秒)
+	Model            string                 `protobuf:"bytes,5,opt,name=model,proto3" json:"model,omitempty"`                                                // 生成所用的模型名称
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
 }
 
 func (x *GenerateResponse) Reset() {
@@ -134,6 +138,330 @@ func (x *GenerateResponse) GetResponse() string {
 	return ""
 }
 
+func (x *GenerateResponse) GetPromptTokens() int32 {
+	if x != nil {
+		return x.PromptTokens
+	}
+	return 0
+}
+
+func (x *GenerateResponse) GetCompletionTokens() int32 {
+	if x != nil {
+		return x.CompletionTokens
+	}
+	return 0
+}
+
+func (x *GenerateResponse) GetLatencyMs() int64 {
+	if x != nil {
+		return x.LatencyMs
+	}
+	return 0
+}
+
+func (x *GenerateResponse) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+type EmbedRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Texts         []string               `protobuf:"bytes,1,rep,name=texts,proto3" json:"texts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EmbedRequest) Reset() {
+	*x = EmbedRequest{}
+	mi := &file_llm_service_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EmbedRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EmbedRequest) ProtoMessage() {}
+
+func (x *EmbedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_llm_service_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EmbedRequest.ProtoReflect.Descriptor instead.
+func (*EmbedRequest) Descriptor() ([]byte, []int) {
+	return file_llm_service_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *EmbedRequest) GetTexts() []string {
+	if x != nil {
+		return x.Texts
+	}
+	return nil
+}
+
+type Embedding struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Values        []float32              `protobuf:"fixed32,1,rep,packed,name=values,proto3" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Embedding) Reset() {
+	*x = Embedding{}
+	mi := &file_llm_service_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Embedding) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Embedding) ProtoMessage() {}
+
+func (x *Embedding) ProtoReflect() protoreflect.Message {
+	mi := &file_llm_service_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Embedding.ProtoReflect.Descriptor instead.
+func (*Embedding) Descriptor() ([]byte, []int) {
+	return file_llm_service_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Embedding) GetValues() []float32 {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type EmbedResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Embeddings    []*Embedding           `protobuf:"bytes,1,rep,name=embeddings,proto3" json:"embeddings,omitempty"` // 与请求中的texts一一对应，已归一化
+	Dimension     int32                  `protobuf:"varint,2,opt,name=dimension,proto3" json:"dimension,omitempty"`
+	Model         string                 `protobuf:"bytes,3,opt,name=model,proto3" json:"model,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EmbedResponse) Reset() {
+	*x = EmbedResponse{}
+	mi := &file_llm_service_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EmbedResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EmbedResponse) ProtoMessage() {}
+
+func (x *EmbedResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_llm_service_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EmbedResponse.ProtoReflect.Descriptor instead.
+func (*EmbedResponse) Descriptor() ([]byte, []int) {
+	return file_llm_service_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *EmbedResponse) GetEmbeddings() []*Embedding {
+	if x != nil {
+		return x.Embeddings
+	}
+	return nil
+}
+
+func (x *EmbedResponse) GetDimension() int32 {
+	if x != nil {
+		return x.Dimension
+	}
+	return 0
+}
+
+func (x *EmbedResponse) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+type ClassifyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Text          string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClassifyRequest) Reset() {
+	*x = ClassifyRequest{}
+	mi := &file_llm_service_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClassifyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClassifyRequest) ProtoMessage() {}
+
+func (x *ClassifyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_llm_service_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClassifyRequest.ProtoReflect.Descriptor instead.
+func (*ClassifyRequest) Descriptor() ([]byte, []int) {
+	return file_llm_service_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ClassifyRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+type ClassifyLabel struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Label         string                 `protobuf:"bytes,1,opt,name=label,proto3" json:"label,omitempty"`   // 内容类别，如 self_harm、violence
+	Score         float32                `protobuf:"fixed32,2,opt,name=score,proto3" json:"score,omitempty"` // 置信度，0到1之间
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClassifyLabel) Reset() {
+	*x = ClassifyLabel{}
+	mi := &file_llm_service_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClassifyLabel) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClassifyLabel) ProtoMessage() {}
+
+func (x *ClassifyLabel) ProtoReflect() protoreflect.Message {
+	mi := &file_llm_service_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClassifyLabel.ProtoReflect.Descriptor instead.
+func (*ClassifyLabel) Descriptor() ([]byte, []int) {
+	return file_llm_service_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ClassifyLabel) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *ClassifyLabel) GetScore() float32 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+type ClassifyResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Labels        []*ClassifyLabel       `protobuf:"bytes,1,rep,name=labels,proto3" json:"labels,omitempty"`
+	Model         string                 `protobuf:"bytes,2,opt,name=model,proto3" json:"model,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClassifyResponse) Reset() {
+	*x = ClassifyResponse{}
+	mi := &file_llm_service_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClassifyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClassifyResponse) ProtoMessage() {}
+
+func (x *ClassifyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_llm_service_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClassifyResponse.ProtoReflect.Descriptor instead.
+func (*ClassifyResponse) Descriptor() ([]byte, []int) {
+	return file_llm_service_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ClassifyResponse) GetLabels() []*ClassifyLabel {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *ClassifyResponse) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
 var File_llm_service_proto protoreflect.FileDescriptor
 
 const file_llm_service_proto_rawDesc = "" +
@@ -143,12 +471,37 @@ const file_llm_service_proto_rawDesc = "" +
 	"\x06prompt\x18\x01 \x01(\tR\x06prompt\x12 \n" +
 	"\vtemperature\x18\x02 \x01(\x02R\vtemperature\x12$\n" +
 	"\x0emax_new_tokens\x18\x03 \x01(\x05R\fmaxNewTokens\x12\x13\n" +
-	"\x05top_k\x18\x04 \x01(\x05R\x04topK\".\n" +
+	"\x05top_k\x18\x04 \x01(\x05R\x04topK\"\xb5\x01\n" +
 	"\x10GenerateResponse\x12\x1a\n" +
-	"\bresponse\x18\x01 \x01(\tR\bresponse2G\n" +
+	"\bresponse\x18\x01 \x01(\tR\bresponse\x12#\n" +
+	"\rprompt_tokens\x18\x02 \x01(\x05R\fpromptTokens\x12+\n" +
+	"\x11completion_tokens\x18\x03 \x01(\x05R\x10completionTokens\x12\x1d\n" +
+	"\n" +
+	"latency_ms\x18\x04 \x01(\x03R\tlatencyMs\x12\x14\n" +
+	"\x05model\x18\x05 \x01(\tR\x05model\"$\n" +
+	"\fEmbedRequest\x12\x14\n" +
+	"\x05texts\x18\x01 \x03(\tR\x05texts\"#\n" +
+	"\tEmbedding\x12\x16\n" +
+	"\x06values\x18\x01 \x03(\x02R\x06values\"s\n" +
+	"\rEmbedResponse\x12.\n" +
+	"\n" +
+	"embeddings\x18\x01 \x03(\v2\x0e.llm.EmbeddingR\n" +
+	"embeddings\x12\x1c\n" +
+	"\tdimension\x18\x02 \x01(\x05R\tdimension\x12\x14\n" +
+	"\x05model\x18\x03 \x01(\tR\x05model\"%\n" +
+	"\x0fClassifyRequest\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\";\n" +
+	"\rClassifyLabel\x12\x14\n" +
+	"\x05label\x18\x01 \x01(\tR\x05label\x12\x14\n" +
+	"\x05score\x18\x02 \x01(\x02R\x05score\"T\n" +
+	"\x10ClassifyResponse\x12*\n" +
+	"\x06labels\x18\x01 \x03(\v2\x12.llm.ClassifyLabelR\x06labels\x12\x14\n" +
+	"\x05model\x18\x02 \x01(\tR\x05model2\xb4\x01\n" +
 	"\n" +
 	"LLMService\x129\n" +
-	"\bGenerate\x12\x14.llm.GenerateRequest\x1a\x15.llm.GenerateResponse\"\x00B\x1eZ\x1cbackend/internal/model/protob\x06proto3"
+	"\bGenerate\x12\x14.llm.GenerateRequest\x1a\x15.llm.GenerateResponse\"\x00\x120\n" +
+	"\x05Embed\x12\x11.llm.EmbedRequest\x1a\x12.llm.EmbedResponse\"\x00\x129\n" +
+	"\bClassify\x12\x14.llm.ClassifyRequest\x1a\x15.llm.ClassifyResponse\"\x00B\x1eZ\x1cbackend/internal/model/protob\x06proto3"
 
 var (
 	file_llm_service_proto_rawDescOnce sync.Once
@@ -162,19 +515,31 @@ func file_llm_service_proto_rawDescGZIP() []byte {
 	return file_llm_service_proto_rawDescData
 }
 
-var file_llm_service_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_llm_service_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
 var file_llm_service_proto_goTypes = []any{
 	(*GenerateRequest)(nil),  // 0: llm.GenerateRequest
 	(*GenerateResponse)(nil), // 1: llm.GenerateResponse
+	(*EmbedRequest)(nil),     // 2: llm.EmbedRequest
+	(*Embedding)(nil),        // 3: llm.Embedding
+	(*EmbedResponse)(nil),    // 4: llm.EmbedResponse
+	(*ClassifyRequest)(nil),  // 5: llm.ClassifyRequest
+	(*ClassifyLabel)(nil),    // 6: llm.ClassifyLabel
+	(*ClassifyResponse)(nil), // 7: llm.ClassifyResponse
 }
 var file_llm_service_proto_depIdxs = []int32{
-	0, // 0: llm.LLMService.Generate:input_type -> llm.GenerateRequest
-	1, // 1: llm.LLMService.Generate:output_type -> llm.GenerateResponse
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	3, // 0: llm.EmbedResponse.embeddings:type_name -> llm.Embedding
+	6, // 1: llm.ClassifyResponse.labels:type_name -> llm.ClassifyLabel
+	0, // 2: llm.LLMService.Generate:input_type -> llm.GenerateRequest
+	2, // 3: llm.LLMService.Embed:input_type -> llm.EmbedRequest
+	5, // 4: llm.LLMService.Classify:input_type -> llm.ClassifyRequest
+	1, // 5: llm.LLMService.Generate:output_type -> llm.GenerateResponse
+	4, // 6: llm.LLMService.Embed:output_type -> llm.EmbedResponse
+	7, // 7: llm.LLMService.Classify:output_type -> llm.ClassifyResponse
+	5, // [5:8] is the sub-list for method output_type
+	2, // [2:5] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
 }
 
 func init() { file_llm_service_proto_init() }
@@ -188,7 +553,7 @@ func file_llm_service_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_llm_service_proto_rawDesc), len(file_llm_service_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   8,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyPrefix API密钥的固定前缀，用于和JWT区分
+const APIKeyPrefix = "sk-"
+
+// API密钥的权限范围
+const (
+	ScopeChat        = "chat"         // 发起对话、上传附件、修改会话
+	ScopeReadHistory = "read-history" // 读取会话及消息
+	ScopeAdmin       = "admin"        // 访问管理接口，仍受用户角色的权限限制
+)
+
+var (
+	// ErrInvalidAPIKey API密钥无效、过期或已撤销
+	ErrInvalidAPIKey = errors.New("无效或过期的API密钥")
+	// ErrInvalidAPIKeySettings 创建密钥的参数无效
+	ErrInvalidAPIKeySettings = errors.New("无效的API密钥设置")
+	// ErrAPIKeyNotFound 密钥不存在或不属于当前用户
+	ErrAPIKeyNotFound = errors.New("API密钥不存在")
+)
+
+// validScopes 支持的权限范围
+var validScopes = map[string]bool{
+	ScopeChat:        true,
+	ScopeReadHistory: true,
+	ScopeAdmin:       true,
+}
+
+// HasScope 检查权限范围列表是否包含scope
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyService 管理和验证API密钥
+type APIKeyService struct {
+	storage  APIKeyStorage
+	access   AccessProvider
+	members  OrgMembershipProvider
+	lastUsed sync.Map // 密钥ID到最近一次写入使用时间的时刻
+}
+
+// NewAPIKeyService 创建API密钥服务，members用于检查密钥所属组织的成员身份
+func NewAPIKeyService(storage APIKeyStorage, access AccessProvider, members OrgMembershipProvider) *APIKeyService {
+	return &APIKeyService{
+		storage: storage,
+		access:  access,
+		members: members,
+	}
+}
+
+// Create 在用户当前所在的组织中创建API密钥，返回的明文密钥只在创建时返回一次。expiresAt为nil时永不过期
+func (s *APIKeyService) Create(userID, orgID uint, name string, scopes []string, expiresAt *time.Time) (*APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > 100 {
+		return nil, "", fmt.Errorf("%w: 名称不能为空且不超过100个字符", ErrInvalidAPIKeySettings)
+	}
+	if len(scopes) == 0 {
+		return nil, "", fmt.Errorf("%w: 至少需要一个权限范围", ErrInvalidAPIKeySettings)
+	}
+
+	unique := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !validScopes[scope] {
+			return nil, "", fmt.Errorf("%w: 不支持的权限范围 %s", ErrInvalidAPIKeySettings, scope)
+		}
+		if !HasScope(unique, scope) {
+			unique = append(unique, scope)
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", fmt.Errorf("%w: 过期时间必须晚于当前时间", ErrInvalidAPIKeySettings)
+	}
+
+	// 普通用户没有任何管理权限，不能创建admin范围的密钥
+	if HasScope(unique, ScopeAdmin) {
+		access, err := s.access.GetUserAccess(userID)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(rolePermissions[access.Role]) == 0 {
+			return nil, "", fmt.Errorf("%w: 当前角色不能使用admin权限范围", ErrInvalidAPIKeySettings)
+		}
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", err
+	}
+	secret := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	key := &APIKey{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    secret[:len(APIKeyPrefix)+6],
+		KeyHash:   hashToken(secret),
+		Scopes:    unique,
+		OrgID:     orgID,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+	if err := s.storage.CreateAPIKey(key); err != nil {
+		return nil, "", err
+	}
+
+	return key, secret, nil
+}
+
+// Authenticate 验证API密钥，返回密钥及所属用户当前的角色
+func (s *APIKeyService) Authenticate(ctx context.Context, secret string) (*APIKey, string, error) {
+	if !strings.HasPrefix(secret, APIKeyPrefix) {
+		return nil, "", ErrInvalidAPIKey
+	}
+
+	key, err := s.storage.GetAPIKeyByHash(hashToken(secret))
+	if err != nil || key.RevokedAt != nil {
+		return nil, "", ErrInvalidAPIKey
+	}
+	if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+		return nil, "", ErrInvalidAPIKey
+	}
+
+	access, err := s.access.GetUserAccess(key.UserID)
+	if err != nil {
+		return nil, "", err
+	}
+	if access.Disabled {
+		return nil, "", ErrAccountDisabled
+	}
+
+	// 用户离开组织后，在该组织中创建的密钥随之失效
+	if key.OrgID != 0 {
+		if s.members == nil {
+			return nil, "", ErrInvalidAPIKey
+		}
+		if _, err := s.members.GetMembership(key.OrgID, key.UserID); err != nil {
+			return nil, "", ErrInvalidAPIKey
+		}
+	}
+
+	s.touch(key.ID)
+
+	return key, access.Role, nil
+}
+
+// List 获取用户未撤销的API密钥
+func (s *APIKeyService) List(userID uint) ([]*APIKey, error) {
+	return s.storage.ListUserAPIKeys(userID)
+}
+
+// Revoke 撤销用户的API密钥
+func (s *APIKeyService) Revoke(userID uint, id string) error {
+	if err := s.storage.RevokeAPIKey(userID, id); err != nil {
+		return ErrAPIKeyNotFound
+	}
+	s.lastUsed.Delete(id)
+	return nil
+}
+
+// touch 记录密钥的使用时间，同一密钥在sessionTouchInterval内只写入一次
+func (s *APIKeyService) touch(id string) {
+	now := time.Now()
+	if last, ok := s.lastUsed.Load(id); ok && now.Sub(last.(time.Time)) < sessionTouchInterval {
+		return
+	}
+	s.lastUsed.Store(id, now)
+
+	if err := s.storage.TouchAPIKey(id, now); err != nil {
+		log.Printf("更新API密钥使用时间失败: %v", err)
+	}
+}
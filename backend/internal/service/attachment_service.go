@@ -0,0 +1,372 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"chat-llama/pkg/blobstore"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidAttachment 附件不存在、不属于当前用户或已随其他消息发送
+	ErrInvalidAttachment = errors.New("无效的附件")
+	// ErrUnsupportedAttachment 无法从附件中提取文本
+	ErrUnsupportedAttachment = errors.New("不支持的附件类型，仅支持纯文本、Markdown和CSV")
+	// ErrAttachmentTooLarge 附件超过大小限制
+	ErrAttachmentTooLarge = errors.New("附件过大")
+)
+
+// 可提取文本的附件格式
+const (
+	attachmentFormatText     = "text"
+	attachmentFormatMarkdown = "markdown"
+	attachmentFormatCSV      = "csv"
+)
+
+// attachmentMediaTypes 下载附件时按格式返回的内容类型，不使用上传时客户端提供的值
+var attachmentMediaTypes = map[string]string{
+	attachmentFormatText:     "text/plain; charset=utf-8",
+	attachmentFormatMarkdown: "text/markdown; charset=utf-8",
+	attachmentFormatCSV:      "text/csv; charset=utf-8",
+}
+
+// maxAttachmentsPerMessage 每条消息最多携带的附件数量
+const maxAttachmentsPerMessage = 5
+
+// AttachmentOptions 附件大小与提示词预算
+type AttachmentOptions struct {
+	MaxFileSize    int64
+	MaxPromptChars int // 提示词中附件内容的总字符数，超出部分截断
+}
+
+// AttachmentService 管理聊天附件的上传、存储与文本提取
+type AttachmentService struct {
+	storage AttachmentStorage
+	blobs   blobstore.Store
+	options AttachmentOptions
+}
+
+// NewAttachmentService 创建附件服务
+func NewAttachmentService(storage AttachmentStorage, blobs blobstore.Store, options AttachmentOptions) *AttachmentService {
+	if options.MaxFileSize <= 0 {
+		options.MaxFileSize = 2 << 20
+	}
+	if options.MaxPromptChars <= 0 {
+		options.MaxPromptChars = 4000
+	}
+
+	return &AttachmentService{
+		storage: storage,
+		blobs:   blobs,
+		options: options,
+	}
+}
+
+// MaxFileSize 获取单个附件的大小上限
+func (a *AttachmentService) MaxFileSize() int64 {
+	return a.options.MaxFileSize
+}
+
+// Upload 保存上传的附件并提取文本，附件在随消息发送前不属于任何会话
+func (a *AttachmentService) Upload(ctx context.Context, userID uint, filename, contentType string, data []byte) (*Attachment, error) {
+	if int64(len(data)) > a.options.MaxFileSize {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	filename = filepath.Base(filename)
+	format := attachmentFormat(filename, contentType)
+	if format == "" {
+		return nil, ErrUnsupportedAttachment
+	}
+
+	text, err := ExtractAttachmentText(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment := &Attachment{
+		ID:            uuid.New().String(),
+		UserID:        userID,
+		Filename:      filename,
+		ContentType:   contentType,
+		Size:          int64(len(data)),
+		TextLength:    utf8.RuneCountInString(text),
+		ExtractedText: text,
+	}
+	attachment.StorageKey = fmt.Sprintf("attachments/%d/%s", userID, attachment.ID)
+
+	if err := a.blobs.Put(ctx, attachment.StorageKey, bytes.NewReader(data), attachment.Size, contentType); err != nil {
+		return nil, fmt.Errorf("保存附件失败: %w", err)
+	}
+
+	if err := a.storage.CreateAttachment(attachment); err != nil {
+		a.blobs.Delete(ctx, attachment.StorageKey)
+		return nil, err
+	}
+
+	return attachment, nil
+}
+
+// Get 获取用户的附件
+func (a *AttachmentService) Get(userID uint, id string) (*Attachment, error) {
+	attachment, err := a.storage.GetAttachment(id)
+	if err != nil {
+		return nil, err
+	}
+	if attachment.UserID != userID {
+		return nil, errors.New("无权访问此附件")
+	}
+
+	return attachment, nil
+}
+
+// Open 打开附件的原始文件，调用方负责关闭返回的Reader
+func (a *AttachmentService) Open(ctx context.Context, userID uint, id string) (*Attachment, io.ReadCloser, error) {
+	attachment, err := a.Get(userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader, err := a.blobs.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return attachment, reader, nil
+}
+
+// DownloadContentType 获取下载附件时使用的内容类型，由上传时判断的格式决定
+func (a *AttachmentService) DownloadContentType(attachment *Attachment) string {
+	if mediaType, ok := attachmentMediaTypes[attachmentFormat(attachment.Filename, attachment.ContentType)]; ok {
+		return mediaType
+	}
+	return "application/octet-stream"
+}
+
+// Delete 删除尚未随消息发送的附件，已发送的附件属于会话历史，随会话一起保留
+func (a *AttachmentService) Delete(ctx context.Context, userID uint, id string) error {
+	attachment, err := a.Get(userID, id)
+	if err != nil {
+		return err
+	}
+	if attachment.MessageID != "" {
+		return errors.New("附件已随消息发送，无法删除")
+	}
+
+	if err := a.storage.DeleteAttachment(id); err != nil {
+		return err
+	}
+	if err := a.blobs.Delete(ctx, attachment.StorageKey); err != nil {
+		log.Printf("删除附件文件失败: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteByConversation 删除会话中已发送的附件及其文件
+func (a *AttachmentService) DeleteByConversation(ctx context.Context, conversationID string) error {
+	attachments, err := a.storage.GetAttachmentsByConversationID(conversationID)
+	if err != nil {
+		return err
+	}
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	if err := a.storage.DeleteAttachmentsByConversationID(conversationID); err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		if err := a.blobs.Delete(ctx, attachment.StorageKey); err != nil {
+			log.Printf("删除附件文件失败: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// Resolve 检查待发送的附件均属于该用户且尚未随其他消息发送
+func (a *AttachmentService) Resolve(userID uint, ids []string) ([]*Attachment, error) {
+	if len(ids) > maxAttachmentsPerMessage {
+		return nil, fmt.Errorf("%w: 每条消息最多%d个附件", ErrInvalidAttachment, maxAttachmentsPerMessage)
+	}
+
+	seen := make(map[string]bool, len(ids))
+	attachments := make([]*Attachment, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		attachment, err := a.storage.GetAttachment(id)
+		if err != nil || attachment.UserID != userID || attachment.MessageID != "" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidAttachment, id)
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, nil
+}
+
+// Link 将附件关联到已保存的用户消息
+func (a *AttachmentService) Link(attachments []*Attachment, conversationID, messageID string) error {
+	ids := make([]string, len(attachments))
+	for i, attachment := range attachments {
+		ids[i] = attachment.ID
+	}
+
+	if err := a.storage.LinkAttachments(ids, conversationID, messageID); err != nil {
+		return err
+	}
+
+	for _, attachment := range attachments {
+		attachment.ConversationID = conversationID
+		attachment.MessageID = messageID
+	}
+	return nil
+}
+
+// ByMessage 获取会话中的附件并按消息ID分组
+func (a *AttachmentService) ByMessage(conversationID string) (map[string][]*Attachment, error) {
+	attachments, err := a.storage.GetAttachmentsByConversationID(conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]*Attachment)
+	for _, attachment := range attachments {
+		result[attachment.MessageID] = append(result[attachment.MessageID], attachment)
+	}
+
+	return result, nil
+}
+
+// PromptSections 在字符预算内为每条消息生成附件内容，越新的消息越优先
+func (a *AttachmentService) PromptSections(messages []*Message, byMessage map[string][]*Attachment) map[string]string {
+	sections := make(map[string]string)
+	remaining := a.options.MaxPromptChars
+
+	for i := len(messages) - 1; i >= 0 && remaining > 0; i-- {
+		attachments := byMessage[messages[i].ID]
+		if len(attachments) == 0 {
+			continue
+		}
+
+		var sb strings.Builder
+		for _, attachment := range attachments {
+			if remaining <= 0 {
+				break
+			}
+
+			text := []rune(attachment.ExtractedText)
+			truncated := false
+			if len(text) > remaining {
+				text = text[:remaining]
+				truncated = true
+			}
+			remaining -= len(text)
+
+			fmt.Fprintf(&sb, "附件《%s》:\n%s\n", attachment.Filename, string(text))
+			if truncated {
+				sb.WriteString("(附件内容过长，已截断)\n")
+			}
+		}
+		sections[messages[i].ID] = sb.String()
+	}
+
+	return sections
+}
+
+// attachmentFormat 根据文件扩展名和内容类型判断附件格式，不支持时返回空字符串
+func attachmentFormat(filename, contentType string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".txt", ".text", ".log":
+		return attachmentFormatText
+	case ".md", ".markdown":
+		return attachmentFormatMarkdown
+	case ".csv":
+		return attachmentFormatCSV
+	}
+
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	switch mediaType {
+	case "text/plain":
+		return attachmentFormatText
+	case "text/markdown":
+		return attachmentFormatMarkdown
+	case "text/csv":
+		return attachmentFormatCSV
+	}
+
+	return ""
+}
+
+// ExtractAttachmentText 从附件中提取用于提示词的纯文本
+func ExtractAttachmentText(data []byte, format string) (string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		return "", errors.New("附件必须是UTF-8编码的文本")
+	}
+
+	var text string
+	switch format {
+	case attachmentFormatText:
+		text = NormalizeDocument(string(data), DocumentFormatText)
+	case attachmentFormatMarkdown:
+		text = NormalizeDocument(string(data), DocumentFormatMarkdown)
+	case attachmentFormatCSV:
+		var err error
+		text, err = csvToText(data)
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", ErrUnsupportedAttachment
+	}
+
+	if text == "" {
+		return "", errors.New("附件内容为空")
+	}
+
+	return text, nil
+}
+
+// csvToText 将CSV转换为每行以" | "分隔的文本
+func csvToText(data []byte) (string, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var sb strings.Builder
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("解析CSV失败: %w", err)
+		}
+
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+		line := strings.Join(record, " | ")
+		if strings.Trim(line, " |") == "" {
+			continue
+		}
+		sb.WriteString(line + "\n")
+	}
+
+	return strings.TrimSpace(sb.String()), nil
+}
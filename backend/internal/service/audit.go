@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// RequestIDFromContext 获取请求ID中间件写入上下文的请求ID
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value("requestID").(string)
+	return requestID
+}
+
+// 审计事件类型
+const (
+	AuditLogin               = "login.success"
+	AuditLoginFailed         = "login.failure"
+	AuditLoginLockout        = "login.lockout"
+	AuditRegister            = "user.register"
+	AuditConversationDelete  = "conversation.delete"
+	AuditConversationTitle   = "conversation.title"
+	AuditConversationShare   = "conversation.share"
+	AuditConversationMember  = "conversation.member"
+	AuditConversationUnshare = "conversation.unshare"
+	AuditConversationExport  = "conversation.export"
+	AuditAdminRequest        = "admin.request"
+	AuditAdminUserRole       = "admin.user.role"
+	AuditAdminUserDisable    = "admin.user.disable"
+	AuditAdminUserEnable     = "admin.user.enable"
+	AuditOrgCreate           = "org.create"
+	AuditOrgMember           = "org.member"
+	AuditOrgSettings         = "org.settings"
+)
+
+// AuditEvent 审计事件
+type AuditEvent struct {
+	ID         uint                   `json:"id,omitempty"`
+	Action     string                 `json:"action"`
+	ActorID    uint                   `json:"actor_id"` // 发起操作的用户，匿名操作为0
+	TargetType string                 `json:"target_type"`
+	TargetID   string                 `json:"target_id"`
+	IP         string                 `json:"ip"`
+	UserAgent  string                 `json:"user_agent"`
+	RequestID  string                 `json:"request_id,omitempty"` // 为空时从上下文中获取
+	Detail     map[string]interface{} `json:"detail,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+// AuditFilter 审计事件的查询条件，零值字段不过滤
+type AuditFilter struct {
+	Action     string
+	ActorID    uint
+	TargetType string
+	TargetID   string
+	From       time.Time // 包含
+	To         time.Time // 不包含
+}
+
+// AuditRecorder 记录审计事件，记录失败不应影响业务流程
+type AuditRecorder interface {
+	Record(ctx context.Context, event *AuditEvent)
+}
+
+// prepareAuditEvent 补全事件的时间和请求ID，并截断过长的User-Agent
+func prepareAuditEvent(ctx context.Context, event *AuditEvent) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	if event.RequestID == "" {
+		event.RequestID = RequestIDFromContext(ctx)
+	}
+	event.UserAgent = truncateUserAgent(event.UserAgent)
+}
+
+// AuditLog 将审计事件写入只追加的审计表，并提供查询和导出
+type AuditLog struct {
+	storage AuditStorage
+}
+
+// NewAuditLog 创建审计日志
+func NewAuditLog(storage AuditStorage) *AuditLog {
+	return &AuditLog{
+		storage: storage,
+	}
+}
+
+// Record 保存审计事件，保存失败时写入日志以免丢失
+func (l *AuditLog) Record(ctx context.Context, event *AuditEvent) {
+	prepareAuditEvent(ctx, event)
+
+	if err := l.storage.SaveAuditEvent(event); err != nil {
+		data, _ := json.Marshal(event)
+		log.Printf("保存审计事件失败: %v, 事件: %s", err, data)
+	}
+}
+
+// List 按条件分页查询审计事件，按时间倒序
+func (l *AuditLog) List(filter AuditFilter, offset, limit int) ([]*AuditEvent, int64, error) {
+	return l.storage.ListAuditEvents(filter, offset, limit)
+}
+
+// Export 按时间顺序依次回调符合条件的审计事件，回调返回错误时停止
+func (l *AuditLog) Export(filter AuditFilter, fn func(event *AuditEvent) error) error {
+	return l.storage.ScanAuditEvents(filter, fn)
+}
@@ -0,0 +1,405 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidToken 访问令牌无效、过期或已撤销
+	ErrInvalidToken = errors.New("无效或过期的令牌")
+	// ErrInvalidRefreshToken 刷新令牌无效、过期或已被使用
+	ErrInvalidRefreshToken = errors.New("无效或过期的刷新令牌")
+	// ErrSessionNotFound 会话不存在或不属于当前用户
+	ErrSessionNotFound = errors.New("会话不存在")
+)
+
+// sessionTouchInterval 同一会话两次更新最近活跃时间的最小间隔
+const sessionTouchInterval = time.Minute
+
+// ClientInfo 发起登录的客户端信息
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// AccessClaims 访问令牌的声明
+type AccessClaims struct {
+	UserID    uint   `json:"user_id"`
+	SessionID string `json:"sid"`
+	Role      string `json:"role"`
+	OrgID     uint   `json:"org_id,omitempty"`   // 当前组织，0表示个人空间
+	OrgRole   string `json:"org_role,omitempty"` // 用户在当前组织中的角色
+	jwt.StandardClaims
+}
+
+// TokenPair 登录或刷新后签发的令牌
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // 访问令牌有效期，单位秒
+	UserID       uint   `json:"-"`          // 令牌所属用户，用于记录审计事件
+}
+
+// RevocationList 已撤销会话的列表，条目在ttl后自动过期
+type RevocationList interface {
+	Revoke(ctx context.Context, id string, ttl time.Duration) error
+	IsRevoked(ctx context.Context, id string) (bool, error)
+}
+
+// AuthOptions 令牌有效期配置
+type AuthOptions struct {
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// AuthService 签发、刷新和撤销登录令牌
+type AuthService struct {
+	storage     SessionStorage
+	revocations RevocationList
+	access      AccessProvider
+	members     OrgMembershipProvider
+	jwtSecret   []byte
+	options     AuthOptions
+	lastTouched sync.Map // 会话ID到最近一次写入活跃时间的时刻
+}
+
+// NewAuthService 创建认证服务，access用于获取签发令牌时写入的角色及检查账号是否停用，
+// members用于切换组织时检查成员身份，为nil时只能使用个人空间
+func NewAuthService(storage SessionStorage, revocations RevocationList, access AccessProvider, members OrgMembershipProvider, jwtSecret string, options AuthOptions) *AuthService {
+	if options.AccessTokenTTL <= 0 {
+		options.AccessTokenTTL = 15 * time.Minute
+	}
+	if options.RefreshTokenTTL <= 0 {
+		options.RefreshTokenTTL = 30 * 24 * time.Hour
+	}
+
+	return &AuthService{
+		storage:     storage,
+		revocations: revocations,
+		access:      access,
+		members:     members,
+		jwtSecret:   []byte(jwtSecret),
+		options:     options,
+	}
+}
+
+// Login 为通过验证的用户创建会话并签发令牌，记录登录的设备和IP
+func (a *AuthService) Login(ctx context.Context, userID uint, client ClientInfo) (*TokenPair, error) {
+	role, err := a.userRole(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &Session{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		UserAgent:  truncateUserAgent(client.UserAgent),
+		IP:         client.IP,
+		LastSeenAt: now,
+		CreatedAt:  now,
+	}
+	if err := a.storage.CreateSession(session); err != nil {
+		return nil, err
+	}
+
+	return a.issueTokens(session, role, "")
+}
+
+// Refresh 使用刷新令牌换取新的令牌，旧的刷新令牌随即失效。
+// 已使用过的刷新令牌再次出现说明可能被盗用，此时撤销整个会话
+func (a *AuthService) Refresh(ctx context.Context, refreshToken, ip string) (*TokenPair, error) {
+	token, err := a.storage.GetRefreshTokenByHash(hashToken(refreshToken))
+	if err != nil || time.Now().After(token.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session, err := a.storage.GetSession(token.SessionID)
+	if err != nil || session.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// 每次刷新重新读取角色，角色变更和账号停用在访问令牌过期后生效
+	role, err := a.userRole(session.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	fresh, err := a.storage.UseRefreshToken(token.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		log.Printf("检测到刷新令牌重复使用，撤销会话: user=%d session=%s", token.UserID, token.SessionID)
+		if err := a.Logout(ctx, token.SessionID); err != nil {
+			log.Printf("撤销会话失败: %v", err)
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	a.lastTouched.Store(session.ID, time.Now())
+	if err := a.storage.TouchSession(session.ID, ip, time.Now()); err != nil {
+		log.Printf("更新会话活跃时间失败: %v", err)
+	}
+
+	return a.issueTokens(session, role, a.sessionOrgRole(session))
+}
+
+// SwitchOrganization 将登录会话切换到组织并重新签发令牌，orgID为0时切换回个人空间
+func (a *AuthService) SwitchOrganization(ctx context.Context, userID uint, sessionID string, orgID uint) (*TokenPair, error) {
+	session, err := a.storage.GetSession(sessionID)
+	if err != nil || session.UserID != userID || session.RevokedAt != nil {
+		return nil, ErrSessionNotFound
+	}
+
+	var orgRole string
+	if orgID != 0 {
+		if a.members == nil {
+			return nil, ErrOrgNotFound
+		}
+		membership, err := a.members.GetMembership(orgID, userID)
+		if err != nil {
+			return nil, ErrOrgNotFound
+		}
+		orgRole = membership.Role
+	}
+
+	role, err := a.userRole(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.storage.UpdateSessionOrg(sessionID, orgID); err != nil {
+		return nil, err
+	}
+	session.OrgID = orgID
+
+	return a.issueTokens(session, role, orgRole)
+}
+
+// sessionOrgRole 获取用户在会话所在组织中的角色，已不是成员时将会话切换回个人空间
+func (a *AuthService) sessionOrgRole(session *Session) string {
+	if session.OrgID == 0 {
+		return ""
+	}
+
+	if a.members != nil {
+		if membership, err := a.members.GetMembership(session.OrgID, session.UserID); err == nil {
+			return membership.Role
+		}
+	}
+
+	log.Printf("用户已不是组织成员，会话切换回个人空间: user=%d org=%d", session.UserID, session.OrgID)
+	if err := a.storage.UpdateSessionOrg(session.ID, 0); err != nil {
+		log.Printf("更新会话组织失败: %v", err)
+	}
+	session.OrgID = 0
+	return ""
+}
+
+// userRole 获取用户角色，账号已停用时返回ErrAccountDisabled
+func (a *AuthService) userRole(userID uint) (string, error) {
+	if a.access == nil {
+		return RoleUser, nil
+	}
+
+	access, err := a.access.GetUserAccess(userID)
+	if err != nil {
+		return "", err
+	}
+	if access.Disabled {
+		return "", ErrAccountDisabled
+	}
+	if !ValidRole(access.Role) {
+		return RoleUser, nil
+	}
+	return access.Role, nil
+}
+
+// Touch 记录会话活跃，同一会话在sessionTouchInterval内只写入一次
+func (a *AuthService) Touch(sessionID, ip string) {
+	now := time.Now()
+	if last, ok := a.lastTouched.Load(sessionID); ok && now.Sub(last.(time.Time)) < sessionTouchInterval {
+		return
+	}
+	a.lastTouched.Store(sessionID, now)
+
+	if err := a.storage.TouchSession(sessionID, ip, now); err != nil {
+		log.Printf("更新会话活跃时间失败: %v", err)
+	}
+}
+
+// ListSessions 获取用户仍然有效的会话，长时间未活跃、刷新令牌已过期的会话不再返回
+func (a *AuthService) ListSessions(userID uint) ([]*Session, error) {
+	sessions, err := a.storage.ListUserSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-a.options.RefreshTokenTTL)
+	result := make([]*Session, 0, len(sessions))
+	for _, session := range sessions {
+		if session.LastSeenAt.After(cutoff) {
+			result = append(result, session)
+		}
+	}
+
+	return result, nil
+}
+
+// RevokeSession 撤销用户的指定会话
+func (a *AuthService) RevokeSession(ctx context.Context, userID uint, sessionID string) error {
+	session, err := a.storage.GetSession(sessionID)
+	if err != nil || session.UserID != userID {
+		return ErrSessionNotFound
+	}
+
+	return a.Logout(ctx, sessionID)
+}
+
+// Logout 撤销会话，会话的刷新令牌和尚未过期的访问令牌都将失效
+func (a *AuthService) Logout(ctx context.Context, sessionID string) error {
+	if err := a.storage.RevokeSession(sessionID); err != nil {
+		return err
+	}
+	a.lastTouched.Delete(sessionID)
+	return a.revocations.Revoke(ctx, sessionID, a.options.AccessTokenTTL)
+}
+
+// LogoutAll 撤销用户的所有会话
+func (a *AuthService) LogoutAll(ctx context.Context, userID uint) error {
+	sessionIDs, err := a.storage.RevokeUserSessions(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, sessionID := range sessionIDs {
+		a.lastTouched.Delete(sessionID)
+		if err := a.revocations.Revoke(ctx, sessionID, a.options.AccessTokenTTL); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LogoutOthers 撤销用户除keepSessionID之外的所有会话，用于修改密码后让其他设备重新登录
+func (a *AuthService) LogoutOthers(ctx context.Context, userID uint, keepSessionID string) error {
+	sessions, err := a.storage.ListUserSessions(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.ID == keepSessionID {
+			continue
+		}
+		if err := a.Logout(ctx, session.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ParseAccessToken 验证访问令牌的签名、有效期及所属会话是否已撤销
+func (a *AuthService) ParseAccessToken(ctx context.Context, tokenString string) (*AccessClaims, error) {
+	claims := &AccessClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("不支持的签名算法: %v", token.Header["alg"])
+		}
+		return a.jwtSecret, nil
+	})
+	if err != nil || !token.Valid || claims.SessionID == "" {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := a.revocations.IsRevoked(ctx, claims.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("检查令牌状态失败: %w", err)
+	}
+	if revoked {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// issueTokens 为会话签发访问令牌和新的刷新令牌，访问令牌中包含会话当前所在的组织
+func (a *AuthService) issueTokens(session *Session, role, orgRole string) (*TokenPair, error) {
+	now := time.Now()
+	claims := &AccessClaims{
+		UserID:    session.UserID,
+		SessionID: session.ID,
+		Role:      role,
+		OrgID:     session.OrgID,
+		OrgRole:   orgRole,
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New().String(),
+			ExpiresAt: now.Add(a.options.AccessTokenTTL).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+	}
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(a.jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(raw)
+
+	if err := a.storage.CreateRefreshToken(&RefreshToken{
+		ID:        uuid.New().String(),
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: now.Add(a.options.RefreshTokenTTL),
+		CreatedAt: now,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(a.options.AccessTokenTTL.Seconds()),
+		UserID:       session.UserID,
+	}, nil
+}
+
+// truncateUserAgent 截断过长的User-Agent，避免超出数据库字段长度
+func truncateUserAgent(userAgent string) string {
+	const maxLength = 255
+	if len(userAgent) <= maxLength {
+		return userAgent
+	}
+
+	truncated := userAgent[:maxLength]
+	for !utf8.ValidString(truncated) {
+		truncated = truncated[:len(truncated)-1]
+	}
+	return truncated
+}
+
+// hashToken 计算令牌的SHA-256哈希，数据库中只保存哈希值
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
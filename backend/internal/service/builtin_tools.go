@@ -0,0 +1,607 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 内置工具名称
+const (
+	ToolCalculator     = "calculator"
+	ToolDateTime       = "current_datetime"
+	ToolUnitConvert    = "unit_convert"
+	ToolDoseCalculator = "dose_calculator"
+)
+
+// RegisterBuiltinTools 注册内置工具，内置工具均为纯计算，不访问网络和文件系统
+func RegisterBuiltinTools(registry *ToolRegistry, location *time.Location) error {
+	if location == nil {
+		location = time.Local
+	}
+
+	tools := []*Tool{
+		{
+			Name:        ToolCalculator,
+			Description: "计算数学表达式，支持 + - * / % ^、括号，以及 sqrt abs round floor ceil min max pow ln log10 函数和常量 pi e",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"expression":{"type":"string","description":"数学表达式，如 (70*0.5)/2"}},"required":["expression"]}`),
+			Handler:     calculatorTool,
+		},
+		{
+			Name:        ToolDateTime,
+			Description: "获取当前日期和时间，可加减天数或小时数推算日期，如复诊、服药时间",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"add_days":{"type":"integer"},"add_hours":{"type":"integer"}}}`),
+			Handler:     dateTimeTool(location),
+		},
+		{
+			Name:        ToolUnitConvert,
+			Description: "医学单位换算：质量(kg g mg mcg lb)、体积(L dL mL)、温度(C F)、浓度(mmol/L umol/L mg/dL mg/L g/L)，摩尔浓度与质量浓度互换时需指定物质",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"value":{"type":"number"},"from":{"type":"string"},"to":{"type":"string"},"substance":{"type":"string","enum":["glucose","cholesterol","triglycerides","creatinine","urea_nitrogen","uric_acid","calcium"]}},"required":["value","from","to"]}`),
+			Handler:     unitConvertTool,
+		},
+		{
+			Name:        ToolDoseCalculator,
+			Description: "按体重计算剂量，如 10 mg/kg，体重 20 kg，可指定每日分几次服用",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"dose_per_kg":{"type":"number"},"dose_unit":{"type":"string","enum":["mg","mcg","g","mL","IU"]},"weight":{"type":"number"},"weight_unit":{"type":"string","enum":["kg","lb"]},"divided_doses":{"type":"integer"}},"required":["dose_per_kg","weight"]}`),
+			Handler:     doseCalculatorTool,
+		},
+	}
+
+	for _, tool := range tools {
+		if err := registry.Register(tool); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formatNumber 格式化计算结果，去掉浮点误差带来的多余位数
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'g', 10, 64)
+}
+
+// calculatorTool 计算数学表达式
+func calculatorTool(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		Expression string `json:"expression"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", err
+	}
+
+	value, err := EvaluateExpression(params.Expression)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s = %s", strings.TrimSpace(params.Expression), formatNumber(value)), nil
+}
+
+// 表达式长度和嵌套深度限制
+const (
+	maxExpressionLength = 256
+	maxExpressionDepth  = 32
+)
+
+// EvaluateExpression 计算数学表达式
+func EvaluateExpression(expression string) (float64, error) {
+	if len(expression) > maxExpressionLength {
+		return 0, fmt.Errorf("表达式过长，最多%d个字符", maxExpressionLength)
+	}
+
+	p := &expressionParser{input: []rune(expression)}
+	value, err := p.parseExpression()
+	if err != nil {
+		return 0, err
+	}
+
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return 0, fmt.Errorf("无法识别的字符: %c", p.input[p.pos])
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, errors.New("计算结果无效")
+	}
+
+	return value, nil
+}
+
+// expressionParser 递归下降的表达式解析器
+type expressionParser struct {
+	input []rune
+	pos   int
+	depth int
+}
+
+func (p *expressionParser) skipSpaces() {
+	for p.pos < len(p.input) && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *expressionParser) peek() rune {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+// parseExpression 处理加减
+func (p *expressionParser) parseExpression() (float64, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxExpressionDepth {
+		return 0, errors.New("表达式嵌套过深")
+	}
+
+	left, err := p.parseTerm()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		switch p.peek() {
+		case '+':
+			p.pos++
+			right, err := p.parseTerm()
+			if err != nil {
+				return 0, err
+			}
+			left += right
+		case '-':
+			p.pos++
+			right, err := p.parseTerm()
+			if err != nil {
+				return 0, err
+			}
+			left -= right
+		default:
+			return left, nil
+		}
+	}
+}
+
+// parseTerm 处理乘除和取余
+func (p *expressionParser) parseTerm() (float64, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		op := p.peek()
+		if op == '×' {
+			op = '*'
+		} else if op == '÷' {
+			op = '/'
+		}
+		if op != '*' && op != '/' && op != '%' {
+			return left, nil
+		}
+
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+
+		switch op {
+		case '*':
+			left *= right
+		case '/':
+			if right == 0 {
+				return 0, errors.New("除数不能为0")
+			}
+			left /= right
+		case '%':
+			if right == 0 {
+				return 0, errors.New("除数不能为0")
+			}
+			left = math.Mod(left, right)
+		}
+	}
+}
+
+// parseUnary 处理正负号
+func (p *expressionParser) parseUnary() (float64, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		value, err := p.parseUnary()
+		return -value, err
+	case '+':
+		p.pos++
+		return p.parseUnary()
+	}
+	return p.parsePower()
+}
+
+// parsePower 处理乘方，右结合
+func (p *expressionParser) parsePower() (float64, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return 0, err
+	}
+
+	if p.peek() == '^' {
+		p.pos++
+		exponent, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		return math.Pow(base, exponent), nil
+	}
+
+	return base, nil
+}
+
+// parsePrimary 处理数字、括号、常量和函数
+func (p *expressionParser) parsePrimary() (float64, error) {
+	ch := p.peek()
+
+	switch {
+	case ch == '(' || ch == '（':
+		p.pos++
+		value, err := p.parseExpression()
+		if err != nil {
+			return 0, err
+		}
+		if closing := p.peek(); closing != ')' && closing != '）' {
+			return 0, errors.New("缺少右括号")
+		}
+		p.pos++
+		return value, nil
+
+	case unicode.IsDigit(ch) || ch == '.':
+		start := p.pos
+		for p.pos < len(p.input) && (unicode.IsDigit(p.input[p.pos]) || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		// 科学计数法，如 1e-3
+		if p.pos < len(p.input) && (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') {
+			next := p.pos + 1
+			if next < len(p.input) && (p.input[next] == '-' || p.input[next] == '+') {
+				next++
+			}
+			if next < len(p.input) && unicode.IsDigit(p.input[next]) {
+				p.pos = next
+				for p.pos < len(p.input) && unicode.IsDigit(p.input[p.pos]) {
+					p.pos++
+				}
+			}
+		}
+		value, err := strconv.ParseFloat(string(p.input[start:p.pos]), 64)
+		if err != nil {
+			return 0, fmt.Errorf("无效的数字: %s", string(p.input[start:p.pos]))
+		}
+		return value, nil
+
+	case unicode.IsLetter(ch):
+		start := p.pos
+		for p.pos < len(p.input) && (unicode.IsLetter(p.input[p.pos]) || unicode.IsDigit(p.input[p.pos])) {
+			p.pos++
+		}
+		name := strings.ToLower(string(p.input[start:p.pos]))
+
+		switch name {
+		case "pi":
+			return math.Pi, nil
+		case "e":
+			return math.E, nil
+		}
+
+		if p.peek() != '(' {
+			return 0, fmt.Errorf("未知的常量: %s", name)
+		}
+		p.pos++
+
+		var args []float64
+		if p.peek() != ')' {
+			for {
+				value, err := p.parseExpression()
+				if err != nil {
+					return 0, err
+				}
+				args = append(args, value)
+				if p.peek() != ',' {
+					break
+				}
+				p.pos++
+			}
+		}
+		if p.peek() != ')' {
+			return 0, errors.New("缺少右括号")
+		}
+		p.pos++
+
+		return callFunction(name, args)
+
+	case ch == 0:
+		return 0, errors.New("表达式不完整")
+	}
+
+	return 0, fmt.Errorf("无法识别的字符: %c", ch)
+}
+
+// callFunction 调用数学函数
+func callFunction(name string, args []float64) (float64, error) {
+	expect := func(n int) error {
+		if len(args) != n {
+			return fmt.Errorf("函数%s需要%d个参数", name, n)
+		}
+		return nil
+	}
+
+	switch name {
+	case "sqrt":
+		if err := expect(1); err != nil {
+			return 0, err
+		}
+		if args[0] < 0 {
+			return 0, errors.New("不能对负数开平方")
+		}
+		return math.Sqrt(args[0]), nil
+	case "abs":
+		if err := expect(1); err != nil {
+			return 0, err
+		}
+		return math.Abs(args[0]), nil
+	case "floor":
+		if err := expect(1); err != nil {
+			return 0, err
+		}
+		return math.Floor(args[0]), nil
+	case "ceil":
+		if err := expect(1); err != nil {
+			return 0, err
+		}
+		return math.Ceil(args[0]), nil
+	case "round":
+		// round(x) 或 round(x, 小数位数)
+		if len(args) == 1 {
+			return math.Round(args[0]), nil
+		}
+		if err := expect(2); err != nil {
+			return 0, err
+		}
+		scale := math.Pow(10, math.Round(args[1]))
+		return math.Round(args[0]*scale) / scale, nil
+	case "min", "max":
+		if len(args) == 0 {
+			return 0, fmt.Errorf("函数%s至少需要1个参数", name)
+		}
+		result := args[0]
+		for _, arg := range args[1:] {
+			if name == "min" {
+				result = math.Min(result, arg)
+			} else {
+				result = math.Max(result, arg)
+			}
+		}
+		return result, nil
+	case "pow":
+		if err := expect(2); err != nil {
+			return 0, err
+		}
+		return math.Pow(args[0], args[1]), nil
+	case "ln", "log10":
+		if err := expect(1); err != nil {
+			return 0, err
+		}
+		if args[0] <= 0 {
+			return 0, errors.New("对数的参数必须大于0")
+		}
+		if name == "ln" {
+			return math.Log(args[0]), nil
+		}
+		return math.Log10(args[0]), nil
+	}
+
+	return 0, fmt.Errorf("未知的函数: %s", name)
+}
+
+// weekdayNames 中文星期名称
+var weekdayNames = [...]string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"}
+
+// dateTimeTool 返回配置时区的当前时间
+func dateTimeTool(location *time.Location) ToolHandler {
+	return func(ctx context.Context, args json.RawMessage) (string, error) {
+		var params struct {
+			AddDays  int `json:"add_days"`
+			AddHours int `json:"add_hours"`
+		}
+		if err := json.Unmarshal(args, &params); err != nil {
+			return "", err
+		}
+
+		now := time.Now().In(location)
+		result := fmt.Sprintf("当前时间: %s %s (%s)", now.Format("2006-01-02 15:04"), weekdayNames[now.Weekday()], location.String())
+
+		if params.AddDays != 0 || params.AddHours != 0 {
+			target := now.AddDate(0, 0, params.AddDays).Add(time.Duration(params.AddHours) * time.Hour)
+			result += fmt.Sprintf("\n推算时间: %s %s", target.Format("2006-01-02 15:04"), weekdayNames[target.Weekday()])
+		}
+
+		return result, nil
+	}
+}
+
+// 单位类别
+const (
+	unitMass        = "mass"
+	unitVolume      = "volume"
+	unitTemperature = "temperature"
+	unitMolarConc   = "molar_concentration"
+	unitMassConc    = "mass_concentration"
+)
+
+// unitDefinition 单位定义，factor为换算到基准单位(g、L、mmol/L、mg/dL)的系数
+type unitDefinition struct {
+	category string
+	factor   float64
+}
+
+var units = map[string]unitDefinition{
+	"kg":     {unitMass, 1000},
+	"g":      {unitMass, 1},
+	"mg":     {unitMass, 1e-3},
+	"mcg":    {unitMass, 1e-6},
+	"ug":     {unitMass, 1e-6},
+	"lb":     {unitMass, 453.59237},
+	"oz":     {unitMass, 28.349523125},
+	"l":      {unitVolume, 1},
+	"dl":     {unitVolume, 0.1},
+	"ml":     {unitVolume, 1e-3},
+	"c":      {unitTemperature, 0},
+	"f":      {unitTemperature, 0},
+	"mmol/l": {unitMolarConc, 1},
+	"umol/l": {unitMolarConc, 1e-3},
+	"mg/dl":  {unitMassConc, 1},
+	"mg/l":   {unitMassConc, 0.1},
+	"g/l":    {unitMassConc, 100},
+}
+
+// molarMasses 常见检验项目的摩尔质量(g/mol)
+var molarMasses = map[string]float64{
+	"glucose":       180.16,
+	"cholesterol":   386.65,
+	"triglycerides": 885.7,
+	"creatinine":    113.12,
+	"urea_nitrogen": 28.014,
+	"uric_acid":     168.11,
+	"calcium":       40.08,
+}
+
+// normalizeUnit 统一单位写法
+func normalizeUnit(unit string) string {
+	unit = strings.ToLower(strings.TrimSpace(unit))
+	unit = strings.NewReplacer("μ", "u", "µ", "u", "℃", "c", "℉", "f", "°", "", " ", "").Replace(unit)
+	switch unit {
+	case "celsius":
+		return "c"
+	case "fahrenheit":
+		return "f"
+	case "lbs":
+		return "lb"
+	}
+	return unit
+}
+
+// ConvertUnit 换算医学常用单位，摩尔浓度与质量浓度互换时需指定物质
+func ConvertUnit(value float64, from, to, substance string) (float64, error) {
+	fromUnit, ok := units[normalizeUnit(from)]
+	if !ok {
+		return 0, fmt.Errorf("不支持的单位: %s", from)
+	}
+	toUnit, ok := units[normalizeUnit(to)]
+	if !ok {
+		return 0, fmt.Errorf("不支持的单位: %s", to)
+	}
+
+	// 温度单独处理
+	if fromUnit.category == unitTemperature || toUnit.category == unitTemperature {
+		if fromUnit.category != toUnit.category {
+			return 0, errors.New("温度不能与其他单位换算")
+		}
+		switch {
+		case normalizeUnit(from) == normalizeUnit(to):
+			return value, nil
+		case normalizeUnit(from) == "c":
+			return value*9/5 + 32, nil
+		default:
+			return (value - 32) * 5 / 9, nil
+		}
+	}
+
+	base := value * fromUnit.factor
+	fromCategory, toCategory := fromUnit.category, toUnit.category
+
+	// 摩尔浓度与质量浓度互换: mg/dL = mmol/L × 摩尔质量 / 10
+	if fromCategory != toCategory && isConcentration(fromCategory) && isConcentration(toCategory) {
+		molarMass, ok := molarMasses[substance]
+		if !ok {
+			return 0, errors.New("摩尔浓度与质量浓度换算需要指定物质")
+		}
+		if fromCategory == unitMolarConc {
+			base = base * molarMass / 10
+		} else {
+			base = base * 10 / molarMass
+		}
+		fromCategory = toCategory
+	}
+
+	if fromCategory != toCategory {
+		return 0, fmt.Errorf("单位%s不能换算为%s", from, to)
+	}
+
+	return base / toUnit.factor, nil
+}
+
+func isConcentration(category string) bool {
+	return category == unitMolarConc || category == unitMassConc
+}
+
+// unitConvertTool 单位换算
+func unitConvertTool(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		Value     float64 `json:"value"`
+		From      string  `json:"from"`
+		To        string  `json:"to"`
+		Substance string  `json:"substance"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", err
+	}
+
+	result, err := ConvertUnit(params.Value, params.From, params.To, params.Substance)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s %s = %s %s", formatNumber(params.Value), params.From, formatNumber(result), params.To), nil
+}
+
+// doseCalculatorTool 按体重计算剂量
+func doseCalculatorTool(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		DosePerKg    float64 `json:"dose_per_kg"`
+		DoseUnit     string  `json:"dose_unit"`
+		Weight       float64 `json:"weight"`
+		WeightUnit   string  `json:"weight_unit"`
+		DividedDoses int     `json:"divided_doses"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", err
+	}
+
+	if params.DosePerKg <= 0 || params.Weight <= 0 {
+		return "", errors.New("剂量和体重必须大于0")
+	}
+	if params.DoseUnit == "" {
+		params.DoseUnit = "mg"
+	}
+
+	weightKg := params.Weight
+	if params.WeightUnit == "lb" {
+		weightKg = params.Weight * 0.45359237
+	}
+
+	// 剂量保留两位小数
+	round2 := func(value float64) float64 { return math.Round(value*100) / 100 }
+	total := params.DosePerKg * weightKg
+	result := fmt.Sprintf("%s %s/kg × %s kg = %s %s", formatNumber(params.DosePerKg), params.DoseUnit,
+		formatNumber(round2(weightKg)), formatNumber(round2(total)), params.DoseUnit)
+	if params.DividedDoses > 1 {
+		result += fmt.Sprintf("，分%d次，每次 %s %s", params.DividedDoses, formatNumber(round2(total/float64(params.DividedDoses))), params.DoseUnit)
+	}
+
+	return result + "\n(仅供参考，实际用药请遵医嘱)", nil
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"log"
+)
+
+// conversationRecipients 获取会话所有者及成员，用于推送会话事件，未启用推送时返回nil
+func (s *ChatService) conversationRecipients(conv *Conversation) []uint {
+	if s.events == nil {
+		return nil
+	}
+
+	userIDs := []uint{conv.UserID}
+	members, err := s.storage.ListConversationMembers(conv.ID)
+	if err != nil {
+		log.Printf("获取会话成员失败: %v", err)
+	}
+	for _, member := range members {
+		userIDs = append(userIDs, member.UserID)
+	}
+	return userIDs
+}
+
+// publish 把会话事件推送给会话所有者及成员在所有设备上的连接
+func (s *ChatService) publish(ctx context.Context, conv *Conversation, event *Event) {
+	if s.events == nil {
+		return
+	}
+	s.publishTo(ctx, s.conversationRecipients(conv), conv, event)
+}
+
+// publishTo 把会话事件推送给指定用户，会话及其成员已删除时由调用方提供接收者
+func (s *ChatService) publishTo(ctx context.Context, userIDs []uint, conv *Conversation, event *Event) {
+	if s.events == nil {
+		return
+	}
+	event.ConversationID = conv.ID
+	event.OrgID = conv.OrgID
+	event.Origin = eventOrigin(ctx)
+	s.events.Publish(userIDs, event)
+}
+
+// publishMessage 推送会话中的新消息，隐藏审核原文
+func (s *ChatService) publishMessage(ctx context.Context, conv *Conversation, msg *Message) {
+	public := *msg
+	public.Moderation = msg.Moderation.public()
+	s.publish(ctx, conv, &Event{Type: EventMessage, Message: &public})
+}
+
+// publishGeneration 推送回复生成的进度
+func (s *ChatService) publishGeneration(ctx context.Context, conv *Conversation, status string, call *ToolCall, result *ToolResult) {
+	s.publish(ctx, conv, &Event{
+		Type:   EventGeneration,
+		Status: status,
+		Call:   call,
+		Result: result,
+	})
+}
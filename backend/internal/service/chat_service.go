@@ -1,189 +1,1002 @@
-package service
-
-import (
-	"context"
-	"errors"
-	"log"
-	"strings"
-
-	"chat-llama/internal/model"
-)
-
-// ChatService 提供聊天相关功能
-type ChatService struct {
-	llmClient *model.LLMClient
-	storage   Storage
-}
-
-// NewChatService 创建聊天服务实例
-func NewChatService(llmClient *model.LLMClient, storage Storage) *ChatService {
-	return &ChatService{
-		llmClient: llmClient,
-		storage:   storage,
-	}
-}
-
-// Chat 处理聊天请求并返回模型响应
-func (s *ChatService) Chat(ctx context.Context, userID uint, req *ChatRequest) (*ChatResponse, error) {
-	var conversationID string
-	var err error
-
-	// 检查是新会话还是已有会话
-	if req.ConversationID == "" {
-		// 创建新会话
-		title := createTitleFromMessage(req.Message)
-		conv, err := s.storage.CreateConversation(userID, title)
-		if err != nil {
-			return nil, err
-		}
-		conversationID = conv.ID
-	} else {
-		// 验证会话存在且属于该用户
-		conv, err := s.storage.GetConversation(req.ConversationID)
-		if err != nil {
-			return nil, err
-		}
-		if conv.UserID != userID {
-			return nil, errors.New("无权访问此会话")
-		}
-		conversationID = req.ConversationID
-	}
-
-	// 保存用户消息
-	_, err = s.storage.AddMessage(conversationID, "user", req.Message)
-	if err != nil {
-		return nil, err
-	}
-
-	// 构建提示词
-	prompt, err := s.buildPrompt(conversationID)
-	if err != nil {
-		return nil, err
-	}
-
-	// 确保参数有合理默认值
-	temperature := req.Temperature
-	if temperature == 0 {
-		temperature = 0.7
-	}
-
-	maxNewTokens := req.MaxNewTokens
-	if maxNewTokens == 0 {
-		maxNewTokens = 500
-	}
-
-	topK := req.TopK
-	if topK == 0 {
-		topK = 40
-	}
-
-	// 调用模型生成回复
-	llmResponse, err := s.llmClient.GenerateResponse(
-		ctx,
-		prompt,
-		temperature,
-		maxNewTokens,
-		topK,
-	)
-	if err != nil {
-		log.Printf("调用LLM服务失败: %v", err)
-		return nil, err
-	}
-
-	// 保存模型回复
-	_, err = s.storage.AddMessage(conversationID, "assistant", llmResponse)
-	if err != nil {
-		return nil, err
-	}
-
-	return &ChatResponse{
-		ConversationID: conversationID,
-		Message:        llmResponse,
-		Role:           "assistant",
-	}, nil
-}
-
-// GetConversations 获取用户的所有会话
-func (s *ChatService) GetConversations(userID uint) ([]*Conversation, error) {
-	return s.storage.GetConversationsByUserID(userID)
-}
-
-// GetConversationHistory 获取会话的消息历史
-func (s *ChatService) GetConversationHistory(userID uint, conversationID string) ([]*Message, error) {
-	// 检查会话归属
-	conv, err := s.storage.GetConversation(conversationID)
-	if err != nil {
-		return nil, err
-	}
-
-	if conv.UserID != userID {
-		return nil, errors.New("无权访问此会话")
-	}
-
-	return s.storage.GetMessagesByConversationID(conversationID)
-}
-
-// DeleteConversation 删除会话
-func (s *ChatService) DeleteConversation(userID uint, conversationID string) error {
-	// 检查会话归属
-	conv, err := s.storage.GetConversation(conversationID)
-	if err != nil {
-		return err
-	}
-
-	if conv.UserID != userID {
-		return errors.New("无权删除此会话")
-	}
-
-	return s.storage.DeleteConversation(conversationID)
-}
-
-// buildPrompt 构建发送给LLM的提示词
-func (s *ChatService) buildPrompt(conversationID string) (string, error) {
-	messages, err := s.storage.GetMessagesByConversationID(conversationID)
-	if err != nil {
-		return "", err
-	}
-
-	// 构建提示词，包含历史对话
-	var sb strings.Builder
-
-	for _, msg := range messages {
-		if msg.Role == "user" {
-			sb.WriteString("用户: " + msg.Content + "\n")
-		} else if msg.Role == "assistant" {
-			sb.WriteString("助手: " + msg.Content + "\n")
-		}
-	}
-
-	// 增加最后的提示
-	sb.WriteString("助手: ")
-
-	return sb.String(), nil
-}
-
-// createTitleFromMessage 从消息内容创建会话标题
-func createTitleFromMessage(message string) string {
-	if len(message) == 0 {
-		return "新对话"
-	}
-
-	// 将字符串转换为[]rune以正确处理Unicode字符
-	runes := []rune(message)
-	if len(runes) > 20 {
-		// 截取前20个字符（而不是字节）
-		return string(runes[:20]) + "..."
-	}
-
-	return message
-}
-
-// GetConversation 获取会话信息
-func (s *ChatService) GetConversation(conversationID string) (*Conversation, error) {
-	return s.storage.GetConversation(conversationID)
-}
-
-// UpdateConversationTitle 更新会话标题
-func (s *ChatService) UpdateConversationTitle(conversationID string, title string) error {
-	return s.storage.UpdateConversationTitle(conversationID, title)
-}
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"chat-llama/internal/model"
+	"chat-llama/pkg/cache"
+	"chat-llama/pkg/metrics"
+	"chat-llama/pkg/pii"
+)
+
+// ChatService 提供聊天相关功能
+type ChatService struct {
+	llmClient     *model.LLMClient
+	storage       Storage
+	quota         *QuotaService
+	responseCache *cache.ResponseCache
+	semanticCache *SemanticCache
+	knowledge     *KnowledgeService
+	attachments   *AttachmentService
+	tools         *ToolRegistry
+	toolOptions   ToolOptions
+	moderation    *ModerationService
+	orgs          *OrganizationService
+	events        EventPublisher
+	safetyRules   *SafetyRules
+	redaction     RedactionPolicy
+	modelName     string
+}
+
+// RedactionPolicy 个人信息屏蔽策略，Redactor为nil时不做屏蔽
+type RedactionPolicy struct {
+	Redactor *pii.Redactor
+	Storage  bool // 保存消息、会话标题及缓存前屏蔽
+	Exports  bool // 导出会话时屏蔽
+}
+
+// ToolOptions 工具调用循环的限制及各角色启用的工具
+type ToolOptions struct {
+	MaxIterations int                 // 单次对话中模型最多请求工具的轮数
+	Timeout       time.Duration       // 单次工具执行的超时时间
+	Personas      map[string][]string // 角色名称到工具列表，未配置default角色时默认启用所有工具
+}
+
+// DefaultPersona 会话未指定角色时使用的角色
+const DefaultPersona = "default"
+
+// ErrInvalidToolSettings 角色或工具名称无效
+var ErrInvalidToolSettings = errors.New("无效的工具设置")
+
+// ToolCatalog 可用的工具及角色
+type ToolCatalog struct {
+	Tools    []*Tool             `json:"tools"`
+	Personas map[string][]string `json:"personas"`
+}
+
+// ChatServiceOption 聊天服务的可选配置
+type ChatServiceOption func(*ChatService)
+
+// WithQuotaService 启用token配额统计与限制
+func WithQuotaService(quota *QuotaService) ChatServiceOption {
+	return func(s *ChatService) {
+		s.quota = quota
+	}
+}
+
+// WithResponseCache 启用相同提示词的响应缓存
+func WithResponseCache(responseCache *cache.ResponseCache) ChatServiceOption {
+	return func(s *ChatService) {
+		s.responseCache = responseCache
+	}
+}
+
+// WithSemanticCache 启用语义缓存，仅对新会话的首个问题生效
+func WithSemanticCache(semanticCache *SemanticCache) ChatServiceOption {
+	return func(s *ChatService) {
+		s.semanticCache = semanticCache
+	}
+}
+
+// WithKnowledgeService 启用知识库检索增强
+func WithKnowledgeService(knowledge *KnowledgeService) ChatServiceOption {
+	return func(s *ChatService) {
+		s.knowledge = knowledge
+	}
+}
+
+// WithAttachmentService 启用聊天附件
+func WithAttachmentService(attachments *AttachmentService) ChatServiceOption {
+	return func(s *ChatService) {
+		s.attachments = attachments
+	}
+}
+
+// WithToolRegistry 启用工具调用
+func WithToolRegistry(tools *ToolRegistry, options ToolOptions) ChatServiceOption {
+	return func(s *ChatService) {
+		if options.MaxIterations <= 0 {
+			options.MaxIterations = 3
+		}
+		if options.Timeout <= 0 {
+			options.Timeout = 5 * time.Second
+		}
+		s.tools = tools
+		s.toolOptions = options
+	}
+}
+
+// WithModerationService 启用输入输出内容审核
+func WithModerationService(moderation *ModerationService) ChatServiceOption {
+	return func(s *ChatService) {
+		s.moderation = moderation
+	}
+}
+
+// WithOrganizationService 启用组织设置，组织内的对话受组织的模型、角色及配额限制
+func WithOrganizationService(orgs *OrganizationService) ChatServiceOption {
+	return func(s *ChatService) {
+		s.orgs = orgs
+	}
+}
+
+// WithEventPublisher 启用实时推送，会话中的新消息推送给所有者及成员已连接的客户端
+func WithEventPublisher(events EventPublisher) ChatServiceOption {
+	return func(s *ChatService) {
+		s.events = events
+	}
+}
+
+// WithSafetyRules 启用医疗安全规则，在回复中插入紧急就医提示和免责声明
+func WithSafetyRules(rules *SafetyRules) ChatServiceOption {
+	return func(s *ChatService) {
+		s.safetyRules = rules
+	}
+}
+
+// WithRedactionPolicy 设置个人信息屏蔽策略
+func WithRedactionPolicy(policy RedactionPolicy) ChatServiceOption {
+	return func(s *ChatService) {
+		s.redaction = policy
+	}
+}
+
+// WithModelName 设置模型服务未返回模型名称时使用的名称
+func WithModelName(name string) ChatServiceOption {
+	return func(s *ChatService) {
+		if name != "" {
+			s.modelName = name
+		}
+	}
+}
+
+// NewChatService 创建聊天服务实例
+func NewChatService(llmClient *model.LLMClient, storage Storage, opts ...ChatServiceOption) *ChatService {
+	s := &ChatService{
+		llmClient: llmClient,
+		storage:   storage,
+		modelName: "default",
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Chat 处理聊天请求并返回模型响应，orgID为用户当前所在的组织，0表示个人空间
+func (s *ChatService) Chat(ctx context.Context, userID, orgID uint, req *ChatRequest) (*ChatResponse, error) {
+	var conversationID string
+	var err error
+
+	// 检查组织是否允许使用当前模型
+	settings, err := s.orgSettings(orgID)
+	if err != nil {
+		return nil, err
+	}
+	if settings != nil && !settings.AllowsModel(s.modelName) {
+		return nil, ErrModelNotAllowed
+	}
+
+	// 检查token配额
+	if s.quota != nil {
+		if err := s.quota.CheckQuota(ctx, userID, orgID); err != nil {
+			return nil, err
+		}
+	}
+
+	// 检查待发送的附件
+	var attachments []*Attachment
+	if len(req.AttachmentIDs) > 0 {
+		if s.attachments == nil {
+			return nil, errors.New("附件功能未启用")
+		}
+		attachments, err = s.attachments.Resolve(userID, req.AttachmentIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 审核用户输入，redact时后续流程使用屏蔽后的内容
+	content := req.Message
+	var inputModeration *ModerationResult
+	if s.moderation != nil && content != "" {
+		inputModeration, content, err = s.moderation.Check(ctx, content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 检查是新会话还是已有会话
+	var conv *Conversation
+	if req.ConversationID == "" {
+		// 未指定角色时使用组织的默认角色
+		persona := req.Persona
+		if persona == "" && settings != nil && s.tools != nil {
+			persona = settings.DefaultPersona
+		}
+
+		// 检查新会话的工具设置
+		if persona != "" || req.Tools != nil {
+			if err := s.validateToolSettings(persona, req.Tools); err != nil {
+				return nil, err
+			}
+		}
+		if settings != nil && s.tools != nil && !settings.AllowsPersona(persona) {
+			return nil, fmt.Errorf("%w: 当前组织不允许使用角色 %s", ErrInvalidToolSettings, persona)
+		}
+
+		// 创建新会话，只有附件时以文件名作为标题
+		title := createTitleFromMessage(content)
+		if content == "" && len(attachments) > 0 {
+			title = createTitleFromMessage(attachments[0].Filename)
+		}
+		conv, err = s.storage.CreateConversation(userID, orgID, s.redactForStorage(title))
+		if err != nil {
+			return nil, err
+		}
+		conversationID = conv.ID
+
+		if persona != "" || req.Tools != nil {
+			if err := s.storage.UpdateConversationTools(orgID, conversationID, persona, req.Tools); err != nil {
+				return nil, err
+			}
+			conv.Persona = persona
+			conv.Tools = req.Tools
+		}
+	} else {
+		// 验证会话属于当前组织且用户可以编辑
+		conv, _, err = s.conversationAccess(userID, orgID, req.ConversationID, ConversationRoleEditor)
+		if err != nil {
+			return nil, err
+		}
+		conversationID = req.ConversationID
+	}
+
+	// 保存用户消息，被拦截的消息同样保存以便人工审核
+	userMsg, err := s.saveMessage(&Message{
+		ConversationID: conversationID,
+		Role:           "user",
+		Content:        content,
+		Moderation:     inputModeration,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.publishMessage(ctx, conv, userMsg)
+	if inputModeration != nil && inputModeration.Action == ModerationBlock {
+		return nil, ErrContentBlocked
+	}
+
+	// 将附件关联到用户消息
+	if len(attachments) > 0 {
+		if err := s.attachments.Link(attachments, conversationID, userMsg.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	// 构建提示词
+	tools := s.enabledTools(conv)
+	prompt, citations, err := s.buildPrompt(ctx, conv.OrgID, conversationID, tools)
+	if err != nil {
+		return nil, err
+	}
+
+	// 确保参数有合理默认值
+	temperature := req.Temperature
+	if temperature == 0 {
+		temperature = 0.7
+	}
+
+	maxNewTokens := req.MaxNewTokens
+	if maxNewTokens == 0 {
+		maxNewTokens = 500
+	}
+
+	topK := req.TopK
+	if topK == 0 {
+		topK = 40
+	}
+
+	// 优先使用缓存的回复，缓存按组织隔离，避免组织的知识库和对话内容泄露给其他组织
+	params := cache.SamplingParams{
+		Temperature:  temperature,
+		MaxNewTokens: maxNewTokens,
+		TopK:         topK,
+	}
+	cacheable := s.responseCache != nil && s.responseCache.Cacheable(params)
+	if cacheable && !req.NoCache {
+		if cached, ok := s.responseCache.Lookup(ctx, orgID, s.modelName, prompt, params); ok {
+			// 提示词相同时注入的知识库内容也相同，本次检索的引用就是缓存回复所依据的引用
+			return s.saveCachedResponse(ctx, conv, content, cached.Response, cached.Model, citations)
+		}
+	}
+
+	// 新会话的首个问题可以复用语义相近问题的回答，带附件或注入了知识库引用的问题答案取决于这些内容，不使用语义缓存
+	var questionVector []float32
+	semanticCacheable := s.semanticCache != nil && req.ConversationID == "" && len(attachments) == 0 && len(citations) == 0
+	if semanticCacheable && !req.NoCache {
+		entry, vector, err := s.semanticCache.Lookup(ctx, orgID, content)
+		if err != nil {
+			log.Printf("语义缓存查询失败: %v", err)
+		} else if entry != nil {
+			return s.saveCachedResponse(ctx, conv, content, entry.Answer, entry.Model, nil)
+		}
+		questionVector = vector
+	}
+
+	// 调用模型生成回复，模型请求工具时执行工具后继续生成
+	s.publishGeneration(ctx, conv, GenerationStarted, nil, nil)
+	gen, err := s.generate(ctx, conv, prompt, params, tools)
+	if err != nil {
+		s.publishGeneration(ctx, conv, GenerationFailed, nil, nil)
+		return nil, err
+	}
+	usage := gen.usage
+	modelName := gen.model
+
+	// 审核模型回复，被拦截时以拒答内容代替，原文仅保留给审核人员
+	response := gen.response
+	var outputModeration *ModerationResult
+	if s.moderation != nil {
+		outputModeration, response, err = s.moderation.Check(ctx, response)
+		if err != nil {
+			return nil, err
+		}
+		if outputModeration != nil && outputModeration.Action == ModerationBlock {
+			outputModeration.Original = gen.response
+			response = s.moderation.RefusalMessage()
+		}
+	}
+
+	// 缓存保存的是应用安全规则之前的回复，命中缓存时重新评估规则
+	cacheResponse := response
+	finalResponse, firedRules := s.applySafetyRules(content, response)
+
+	// 保存模型回复
+	assistantMsg, err := s.saveMessage(&Message{
+		ConversationID: conversationID,
+		Role:           "assistant",
+		Content:        finalResponse,
+		Model:          modelName,
+		Usage:          usage,
+		Citations:      citations,
+		Moderation:     outputModeration,
+		SafetyRules:    firedRules,
+	})
+	if err != nil {
+		s.publishGeneration(ctx, conv, GenerationFailed, nil, nil)
+		return nil, err
+	}
+	s.publishMessage(ctx, conv, assistantMsg)
+	s.publishGeneration(ctx, conv, GenerationFinished, nil, nil)
+
+	// 写入响应缓存，使用过工具或命中审核规则的回答不写入缓存
+	skipCache := len(gen.toolResults) > 0 || outputModeration != nil
+	if cacheable && !skipCache {
+		if err := s.responseCache.Store(ctx, orgID, s.modelName, prompt, params, s.redactForStorage(cacheResponse)); err != nil {
+			log.Printf("写入响应缓存失败: %v", err)
+		}
+	}
+	if semanticCacheable && !skipCache {
+		if err := s.semanticCache.Store(ctx, orgID, s.redactForStorage(content), s.redactForStorage(cacheResponse), modelName, questionVector); err != nil {
+			log.Printf("写入语义缓存失败: %v", err)
+		}
+	}
+
+	// 记录用量
+	if err := s.storage.RecordUsage(userID, orgID, modelName, usage); err != nil {
+		log.Printf("记录用量统计失败: %v", err)
+	}
+	if s.quota != nil {
+		if err := s.quota.RecordUsage(ctx, userID, orgID, int64(usage.TotalTokens)); err != nil {
+			log.Printf("记录token用量失败: %v", err)
+		}
+	}
+
+	return &ChatResponse{
+		ConversationID: conversationID,
+		MessageID:      assistantMsg.ID,
+		Message:        finalResponse,
+		Role:           "assistant",
+		Model:          modelName,
+		Usage:          usage,
+		Citations:      citations,
+		ToolResults:    gen.toolResults,
+		Moderation:     outputModeration.public(),
+		SafetyRules:    firedRules,
+	}, nil
+}
+
+// generation 一次回复的生成结果
+type generation struct {
+	response    string
+	model       string
+	usage       *TokenUsage
+	toolResults []*ToolResult
+}
+
+// llmTokens 模型调用消耗的token数，模型服务未返回时为估算值
+var llmTokens = metrics.NewCounter("chat_llama_llm_tokens_total", "模型调用消耗的token数", "type")
+
+// generate 调用模型生成回复，模型请求工具时执行工具、保存工具消息并把结果追加到提示词中继续生成
+func (s *ChatService) generate(ctx context.Context, conv *Conversation, prompt string, params cache.SamplingParams, tools []*Tool) (*generation, error) {
+	conversationID := conv.ID
+	gen := &generation{
+		model: s.modelName,
+		usage: &TokenUsage{},
+	}
+	useTools := len(tools) > 0
+	enabled := make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		enabled[tool.Name] = true
+	}
+
+	for iteration := 0; ; iteration++ {
+		result, err := s.llmClient.GenerateResponse(
+			ctx,
+			prompt,
+			params.Temperature,
+			params.MaxNewTokens,
+			params.TopK,
+		)
+		if err != nil {
+			log.Printf("调用LLM服务失败: %v", err)
+			return nil, err
+		}
+
+		// 统计token用量，模型服务未返回时按字符数估算
+		promptTokens, completionTokens := result.PromptTokens, result.CompletionTokens
+		if promptTokens == 0 && completionTokens == 0 {
+			promptTokens = int32(EstimateTokens(prompt))
+			completionTokens = int32(EstimateTokens(result.Response))
+		}
+		gen.usage.PromptTokens += promptTokens
+		gen.usage.CompletionTokens += completionTokens
+		gen.usage.TotalTokens = gen.usage.PromptTokens + gen.usage.CompletionTokens
+		llmTokens.Add(float64(promptTokens), "prompt")
+		llmTokens.Add(float64(completionTokens), "completion")
+		gen.usage.LatencyMs += result.Latency.Milliseconds()
+		if result.Model != "" {
+			gen.model = result.Model
+		}
+
+		if !useTools {
+			gen.response = result.Response
+			return gen, nil
+		}
+
+		text, calls := ParseToolCalls(result.Response)
+		if len(calls) == 0 {
+			gen.response = result.Response
+			return gen, nil
+		}
+
+		// 达到轮数上限后不再执行工具，去掉调用标记作为最终回复
+		if iteration >= s.toolOptions.MaxIterations {
+			gen.response = text
+			if gen.response == "" {
+				gen.response = "抱歉，暂时无法完成这个请求，请换个方式提问。"
+			}
+			return gen, nil
+		}
+
+		// 保存发起工具调用的助手消息
+		callMsg, err := s.saveMessage(&Message{
+			ConversationID: conversationID,
+			Role:           "assistant",
+			Content:        text,
+			Model:          gen.model,
+			ToolCalls:      calls,
+		})
+		if err != nil {
+			return nil, err
+		}
+		s.publishMessage(ctx, conv, callMsg)
+
+		var sb strings.Builder
+		sb.WriteString(strings.TrimSuffix(prompt, assistantPrefix))
+		sb.WriteString(formatPromptMessage(callMsg))
+
+		// 依次执行工具并保存结果
+		for _, call := range calls {
+			emitToolEvent(ctx, &ToolEvent{Type: ToolEventCall, ConversationID: conversationID, Call: call})
+			s.publishGeneration(ctx, conv, GenerationToolCall, call, nil)
+
+			var toolResult *ToolResult
+			if enabled[call.Name] {
+				toolResult = s.tools.Execute(ctx, call, s.toolOptions.Timeout)
+			} else {
+				toolResult = &ToolResult{CallID: call.ID, Name: call.Name, Error: "当前会话未启用该工具: " + call.Name}
+			}
+			gen.toolResults = append(gen.toolResults, toolResult)
+
+			emitToolEvent(ctx, &ToolEvent{Type: ToolEventResult, ConversationID: conversationID, Result: toolResult})
+			s.publishGeneration(ctx, conv, GenerationToolResult, nil, toolResult)
+
+			output := toolResult.Output
+			if toolResult.Error != "" {
+				output = "错误: " + toolResult.Error
+			}
+			toolMsg, err := s.saveMessage(&Message{
+				ConversationID: conversationID,
+				Role:           "tool",
+				Content:        output,
+				ToolCallID:     call.ID,
+				ToolName:       call.Name,
+			})
+			if err != nil {
+				return nil, err
+			}
+			s.publishMessage(ctx, conv, toolMsg)
+			sb.WriteString(formatPromptMessage(toolMsg))
+		}
+
+		sb.WriteString(assistantPrefix)
+		prompt = sb.String()
+	}
+}
+
+// saveCachedResponse 保存来自缓存的回复及其知识库引用，缓存命中不消耗模型token
+func (s *ChatService) saveCachedResponse(ctx context.Context, conv *Conversation, question, response, modelName string, citations []*Citation) (*ChatResponse, error) {
+	response, firedRules := s.applySafetyRules(question, response)
+	assistantMsg, err := s.saveMessage(&Message{
+		ConversationID: conv.ID,
+		Role:           "assistant",
+		Content:        response,
+		Model:          modelName,
+		Citations:      citations,
+		SafetyRules:    firedRules,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.publishMessage(ctx, conv, assistantMsg)
+
+	return &ChatResponse{
+		ConversationID: conv.ID,
+		MessageID:      assistantMsg.ID,
+		Message:        response,
+		Role:           "assistant",
+		Model:          modelName,
+		Citations:      citations,
+		Cached:         true,
+		SafetyRules:    firedRules,
+	}, nil
+}
+
+// applySafetyRules 对回复应用医疗安全规则，返回处理后的回复及命中的规则
+func (s *ChatService) applySafetyRules(question, response string) (string, []string) {
+	if s.safetyRules == nil {
+		return response, nil
+	}
+	return s.safetyRules.Apply(question, response)
+}
+
+// redactForStorage 按策略屏蔽即将持久化的文本
+func (s *ChatService) redactForStorage(text string) string {
+	if !s.redaction.Storage {
+		return text
+	}
+	return s.redaction.Redactor.Redact(text)
+}
+
+// saveMessage 保存消息，策略要求时先屏蔽内容中的个人信息
+func (s *ChatService) saveMessage(msg *Message) (*Message, error) {
+	if s.redaction.Storage {
+		masked := *msg
+		masked.Content = s.redactForStorage(msg.Content)
+		if msg.Moderation != nil && msg.Moderation.Original != "" {
+			moderation := *msg.Moderation
+			moderation.Original = s.redactForStorage(moderation.Original)
+			masked.Moderation = &moderation
+		}
+		msg = &masked
+	}
+	return s.storage.SaveMessage(msg)
+}
+
+// GetConversations 获取用户在组织内创建的会话及共享给用户的会话，按更新时间倒序
+func (s *ChatService) GetConversations(userID, orgID uint) ([]*Conversation, error) {
+	owned, err := s.storage.GetConversationsByUserID(userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := s.storage.GetSharedConversations(userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 复制后再填写权限，避免修改存储中的对象
+	result := make([]*Conversation, 0, len(owned)+len(shared))
+	for _, conv := range owned {
+		c := *conv
+		c.Role = ConversationRoleOwner
+		result = append(result, &c)
+	}
+	for _, conv := range shared {
+		c := *conv
+		if member, err := s.storage.GetConversationMember(conv.ID, userID); err == nil {
+			c.Role = member.Role
+		}
+		result = append(result, &c)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].UpdatedAt.After(result[j].UpdatedAt)
+	})
+
+	return result, nil
+}
+
+// GetConversationHistory 获取会话的消息历史
+func (s *ChatService) GetConversationHistory(userID, orgID uint, conversationID string) ([]*Message, error) {
+	// 检查会话权限
+	if _, _, err := s.conversationAccess(userID, orgID, conversationID, ConversationRoleViewer); err != nil {
+		return nil, err
+	}
+
+	return s.conversationMessages(conversationID, true)
+}
+
+// ReviewConversation 管理员查看任意会话用于滥用审查，不检查归属，包含被审核拦截的原文
+func (s *ChatService) ReviewConversation(conversationID string) (*ConversationExport, error) {
+	conv, err := s.storage.GetConversationUnscoped(conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := s.conversationMessages(conversationID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConversationExport{
+		Conversation: conv,
+		Messages:     messages,
+		ExportedAt:   time.Now(),
+	}, nil
+}
+
+// conversationMessages 获取会话消息的副本并补充附件，public为true时隐藏审核原文
+func (s *ChatService) conversationMessages(conversationID string, public bool) ([]*Message, error) {
+	messages, err := s.storage.GetMessagesByConversationID(conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 复制消息后再补充附件和隐藏审核原文，避免修改存储中的对象
+	copied := make([]*Message, len(messages))
+	for i, msg := range messages {
+		m := *msg
+		if public {
+			m.Moderation = msg.Moderation.public()
+		}
+		copied[i] = &m
+	}
+	messages = copied
+
+	// 补充消息携带的附件
+	if s.attachments != nil {
+		byMessage, err := s.attachments.ByMessage(conversationID)
+		if err != nil {
+			log.Printf("获取会话附件失败: %v", err)
+		} else if len(byMessage) > 0 {
+			for _, msg := range messages {
+				msg.Attachments = byMessage[msg.ID]
+			}
+		}
+	}
+
+	return messages, nil
+}
+
+// ConversationExport 导出的会话
+type ConversationExport struct {
+	Conversation *Conversation `json:"conversation"`
+	Messages     []*Message    `json:"messages"`
+	ExportedAt   time.Time     `json:"exported_at"`
+}
+
+// ExportConversation 导出会话及消息，策略要求时屏蔽其中的个人信息
+func (s *ChatService) ExportConversation(userID, orgID uint, conversationID string) (*ConversationExport, error) {
+	messages, err := s.GetConversationHistory(userID, orgID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.storage.GetConversation(orgID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	conv := *stored
+
+	// GetConversationHistory返回的是副本，可以直接修改
+	if s.redaction.Exports {
+		conv.Title = s.redaction.Redactor.Redact(conv.Title)
+		for _, msg := range messages {
+			msg.Content = s.redaction.Redactor.Redact(msg.Content)
+		}
+	}
+
+	return &ConversationExport{
+		Conversation: &conv,
+		Messages:     messages,
+		ExportedAt:   time.Now(),
+	}, nil
+}
+
+// DeleteConversation 删除会话，仅所有者可以删除
+func (s *ChatService) DeleteConversation(ctx context.Context, userID, orgID uint, conversationID string) error {
+	// 检查会话权限
+	conv, _, err := s.conversationAccess(userID, orgID, conversationID, ConversationRoleOwner)
+	if err != nil {
+		return err
+	}
+
+	// 删除会话会一并删除成员，先获取需要通知的用户
+	recipients := s.conversationRecipients(conv)
+	if err := s.storage.DeleteConversation(orgID, conversationID); err != nil {
+		return err
+	}
+
+	// 附件属于会话历史，随会话一起删除
+	if s.attachments != nil {
+		if err := s.attachments.DeleteByConversation(ctx, conversationID); err != nil {
+			log.Printf("删除会话附件失败: %v", err)
+		}
+	}
+
+	s.publishTo(ctx, recipients, conv, &Event{Type: EventConversationDeleted})
+	return nil
+}
+
+// buildPrompt 构建发送给LLM的提示词，启用知识库时检索会话所属组织的知识库并返回注入的引用
+func (s *ChatService) buildPrompt(ctx context.Context, orgID uint, conversationID string, tools []*Tool) (string, []*Citation, error) {
+	messages, err := s.storage.GetMessagesByConversationID(conversationID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// 构建提示词，包含历史对话
+	var sb strings.Builder
+
+	// 说明可用工具及调用方式
+	sb.WriteString(BuildToolsPrompt(tools))
+
+	// 检索与最新用户消息相关的知识库片段
+	var citations []*Citation
+	if s.knowledge != nil {
+		if query := latestUserMessage(messages); query != "" {
+			chunks, err := s.knowledge.Retrieve(ctx, orgID, query)
+			if err != nil {
+				log.Printf("检索知识库失败: %v", err)
+			}
+			var knowledgeContext string
+			knowledgeContext, citations = BuildContext(chunks)
+			sb.WriteString(knowledgeContext)
+		}
+	}
+
+	// 在预算内注入附件内容
+	var attachmentSections map[string]string
+	if s.attachments != nil {
+		byMessage, err := s.attachments.ByMessage(conversationID)
+		if err != nil {
+			log.Printf("获取会话附件失败: %v", err)
+		} else if len(byMessage) > 0 {
+			attachmentSections = s.attachments.PromptSections(messages, byMessage)
+		}
+	}
+
+	for _, msg := range messages {
+		// 被拦截的用户消息不发送给模型
+		if msg.Moderation != nil && msg.Moderation.Action == ModerationBlock && msg.Role == "user" {
+			continue
+		}
+		if msg.Role == "user" {
+			sb.WriteString(attachmentSections[msg.ID])
+		}
+		sb.WriteString(formatPromptMessage(msg))
+	}
+
+	// 增加最后的提示
+	sb.WriteString(assistantPrefix)
+
+	return sb.String(), citations, nil
+}
+
+// assistantPrefix 提示词中助手发言的前缀
+const assistantPrefix = "助手: "
+
+// formatPromptMessage 将一条历史消息格式化为提示词中的一行
+func formatPromptMessage(msg *Message) string {
+	switch msg.Role {
+	case "user":
+		return "用户: " + msg.Content + "\n"
+	case "assistant":
+		content := msg.Content
+		for _, call := range msg.ToolCalls {
+			content += formatToolCall(call)
+		}
+		return assistantPrefix + content + "\n"
+	case "tool":
+		return "工具结果(" + msg.ToolName + "): " + msg.Content + "\n"
+	}
+	return ""
+}
+
+// latestUserMessage 获取最后一条用户消息
+func latestUserMessage(messages []*Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// createTitleFromMessage 从消息内容创建会话标题
+func createTitleFromMessage(message string) string {
+	if len(message) == 0 {
+		return "新对话"
+	}
+
+	// 将字符串转换为[]rune以正确处理Unicode字符
+	runes := []rune(message)
+	if len(runes) > 20 {
+		// 截取前20个字符（而不是字节）
+		return string(runes[:20]) + "..."
+	}
+
+	return message
+}
+
+// UpdateConversationTitle 更新会话标题，需要编辑权限
+func (s *ChatService) UpdateConversationTitle(ctx context.Context, userID, orgID uint, conversationID string, title string) error {
+	// 检查会话权限
+	conv, _, err := s.conversationAccess(userID, orgID, conversationID, ConversationRoleEditor)
+	if err != nil {
+		return err
+	}
+
+	// 与创建会话时一样，按策略屏蔽标题中的个人信息
+	title = s.redactForStorage(title)
+	if err := s.storage.UpdateConversationTitle(orgID, conversationID, title); err != nil {
+		return err
+	}
+
+	s.publish(ctx, conv, &Event{Type: EventTitle, Title: title})
+	return nil
+}
+
+// enabledTools 获取会话启用的工具，会话未单独设置时按角色设定
+func (s *ChatService) enabledTools(conv *Conversation) []*Tool {
+	if s.tools == nil {
+		return nil
+	}
+
+	names := conv.Tools
+	if names == nil {
+		persona := conv.Persona
+		if persona == "" {
+			persona = DefaultPersona
+		}
+		var exists bool
+		names, exists = s.toolOptions.Personas[persona]
+		if !exists {
+			return s.tools.List()
+		}
+	}
+
+	var result []*Tool
+	for _, name := range names {
+		if tool, exists := s.tools.Get(name); exists {
+			result = append(result, tool)
+		}
+	}
+
+	return result
+}
+
+// validateToolSettings 检查角色和工具名称均已配置
+func (s *ChatService) validateToolSettings(persona string, tools []string) error {
+	if s.tools == nil {
+		return fmt.Errorf("%w: 工具调用未启用", ErrInvalidToolSettings)
+	}
+	if persona != "" && persona != DefaultPersona {
+		if _, exists := s.toolOptions.Personas[persona]; !exists {
+			return fmt.Errorf("%w: 未知的角色 %s", ErrInvalidToolSettings, persona)
+		}
+	}
+	for _, name := range tools {
+		if _, exists := s.tools.Get(name); !exists {
+			return fmt.Errorf("%w: 未知的工具 %s", ErrInvalidToolSettings, name)
+		}
+	}
+
+	return nil
+}
+
+// ToolCatalog 获取组织内可用的工具及角色，未启用工具调用时返回nil
+func (s *ChatService) ToolCatalog(orgID uint) (*ToolCatalog, error) {
+	if s.tools == nil {
+		return nil, nil
+	}
+
+	settings, err := s.orgSettings(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	personas := s.toolOptions.Personas
+	if settings != nil && len(settings.Personas) > 0 {
+		personas = make(map[string][]string)
+		for name, tools := range s.toolOptions.Personas {
+			if settings.AllowsPersona(name) {
+				personas[name] = tools
+			}
+		}
+	}
+
+	return &ToolCatalog{
+		Tools:    s.tools.List(),
+		Personas: personas,
+	}, nil
+}
+
+// UpdateConversationTools 设置会话的角色及启用的工具，tools为nil时按角色设定，需要编辑权限
+func (s *ChatService) UpdateConversationTools(userID, orgID uint, conversationID, persona string, tools []string) error {
+	// 检查会话权限
+	if _, _, err := s.conversationAccess(userID, orgID, conversationID, ConversationRoleEditor); err != nil {
+		return err
+	}
+
+	if err := s.validateToolSettings(persona, tools); err != nil {
+		return err
+	}
+
+	settings, err := s.orgSettings(orgID)
+	if err != nil {
+		return err
+	}
+	if settings != nil && !settings.AllowsPersona(persona) {
+		return fmt.Errorf("%w: 当前组织不允许使用角色 %s", ErrInvalidToolSettings, persona)
+	}
+
+	return s.storage.UpdateConversationTools(orgID, conversationID, persona, tools)
+}
+
+// orgSettings 获取组织设置，个人空间或未启用组织时返回nil
+func (s *ChatService) orgSettings(orgID uint) (*OrgSettings, error) {
+	if orgID == 0 || s.orgs == nil {
+		return nil, nil
+	}
+	return s.orgs.Settings(orgID)
+}
+
+// GetUsageStats 获取用户最近若干天在组织内的用量统计
+func (s *ChatService) GetUsageStats(userID, orgID uint, days int) ([]*UsageStat, error) {
+	since := time.Now().AddDate(0, 0, -(days - 1))
+	return s.storage.GetUsageStats(userID, orgID, since)
+}
+
+// ResponseCacheStats 获取响应缓存的命中统计，未启用缓存时返回nil
+func (s *ChatService) ResponseCacheStats() *cache.ResponseCacheStats {
+	if s.responseCache == nil {
+		return nil
+	}
+	stats := s.responseCache.Stats()
+	return &stats
+}
+
+// SemanticCache 获取语义缓存，未启用时返回nil
+func (s *ChatService) SemanticCache() *SemanticCache {
+	return s.semanticCache
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+)
+
+// 文档格式
+const (
+	DocumentFormatText     = "text"
+	DocumentFormatMarkdown = "markdown"
+	DocumentFormatPDF      = "pdf" // 从PDF中提取出的纯文本
+)
+
+var (
+	markdownImagePattern   = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	markdownLinkPattern    = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	markdownHeadingPattern = regexp.MustCompile(`(?m)^#{1,6}\s+`)
+	markdownEmphasis       = regexp.MustCompile("(\\*\\*|__|`)")
+	blankLinesPattern      = regexp.MustCompile(`\n\s*\n+`)
+)
+
+// NormalizeDocument 将不同格式的文档整理为便于切分的纯文本，段落之间以空行分隔
+func NormalizeDocument(content, format string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	switch format {
+	case DocumentFormatMarkdown:
+		content = markdownImagePattern.ReplaceAllString(content, "$1")
+		content = markdownLinkPattern.ReplaceAllString(content, "$1")
+		content = markdownHeadingPattern.ReplaceAllString(content, "")
+		content = markdownEmphasis.ReplaceAllString(content, "")
+	case DocumentFormatPDF:
+		// PDF提取的文本常在句中换行，并以换页符分页
+		content = strings.ReplaceAll(content, "\f", "\n\n")
+		paragraphs := blankLinesPattern.Split(content, -1)
+		for i, paragraph := range paragraphs {
+			lines := strings.Split(paragraph, "\n")
+			for j := range lines {
+				lines[j] = strings.TrimSpace(lines[j])
+			}
+			paragraphs[i] = strings.Join(lines, "")
+		}
+		content = strings.Join(paragraphs, "\n\n")
+	}
+
+	return strings.TrimSpace(blankLinesPattern.ReplaceAllString(content, "\n\n"))
+}
+
+// ChunkText 按段落将文本切分为不超过size个字符的片段，相邻片段重叠overlap个字符
+func ChunkText(text string, size, overlap int) []string {
+	if size <= 0 {
+		size = 300
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	var chunks []string
+	var current []rune
+
+	flush := func() {
+		chunk := strings.TrimSpace(string(current))
+		if chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		// 保留末尾部分作为下一个片段的开头
+		if overlap > 0 && len(current) > overlap {
+			current = append([]rune{}, current[len(current)-overlap:]...)
+		} else {
+			current = nil
+		}
+	}
+
+	for _, paragraph := range blankLinesPattern.Split(text, -1) {
+		runes := []rune(strings.TrimSpace(paragraph))
+		if len(runes) == 0 {
+			continue
+		}
+
+		// 当前片段放不下整个段落时先输出
+		if len(current) > overlap && len(current)+len(runes)+1 > size {
+			flush()
+		}
+
+		// 段落之间以换行分隔
+		if len(current) > 0 {
+			current = append(current, '\n')
+		}
+
+		// 超长段落按固定长度切开
+		for len(runes) > 0 {
+			room := size - len(current)
+			if room <= 0 {
+				flush()
+				continue
+			}
+			if len(runes) <= room {
+				current = append(current, runes...)
+				break
+			}
+			current = append(current, runes[:room]...)
+			runes = runes[room:]
+			flush()
+		}
+	}
+
+	if len(current) > overlap || len(chunks) == 0 {
+		flush()
+	}
+
+	return chunks
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 用户对会话的权限
+const (
+	ConversationRoleViewer = "viewer" // 查看和导出消息
+	ConversationRoleEditor = "editor" // 发送消息、修改标题及工具设置
+	ConversationRoleOwner  = "owner"  // 创建者，可以共享和删除会话
+)
+
+var (
+	// ErrConversationForbidden 用户不是会话的所有者或成员，或权限不足
+	ErrConversationForbidden = errors.New("无权访问此会话")
+	// ErrInvalidShare 共享对象或角色无效
+	ErrInvalidShare = errors.New("无效的共享设置")
+)
+
+// conversationRoleRanks 会话权限的高低，用于比较权限
+var conversationRoleRanks = map[string]int{
+	ConversationRoleViewer: 1,
+	ConversationRoleEditor: 2,
+	ConversationRoleOwner:  3,
+}
+
+// conversationAccess 获取组织内的会话并检查用户的权限不低于minRole，返回会话及用户的权限
+func (s *ChatService) conversationAccess(userID, orgID uint, conversationID, minRole string) (*Conversation, string, error) {
+	conv, err := s.storage.GetConversation(orgID, conversationID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	role := ConversationRoleOwner
+	if conv.UserID != userID {
+		member, err := s.storage.GetConversationMember(conversationID, userID)
+		if err != nil {
+			return nil, "", ErrConversationForbidden
+		}
+		role = member.Role
+	}
+	if conversationRoleRanks[role] < conversationRoleRanks[minRole] {
+		return nil, "", ErrConversationForbidden
+	}
+
+	return conv, role, nil
+}
+
+// CheckConversationAccess 检查用户对组织内会话的权限不低于minRole
+func (s *ChatService) CheckConversationAccess(userID, orgID uint, conversationID, minRole string) error {
+	_, _, err := s.conversationAccess(userID, orgID, conversationID, minRole)
+	return err
+}
+
+// ConversationMembers 获取会话的成员，会话成员均可查看
+func (s *ChatService) ConversationMembers(userID, orgID uint, conversationID string) ([]*ConversationMember, error) {
+	if _, _, err := s.conversationAccess(userID, orgID, conversationID, ConversationRoleViewer); err != nil {
+		return nil, err
+	}
+	return s.storage.ListConversationMembers(conversationID)
+}
+
+// ShareConversation 把会话共享给用户或修改其权限，仅所有者可以操作，组织内的会话只能共享给组织成员
+func (s *ChatService) ShareConversation(actorID, orgID uint, conversationID string, memberID uint, role string) error {
+	if role != ConversationRoleViewer && role != ConversationRoleEditor {
+		return fmt.Errorf("%w: 未知的会话角色 %s", ErrInvalidShare, role)
+	}
+
+	conv, _, err := s.conversationAccess(actorID, orgID, conversationID, ConversationRoleOwner)
+	if err != nil {
+		return err
+	}
+	if memberID == conv.UserID {
+		return fmt.Errorf("%w: 不能共享给会话所有者", ErrInvalidShare)
+	}
+	if conv.OrgID != 0 && s.orgs != nil {
+		if err := s.orgs.CheckRole(memberID, conv.OrgID, OrgRoleMember); err != nil {
+			return fmt.Errorf("%w: 用户不是组织成员", ErrInvalidShare)
+		}
+	}
+
+	return s.storage.SaveConversationMember(&ConversationMember{
+		ConversationID: conversationID,
+		UserID:         memberID,
+		Role:           role,
+		AddedBy:        actorID,
+		CreatedAt:      time.Now(),
+	})
+}
+
+// UpdateConversationMember 修改已有会话成员的权限
+func (s *ChatService) UpdateConversationMember(actorID, orgID uint, conversationID string, memberID uint, role string) error {
+	if _, _, err := s.conversationAccess(actorID, orgID, conversationID, ConversationRoleOwner); err != nil {
+		return err
+	}
+	if _, err := s.storage.GetConversationMember(conversationID, memberID); err != nil {
+		return fmt.Errorf("%w: 用户不是会话成员", ErrInvalidShare)
+	}
+	return s.ShareConversation(actorID, orgID, conversationID, memberID, role)
+}
+
+// UnshareConversation 移除会话成员，成员可以自行退出共享
+func (s *ChatService) UnshareConversation(actorID, orgID uint, conversationID string, memberID uint) error {
+	_, role, err := s.conversationAccess(actorID, orgID, conversationID, ConversationRoleViewer)
+	if err != nil {
+		return err
+	}
+	if role != ConversationRoleOwner && actorID != memberID {
+		return ErrConversationForbidden
+	}
+
+	if err := s.storage.DeleteConversationMember(conversationID, memberID); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidShare, err)
+	}
+	return nil
+}
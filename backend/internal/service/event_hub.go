@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 推送给用户已连接客户端的事件类型
+const (
+	EventMessage             = "message"              // 会话中保存了新消息
+	EventTitle               = "conversation_title"   // 会话标题已修改
+	EventConversationDeleted = "conversation_deleted" // 会话已删除
+	EventGeneration          = "generation"           // 回复生成的进度
+)
+
+// 回复生成的阶段
+const (
+	GenerationStarted    = "started"
+	GenerationToolCall   = "tool_call"
+	GenerationToolResult = "tool_result"
+	GenerationFinished   = "finished"
+	GenerationFailed     = "failed"
+)
+
+// subscriptionBuffer 每个订阅缓存的事件数，客户端消费过慢时丢弃新事件
+const subscriptionBuffer = 64
+
+// ErrReplayUnavailable 未配置事件缓冲区，无法补发事件
+var ErrReplayUnavailable = errors.New("未启用断线重连")
+
+// Event 推送给用户已连接客户端的事件
+type Event struct {
+	Seq            uint64      `json:"seq,omitempty"` // 用户事件的序号，每个用户单调递增
+	Type           string      `json:"type"`
+	ConversationID string      `json:"conversation_id"`
+	OrgID          uint        `json:"org_id"` // 会话所属组织，客户端只处理当前组织的事件
+	Message        *Message    `json:"message,omitempty"`
+	Title          string      `json:"title,omitempty"`
+	Status         string      `json:"status,omitempty"` // 生成进度事件的阶段
+	Call           *ToolCall   `json:"call,omitempty"`
+	Result         *ToolResult `json:"result,omitempty"`
+	Origin         string      `json:"-"` // 触发事件的订阅，不再推送给它
+}
+
+// EventPublisher 向用户推送事件
+type EventPublisher interface {
+	Publish(userIDs []uint, event *Event)
+}
+
+// EventBackplane 在多个后端实例间转发事件，每个实例都会收到所有广播，包括自己发出的
+type EventBackplane interface {
+	Publish(ctx context.Context, payload []byte) error
+	// Subscribe 接收广播并依次回调，阻塞直到ctx取消或连接失败
+	Subscribe(ctx context.Context, handler func(payload []byte)) error
+}
+
+// eventEnvelope 经由backplane转发的事件
+type eventEnvelope struct {
+	Instance   string          `json:"instance"` // 发出事件的实例，实例收到自己发出的事件时忽略
+	Origin     string          `json:"origin,omitempty"`
+	Deliveries []eventDelivery `json:"deliveries"`
+}
+
+// eventDelivery 发给一个用户的事件，各用户的序号不同
+type eventDelivery struct {
+	UserID uint   `json:"user_id"`
+	Event  *Event `json:"event"`
+}
+
+type eventOriginKey struct{}
+
+// WithEventOrigin 在上下文中记录发起请求的订阅，该订阅已通过请求本身拿到结果
+func WithEventOrigin(ctx context.Context, subscriptionID string) context.Context {
+	return context.WithValue(ctx, eventOriginKey{}, subscriptionID)
+}
+
+// eventOrigin 获取上下文中发起请求的订阅
+func eventOrigin(ctx context.Context) string {
+	origin, _ := ctx.Value(eventOriginKey{}).(string)
+	return origin
+}
+
+// Subscription 一个客户端连接对用户事件的订阅
+type Subscription struct {
+	ID     string
+	UserID uint
+	events chan *Event
+	hub    *EventHub
+	once   sync.Once
+}
+
+// Events 返回接收事件的通道，订阅关闭后通道关闭
+func (s *Subscription) Events() <-chan *Event {
+	return s.events
+}
+
+// Close 取消订阅
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.remove(s)
+	})
+}
+
+// publishLock 用户的发布锁，refs为等待或持有该锁的发布数，为0时从表中移除
+type publishLock struct {
+	sync.Mutex
+	refs int
+}
+
+// EventHub 把事件分发给用户在所有设备上的连接，配置backplane时同时转发给其他实例
+type EventHub struct {
+	instanceID   string
+	backplane    EventBackplane
+	eventLog     EventLog
+	subscribers  map[uint]map[string]*Subscription // 用户ID到订阅ID到订阅
+	mutex        sync.RWMutex
+	publishLocks map[uint]*publishLock // 保证当前实例按序号顺序分发同一用户的事件，不同用户互不等待
+	locksMutex   sync.Mutex
+}
+
+// NewEventHub 创建事件中心，backplane为nil时只分发给当前实例上的连接，eventLog为nil时事件没有序号且不能补发
+func NewEventHub(backplane EventBackplane, eventLog EventLog) *EventHub {
+	return &EventHub{
+		instanceID:   uuid.New().String(),
+		backplane:    backplane,
+		eventLog:     eventLog,
+		subscribers:  make(map[uint]map[string]*Subscription),
+		publishLocks: make(map[uint]*publishLock),
+	}
+}
+
+// Run 接收其他实例转发的事件，阻塞直到ctx取消，连接断开时自动重试
+func (h *EventHub) Run(ctx context.Context) {
+	if h.backplane == nil {
+		return
+	}
+
+	for {
+		err := h.backplane.Subscribe(ctx, h.receive)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("订阅事件广播失败，稍后重试: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// Subscribe 订阅用户的事件，使用完毕后需要调用Close
+func (h *EventHub) Subscribe(userID uint) *Subscription {
+	sub := &Subscription{
+		ID:     uuid.New().String(),
+		UserID: userID,
+		events: make(chan *Event, subscriptionBuffer),
+		hub:    h,
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	subs, exists := h.subscribers[userID]
+	if !exists {
+		subs = make(map[string]*Subscription)
+		h.subscribers[userID] = subs
+	}
+	subs[sub.ID] = sub
+
+	return sub
+}
+
+// Publish 为每个用户分配序号后推送给其所有订阅，跳过发起事件的订阅。
+// 分配序号和本地分发时只持有该用户的锁，广播给其他实例时不持有任何锁
+func (h *EventHub) Publish(userIDs []uint, event *Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	deliveries := make([]eventDelivery, 0, len(userIDs))
+	for _, userID := range userIDs {
+		deliveries = append(deliveries, eventDelivery{UserID: userID, Event: h.publishLocal(ctx, userID, event)})
+	}
+
+	if h.backplane == nil {
+		return
+	}
+
+	payload, err := json.Marshal(&eventEnvelope{
+		Instance:   h.instanceID,
+		Origin:     event.Origin,
+		Deliveries: deliveries,
+	})
+	if err != nil {
+		log.Printf("序列化事件失败: %v", err)
+		return
+	}
+	if err := h.backplane.Publish(ctx, payload); err != nil {
+		log.Printf("广播事件失败: %v", err)
+	}
+}
+
+// publishLocal 为用户分配序号并分发给当前实例上的订阅，同一用户的发布依次进行
+func (h *EventHub) publishLocal(ctx context.Context, userID uint, event *Event) *Event {
+	lock := h.lockUser(userID)
+	defer h.unlockUser(userID, lock)
+
+	e := *event
+	if h.eventLog != nil {
+		if err := h.eventLog.Append(ctx, userID, &e); err != nil {
+			log.Printf("保存用户 %d 的事件失败: %v", userID, err)
+		}
+	}
+	h.deliver(userID, &e)
+
+	return &e
+}
+
+// lockUser 获取用户的发布锁
+func (h *EventHub) lockUser(userID uint) *publishLock {
+	h.locksMutex.Lock()
+	lock, exists := h.publishLocks[userID]
+	if !exists {
+		lock = &publishLock{}
+		h.publishLocks[userID] = lock
+	}
+	lock.refs++
+	h.locksMutex.Unlock()
+
+	lock.Lock()
+	return lock
+}
+
+// unlockUser 释放用户的发布锁，没有其他发布等待时移除
+func (h *EventHub) unlockUser(userID uint, lock *publishLock) {
+	lock.Unlock()
+
+	h.locksMutex.Lock()
+	defer h.locksMutex.Unlock()
+	lock.refs--
+	if lock.refs == 0 {
+		delete(h.publishLocks, userID)
+	}
+}
+
+// Replay 获取用户序号大于after的事件，用于断线重连后补发
+func (h *EventHub) Replay(ctx context.Context, userID uint, after uint64) (*Replay, error) {
+	if h.eventLog == nil {
+		return nil, ErrReplayUnavailable
+	}
+	return h.eventLog.Since(ctx, userID, after)
+}
+
+// QueueDepth 返回当前实例上所有订阅中等待推送给客户端的事件数
+func (h *EventHub) QueueDepth() int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	depth := 0
+	for _, subs := range h.subscribers {
+		for _, sub := range subs {
+			depth += len(sub.events)
+		}
+	}
+	return depth
+}
+
+// receive 处理backplane转发的事件，忽略当前实例发出的事件
+func (h *EventHub) receive(payload []byte) {
+	var envelope eventEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		log.Printf("解析广播事件失败: %v", err)
+		return
+	}
+	if envelope.Instance == h.instanceID {
+		return
+	}
+
+	for _, delivery := range envelope.Deliveries {
+		if delivery.Event == nil {
+			continue
+		}
+		delivery.Event.Origin = envelope.Origin
+		h.deliver(delivery.UserID, delivery.Event)
+	}
+}
+
+// deliver 把事件放入当前实例上用户订阅的缓冲区
+func (h *EventHub) deliver(userID uint, event *Event) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	for id, sub := range h.subscribers[userID] {
+		if id == event.Origin {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			log.Printf("用户 %d 的事件缓冲区已满，丢弃事件 %s", userID, event.Type)
+		}
+	}
+}
+
+// remove 移除订阅并关闭其事件通道
+func (h *EventHub) remove(sub *Subscription) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if subs, exists := h.subscribers[sub.UserID]; exists {
+		delete(subs, sub.ID)
+		if len(subs) == 0 {
+			delete(h.subscribers, sub.UserID)
+		}
+	}
+	close(sub.events)
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"sync"
+
+	"chat-llama/pkg/cache"
+)
+
+// Replay 断线重连后补发的事件
+type Replay struct {
+	Events  []*Event `json:"-"`
+	LastSeq uint64   `json:"last_seq"` // 用户当前最新的事件序号
+	Reset   bool     `json:"reset"`    // 缓冲区已不包含全部错过的事件，客户端需要重新加载会话
+}
+
+// EventLog 为每个用户的事件分配单调递增的序号，并保留最近的事件用于重连后补发
+type EventLog interface {
+	// Append 为用户追加事件并把分配的序号写入event.Seq
+	Append(ctx context.Context, userID uint, event *Event) error
+	// Since 获取序号大于after的事件
+	Since(ctx context.Context, userID uint, after uint64) (*Replay, error)
+}
+
+// newReplay 根据缓冲区中的事件判断是否缺少after之后的事件
+func newReplay(events []*Event, after, latest uint64) *Replay {
+	replay := &Replay{Events: events, LastSeq: latest}
+	switch {
+	case after > latest:
+		// 序号已重置，例如缓冲区过期
+		replay.Reset = true
+	case after < latest:
+		replay.Reset = len(events) == 0 || events[0].Seq > after+1
+	}
+	return replay
+}
+
+// userEventLog 单个用户的事件缓冲区
+type userEventLog struct {
+	seq    uint64
+	events []*Event
+}
+
+// MemoryEventLog 基于内存的事件缓冲区，只适用于单实例部署
+type MemoryEventLog struct {
+	size  int
+	users map[uint]*userEventLog
+	mutex sync.Mutex
+}
+
+// NewMemoryEventLog 创建内存事件缓冲区，每个用户最多保留size个事件
+func NewMemoryEventLog(size int) *MemoryEventLog {
+	return &MemoryEventLog{
+		size:  size,
+		users: make(map[uint]*userEventLog),
+	}
+}
+
+// Append 为用户追加事件
+func (l *MemoryEventLog) Append(ctx context.Context, userID uint, event *Event) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	user, exists := l.users[userID]
+	if !exists {
+		user = &userEventLog{}
+		l.users[userID] = user
+	}
+
+	user.seq++
+	event.Seq = user.seq
+	user.events = append(user.events, event)
+	if len(user.events) > l.size {
+		user.events = user.events[len(user.events)-l.size:]
+	}
+
+	return nil
+}
+
+// Since 获取序号大于after的事件
+func (l *MemoryEventLog) Since(ctx context.Context, userID uint, after uint64) (*Replay, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	user, exists := l.users[userID]
+	if !exists {
+		return newReplay(nil, after, 0), nil
+	}
+
+	var events []*Event
+	for _, event := range user.events {
+		if event.Seq > after {
+			events = append(events, event)
+		}
+	}
+
+	return newReplay(events, after, user.seq), nil
+}
+
+// RedisEventLog 基于Redis的事件缓冲区，多个实例共享序号
+type RedisEventLog struct {
+	log *cache.SequenceLog
+}
+
+// NewRedisEventLog 创建Redis事件缓冲区
+func NewRedisEventLog(seqLog *cache.SequenceLog) *RedisEventLog {
+	return &RedisEventLog{log: seqLog}
+}
+
+// Append 为用户追加事件
+func (l *RedisEventLog) Append(ctx context.Context, userID uint, event *Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	seq, err := l.log.Append(ctx, strconv.FormatUint(uint64(userID), 10), payload)
+	if err != nil {
+		return err
+	}
+	event.Seq = seq
+
+	return nil
+}
+
+// Since 获取序号大于after的事件
+func (l *RedisEventLog) Since(ctx context.Context, userID uint, after uint64) (*Replay, error) {
+	entries, latest, err := l.log.Since(ctx, strconv.FormatUint(uint64(userID), 10), after)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*Event, 0, len(entries))
+	for _, entry := range entries {
+		var event Event
+		if err := json.Unmarshal(entry.Payload, &event); err != nil {
+			log.Printf("解析缓冲的事件失败: %v", err)
+			continue
+		}
+		event.Seq = entry.Seq
+		events = append(events, &event)
+	}
+
+	return newReplay(events, after, latest), nil
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"chat-llama/pkg/vectorstore"
+
+	"github.com/google/uuid"
+)
+
+// embedBatchSize 每次调用Embed的片段数量
+const embedBatchSize = 16
+
+// KnowledgeOptions 知识库切分与检索参数
+type KnowledgeOptions struct {
+	ChunkSize    int
+	ChunkOverlap int
+	TopK         int
+	MinScore     float32
+}
+
+// RetrievedChunk 检索到的知识库片段
+type RetrievedChunk struct {
+	Chunk    *KnowledgeChunk
+	Document *KnowledgeDocument
+	Score    float32
+}
+
+// KnowledgeService 管理知识库文档并为对话检索相关片段
+type KnowledgeService struct {
+	storage  KnowledgeStorage
+	embedder Embedder
+	vectors  vectorstore.Store
+	options  KnowledgeOptions
+}
+
+// NewKnowledgeService 创建知识库服务
+func NewKnowledgeService(storage KnowledgeStorage, embedder Embedder, vectors vectorstore.Store, options KnowledgeOptions) *KnowledgeService {
+	if options.ChunkSize <= 0 {
+		options.ChunkSize = 300
+	}
+	if options.TopK <= 0 {
+		options.TopK = 3
+	}
+
+	return &KnowledgeService{
+		storage:  storage,
+		embedder: embedder,
+		vectors:  vectors,
+		options:  options,
+	}
+}
+
+// AddDocument 切分文档、计算向量并写入组织的知识库，orgID为0表示个人空间
+func (k *KnowledgeService) AddDocument(ctx context.Context, userID, orgID uint, title, source, format, content string) (*KnowledgeDocument, error) {
+	switch format {
+	case DocumentFormatText, DocumentFormatMarkdown, DocumentFormatPDF:
+	case "":
+		format = DocumentFormatText
+	default:
+		return nil, fmt.Errorf("不支持的文档格式: %s", format)
+	}
+
+	text := NormalizeDocument(content, format)
+	if text == "" {
+		return nil, errors.New("文档内容为空")
+	}
+	if title == "" {
+		title = source
+	}
+	if title == "" {
+		title = createTitleFromMessage(text)
+	}
+
+	doc := &KnowledgeDocument{
+		ID:        uuid.New().String(),
+		OrgID:     orgID,
+		Title:     title,
+		Source:    source,
+		Format:    format,
+		Size:      len([]rune(text)),
+		CreatedBy: userID,
+	}
+
+	// 切分文档
+	texts := ChunkText(text, k.options.ChunkSize, k.options.ChunkOverlap)
+	chunks := make([]*KnowledgeChunk, len(texts))
+	for i, chunkText := range texts {
+		chunks[i] = &KnowledgeChunk{
+			ID:         uuid.New().String(),
+			OrgID:      orgID,
+			DocumentID: doc.ID,
+			Index:      i,
+			Content:    chunkText,
+		}
+	}
+	doc.ChunkCount = len(chunks)
+
+	// 分批计算向量
+	records := make([]*vectorstore.Record, 0, len(chunks))
+	for start := 0; start < len(chunks); start += embedBatchSize {
+		end := start + embedBatchSize
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+
+		vectors, err := k.embedder.Embed(ctx, texts[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("计算文档向量失败: %w", err)
+		}
+
+		for i, vector := range vectors {
+			chunk := chunks[start+i]
+			records = append(records, &vectorstore.Record{
+				ID:     chunk.ID,
+				Vector: vector,
+				Metadata: map[string]string{
+					"document_id": doc.ID,
+					vectorMetaOrg: strconv.FormatUint(uint64(orgID), 10),
+				},
+			})
+		}
+	}
+
+	if err := k.storage.CreateDocument(doc, chunks); err != nil {
+		return nil, err
+	}
+
+	if err := k.vectors.Upsert(ctx, records...); err != nil {
+		// 向量写入失败时回滚文档，避免出现无法检索的文档
+		k.storage.DeleteDocument(orgID, doc.ID)
+		return nil, fmt.Errorf("写入向量失败: %w", err)
+	}
+
+	return doc, nil
+}
+
+// ListDocuments 获取组织的所有文档
+func (k *KnowledgeService) ListDocuments(orgID uint) ([]*KnowledgeDocument, error) {
+	return k.storage.ListDocuments(orgID)
+}
+
+// GetDocumentChunks 获取组织内的文档及其片段
+func (k *KnowledgeService) GetDocumentChunks(orgID uint, id string) (*KnowledgeDocument, []*KnowledgeChunk, error) {
+	doc, err := k.storage.GetDocument(orgID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	chunks, err := k.storage.GetChunksByDocumentID(orgID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return doc, chunks, nil
+}
+
+// DeleteDocument 删除组织内的文档及其向量
+func (k *KnowledgeService) DeleteDocument(ctx context.Context, orgID uint, id string) error {
+	if _, err := k.storage.GetDocument(orgID, id); err != nil {
+		return err
+	}
+
+	chunks, err := k.storage.GetChunksByDocumentID(orgID, id)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		ids[i] = chunk.ID
+	}
+
+	if err := k.vectors.Delete(ctx, ids...); err != nil {
+		return err
+	}
+
+	return k.storage.DeleteDocument(orgID, id)
+}
+
+// Retrieve 在组织的知识库中检索与查询最相关的片段，不会返回其他组织的文档
+func (k *KnowledgeService) Retrieve(ctx context.Context, orgID uint, query string) ([]*RetrievedChunk, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, nil
+	}
+
+	vectors, err := k.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := k.vectors.Search(ctx, vectors[0], k.options.TopK, orgFilter(orgID))
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	scores := make(map[string]float32)
+	for _, match := range matches {
+		if match.Score < k.options.MinScore {
+			continue
+		}
+		ids = append(ids, match.ID)
+		scores[match.ID] = match.Score
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	chunks, err := k.storage.GetChunks(orgID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*KnowledgeChunk, len(chunks))
+	for _, chunk := range chunks {
+		byID[chunk.ID] = chunk
+	}
+
+	// 保持相似度顺序
+	documents := make(map[string]*KnowledgeDocument)
+	var result []*RetrievedChunk
+	for _, id := range ids {
+		chunk, exists := byID[id]
+		if !exists {
+			continue
+		}
+
+		doc, exists := documents[chunk.DocumentID]
+		if !exists {
+			doc, err = k.storage.GetDocument(orgID, chunk.DocumentID)
+			if err != nil {
+				log.Printf("获取知识库文档失败: %v", err)
+				continue
+			}
+			documents[chunk.DocumentID] = doc
+		}
+
+		result = append(result, &RetrievedChunk{
+			Chunk:    chunk,
+			Document: doc,
+			Score:    scores[id],
+		})
+	}
+
+	return result, nil
+}
+
+// snippetLength 引用中摘录的字符数
+const snippetLength = 80
+
+// BuildContext 将检索结果整理为提示词中的参考资料及对应的引用
+func BuildContext(chunks []*RetrievedChunk) (string, []*Citation) {
+	if len(chunks) == 0 {
+		return "", nil
+	}
+
+	var sb strings.Builder
+	citations := make([]*Citation, len(chunks))
+
+	sb.WriteString("参考资料:\n")
+	for i, retrieved := range chunks {
+		fmt.Fprintf(&sb, "[%d] %s: %s\n", i+1, retrieved.Document.Title, retrieved.Chunk.Content)
+
+		snippet := []rune(retrieved.Chunk.Content)
+		if len(snippet) > snippetLength {
+			snippet = append(snippet[:snippetLength], []rune("...")...)
+		}
+
+		citations[i] = &Citation{
+			Index:         i + 1,
+			DocumentID:    retrieved.Document.ID,
+			DocumentTitle: retrieved.Document.Title,
+			ChunkID:       retrieved.Chunk.ID,
+			Snippet:       string(snippet),
+			Score:         retrieved.Score,
+		}
+	}
+	sb.WriteString("请根据以上参考资料回答，并在引用处标注编号，如[1]。\n")
+
+	return sb.String(), citations
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"chat-llama/pkg/ratelimit"
+)
+
+// ErrLoginLocked 登录失败次数过多，暂时禁止登录
+var ErrLoginLocked = errors.New("登录尝试次数过多，请稍后再试")
+
+// LoginGuardOptions 登录防暴力破解配置
+type LoginGuardOptions struct {
+	MaxUserFailures int64         // 同一用户名连续失败达到该次数后锁定
+	MaxIPFailures   int64         // 同一IP失败达到该次数后锁定
+	FailureWindow   time.Duration // 失败次数的统计窗口
+	BaseDelay       time.Duration // 第一次失败后需要等待的时间，之后每次失败翻倍
+	MaxDelay        time.Duration // 等待时间上限
+	LockoutDuration time.Duration // 锁定时长
+}
+
+// LoginGuard 按用户名和IP统计登录失败次数，失败后按指数退避延迟下一次尝试，超过次数后暂时锁定。
+// 用户名不存在时同样计数，避免通过锁定行为判断用户是否存在
+type LoginGuard struct {
+	store   ratelimit.Store
+	audit   AuditRecorder
+	options LoginGuardOptions
+}
+
+// NewLoginGuard 创建登录保护
+func NewLoginGuard(store ratelimit.Store, audit AuditRecorder, options LoginGuardOptions) *LoginGuard {
+	if options.MaxUserFailures <= 0 {
+		options.MaxUserFailures = 5
+	}
+	if options.MaxIPFailures <= 0 {
+		options.MaxIPFailures = 20
+	}
+	if options.FailureWindow <= 0 {
+		options.FailureWindow = 15 * time.Minute
+	}
+	if options.BaseDelay <= 0 {
+		options.BaseDelay = time.Second
+	}
+	if options.MaxDelay <= 0 {
+		options.MaxDelay = time.Minute
+	}
+	if options.LockoutDuration <= 0 {
+		options.LockoutDuration = 15 * time.Minute
+	}
+
+	return &LoginGuard{
+		store:   store,
+		audit:   audit,
+		options: options,
+	}
+}
+
+// loginScope 一个计数维度
+type loginScope struct {
+	name        string // username 或 ip
+	value       string
+	maxFailures int64
+}
+
+func (g *LoginGuard) scopes(username, ip string) []loginScope {
+	scopes := []loginScope{{name: "username", value: strings.ToLower(username), maxFailures: g.options.MaxUserFailures}}
+	if ip != "" {
+		scopes = append(scopes, loginScope{name: "ip", value: ip, maxFailures: g.options.MaxIPFailures})
+	}
+	return scopes
+}
+
+func loginKey(kind string, scope loginScope) string {
+	return fmt.Sprintf("login:%s:%s:%s", kind, scope.name, scope.value)
+}
+
+// Check 检查是否允许登录尝试，被锁定或处于退避等待时返回ErrLoginLocked及需要等待的时间
+func (g *LoginGuard) Check(ctx context.Context, username, ip string) (time.Duration, error) {
+	var wait time.Duration
+	for _, scope := range g.scopes(username, ip) {
+		for _, kind := range []string{"lock", "delay"} {
+			ttl, err := g.store.TTL(ctx, loginKey(kind, scope))
+			if err != nil {
+				// 计数存储不可用时放行，登录本身仍需验证密码
+				log.Printf("检查登录限制失败: %v", err)
+				continue
+			}
+			if ttl > wait {
+				wait = ttl
+			}
+		}
+	}
+
+	if wait > 0 {
+		return wait, ErrLoginLocked
+	}
+	return 0, nil
+}
+
+// RecordFailure 记录一次登录失败，设置下一次尝试前的等待时间，达到次数上限时锁定
+func (g *LoginGuard) RecordFailure(ctx context.Context, username string, client ClientInfo) {
+	for _, scope := range g.scopes(username, client.IP) {
+		failures, err := g.store.IncrBy(ctx, loginKey("failures", scope), 1, g.options.FailureWindow)
+		if err != nil {
+			log.Printf("记录登录失败次数失败: %v", err)
+			continue
+		}
+
+		if failures >= scope.maxFailures {
+			g.lock(ctx, scope, failures, client)
+			continue
+		}
+
+		if _, err := g.store.IncrBy(ctx, loginKey("delay", scope), 1, g.backoff(failures)); err != nil {
+			log.Printf("设置登录等待时间失败: %v", err)
+		}
+	}
+}
+
+// RecordSuccess 登录成功后清除用户名维度的失败记录，IP维度的记录保留到窗口结束
+func (g *LoginGuard) RecordSuccess(ctx context.Context, username string) {
+	scope := g.scopes(username, "")[0]
+	for _, kind := range []string{"failures", "delay"} {
+		if err := g.store.Delete(ctx, loginKey(kind, scope)); err != nil {
+			log.Printf("清除登录失败记录失败: %v", err)
+		}
+	}
+}
+
+// backoff 计算第failures次失败后的等待时间
+func (g *LoginGuard) backoff(failures int64) time.Duration {
+	delay := g.options.BaseDelay
+	for i := int64(1); i < failures && delay < g.options.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > g.options.MaxDelay {
+		delay = g.options.MaxDelay
+	}
+	return delay
+}
+
+// lock 锁定并重新开始计数
+func (g *LoginGuard) lock(ctx context.Context, scope loginScope, failures int64, client ClientInfo) {
+	if _, err := g.store.IncrBy(ctx, loginKey("lock", scope), 1, g.options.LockoutDuration); err != nil {
+		log.Printf("锁定登录失败: %v", err)
+		return
+	}
+	if err := g.store.Delete(ctx, loginKey("failures", scope)); err != nil {
+		log.Printf("清除登录失败次数失败: %v", err)
+	}
+
+	if g.audit != nil {
+		g.audit.Record(ctx, &AuditEvent{
+			Action:     AuditLoginLockout,
+			TargetType: scope.name,
+			TargetID:   scope.value,
+			IP:         client.IP,
+			UserAgent:  client.UserAgent,
+			Detail: map[string]interface{}{
+				"failures": failures,
+				"duration": g.options.LockoutDuration.String(),
+			},
+			CreatedAt: time.Now(),
+		})
+	}
+}
@@ -1,141 +1,965 @@
-package service
-
-import (
-	"errors"
-	"sync"
-	"time"
-
-	"github.com/google/uuid"
-)
-
-// MemoryStorage 提供基于内存的存储实现，适用于开发和测试
-type MemoryStorage struct {
-	conversations map[string]*Conversation
-	messages      map[string][]*Message
-	mutex         sync.RWMutex
-}
-
-// NewMemoryStorage 创建内存存储实例
-func NewMemoryStorage() *MemoryStorage {
-	return &MemoryStorage{
-		conversations: make(map[string]*Conversation),
-		messages:      make(map[string][]*Message),
-	}
-}
-
-// CreateConversation 创建新会话
-func (s *MemoryStorage) CreateConversation(userID uint, title string) (*Conversation, error) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	now := time.Now()
-	conv := &Conversation{
-		ID:        uuid.New().String(),
-		UserID:    userID,
-		Title:     title,
-		CreatedAt: now,
-		UpdatedAt: now,
-	}
-
-	s.conversations[conv.ID] = conv
-	s.messages[conv.ID] = []*Message{}
-
-	return conv, nil
-}
-
-// GetConversation 获取会话
-func (s *MemoryStorage) GetConversation(id string) (*Conversation, error) {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
-
-	conv, exists := s.conversations[id]
-	if !exists {
-		return nil, errors.New("会话不存在")
-	}
-
-	return conv, nil
-}
-
-// GetConversationsByUserID 获取用户的所有会话
-func (s *MemoryStorage) GetConversationsByUserID(userID uint) ([]*Conversation, error) {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
-
-	var result []*Conversation
-	for _, conv := range s.conversations {
-		if conv.UserID == userID {
-			result = append(result, conv)
-		}
-	}
-
-	return result, nil
-}
-
-// UpdateConversationTitle 更新会话标题
-func (s *MemoryStorage) UpdateConversationTitle(id string, title string) error {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	conv, exists := s.conversations[id]
-	if !exists {
-		return errors.New("会话不存在")
-	}
-
-	conv.Title = title
-	conv.UpdatedAt = time.Now()
-
-	return nil
-}
-
-// DeleteConversation 删除会话
-func (s *MemoryStorage) DeleteConversation(id string) error {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	if _, exists := s.conversations[id]; !exists {
-		return errors.New("会话不存在")
-	}
-
-	delete(s.conversations, id)
-	delete(s.messages, id)
-
-	return nil
-}
-
-// AddMessage 添加消息
-func (s *MemoryStorage) AddMessage(conversationID, role, content string) (*Message, error) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	if _, exists := s.conversations[conversationID]; !exists {
-		return nil, errors.New("会话不存在")
-	}
-
-	msg := &Message{
-		ID:             uuid.New().String(),
-		ConversationID: conversationID,
-		Role:           role,
-		Content:        content,
-		CreatedAt:      time.Now(),
-	}
-
-	s.messages[conversationID] = append(s.messages[conversationID], msg)
-
-	// 更新会话的最后更新时间
-	s.conversations[conversationID].UpdatedAt = time.Now()
-
-	return msg, nil
-}
-
-// GetMessagesByConversationID 获取会话的所有消息
-func (s *MemoryStorage) GetMessagesByConversationID(conversationID string) ([]*Message, error) {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
-
-	msgs, exists := s.messages[conversationID]
-	if !exists {
-		return nil, errors.New("会话不存在")
-	}
-
-	return msgs, nil
-}
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStorage 提供基于内存的存储实现，适用于开发和测试
+type MemoryStorage struct {
+	conversations map[string]*Conversation
+	messages      map[string][]*Message
+	usageStats    map[string]*UsageStat
+	documents     map[string]*KnowledgeDocument
+	chunks        map[string]*KnowledgeChunk
+	attachments   map[string]*Attachment
+	sessions      map[string]*Session
+	refreshTokens map[string]*RefreshToken
+	apiKeys       map[string]*APIKey
+	organizations map[uint]*Organization
+	memberships   map[uint]map[uint]*OrgMembership // 组织ID到用户ID到成员身份
+	nextOrgID     uint
+	convMembers   map[string]map[uint]*ConversationMember // 会话ID到用户ID到成员身份
+	mutex         sync.RWMutex
+}
+
+// NewMemoryStorage 创建内存存储实例
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		conversations: make(map[string]*Conversation),
+		messages:      make(map[string][]*Message),
+		usageStats:    make(map[string]*UsageStat),
+		documents:     make(map[string]*KnowledgeDocument),
+		chunks:        make(map[string]*KnowledgeChunk),
+		attachments:   make(map[string]*Attachment),
+		sessions:      make(map[string]*Session),
+		refreshTokens: make(map[string]*RefreshToken),
+		apiKeys:       make(map[string]*APIKey),
+		organizations: make(map[uint]*Organization),
+		memberships:   make(map[uint]map[uint]*OrgMembership),
+		convMembers:   make(map[string]map[uint]*ConversationMember),
+	}
+}
+
+// CreateConversation 创建新会话
+func (s *MemoryStorage) CreateConversation(userID, orgID uint, title string) (*Conversation, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	conv := &Conversation{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		OrgID:     orgID,
+		Title:     title,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	s.conversations[conv.ID] = conv
+	s.messages[conv.ID] = []*Message{}
+
+	return conv, nil
+}
+
+// GetConversation 获取组织内的会话
+func (s *MemoryStorage) GetConversation(orgID uint, id string) (*Conversation, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.scopedConversation(orgID, id)
+}
+
+// GetConversationUnscoped 获取会话，不按组织过滤
+func (s *MemoryStorage) GetConversationUnscoped(id string) (*Conversation, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	conv, exists := s.conversations[id]
+	if !exists {
+		return nil, errors.New("会话不存在")
+	}
+
+	return conv, nil
+}
+
+// GetConversationsByUserID 获取用户在组织内的所有会话
+func (s *MemoryStorage) GetConversationsByUserID(userID, orgID uint) ([]*Conversation, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var result []*Conversation
+	for _, conv := range s.conversations {
+		if conv.UserID == userID && conv.OrgID == orgID {
+			result = append(result, conv)
+		}
+	}
+
+	return result, nil
+}
+
+// UpdateConversationTitle 更新会话标题
+func (s *MemoryStorage) UpdateConversationTitle(orgID uint, id string, title string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	conv, err := s.scopedConversation(orgID, id)
+	if err != nil {
+		return err
+	}
+
+	conv.Title = title
+	conv.UpdatedAt = time.Now()
+
+	return nil
+}
+
+// UpdateConversationTools 更新会话的角色及启用的工具
+func (s *MemoryStorage) UpdateConversationTools(orgID uint, id string, persona string, tools []string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	conv, err := s.scopedConversation(orgID, id)
+	if err != nil {
+		return err
+	}
+
+	conv.Persona = persona
+	conv.Tools = tools
+	conv.UpdatedAt = time.Now()
+
+	return nil
+}
+
+// DeleteConversation 删除会话
+func (s *MemoryStorage) DeleteConversation(orgID uint, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, err := s.scopedConversation(orgID, id); err != nil {
+		return err
+	}
+
+	delete(s.conversations, id)
+	delete(s.messages, id)
+	delete(s.convMembers, id)
+
+	return nil
+}
+
+// GetSharedConversations 获取组织内共享给用户的会话
+func (s *MemoryStorage) GetSharedConversations(userID, orgID uint) ([]*Conversation, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var result []*Conversation
+	for id, members := range s.convMembers {
+		conv, exists := s.conversations[id]
+		if !exists || conv.OrgID != orgID {
+			continue
+		}
+		if _, ok := members[userID]; ok {
+			result = append(result, conv)
+		}
+	}
+
+	return result, nil
+}
+
+// GetConversationMember 获取用户在会话中的成员身份
+func (s *MemoryStorage) GetConversationMember(conversationID string, userID uint) (*ConversationMember, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	member, exists := s.convMembers[conversationID][userID]
+	if !exists {
+		return nil, errors.New("不是会话成员")
+	}
+
+	copied := *member
+	return &copied, nil
+}
+
+// ListConversationMembers 获取会话的所有成员
+func (s *MemoryStorage) ListConversationMembers(conversationID string) ([]*ConversationMember, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var result []*ConversationMember
+	for _, member := range s.convMembers[conversationID] {
+		copied := *member
+		result = append(result, &copied)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].UserID < result[j].UserID })
+
+	return result, nil
+}
+
+// SaveConversationMember 添加会话成员，成员已存在时修改角色
+func (s *MemoryStorage) SaveConversationMember(member *ConversationMember) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.conversations[member.ConversationID]; !exists {
+		return errors.New("会话不存在")
+	}
+
+	members, exists := s.convMembers[member.ConversationID]
+	if !exists {
+		members = make(map[uint]*ConversationMember)
+		s.convMembers[member.ConversationID] = members
+	}
+	if existing, ok := members[member.UserID]; ok {
+		existing.Role = member.Role
+		return nil
+	}
+
+	saved := *member
+	members[member.UserID] = &saved
+	return nil
+}
+
+// DeleteConversationMember 移除会话成员
+func (s *MemoryStorage) DeleteConversationMember(conversationID string, userID uint) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.convMembers[conversationID][userID]; !exists {
+		return errors.New("不是会话成员")
+	}
+
+	delete(s.convMembers[conversationID], userID)
+	return nil
+}
+
+// scopedConversation 获取属于orgID的会话，调用方需持有锁
+func (s *MemoryStorage) scopedConversation(orgID uint, id string) (*Conversation, error) {
+	conv, exists := s.conversations[id]
+	if !exists || conv.OrgID != orgID {
+		return nil, errors.New("会话不存在")
+	}
+
+	return conv, nil
+}
+
+// AddMessage 添加消息
+func (s *MemoryStorage) AddMessage(conversationID, role, content string) (*Message, error) {
+	return s.SaveMessage(&Message{
+		ConversationID: conversationID,
+		Role:           role,
+		Content:        content,
+	})
+}
+
+// SaveMessage 保存完整的消息
+func (s *MemoryStorage) SaveMessage(msg *Message) (*Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.conversations[msg.ConversationID]; !exists {
+		return nil, errors.New("会话不存在")
+	}
+
+	saved := *msg
+	if saved.ID == "" {
+		saved.ID = uuid.New().String()
+	}
+	if saved.CreatedAt.IsZero() {
+		saved.CreatedAt = time.Now()
+	}
+
+	s.messages[saved.ConversationID] = append(s.messages[saved.ConversationID], &saved)
+
+	// 更新会话的最后更新时间
+	s.conversations[saved.ConversationID].UpdatedAt = time.Now()
+
+	return &saved, nil
+}
+
+// GetMessagesByConversationID 获取会话的所有消息
+func (s *MemoryStorage) GetMessagesByConversationID(conversationID string) ([]*Message, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	msgs, exists := s.messages[conversationID]
+	if !exists {
+		return nil, errors.New("会话不存在")
+	}
+
+	return msgs, nil
+}
+
+// RecordUsage 累加用户当天在组织内指定模型上的用量
+func (s *MemoryStorage) RecordUsage(userID, orgID uint, model string, usage *TokenUsage) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	date := time.Now().Format("2006-01-02")
+	key := fmt.Sprintf("%d:%d:%s:%s", userID, orgID, model, date)
+
+	stat, exists := s.usageStats[key]
+	if !exists {
+		stat = &UsageStat{
+			UserID: userID,
+			OrgID:  orgID,
+			Model:  model,
+			Date:   date,
+		}
+		s.usageStats[key] = stat
+	}
+
+	stat.Requests++
+	stat.PromptTokens += int64(usage.PromptTokens)
+	stat.CompletionTokens += int64(usage.CompletionTokens)
+	stat.TotalTokens += int64(usage.TotalTokens)
+	stat.TotalLatencyMs += usage.LatencyMs
+
+	return nil
+}
+
+// GetUsageStats 获取用户自指定时间以来在组织内的每日用量
+func (s *MemoryStorage) GetUsageStats(userID, orgID uint, since time.Time) ([]*UsageStat, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	sinceDate := since.Format("2006-01-02")
+	var result []*UsageStat
+	for _, stat := range s.usageStats {
+		if stat.UserID == userID && stat.OrgID == orgID && stat.Date >= sinceDate {
+			statCopy := *stat
+			result = append(result, &statCopy)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Date != result[j].Date {
+			return result[i].Date < result[j].Date
+		}
+		return result[i].Model < result[j].Model
+	})
+
+	return result, nil
+}
+
+// CreateDocument 保存知识库文档及其片段
+func (s *MemoryStorage) CreateDocument(doc *KnowledgeDocument, chunks []*KnowledgeChunk) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if doc.CreatedAt.IsZero() {
+		doc.CreatedAt = time.Now()
+	}
+	s.documents[doc.ID] = doc
+	for _, chunk := range chunks {
+		s.chunks[chunk.ID] = chunk
+	}
+
+	return nil
+}
+
+// GetDocument 获取组织内的知识库文档
+func (s *MemoryStorage) GetDocument(orgID uint, id string) (*KnowledgeDocument, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	doc, exists := s.documents[id]
+	if !exists || doc.OrgID != orgID {
+		return nil, errors.New("文档不存在")
+	}
+
+	return doc, nil
+}
+
+// ListDocuments 获取组织的所有知识库文档
+func (s *MemoryStorage) ListDocuments(orgID uint) ([]*KnowledgeDocument, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	result := make([]*KnowledgeDocument, 0, len(s.documents))
+	for _, doc := range s.documents {
+		if doc.OrgID == orgID {
+			result = append(result, doc)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+
+	return result, nil
+}
+
+// DeleteDocument 删除组织内的知识库文档及其片段
+func (s *MemoryStorage) DeleteDocument(orgID uint, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if doc, exists := s.documents[id]; !exists || doc.OrgID != orgID {
+		return errors.New("文档不存在")
+	}
+
+	delete(s.documents, id)
+	for chunkID, chunk := range s.chunks {
+		if chunk.DocumentID == id {
+			delete(s.chunks, chunkID)
+		}
+	}
+
+	return nil
+}
+
+// GetChunksByDocumentID 获取组织内文档的所有片段
+func (s *MemoryStorage) GetChunksByDocumentID(orgID uint, documentID string) ([]*KnowledgeChunk, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var result []*KnowledgeChunk
+	for _, chunk := range s.chunks {
+		if chunk.DocumentID == documentID && chunk.OrgID == orgID {
+			result = append(result, chunk)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Index < result[j].Index
+	})
+
+	return result, nil
+}
+
+// GetChunks 按ID批量获取组织内的片段，不存在或属于其他组织的ID会被忽略
+func (s *MemoryStorage) GetChunks(orgID uint, ids []string) ([]*KnowledgeChunk, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var result []*KnowledgeChunk
+	for _, id := range ids {
+		if chunk, exists := s.chunks[id]; exists && chunk.OrgID == orgID {
+			result = append(result, chunk)
+		}
+	}
+
+	return result, nil
+}
+
+// CreateAttachment 保存附件信息
+func (s *MemoryStorage) CreateAttachment(attachment *Attachment) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if attachment.CreatedAt.IsZero() {
+		attachment.CreatedAt = time.Now()
+	}
+	s.attachments[attachment.ID] = attachment
+
+	return nil
+}
+
+// GetAttachment 获取附件信息
+func (s *MemoryStorage) GetAttachment(id string) (*Attachment, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	attachment, exists := s.attachments[id]
+	if !exists {
+		return nil, errors.New("附件不存在")
+	}
+
+	return attachment, nil
+}
+
+// LinkAttachments 将尚未发送的附件关联到消息，任一附件已随其他消息发送或不存在时不做修改
+func (s *MemoryStorage) LinkAttachments(ids []string, conversationID, messageID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, id := range ids {
+		attachment, exists := s.attachments[id]
+		if !exists || attachment.MessageID != "" {
+			return ErrInvalidAttachment
+		}
+	}
+
+	for _, id := range ids {
+		attachment := s.attachments[id]
+		attachment.ConversationID = conversationID
+		attachment.MessageID = messageID
+	}
+
+	return nil
+}
+
+// GetAttachmentsByConversationID 获取会话中已发送的附件
+func (s *MemoryStorage) GetAttachmentsByConversationID(conversationID string) ([]*Attachment, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var result []*Attachment
+	for _, attachment := range s.attachments {
+		if attachment.ConversationID == conversationID {
+			result = append(result, attachment)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	return result, nil
+}
+
+// DeleteAttachmentsByConversationID 删除会话中的所有附件信息
+func (s *MemoryStorage) DeleteAttachmentsByConversationID(conversationID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for id, attachment := range s.attachments {
+		if attachment.ConversationID == conversationID {
+			delete(s.attachments, id)
+		}
+	}
+
+	return nil
+}
+
+// DeleteAttachment 删除附件信息
+func (s *MemoryStorage) DeleteAttachment(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.attachments[id]; !exists {
+		return errors.New("附件不存在")
+	}
+	delete(s.attachments, id)
+
+	return nil
+}
+
+// ListModeratedMessages 分页获取命中审核规则的消息，按时间倒序
+func (s *MemoryStorage) ListModeratedMessages(status string, offset, limit int) ([]*Message, int64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var result []*Message
+	for _, messages := range s.messages {
+		for _, msg := range messages {
+			if msg.Moderation == nil || msg.Moderation.Review == nil {
+				continue
+			}
+			if status != "" && msg.Moderation.Review.Status != status {
+				continue
+			}
+			result = append(result, msg)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+
+	total := int64(len(result))
+	if offset >= len(result) {
+		return []*Message{}, total, nil
+	}
+	end := offset + limit
+	if end > len(result) {
+		end = len(result)
+	}
+
+	return result[offset:end], total, nil
+}
+
+// UpdateMessageReview 更新消息的人工审核结果
+func (s *MemoryStorage) UpdateMessageReview(messageID string, review *ModerationReview) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, messages := range s.messages {
+		for _, msg := range messages {
+			if msg.ID != messageID {
+				continue
+			}
+			if msg.Moderation == nil {
+				return errors.New("消息未命中审核规则")
+			}
+			moderation := *msg.Moderation
+			moderation.Review = review
+			msg.Moderation = &moderation
+			return nil
+		}
+	}
+
+	return errors.New("消息不存在")
+}
+
+// CreateSession 创建登录会话
+func (s *MemoryStorage) CreateSession(session *Session) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	saved := *session
+	s.sessions[session.ID] = &saved
+	return nil
+}
+
+// GetSession 获取登录会话
+func (s *MemoryStorage) GetSession(id string) (*Session, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	session, exists := s.sessions[id]
+	if !exists {
+		return nil, errors.New("会话不存在")
+	}
+
+	copied := *session
+	return &copied, nil
+}
+
+// ListUserSessions 获取用户所有未撤销的会话
+func (s *MemoryStorage) ListUserSessions(userID uint) ([]*Session, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var result []*Session
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			copied := *session
+			result = append(result, &copied)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastSeenAt.After(result[j].LastSeenAt)
+	})
+
+	return result, nil
+}
+
+// TouchSession 更新会话的最近活跃时间和IP
+func (s *MemoryStorage) TouchSession(id, ip string, at time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, exists := s.sessions[id]
+	if !exists {
+		return errors.New("会话不存在")
+	}
+	session.LastSeenAt = at
+	if ip != "" {
+		session.IP = ip
+	}
+	return nil
+}
+
+// UpdateSessionOrg 修改会话当前所在的组织
+func (s *MemoryStorage) UpdateSessionOrg(id string, orgID uint) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, exists := s.sessions[id]
+	if !exists {
+		return errors.New("会话不存在")
+	}
+	session.OrgID = orgID
+	return nil
+}
+
+// RevokeSession 撤销登录会话
+func (s *MemoryStorage) RevokeSession(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, exists := s.sessions[id]
+	if !exists {
+		return errors.New("会话不存在")
+	}
+	if session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+	}
+	return nil
+}
+
+// RevokeUserSessions 撤销用户所有未撤销的会话
+func (s *MemoryStorage) RevokeUserSessions(userID uint) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	var revoked []string
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			revoked = append(revoked, session.ID)
+		}
+	}
+	return revoked, nil
+}
+
+// CreateRefreshToken 保存刷新令牌
+func (s *MemoryStorage) CreateRefreshToken(token *RefreshToken) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	saved := *token
+	s.refreshTokens[token.ID] = &saved
+	return nil
+}
+
+// GetRefreshTokenByHash 通过哈希值获取刷新令牌
+func (s *MemoryStorage) GetRefreshTokenByHash(hash string) (*RefreshToken, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, token := range s.refreshTokens {
+		if token.TokenHash == hash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, errors.New("刷新令牌不存在")
+}
+
+// UseRefreshToken 将刷新令牌标记为已使用
+func (s *MemoryStorage) UseRefreshToken(id string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	token, exists := s.refreshTokens[id]
+	if !exists {
+		return false, errors.New("刷新令牌不存在")
+	}
+	if token.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	return true, nil
+}
+
+// CreateAPIKey 保存API密钥
+func (s *MemoryStorage) CreateAPIKey(key *APIKey) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	saved := *key
+	s.apiKeys[key.ID] = &saved
+	return nil
+}
+
+// GetAPIKeyByHash 通过哈希值获取API密钥
+func (s *MemoryStorage) GetAPIKeyByHash(hash string) (*APIKey, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, key := range s.apiKeys {
+		if key.KeyHash == hash {
+			copied := *key
+			return &copied, nil
+		}
+	}
+	return nil, errors.New("API密钥不存在")
+}
+
+// ListUserAPIKeys 获取用户所有未撤销的密钥
+func (s *MemoryStorage) ListUserAPIKeys(userID uint) ([]*APIKey, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var result []*APIKey
+	for _, key := range s.apiKeys {
+		if key.UserID == userID && key.RevokedAt == nil {
+			copied := *key
+			result = append(result, &copied)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+
+	return result, nil
+}
+
+// RevokeAPIKey 撤销用户的密钥
+func (s *MemoryStorage) RevokeAPIKey(userID uint, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key, exists := s.apiKeys[id]
+	if !exists || key.UserID != userID || key.RevokedAt != nil {
+		return errors.New("API密钥不存在")
+	}
+	now := time.Now()
+	key.RevokedAt = &now
+	return nil
+}
+
+// TouchAPIKey 更新密钥的最近使用时间
+func (s *MemoryStorage) TouchAPIKey(id string, at time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key, exists := s.apiKeys[id]
+	if !exists {
+		return errors.New("API密钥不存在")
+	}
+	key.LastUsedAt = &at
+	return nil
+}
+
+// CreateOrganization 创建组织并将ownerID设为所有者
+func (s *MemoryStorage) CreateOrganization(org *Organization, ownerID uint) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.nextOrgID++
+	org.ID = s.nextOrgID
+	saved := *org
+	s.organizations[org.ID] = &saved
+	s.memberships[org.ID] = map[uint]*OrgMembership{
+		ownerID: {
+			OrgID:     org.ID,
+			UserID:    ownerID,
+			Role:      OrgRoleOwner,
+			CreatedAt: org.CreatedAt,
+		},
+	}
+	return nil
+}
+
+// GetOrganization 获取组织
+func (s *MemoryStorage) GetOrganization(id uint) (*Organization, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	org, exists := s.organizations[id]
+	if !exists {
+		return nil, errors.New("组织不存在")
+	}
+
+	copied := *org
+	return &copied, nil
+}
+
+// ListOrganizations 获取所有组织
+func (s *MemoryStorage) ListOrganizations() ([]*Organization, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	result := make([]*Organization, 0, len(s.organizations))
+	for _, org := range s.organizations {
+		copied := *org
+		result = append(result, &copied)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+
+	return result, nil
+}
+
+// UpdateOrganizationSettings 修改组织设置
+func (s *MemoryStorage) UpdateOrganizationSettings(id uint, settings OrgSettings) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	org, exists := s.organizations[id]
+	if !exists {
+		return errors.New("组织不存在")
+	}
+	org.Settings = settings
+	org.UpdatedAt = time.Now()
+	return nil
+}
+
+// GetMembership 获取用户在组织中的成员身份
+func (s *MemoryStorage) GetMembership(orgID, userID uint) (*OrgMembership, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	membership, exists := s.memberships[orgID][userID]
+	if !exists {
+		return nil, errors.New("不是组织成员")
+	}
+
+	copied := *membership
+	return &copied, nil
+}
+
+// ListMembers 获取组织的所有成员
+func (s *MemoryStorage) ListMembers(orgID uint) ([]*OrgMembership, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var result []*OrgMembership
+	for _, membership := range s.memberships[orgID] {
+		copied := *membership
+		result = append(result, &copied)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].UserID < result[j].UserID
+	})
+
+	return result, nil
+}
+
+// ListUserMemberships 获取用户加入的所有组织
+func (s *MemoryStorage) ListUserMemberships(userID uint) ([]*OrgMembership, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var result []*OrgMembership
+	for orgID, members := range s.memberships {
+		if membership, exists := members[userID]; exists {
+			copied := *membership
+			copied.OrgName = s.organizations[orgID].Name
+			result = append(result, &copied)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].OrgID < result[j].OrgID
+	})
+
+	return result, nil
+}
+
+// SaveMembership 添加成员，成员已存在时修改角色
+func (s *MemoryStorage) SaveMembership(membership *OrgMembership) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	members, exists := s.memberships[membership.OrgID]
+	if !exists {
+		return errors.New("组织不存在")
+	}
+	if existing, exists := members[membership.UserID]; exists {
+		existing.Role = membership.Role
+		return nil
+	}
+
+	saved := *membership
+	members[membership.UserID] = &saved
+	return nil
+}
+
+// DeleteMembership 移除组织成员
+func (s *MemoryStorage) DeleteMembership(orgID, userID uint) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.memberships[orgID][userID]; !exists {
+		return errors.New("不是组织成员")
+	}
+	delete(s.memberships[orgID], userID)
+	return nil
+}
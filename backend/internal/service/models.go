@@ -1,37 +1,216 @@
-package service
-
-import "time"
-
-// Conversation 表示一个聊天会话
-type Conversation struct {
-	ID        string    `json:"id"`
-	UserID    uint      `json:"user_id"`
-	Title     string    `json:"title"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-}
-
-// Message 表示聊天消息
-type Message struct {
-	ID             string    `json:"id"`
-	ConversationID string    `json:"conversation_id"`
-	Role           string    `json:"role"` // "user" 或 "assistant"
-	Content        string    `json:"content"`
-	CreatedAt      time.Time `json:"created_at"`
-}
-
-// ChatRequest 表示聊天请求
-type ChatRequest struct {
-	ConversationID string  `json:"conversation_id,omitempty"`
-	Message        string  `json:"message"`
-	Temperature    float32 `json:"temperature,omitempty"`
-	MaxNewTokens   int32   `json:"max_new_tokens,omitempty"`
-	TopK           int32   `json:"top_k,omitempty"`
-}
-
-// ChatResponse 表示聊天响应
-type ChatResponse struct {
-	ConversationID string `json:"conversation_id"`
-	Message        string `json:"message"`
-	Role           string `json:"role"`
-}
+package service
+
+import "time"
+
+// Conversation 表示一个聊天会话
+type Conversation struct {
+	ID        string    `json:"id"`
+	UserID    uint      `json:"user_id"`
+	OrgID     uint      `json:"org_id,omitempty"` // 所属组织，0表示个人空间
+	Title     string    `json:"title"`
+	Persona   string    `json:"persona,omitempty"` // 决定默认启用哪些工具
+	Tools     []string  `json:"tools"`             // 会话单独启用的工具，为nil时按角色设定
+	Role      string    `json:"role,omitempty"`    // 当前用户对会话的权限，仅在会话列表中填写
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ConversationMember 共享会话的协作者，会话所有者不在成员中
+type ConversationMember struct {
+	ConversationID string    `json:"conversation_id"`
+	UserID         uint      `json:"user_id"`
+	Username       string    `json:"username,omitempty"`
+	Role           string    `json:"role"` // viewer或editor
+	AddedBy        uint      `json:"added_by"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Message 表示聊天消息
+type Message struct {
+	ID             string            `json:"id"`
+	ConversationID string            `json:"conversation_id"`
+	Role           string            `json:"role"` // "user"、"assistant" 或 "tool"
+	Content        string            `json:"content"`
+	Model          string            `json:"model,omitempty"`        // 生成回复的模型，仅助手消息有值
+	Usage          *TokenUsage       `json:"usage,omitempty"`        // token用量，仅助手消息有值
+	Citations      []*Citation       `json:"citations,omitempty"`    // 回答引用的知识库片段
+	Attachments    []*Attachment     `json:"attachments,omitempty"`  // 用户消息携带的附件
+	ToolCalls      []*ToolCall       `json:"tool_calls,omitempty"`   // 助手消息发起的工具调用
+	ToolCallID     string            `json:"tool_call_id,omitempty"` // 工具消息对应的调用ID
+	ToolName       string            `json:"tool_name,omitempty"`    // 工具消息对应的工具名称
+	Moderation     *ModerationResult `json:"moderation,omitempty"`   // 命中审核规则时的审核结果
+	SafetyRules    []string          `json:"safety_rules,omitempty"` // 生成回复时命中的医疗安全规则
+	CreatedAt      time.Time         `json:"created_at"`
+}
+
+// Attachment 表示用户上传的聊天附件
+type Attachment struct {
+	ID             string    `json:"id"`
+	UserID         uint      `json:"user_id"`
+	ConversationID string    `json:"conversation_id,omitempty"` // 随消息发送前为空
+	MessageID      string    `json:"message_id,omitempty"`      // 随消息发送前为空
+	Filename       string    `json:"filename"`
+	ContentType    string    `json:"content_type"`
+	Size           int64     `json:"size"`        // 文件字节数
+	TextLength     int       `json:"text_length"` // 提取文本的字符数
+	StorageKey     string    `json:"-"`
+	ExtractedText  string    `json:"-"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Citation 表示回答引用的知识库片段
+type Citation struct {
+	Index         int     `json:"index"` // 提示词中的引用编号，从1开始
+	DocumentID    string  `json:"document_id"`
+	DocumentTitle string  `json:"document_title"`
+	ChunkID       string  `json:"chunk_id"`
+	Snippet       string  `json:"snippet"`
+	Score         float32 `json:"score"`
+}
+
+// KnowledgeDocument 表示知识库文档
+type KnowledgeDocument struct {
+	ID         string    `json:"id"`
+	OrgID      uint      `json:"org_id"` // 所属组织，0表示个人空间，只有同一组织的对话会检索到
+	Title      string    `json:"title"`
+	Source     string    `json:"source,omitempty"` // 上传的文件名
+	Format     string    `json:"format"`
+	Size       int       `json:"size"` // 字符数
+	ChunkCount int       `json:"chunk_count"`
+	CreatedBy  uint      `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// KnowledgeChunk 表示知识库文档切分后的片段
+type KnowledgeChunk struct {
+	ID         string `json:"id"`
+	OrgID      uint   `json:"org_id"`
+	DocumentID string `json:"document_id"`
+	Index      int    `json:"index"`
+	Content    string `json:"content"`
+}
+
+// TokenUsage 表示一次模型生成的用量
+type TokenUsage struct {
+	PromptTokens     int32 `json:"prompt_tokens"`
+	CompletionTokens int32 `json:"completion_tokens"`
+	TotalTokens      int32 `json:"total_tokens"`
+	LatencyMs        int64 `json:"latency_ms"`
+}
+
+// UsageStat 表示按用户、模型和日期汇总的用量
+type UsageStat struct {
+	UserID           uint   `json:"user_id"`
+	OrgID            uint   `json:"org_id"` // 产生用量的组织，0表示个人空间
+	Model            string `json:"model"`
+	Date             string `json:"date"` // 格式 2006-01-02
+	Requests         int64  `json:"requests"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	TotalTokens      int64  `json:"total_tokens"`
+	TotalLatencyMs   int64  `json:"total_latency_ms"`
+}
+
+// ChatRequest 表示聊天请求
+type ChatRequest struct {
+	ConversationID string   `json:"conversation_id,omitempty"`
+	Message        string   `json:"message"`
+	Temperature    float32  `json:"temperature,omitempty"`
+	MaxNewTokens   int32    `json:"max_new_tokens,omitempty"`
+	TopK           int32    `json:"top_k,omitempty"`
+	NoCache        bool     `json:"no_cache,omitempty"`       // 跳过响应缓存，强制重新生成
+	AttachmentIDs  []string `json:"attachment_ids,omitempty"` // 随消息发送的附件，需先通过上传接口获得
+	Persona        string   `json:"persona,omitempty"`        // 新会话使用的角色
+	Tools          []string `json:"tools,omitempty"`          // 新会话启用的工具，优先于角色设定
+}
+
+// ChatResponse 表示聊天响应
+type ChatResponse struct {
+	ConversationID string            `json:"conversation_id"`
+	MessageID      string            `json:"message_id"`
+	Message        string            `json:"message"`
+	Role           string            `json:"role"`
+	Model          string            `json:"model,omitempty"`
+	Usage          *TokenUsage       `json:"usage,omitempty"`
+	Cached         bool              `json:"cached,omitempty"` // 回复是否来自缓存
+	Citations      []*Citation       `json:"citations,omitempty"`
+	ToolResults    []*ToolResult     `json:"tool_results,omitempty"` // 生成回复过程中执行的工具
+	Moderation     *ModerationResult `json:"moderation,omitempty"`   // 回复命中审核规则时的审核结果
+	SafetyRules    []string          `json:"safety_rules,omitempty"` // 命中的医疗安全规则
+}
+
+// Session 一次登录产生的会话，刷新令牌轮换时会话保持不变
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     uint       `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	OrgID      uint       `json:"org_id,omitempty"` // 当前切换到的组织，0表示个人空间
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ExternalIdentity 外部身份提供方的账号与本地用户的关联
+type ExternalIdentity struct {
+	ID          uint      `json:"id"`
+	UserID      uint      `json:"user_id"`
+	Provider    string    `json:"provider"`
+	Subject     string    `json:"subject"` // 身份提供方中的用户标识(sub)
+	Email       string    `json:"email"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
+
+// APIKey 用于程序调用的长期密钥，只保存哈希值
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     uint       `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // 密钥开头的几个字符，便于用户识别
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	OrgID      uint       `json:"org_id,omitempty"`     // 创建时所在的组织，密钥只能访问该组织的数据
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // 为空时永不过期
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// RefreshToken 刷新令牌，只保存哈希值
+type RefreshToken struct {
+	ID        string     `json:"id"`
+	SessionID string     `json:"session_id"`
+	UserID    uint       `json:"user_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"` // 轮换后记录使用时间，不可再次使用
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Organization 组织，组织内的会话与其他组织及个人空间隔离
+type Organization struct {
+	ID        uint        `json:"id"`
+	Name      string      `json:"name"`
+	Settings  OrgSettings `json:"settings"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// OrgSettings 组织的模型、角色及配额设置
+type OrgSettings struct {
+	Models         []string `json:"models"`                    // 允许使用的模型，为空时不限制
+	Personas       []string `json:"personas"`                  // 允许使用的角色，为空时不限制
+	DefaultPersona string   `json:"default_persona,omitempty"` // 新会话未指定角色时使用的角色
+	Plan           string   `json:"plan,omitempty"`            // 组织共享的配额套餐，为空时不限制组织总用量
+}
+
+// OrgMembership 用户在组织中的成员身份
+type OrgMembership struct {
+	OrgID     uint      `json:"org_id"`
+	OrgName   string    `json:"org_name,omitempty"`
+	UserID    uint      `json:"user_id"`
+	Username  string    `json:"username,omitempty"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
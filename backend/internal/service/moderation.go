@@ -0,0 +1,430 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"chat-llama/internal/model"
+	"chat-llama/pkg/pii"
+)
+
+// 审核动作，按严重程度递增
+const (
+	ModerationAllow  = "allow"
+	ModerationFlag   = "flag"   // 放行但进入人工审核队列
+	ModerationRedact = "redact" // 屏蔽命中的内容后放行
+	ModerationBlock  = "block"  // 拦截
+)
+
+var moderationSeverity = map[string]int{
+	ModerationAllow:  0,
+	ModerationFlag:   1,
+	ModerationRedact: 2,
+	ModerationBlock:  3,
+}
+
+// 人工审核状态
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+var (
+	// ErrContentBlocked 消息被内容审核拦截
+	ErrContentBlocked = errors.New("消息包含不允许的内容，已被拦截")
+	// ErrInvalidReviewStatus 人工审核状态无效
+	ErrInvalidReviewStatus = errors.New("无效的审核状态，只能是approved或rejected")
+)
+
+// ModerationFinding 检查器的一次命中
+type ModerationFinding struct {
+	Checker  string  `json:"checker"`
+	Category string  `json:"category"`
+	Action   string  `json:"action"`
+	Score    float32 `json:"score,omitempty"` // 仅分类器有值
+
+	// 需要屏蔽的位置，分类器的命中没有位置
+	start       int
+	end         int
+	replacement string
+}
+
+// ModerationReview 人工审核结果
+type ModerationReview struct {
+	Status     string     `json:"status"`
+	ReviewerID uint       `json:"reviewer_id,omitempty"`
+	Note       string     `json:"note,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+}
+
+// ModerationResult 一条消息的审核结果
+type ModerationResult struct {
+	Action   string               `json:"action"`
+	Findings []*ModerationFinding `json:"findings,omitempty"`
+	Original string               `json:"original,omitempty"` // 被拦截的模型回复原文，仅供审核人员查看
+	Review   *ModerationReview    `json:"review,omitempty"`
+}
+
+// public 去掉仅供审核人员查看的信息，r为nil时返回nil
+func (r *ModerationResult) public() *ModerationResult {
+	if r == nil {
+		return nil
+	}
+	return &ModerationResult{
+		Action:   r.Action,
+		Findings: r.Findings,
+	}
+}
+
+// ModerationChecker 内容检查器
+type ModerationChecker interface {
+	Name() string
+	Check(ctx context.Context, text string) ([]*ModerationFinding, error)
+}
+
+// validModerationAction 检查动作是否有效
+func validModerationAction(action string) error {
+	if _, exists := moderationSeverity[action]; !exists || action == ModerationAllow {
+		return fmt.Errorf("无效的审核动作: %s", action)
+	}
+	return nil
+}
+
+// PatternChecker 基于关键词或正则的黑名单检查器
+type PatternChecker struct {
+	name     string
+	category string
+	action   string
+	pattern  *regexp.Regexp
+}
+
+// NewKeywordChecker 创建关键词检查器，匹配时忽略大小写
+func NewKeywordChecker(category, action string, keywords []string) (*PatternChecker, error) {
+	quoted := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		quoted = append(quoted, regexp.QuoteMeta(keyword))
+	}
+	return newPatternChecker("keyword", category, action, quoted)
+}
+
+// NewRegexChecker 创建正则检查器
+func NewRegexChecker(category, action string, patterns []string) (*PatternChecker, error) {
+	for _, pattern := range patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("无效的正则 %q: %w", pattern, err)
+		}
+	}
+	return newPatternChecker("regex", category, action, patterns)
+}
+
+func newPatternChecker(name, category, action string, patterns []string) (*PatternChecker, error) {
+	if err := validModerationAction(action); err != nil {
+		return nil, err
+	}
+	if len(patterns) == 0 {
+		return nil, errors.New("黑名单为空")
+	}
+
+	// 合并为一个正则，一次扫描即可找出所有命中
+	pattern, err := regexp.Compile("(?i)(?:" + strings.Join(patterns, "|") + ")")
+	if err != nil {
+		return nil, err
+	}
+
+	return &PatternChecker{
+		name:     name,
+		category: category,
+		action:   action,
+		pattern:  pattern,
+	}, nil
+}
+
+// Name 检查器名称
+func (c *PatternChecker) Name() string {
+	return c.name
+}
+
+// Check 检查文本
+func (c *PatternChecker) Check(ctx context.Context, text string) ([]*ModerationFinding, error) {
+	var findings []*ModerationFinding
+	for _, loc := range c.pattern.FindAllStringIndex(text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		findings = append(findings, &ModerationFinding{
+			Checker:     c.name,
+			Category:    c.category,
+			Action:      c.action,
+			start:       loc[0],
+			end:         loc[1],
+			replacement: strings.Repeat("*", len([]rune(text[loc[0]:loc[1]]))),
+		})
+	}
+	return findings, nil
+}
+
+// LoadPatternFile 读取黑名单文件，每行一个关键词或正则，忽略空行和#开头的注释
+func LoadPatternFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+
+	return patterns, scanner.Err()
+}
+
+// PIIChecker 个人信息检查器
+type PIIChecker struct {
+	detectors []pii.Detector
+	actions   map[string]string
+}
+
+// NewPIIChecker 创建个人信息检查器，actions为个人信息类型(id_card、phone、email、bank_card)到审核动作的映射
+func NewPIIChecker(actions map[string]string) (*PIIChecker, error) {
+	for typ, action := range actions {
+		if _, err := pii.NewDetector(typ); err != nil {
+			return nil, err
+		}
+		if err := validModerationAction(action); err != nil {
+			return nil, err
+		}
+	}
+
+	// 按优先级顺序创建检测器，保证重叠时的识别结果稳定
+	checker := &PIIChecker{actions: actions}
+	for _, typ := range pii.PatternTypes {
+		if _, exists := actions[typ]; exists {
+			detector, _ := pii.NewDetector(typ)
+			checker.detectors = append(checker.detectors, detector)
+		}
+	}
+
+	return checker, nil
+}
+
+// Name 检查器名称
+func (c *PIIChecker) Name() string {
+	return "pii"
+}
+
+// Check 检查文本
+func (c *PIIChecker) Check(ctx context.Context, text string) ([]*ModerationFinding, error) {
+	var findings []*ModerationFinding
+	for _, match := range pii.FindAll(text, c.detectors...) {
+		findings = append(findings, &ModerationFinding{
+			Checker:     c.Name(),
+			Category:    match.Type,
+			Action:      c.actions[match.Type],
+			start:       match.Start,
+			end:         match.End,
+			replacement: pii.MaskPlaceholder(match),
+		})
+	}
+	return findings, nil
+}
+
+// Classifier 内容安全分类模型
+type Classifier interface {
+	Classify(ctx context.Context, text string) ([]model.ClassifyLabel, error)
+}
+
+// ClassifierChecker 调用分类模型的检查器，命中没有位置，redact动作等同于flag
+type ClassifierChecker struct {
+	classifier Classifier
+	threshold  float32
+	action     string
+	labels     map[string]bool
+}
+
+// NewClassifierChecker 创建分类模型检查器，labels为空时处理所有类别
+func NewClassifierChecker(classifier Classifier, threshold float32, action string, labels []string) (*ClassifierChecker, error) {
+	if err := validModerationAction(action); err != nil {
+		return nil, err
+	}
+	if action == ModerationRedact {
+		action = ModerationFlag
+	}
+
+	checker := &ClassifierChecker{
+		classifier: classifier,
+		threshold:  threshold,
+		action:     action,
+	}
+	if len(labels) > 0 {
+		checker.labels = make(map[string]bool, len(labels))
+		for _, label := range labels {
+			checker.labels[label] = true
+		}
+	}
+
+	return checker, nil
+}
+
+// Name 检查器名称
+func (c *ClassifierChecker) Name() string {
+	return "classifier"
+}
+
+// Check 检查文本
+func (c *ClassifierChecker) Check(ctx context.Context, text string) ([]*ModerationFinding, error) {
+	labels, err := c.classifier.Classify(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []*ModerationFinding
+	for _, label := range labels {
+		if label.Score < c.threshold {
+			continue
+		}
+		if c.labels != nil && !c.labels[label.Label] {
+			continue
+		}
+		findings = append(findings, &ModerationFinding{
+			Checker:  c.Name(),
+			Category: label.Label,
+			Action:   c.action,
+			Score:    label.Score,
+		})
+	}
+	return findings, nil
+}
+
+// ModerationOptions 审核流程配置
+type ModerationOptions struct {
+	FailOpen       bool   // 检查器出错时放行，否则拒绝请求
+	RefusalMessage string // 模型回复被拦截时返回给用户的内容
+}
+
+// ModerationService 对用户输入和模型输出做内容审核，并管理人工审核队列
+type ModerationService struct {
+	storage  ModerationStorage
+	checkers []ModerationChecker
+	options  ModerationOptions
+}
+
+// NewModerationService 创建内容审核服务
+func NewModerationService(storage ModerationStorage, checkers []ModerationChecker, options ModerationOptions) *ModerationService {
+	if options.RefusalMessage == "" {
+		options.RefusalMessage = "抱歉，这个问题我无法回答。"
+	}
+
+	return &ModerationService{
+		storage:  storage,
+		checkers: checkers,
+		options:  options,
+	}
+}
+
+// RefusalMessage 模型回复被拦截时返回给用户的内容
+func (m *ModerationService) RefusalMessage() string {
+	return m.options.RefusalMessage
+}
+
+// Check 依次运行所有检查器，返回审核结果及处理后的文本，未命中时结果为nil
+func (m *ModerationService) Check(ctx context.Context, text string) (*ModerationResult, string, error) {
+	var findings []*ModerationFinding
+	for _, checker := range m.checkers {
+		found, err := checker.Check(ctx, text)
+		if err != nil {
+			if m.options.FailOpen {
+				log.Printf("内容检查器%s出错，已放行: %v", checker.Name(), err)
+				continue
+			}
+			return nil, text, fmt.Errorf("内容审核失败: %w", err)
+		}
+		findings = append(findings, found...)
+	}
+
+	if len(findings) == 0 {
+		return nil, text, nil
+	}
+
+	result := &ModerationResult{
+		Action:   ModerationAllow,
+		Findings: findings,
+		Review:   &ModerationReview{Status: ReviewPending},
+	}
+	for _, finding := range findings {
+		if moderationSeverity[finding.Action] > moderationSeverity[result.Action] {
+			result.Action = finding.Action
+		}
+	}
+
+	if result.Action == ModerationRedact {
+		text = redactFindings(text, findings)
+	}
+
+	return result, text, nil
+}
+
+// redactFindings 屏蔽需要redact的命中内容，重叠的位置只处理先出现的较长者
+func redactFindings(text string, findings []*ModerationFinding) string {
+	var spans []*ModerationFinding
+	for _, finding := range findings {
+		if finding.Action == ModerationRedact && finding.end > finding.start {
+			spans = append(spans, finding)
+		}
+	}
+
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].end > spans[j].end
+	})
+
+	var sb strings.Builder
+	last := 0
+	for _, span := range spans {
+		if span.start < last {
+			continue
+		}
+		sb.WriteString(text[last:span.start])
+		sb.WriteString(span.replacement)
+		last = span.end
+	}
+	sb.WriteString(text[last:])
+
+	return sb.String()
+}
+
+// ListQueue 分页获取审核队列，status为空时返回所有命中过审核规则的消息
+func (m *ModerationService) ListQueue(status string, offset, limit int) ([]*Message, int64, error) {
+	return m.storage.ListModeratedMessages(status, offset, limit)
+}
+
+// Review 记录人工审核结果
+func (m *ModerationService) Review(messageID string, reviewerID uint, status, note string) error {
+	if status != ReviewApproved && status != ReviewRejected {
+		return ErrInvalidReviewStatus
+	}
+
+	now := time.Now()
+	return m.storage.UpdateMessageReview(messageID, &ModerationReview{
+		Status:     status,
+		ReviewerID: reviewerID,
+		Note:       note,
+		ReviewedAt: &now,
+	})
+}
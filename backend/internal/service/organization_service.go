@@ -0,0 +1,305 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 组织内的成员角色
+const (
+	OrgRoleOwner  = "owner"  // 管理成员、设置，可以授予所有者角色
+	OrgRoleAdmin  = "admin"  // 管理成员和设置
+	OrgRoleMember = "member" // 在组织内聊天
+)
+
+var (
+	// ErrOrgNotFound 组织不存在或当前用户不是其成员
+	ErrOrgNotFound = errors.New("组织不存在")
+	// ErrOrgForbidden 组织角色不足
+	ErrOrgForbidden = errors.New("无权管理该组织")
+	// ErrInvalidOrgSettings 组织名称、成员角色或设置无效
+	ErrInvalidOrgSettings = errors.New("无效的组织设置")
+	// ErrLastOwner 组织至少需要保留一名所有者
+	ErrLastOwner = errors.New("组织至少需要一名所有者")
+	// ErrModelNotAllowed 当前组织不允许使用该模型
+	ErrModelNotAllowed = errors.New("当前组织不允许使用该模型")
+)
+
+// orgRoleRanks 组织角色的高低，用于比较权限
+var orgRoleRanks = map[string]int{
+	OrgRoleMember: 1,
+	OrgRoleAdmin:  2,
+	OrgRoleOwner:  3,
+}
+
+// ValidOrgRole 检查组织角色是否存在
+func ValidOrgRole(role string) bool {
+	_, ok := orgRoleRanks[role]
+	return ok
+}
+
+// OrgMembershipProvider 获取用户在组织中的成员身份，签发令牌和验证API密钥时使用
+type OrgMembershipProvider interface {
+	GetMembership(orgID, userID uint) (*OrgMembership, error)
+}
+
+// AllowsModel 检查组织是否允许使用模型
+func (o *OrgSettings) AllowsModel(model string) bool {
+	return len(o.Models) == 0 || containsString(o.Models, model)
+}
+
+// AllowsPersona 检查组织是否允许使用角色，空角色按默认角色处理
+func (o *OrgSettings) AllowsPersona(persona string) bool {
+	if persona == "" {
+		persona = DefaultPersona
+	}
+	return len(o.Personas) == 0 || containsString(o.Personas, persona)
+}
+
+// OrganizationOptions 校验组织设置时可选的套餐和角色
+type OrganizationOptions struct {
+	Plans    []string // 配额套餐名称
+	Personas []string // 工具调用的角色名称
+}
+
+// OrganizationService 管理组织、成员及组织设置
+type OrganizationService struct {
+	storage OrganizationStorage
+	options OrganizationOptions
+}
+
+// NewOrganizationService 创建组织服务
+func NewOrganizationService(storage OrganizationStorage, options OrganizationOptions) *OrganizationService {
+	return &OrganizationService{
+		storage: storage,
+		options: options,
+	}
+}
+
+// Create 创建组织，ownerID成为组织的所有者。仅平台管理员可以创建组织
+func (s *OrganizationService) Create(name string, ownerID uint) (*Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > 100 {
+		return nil, fmt.Errorf("%w: 名称不能为空且不超过100个字符", ErrInvalidOrgSettings)
+	}
+
+	now := time.Now()
+	org := &Organization{
+		Name:      name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.storage.CreateOrganization(org, ownerID); err != nil {
+		return nil, err
+	}
+
+	return org, nil
+}
+
+// List 获取所有组织
+func (s *OrganizationService) List() ([]*Organization, error) {
+	return s.storage.ListOrganizations()
+}
+
+// ListForUser 获取用户加入的组织及其角色
+func (s *OrganizationService) ListForUser(userID uint) ([]*OrgMembership, error) {
+	return s.storage.ListUserMemberships(userID)
+}
+
+// Get 获取组织详情，用户不是成员时返回ErrOrgNotFound
+func (s *OrganizationService) Get(userID, orgID uint) (*Organization, *OrgMembership, error) {
+	membership, err := s.requireRole(userID, orgID, OrgRoleMember)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	org, err := s.storage.GetOrganization(orgID)
+	if err != nil {
+		return nil, nil, ErrOrgNotFound
+	}
+
+	return org, membership, nil
+}
+
+// Members 获取组织成员，仅成员可以查看
+func (s *OrganizationService) Members(userID, orgID uint) ([]*OrgMembership, error) {
+	if _, err := s.requireRole(userID, orgID, OrgRoleMember); err != nil {
+		return nil, err
+	}
+	return s.storage.ListMembers(orgID)
+}
+
+// AddMember 添加成员或修改已有成员的角色，授予所有者角色需要操作者为所有者
+func (s *OrganizationService) AddMember(actorID, orgID, userID uint, role string) error {
+	if !ValidOrgRole(role) {
+		return fmt.Errorf("%w: 未知的组织角色 %s", ErrInvalidOrgSettings, role)
+	}
+
+	actor, err := s.requireRole(actorID, orgID, OrgRoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	existing, err := s.storage.GetMembership(orgID, userID)
+	if err != nil {
+		existing = nil
+	}
+	if err := s.checkManage(actor, existing, role); err != nil {
+		return err
+	}
+	if existing != nil && existing.Role == OrgRoleOwner && role != OrgRoleOwner {
+		if err := s.keepOwner(orgID); err != nil {
+			return err
+		}
+	}
+
+	return s.storage.SaveMembership(&OrgMembership{
+		OrgID:     orgID,
+		UserID:    userID,
+		Role:      role,
+		CreatedAt: time.Now(),
+	})
+}
+
+// UpdateMemberRole 修改成员角色
+func (s *OrganizationService) UpdateMemberRole(actorID, orgID, userID uint, role string) error {
+	if _, err := s.requireRole(actorID, orgID, OrgRoleAdmin); err != nil {
+		return err
+	}
+	if _, err := s.storage.GetMembership(orgID, userID); err != nil {
+		return fmt.Errorf("%w: 用户不是组织成员", ErrInvalidOrgSettings)
+	}
+	return s.AddMember(actorID, orgID, userID, role)
+}
+
+// RemoveMember 移除成员，成员可以自行退出组织
+func (s *OrganizationService) RemoveMember(actorID, orgID, userID uint) error {
+	actor, err := s.requireRole(actorID, orgID, OrgRoleMember)
+	if err != nil {
+		return err
+	}
+
+	target, err := s.storage.GetMembership(orgID, userID)
+	if err != nil {
+		return fmt.Errorf("%w: 用户不是组织成员", ErrInvalidOrgSettings)
+	}
+	if actorID != userID {
+		if orgRoleRanks[actor.Role] < orgRoleRanks[OrgRoleAdmin] {
+			return ErrOrgForbidden
+		}
+		if err := s.checkManage(actor, target, OrgRoleMember); err != nil {
+			return err
+		}
+	}
+	if target.Role == OrgRoleOwner {
+		if err := s.keepOwner(orgID); err != nil {
+			return err
+		}
+	}
+
+	return s.storage.DeleteMembership(orgID, userID)
+}
+
+// UpdateSettings 修改组织设置，需要组织管理员角色
+func (s *OrganizationService) UpdateSettings(actorID, orgID uint, settings OrgSettings) error {
+	if _, err := s.requireRole(actorID, orgID, OrgRoleAdmin); err != nil {
+		return err
+	}
+
+	if settings.Plan != "" && !containsString(s.options.Plans, settings.Plan) {
+		return fmt.Errorf("%w: 未知的套餐 %s", ErrInvalidOrgSettings, settings.Plan)
+	}
+	for _, persona := range settings.Personas {
+		if persona != DefaultPersona && !containsString(s.options.Personas, persona) {
+			return fmt.Errorf("%w: 未知的角色 %s", ErrInvalidOrgSettings, persona)
+		}
+	}
+	if settings.DefaultPersona != "" {
+		if settings.DefaultPersona != DefaultPersona && !containsString(s.options.Personas, settings.DefaultPersona) {
+			return fmt.Errorf("%w: 未知的角色 %s", ErrInvalidOrgSettings, settings.DefaultPersona)
+		}
+		if !settings.AllowsPersona(settings.DefaultPersona) {
+			return fmt.Errorf("%w: 默认角色不在允许的角色中", ErrInvalidOrgSettings)
+		}
+	}
+
+	return s.storage.UpdateOrganizationSettings(orgID, settings)
+}
+
+// Settings 获取组织设置
+func (s *OrganizationService) Settings(orgID uint) (*OrgSettings, error) {
+	org, err := s.storage.GetOrganization(orgID)
+	if err != nil {
+		return nil, ErrOrgNotFound
+	}
+	return &org.Settings, nil
+}
+
+// GetOrgPlan 获取组织共享的配额套餐，为空时不限制组织总用量
+func (s *OrganizationService) GetOrgPlan(orgID uint) (string, error) {
+	settings, err := s.Settings(orgID)
+	if err != nil {
+		return "", err
+	}
+	return settings.Plan, nil
+}
+
+// CheckRole 检查用户在组织中的角色不低于minRole
+func (s *OrganizationService) CheckRole(userID, orgID uint, minRole string) error {
+	_, err := s.requireRole(userID, orgID, minRole)
+	return err
+}
+
+// requireRole 获取用户的成员身份并检查角色，不是成员时返回ErrOrgNotFound以免泄露组织是否存在
+func (s *OrganizationService) requireRole(userID, orgID uint, minRole string) (*OrgMembership, error) {
+	membership, err := s.storage.GetMembership(orgID, userID)
+	if err != nil {
+		return nil, ErrOrgNotFound
+	}
+	if orgRoleRanks[membership.Role] < orgRoleRanks[minRole] {
+		return nil, ErrOrgForbidden
+	}
+	return membership, nil
+}
+
+// checkManage 检查操作者能否把目标成员设为role，只有所有者可以管理所有者或授予所有者角色
+func (s *OrganizationService) checkManage(actor, target *OrgMembership, role string) error {
+	if actor.Role == OrgRoleOwner {
+		return nil
+	}
+	if role == OrgRoleOwner || (target != nil && target.Role == OrgRoleOwner) {
+		return ErrOrgForbidden
+	}
+	return nil
+}
+
+// keepOwner 所有者被降级或移除前检查组织还有其他所有者
+func (s *OrganizationService) keepOwner(orgID uint) error {
+	members, err := s.storage.ListMembers(orgID)
+	if err != nil {
+		return err
+	}
+
+	owners := 0
+	for _, member := range members {
+		if member.Role == OrgRoleOwner {
+			owners++
+		}
+	}
+	if owners <= 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// containsString 检查列表是否包含value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
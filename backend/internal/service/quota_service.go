@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"chat-llama/config"
+	"chat-llama/pkg/ratelimit"
+)
+
+// ErrQuotaExceeded token配额已用尽
+var ErrQuotaExceeded = errors.New("token配额已用尽，请稍后再试")
+
+// PlanProvider 提供用户所属的配额套餐
+type PlanProvider interface {
+	GetUserPlan(userID uint) (string, error)
+}
+
+// OrgPlanProvider 提供组织共享的配额套餐
+type OrgPlanProvider interface {
+	GetOrgPlan(orgID uint) (string, error)
+}
+
+// UsageWindow 某个统计周期内的用量
+type UsageWindow struct {
+	Used    int64     `json:"used"`
+	Limit   int64     `json:"limit"` // 0表示不限制
+	ResetAt time.Time `json:"reset_at"`
+}
+
+// Usage 用户的token用量
+type Usage struct {
+	Plan     string      `json:"plan"`
+	Enforced bool        `json:"enforced"`
+	Daily    UsageWindow `json:"daily"`
+	Monthly  UsageWindow `json:"monthly"`
+}
+
+// QuotaService 统计并限制用户的token用量
+type QuotaService struct {
+	store    ratelimit.Store
+	config   config.QuotaConfig
+	plans    PlanProvider
+	orgPlans OrgPlanProvider
+}
+
+// NewQuotaService 创建配额服务，orgPlans为nil时不统计组织总用量
+func NewQuotaService(store ratelimit.Store, quotaConfig config.QuotaConfig, plans PlanProvider, orgPlans OrgPlanProvider) *QuotaService {
+	return &QuotaService{
+		store:    store,
+		config:   quotaConfig,
+		plans:    plans,
+		orgPlans: orgPlans,
+	}
+}
+
+// 配额计数键
+func dailyQuotaKey(userID uint, now time.Time) string {
+	return fmt.Sprintf("quota:%d:day:%s", userID, now.Format("20060102"))
+}
+
+func monthlyQuotaKey(userID uint, now time.Time) string {
+	return fmt.Sprintf("quota:%d:month:%s", userID, now.Format("200601"))
+}
+
+func orgDailyQuotaKey(orgID uint, now time.Time) string {
+	return fmt.Sprintf("quota:org:%d:day:%s", orgID, now.Format("20060102"))
+}
+
+func orgMonthlyQuotaKey(orgID uint, now time.Time) string {
+	return fmt.Sprintf("quota:org:%d:month:%s", orgID, now.Format("200601"))
+}
+
+// getPlan 获取用户套餐，获取失败时使用默认套餐
+func (q *QuotaService) getPlan(userID uint) (string, config.PlanConfig) {
+	var name string
+	if q.plans != nil {
+		name, _ = q.plans.GetUserPlan(userID)
+	}
+	return q.config.GetPlan(name)
+}
+
+// CheckQuota 检查用户及其所在组织是否还有剩余配额，orgID为0时只检查用户配额
+func (q *QuotaService) CheckQuota(ctx context.Context, userID, orgID uint) error {
+	if !q.config.Enabled {
+		return nil
+	}
+
+	usage, err := q.GetUsage(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if usage.exceeded() {
+		return ErrQuotaExceeded
+	}
+
+	if orgID != 0 && q.orgPlans != nil {
+		orgUsage, err := q.GetOrgUsage(ctx, orgID)
+		if err != nil {
+			return err
+		}
+		if orgUsage.exceeded() {
+			return ErrQuotaExceeded
+		}
+	}
+
+	return nil
+}
+
+// exceeded 检查任一周期的用量是否已达上限
+func (u *Usage) exceeded() bool {
+	if u.Daily.Limit > 0 && u.Daily.Used >= u.Daily.Limit {
+		return true
+	}
+	return u.Monthly.Limit > 0 && u.Monthly.Used >= u.Monthly.Limit
+}
+
+// RecordUsage 记录用户消耗的token数，orgID不为0时同时计入组织用量
+func (q *QuotaService) RecordUsage(ctx context.Context, userID, orgID uint, tokens int64) error {
+	if tokens <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if err := q.incr(ctx, dailyQuotaKey(userID, now), monthlyQuotaKey(userID, now), tokens); err != nil {
+		return err
+	}
+	if orgID != 0 {
+		if err := q.incr(ctx, orgDailyQuotaKey(orgID, now), orgMonthlyQuotaKey(orgID, now), tokens); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// incr 累加每日和每月的用量计数
+func (q *QuotaService) incr(ctx context.Context, dailyKey, monthlyKey string, tokens int64) error {
+	if _, err := q.store.IncrBy(ctx, dailyKey, tokens, 48*time.Hour); err != nil {
+		return err
+	}
+	if _, err := q.store.IncrBy(ctx, monthlyKey, tokens, 32*24*time.Hour); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetUsage 获取用户当前的用量
+func (q *QuotaService) GetUsage(ctx context.Context, userID uint) (*Usage, error) {
+	now := time.Now()
+	planName, plan := q.getPlan(userID)
+	return q.usage(ctx, dailyQuotaKey(userID, now), monthlyQuotaKey(userID, now), planName, plan, now)
+}
+
+// GetOrgUsage 获取组织当前的总用量，组织未设置套餐时不限制
+func (q *QuotaService) GetOrgUsage(ctx context.Context, orgID uint) (*Usage, error) {
+	var planName string
+	var plan config.PlanConfig
+	if q.orgPlans != nil {
+		name, err := q.orgPlans.GetOrgPlan(orgID)
+		if err != nil {
+			return nil, err
+		}
+		if name != "" {
+			planName, plan = q.config.GetPlan(name)
+		}
+	}
+
+	now := time.Now()
+	return q.usage(ctx, orgDailyQuotaKey(orgID, now), orgMonthlyQuotaKey(orgID, now), planName, plan, now)
+}
+
+// usage 读取计数并按套餐计算用量
+func (q *QuotaService) usage(ctx context.Context, dailyKey, monthlyKey, planName string, plan config.PlanConfig, now time.Time) (*Usage, error) {
+	daily, err := q.store.Get(ctx, dailyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	monthly, err := q.store.Get(ctx, monthlyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	year, month, day := now.Date()
+	return &Usage{
+		Plan:     planName,
+		Enforced: q.config.Enabled,
+		Daily: UsageWindow{
+			Used:    daily,
+			Limit:   plan.DailyTokens,
+			ResetAt: time.Date(year, month, day+1, 0, 0, 0, 0, now.Location()),
+		},
+		Monthly: UsageWindow{
+			Used:    monthly,
+			Limit:   plan.MonthlyTokens,
+			ResetAt: time.Date(year, month+1, 1, 0, 0, 0, 0, now.Location()),
+		},
+	}, nil
+}
+
+// EstimateTokens 按字符数粗略估算token数，中文分词器下一个汉字约对应一个token
+func EstimateTokens(text string) int64 {
+	return int64(utf8.RuneCountInString(text))
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"errors"
+)
+
+// 用户角色
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// 权限
+const (
+	PermissionManageUsers         = "users:manage"         // 查看用户、修改角色、停用账号、重置密码
+	PermissionReviewConversations = "conversations:review" // 查看任意会话用于滥用审查
+	PermissionReviewModeration    = "moderation:review"    // 处理内容审核队列
+	PermissionManageKnowledge     = "knowledge:manage"     // 管理知识库文档
+	PermissionManageCache         = "cache:manage"         // 管理语义缓存
+	PermissionManageOrganizations = "orgs:manage"          // 创建组织、查看所有组织
+	PermissionViewAudit           = "audit:view"           // 查询和导出审计日志
+)
+
+var (
+	// ErrInvalidRole 角色不存在
+	ErrInvalidRole = errors.New("无效的角色")
+	// ErrAccountDisabled 账号已被停用
+	ErrAccountDisabled = errors.New("账号已停用")
+)
+
+// rolePermissions 各角色拥有的权限
+var rolePermissions = map[string][]string{
+	RoleUser: nil,
+	RoleModerator: {
+		PermissionReviewConversations,
+		PermissionReviewModeration,
+	},
+	RoleAdmin: {
+		PermissionManageUsers,
+		PermissionReviewConversations,
+		PermissionReviewModeration,
+		PermissionManageKnowledge,
+		PermissionManageCache,
+		PermissionManageOrganizations,
+		PermissionViewAudit,
+	},
+}
+
+// ValidRole 检查角色是否存在
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission 检查角色是否拥有权限
+func HasPermission(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// UserAccess 用户的角色及账号状态
+type UserAccess struct {
+	Role     string
+	Disabled bool
+}
+
+// AccessProvider 获取用户的角色及账号状态，签发令牌时使用
+type AccessProvider interface {
+	GetUserAccess(userID uint) (*UserAccess, error)
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 安全规则类型
+const (
+	SafetyRuleEscalation = "escalation" // 用户消息命中时在回复前插入紧急就医提示
+	SafetyRuleDisclaimer = "disclaimer" // 问题或回复命中时在回复后追加免责声明
+)
+
+// SafetyRule 医疗安全规则
+type SafetyRule struct {
+	Name     string   // 规则名称，命中后记录在消息上
+	Kind     string   // escalation 或 disclaimer
+	Keywords []string // 匹配时忽略大小写，disclaimer规则为空时对所有回复生效
+	Message  string   // 插入回复的内容
+}
+
+// SafetyRules 医疗安全规则引擎，按配置顺序评估规则
+type SafetyRules struct {
+	rules []*SafetyRule
+}
+
+// NewSafetyRules 创建安全规则引擎
+func NewSafetyRules(rules []SafetyRule) (*SafetyRules, error) {
+	engine := &SafetyRules{}
+	names := make(map[string]bool, len(rules))
+	for i := range rules {
+		rule := rules[i]
+		if rule.Name == "" {
+			return nil, errors.New("安全规则缺少名称")
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("安全规则名称重复: %s", rule.Name)
+		}
+		names[rule.Name] = true
+
+		switch rule.Kind {
+		case SafetyRuleEscalation:
+			if len(rule.Keywords) == 0 {
+				return nil, fmt.Errorf("安全规则%s缺少关键词", rule.Name)
+			}
+		case SafetyRuleDisclaimer:
+		default:
+			return nil, fmt.Errorf("安全规则%s的类型无效: %s", rule.Name, rule.Kind)
+		}
+		if strings.TrimSpace(rule.Message) == "" {
+			return nil, fmt.Errorf("安全规则%s缺少提示内容", rule.Name)
+		}
+
+		keywords := make([]string, 0, len(rule.Keywords))
+		for _, keyword := range rule.Keywords {
+			if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+				keywords = append(keywords, keyword)
+			}
+		}
+		rule.Keywords = keywords
+		engine.rules = append(engine.rules, &rule)
+	}
+
+	return engine, nil
+}
+
+// matches 检查文本是否包含规则的任一关键词，texts须已转为小写
+func (r *SafetyRule) matches(texts ...string) bool {
+	if len(r.Keywords) == 0 {
+		return true
+	}
+	for _, keyword := range r.Keywords {
+		for _, text := range texts {
+			if strings.Contains(text, keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Apply 评估规则并返回处理后的回复及命中的规则名称，escalation规则只检查用户消息
+func (e *SafetyRules) Apply(question, answer string) (string, []string) {
+	lowerQuestion := strings.ToLower(question)
+	lowerAnswer := strings.ToLower(answer)
+
+	var fired []string
+	var escalations, disclaimers []string
+	for _, rule := range e.rules {
+		switch rule.Kind {
+		case SafetyRuleEscalation:
+			if !rule.matches(lowerQuestion) {
+				continue
+			}
+			escalations = append(escalations, rule.Message)
+		case SafetyRuleDisclaimer:
+			if !rule.matches(lowerQuestion, lowerAnswer) {
+				continue
+			}
+			disclaimers = append(disclaimers, rule.Message)
+		}
+		fired = append(fired, rule.Name)
+	}
+
+	if len(fired) == 0 {
+		return answer, nil
+	}
+
+	var sb strings.Builder
+	for _, message := range escalations {
+		sb.WriteString(message)
+		sb.WriteString("\n\n")
+	}
+	sb.WriteString(answer)
+	for _, message := range disclaimers {
+		sb.WriteString("\n\n")
+		sb.WriteString(message)
+	}
+
+	return sb.String(), fired
+}
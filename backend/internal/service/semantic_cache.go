@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"chat-llama/pkg/vectorstore"
+
+	"github.com/google/uuid"
+)
+
+// Embedder 计算文本向量
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// SemanticCacheEntry 语义缓存条目
+type SemanticCacheEntry struct {
+	ID        string    `json:"id"`
+	OrgID     uint      `json:"org_id"` // 写入条目的组织，0表示个人空间
+	Question  string    `json:"question"`
+	Answer    string    `json:"answer"`
+	Model     string    `json:"model"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Score     float32   `json:"score,omitempty"` // 仅检索结果有值
+}
+
+// SemanticCacheStats 语义缓存命中统计
+type SemanticCacheStats struct {
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	Threshold float32 `json:"threshold"`
+}
+
+// SemanticCache 对语义相近的问题复用已有回答
+type SemanticCache struct {
+	embedder   Embedder
+	store      vectorstore.Store
+	threshold  float32
+	ttl        time.Duration
+	maxEntries int
+	hits       atomic.Int64
+	misses     atomic.Int64
+}
+
+// NewSemanticCache 创建语义缓存，相似度不低于threshold时视为命中，最多保留maxEntries个条目
+func NewSemanticCache(embedder Embedder, store vectorstore.Store, threshold float32, ttl time.Duration, maxEntries int) *SemanticCache {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	if maxEntries <= 0 {
+		maxEntries = 10000
+	}
+
+	return &SemanticCache{
+		embedder:   embedder,
+		store:      store,
+		threshold:  threshold,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+	}
+}
+
+// 向量记录的元数据键
+const (
+	semanticMetaQuestion = "question"
+	semanticMetaAnswer   = "answer"
+	semanticMetaModel    = "model"
+)
+
+// vectorMetaOrg 向量记录中所属组织的元数据键，语义缓存和知识库共用
+const vectorMetaOrg = "org_id"
+
+// toEntry 将向量记录转换为缓存条目
+func (c *SemanticCache) toEntry(record *vectorstore.Record) *SemanticCacheEntry {
+	orgID, _ := strconv.ParseUint(record.Metadata[vectorMetaOrg], 10, 64)
+	return &SemanticCacheEntry{
+		ID:        record.ID,
+		OrgID:     uint(orgID),
+		Question:  record.Metadata[semanticMetaQuestion],
+		Answer:    record.Metadata[semanticMetaAnswer],
+		Model:     record.Metadata[semanticMetaModel],
+		CreatedAt: record.CreatedAt,
+		ExpiresAt: record.CreatedAt.Add(c.ttl),
+	}
+}
+
+// orgFilter 只匹配同一组织写入的向量记录，orgID为0表示个人空间
+func orgFilter(orgID uint) vectorstore.Filter {
+	return vectorstore.Filter{vectorMetaOrg: strconv.FormatUint(uint64(orgID), 10)}
+}
+
+// Lookup 在组织内查找与问题语义相近的缓存回答，同时返回问题的向量供写入时复用
+func (c *SemanticCache) Lookup(ctx context.Context, orgID uint, question string) (*SemanticCacheEntry, []float32, error) {
+	vectors, err := c.embedder.Embed(ctx, []string{question})
+	if err != nil {
+		return nil, nil, err
+	}
+	vector := vectors[0]
+
+	matches, err := c.store.Search(ctx, vector, 5, orgFilter(orgID))
+	if err != nil {
+		return nil, vector, err
+	}
+
+	now := time.Now()
+	for _, match := range matches {
+		if match.Score < c.threshold {
+			break
+		}
+
+		entry := c.toEntry(match.Record)
+		if now.After(entry.ExpiresAt) {
+			// 清理过期条目
+			c.store.Delete(ctx, match.ID)
+			continue
+		}
+
+		c.hits.Add(1)
+		entry.Score = match.Score
+		return entry, vector, nil
+	}
+
+	c.misses.Add(1)
+	return nil, vector, nil
+}
+
+// Store 写入组织内的问题和回答，vector为空时重新计算
+func (c *SemanticCache) Store(ctx context.Context, orgID uint, question, answer, model string, vector []float32) error {
+	if len(vector) == 0 {
+		vectors, err := c.embedder.Embed(ctx, []string{question})
+		if err != nil {
+			return err
+		}
+		vector = vectors[0]
+	}
+
+	// 查找时只清理命中的过期条目，写入前清理其余过期和超出上限的条目
+	if err := c.prune(ctx); err != nil {
+		log.Printf("清理语义缓存失败: %v", err)
+	}
+
+	return c.store.Upsert(ctx, &vectorstore.Record{
+		ID:     uuid.New().String(),
+		Vector: vector,
+		Metadata: map[string]string{
+			semanticMetaQuestion: question,
+			semanticMetaAnswer:   answer,
+			semanticMetaModel:    model,
+			vectorMetaOrg:        strconv.FormatUint(uint64(orgID), 10),
+		},
+		CreatedAt: time.Now(),
+	})
+}
+
+// prune 删除过期的条目，并删除最早的条目直到为新条目留出空间
+func (c *SemanticCache) prune(ctx context.Context) error {
+	// 按创建时间倒序返回
+	records, _, err := c.store.List(ctx, 0, 0, nil)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var ids []string
+	for i, record := range records {
+		if i >= c.maxEntries-1 || now.After(record.CreatedAt.Add(c.ttl)) {
+			ids = append(ids, record.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return c.store.Delete(ctx, ids...)
+}
+
+// List 分页列出缓存条目
+func (c *SemanticCache) List(ctx context.Context, offset, limit int) ([]*SemanticCacheEntry, int, error) {
+	records, total, err := c.store.List(ctx, offset, limit, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries := make([]*SemanticCacheEntry, len(records))
+	for i, record := range records {
+		entries[i] = c.toEntry(record)
+	}
+
+	return entries, total, nil
+}
+
+// Evict 删除指定条目
+func (c *SemanticCache) Evict(ctx context.Context, id string) error {
+	if _, err := c.store.Get(ctx, id); err != nil {
+		if errors.Is(err, vectorstore.ErrNotFound) {
+			return errors.New("缓存条目不存在")
+		}
+		return err
+	}
+
+	return c.store.Delete(ctx, id)
+}
+
+// Clear 清空所有条目
+func (c *SemanticCache) Clear(ctx context.Context) error {
+	records, _, err := c.store.List(ctx, 0, 0, nil)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, len(records))
+	for i, record := range records {
+		ids[i] = record.ID
+	}
+
+	return c.store.Delete(ctx, ids...)
+}
+
+// Stats 获取命中统计
+func (c *SemanticCache) Stats() SemanticCacheStats {
+	return SemanticCacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Threshold: c.threshold,
+	}
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"chat-llama/pkg/oidc"
+)
+
+var (
+	// ErrSSOProviderNotFound 未配置该身份提供方
+	ErrSSOProviderNotFound = errors.New("未配置该登录方式")
+	// ErrSSOInvalidState 登录请求不存在、已过期、已使用或不是由当前浏览器发起
+	ErrSSOInvalidState = errors.New("登录请求已过期或无效")
+	// ErrSSOAccountNotLinked 外部账号未关联本地用户，且不允许自动创建
+	ErrSSOAccountNotLinked = errors.New("该账号未关联本地用户，请联系管理员")
+)
+
+// SSOStateStore 保存授权请求的临时状态，每个状态只能取出一次
+type SSOStateStore interface {
+	Save(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Take(ctx context.Context, key string, dest interface{}) (bool, error)
+}
+
+// SSOProvider 单点登录身份提供方
+type SSOProvider struct {
+	Name        string
+	DisplayName string
+	Client      *oidc.Provider
+	AutoCreate  bool // 首次登录时自动创建本地用户
+	LinkByEmail bool // 首次登录时关联邮箱相同的本地用户，双方的邮箱都必须已验证
+}
+
+// SSOProviderInfo 返回给前端的身份提供方信息
+type SSOProviderInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// ssoState 授权请求的临时状态
+type ssoState struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// SSOService 通过OIDC授权码流程登录，外部身份关联到本地用户后按本地会话签发令牌
+type SSOService struct {
+	identities IdentityStorage
+	states     SSOStateStore
+	auth       *AuthService
+	providers  map[string]*SSOProvider
+	order      []string
+	stateTTL   time.Duration
+}
+
+// NewSSOService 创建单点登录服务
+func NewSSOService(identities IdentityStorage, states SSOStateStore, auth *AuthService, providers []*SSOProvider, stateTTL time.Duration) *SSOService {
+	if stateTTL <= 0 {
+		stateTTL = 10 * time.Minute
+	}
+
+	s := &SSOService{
+		identities: identities,
+		states:     states,
+		auth:       auth,
+		providers:  make(map[string]*SSOProvider, len(providers)),
+		stateTTL:   stateTTL,
+	}
+	for _, provider := range providers {
+		s.providers[provider.Name] = provider
+		s.order = append(s.order, provider.Name)
+	}
+
+	return s
+}
+
+// Providers 获取已配置的身份提供方
+func (s *SSOService) Providers() []SSOProviderInfo {
+	result := make([]SSOProviderInfo, len(s.order))
+	for i, name := range s.order {
+		result[i] = SSOProviderInfo{
+			Name:        name,
+			DisplayName: s.providers[name].DisplayName,
+		}
+	}
+	return result
+}
+
+// StateTTL 返回授权请求的有效期
+func (s *SSOService) StateTTL() time.Duration {
+	return s.stateTTL
+}
+
+// Begin 开始授权流程，返回身份提供方的授权地址和state。
+// 调用方需要把state保存在发起登录的浏览器中（如Cookie），回调时传给Complete校验
+func (s *SSOService) Begin(ctx context.Context, providerName string) (string, string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", "", ErrSSOProviderNotFound
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	codeVerifier, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := provider.Client.AuthCodeURL(ctx, state, nonce, codeVerifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := s.states.Save(ctx, state, &ssoState{
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+	}, s.stateTTL); err != nil {
+		return "", "", err
+	}
+
+	return authURL, state, nil
+}
+
+// Complete 处理身份提供方的回调，校验state和ID令牌后登录关联的本地用户。
+// browserState为发起登录的浏览器保存的state，与回调中的state不一致时拒绝，防止把他人发起的登录回调发给受害者（登录CSRF）
+func (s *SSOService) Complete(ctx context.Context, providerName, code, state, browserState string, client ClientInfo) (*TokenPair, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrSSOProviderNotFound
+	}
+	if code == "" || state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		return nil, ErrSSOInvalidState
+	}
+
+	var saved ssoState
+	found, err := s.states.Take(ctx, state, &saved)
+	if err != nil {
+		return nil, err
+	}
+	if !found || saved.Provider != providerName {
+		return nil, ErrSSOInvalidState
+	}
+
+	token, err := provider.Client.Exchange(ctx, code, saved.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := provider.Client.VerifyIDToken(ctx, token.IDToken, saved.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := s.resolveUser(provider, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.auth.Login(ctx, userID, client)
+}
+
+// resolveUser 获取外部身份关联的本地用户，首次登录时按配置关联或创建用户
+func (s *SSOService) resolveUser(provider *SSOProvider, claims *oidc.Claims) (uint, error) {
+	userID, found, err := s.identities.GetUserIDByIdentity(provider.Name, claims.Subject)
+	if err != nil {
+		return 0, err
+	}
+	if found {
+		return userID, nil
+	}
+
+	identity := &ExternalIdentity{
+		Provider:    provider.Name,
+		Subject:     claims.Subject,
+		Email:       claims.Email,
+		CreatedAt:   time.Now(),
+		LastLoginAt: time.Now(),
+	}
+
+	// 只信任身份提供方已验证的邮箱，且只关联邮箱已验证的本地用户，
+	// 避免通过伪造邮箱或抢先注册他人邮箱的本地账号接管对方的单点登录身份
+	if provider.LinkByEmail && claims.Email != "" && claims.EmailVerified {
+		userID, found, err := s.identities.FindUserIDByEmail(claims.Email)
+		if err != nil {
+			return 0, err
+		}
+		if found {
+			identity.UserID = userID
+			if err := s.identities.LinkIdentity(identity); err != nil {
+				return 0, err
+			}
+			log.Printf("外部身份已按邮箱关联本地用户: provider=%s user=%d", provider.Name, userID)
+			return userID, nil
+		}
+	}
+
+	if !provider.AutoCreate {
+		return 0, ErrSSOAccountNotLinked
+	}
+
+	email := ""
+	if claims.EmailVerified {
+		email = claims.Email
+	}
+	userID, err = s.identities.CreateUserWithIdentity(ssoUsername(provider.Name, claims), email, identity)
+	if err != nil {
+		return 0, fmt.Errorf("创建用户失败: %w", err)
+	}
+	log.Printf("通过单点登录创建用户: provider=%s user=%d", provider.Name, userID)
+
+	return userID, nil
+}
+
+// usernamePattern 用户名中允许的字符
+var usernamePattern = regexp.MustCompile(`[^\p{L}\p{N}._-]+`)
+
+// ssoUsername 根据ID令牌生成本地用户名，优先使用preferred_username，其次是邮箱的用户名部分
+func ssoUsername(providerName string, claims *oidc.Claims) string {
+	candidates := []string{claims.PreferredUsername}
+	if at := strings.Index(claims.Email, "@"); at > 0 {
+		candidates = append(candidates, claims.Email[:at])
+	}
+
+	for _, candidate := range candidates {
+		username := usernamePattern.ReplaceAllString(candidate, "")
+		if username != "" {
+			if runes := []rune(username); len(runes) > 40 {
+				username = string(runes[:40])
+			}
+			return username
+		}
+	}
+
+	subject := usernamePattern.ReplaceAllString(claims.Subject, "")
+	if len(subject) > 32 {
+		subject = subject[:32]
+	}
+	return providerName + "_" + subject
+}
@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"chat-llama/pkg/oidc"
+	"chat-llama/pkg/oidc/oidctest"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// memoryStateStore 带过期时间的内存state存储
+type memoryStateStore struct {
+	mutex   sync.Mutex
+	entries map[string]stateEntry
+}
+
+type stateEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func (m *memoryStateStore) Save(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.entries == nil {
+		m.entries = make(map[string]stateEntry)
+	}
+	m.entries[key] = stateEntry{value: data, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *memoryStateStore) Take(ctx context.Context, key string, dest interface{}) (bool, error) {
+	m.mutex.Lock()
+	entry, ok := m.entries[key]
+	delete(m.entries, key)
+	m.mutex.Unlock()
+
+	if !ok || time.Now().After(entry.expiresAt) {
+		return false, nil
+	}
+	return true, json.Unmarshal(entry.value, dest)
+}
+
+// memoryIdentityStorage 内存中的外部身份和本地用户，emails中只有邮箱已验证的用户
+type memoryIdentityStorage struct {
+	identities map[string]uint // provider/subject -> userID
+	emails     map[string]uint
+	created    []string // 自动创建的用户名
+	nextUserID uint
+}
+
+func newMemoryIdentityStorage() *memoryIdentityStorage {
+	return &memoryIdentityStorage{
+		identities: make(map[string]uint),
+		emails:     make(map[string]uint),
+		nextUserID: 100,
+	}
+}
+
+func (m *memoryIdentityStorage) GetUserIDByIdentity(provider, subject string) (uint, bool, error) {
+	userID, ok := m.identities[provider+"/"+subject]
+	return userID, ok, nil
+}
+
+func (m *memoryIdentityStorage) FindUserIDByEmail(email string) (uint, bool, error) {
+	userID, ok := m.emails[email]
+	return userID, ok, nil
+}
+
+func (m *memoryIdentityStorage) LinkIdentity(identity *ExternalIdentity) error {
+	m.identities[identity.Provider+"/"+identity.Subject] = identity.UserID
+	return nil
+}
+
+func (m *memoryIdentityStorage) CreateUserWithIdentity(username, email string, identity *ExternalIdentity) (uint, error) {
+	m.nextUserID++
+	m.created = append(m.created, username)
+	if email != "" {
+		m.emails[email] = m.nextUserID
+	}
+	identity.UserID = m.nextUserID
+	return m.nextUserID, m.LinkIdentity(identity)
+}
+
+// memorySessionStorage 只实现登录用到的方法
+type memorySessionStorage struct {
+	SessionStorage
+	sessions []*Session
+}
+
+func (m *memorySessionStorage) CreateSession(session *Session) error {
+	m.sessions = append(m.sessions, session)
+	return nil
+}
+
+func (m *memorySessionStorage) CreateRefreshToken(token *RefreshToken) error {
+	return nil
+}
+
+// ssoFixture 连接到模拟身份提供方的单点登录服务
+type ssoFixture struct {
+	idp        *oidctest.IdP
+	identities *memoryIdentityStorage
+	sessions   *memorySessionStorage
+	service    *SSOService
+}
+
+func newSSOFixture(t *testing.T, provider SSOProvider, stateTTL time.Duration) *ssoFixture {
+	t.Helper()
+	idp := oidctest.NewIdP()
+	t.Cleanup(idp.Close)
+
+	provider.Name = "test"
+	provider.Client = oidc.NewProvider(idp.Config())
+
+	f := &ssoFixture{
+		idp:        idp,
+		identities: newMemoryIdentityStorage(),
+		sessions:   &memorySessionStorage{},
+	}
+	auth := NewAuthService(f.sessions, nil, nil, nil, "test-secret", AuthOptions{})
+	f.service = NewSSOService(f.identities, &memoryStateStore{}, auth, []*SSOProvider{&provider}, stateTTL)
+	return f
+}
+
+// begin 发起登录并模拟用户在身份提供方完成授权，返回授权码和state
+func (f *ssoFixture) begin(t *testing.T) (string, string) {
+	t.Helper()
+	authURL, state, err := f.service.Begin(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := f.idp.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code, state
+}
+
+func (f *ssoFixture) complete(code, state, browserState string) (*TokenPair, error) {
+	return f.service.Complete(context.Background(), "test", code, state, browserState, ClientInfo{IP: "127.0.0.1"})
+}
+
+func TestSSOCompleteLogsInLinkedUser(t *testing.T) {
+	f := newSSOFixture(t, SSOProvider{}, 0)
+	f.identities.identities["test/"+f.idp.Subject] = 7
+
+	code, state := f.begin(t)
+	tokens, err := f.complete(code, state, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.UserID != 7 || tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Fatalf("unexpected tokens: %+v", tokens)
+	}
+	if len(f.sessions.sessions) != 1 || f.sessions.sessions[0].IP != "127.0.0.1" {
+		t.Fatalf("expected one session for the login, got %+v", f.sessions.sessions)
+	}
+}
+
+func TestSSOCompleteRejectsInvalidState(t *testing.T) {
+	f := newSSOFixture(t, SSOProvider{AutoCreate: true}, 0)
+
+	// 回调中的state与浏览器保存的不一致（登录CSRF）
+	code, state := f.begin(t)
+	if _, err := f.complete(code, state, "attacker-state"); !errors.Is(err, ErrSSOInvalidState) {
+		t.Fatalf("err = %v, want ErrSSOInvalidState for a browser state mismatch", err)
+	}
+	if _, err := f.complete(code, state, ""); !errors.Is(err, ErrSSOInvalidState) {
+		t.Fatalf("err = %v, want ErrSSOInvalidState without a browser state", err)
+	}
+
+	// 未保存过的state
+	if _, err := f.complete(code, "unknown", "unknown"); !errors.Is(err, ErrSSOInvalidState) {
+		t.Fatalf("err = %v, want ErrSSOInvalidState for an unknown state", err)
+	}
+
+	// state只能使用一次
+	if _, err := f.complete(code, state, state); err != nil {
+		t.Fatal(err)
+	}
+	code, _ = f.begin(t)
+	if _, err := f.complete(code, state, state); !errors.Is(err, ErrSSOInvalidState) {
+		t.Fatalf("err = %v, want ErrSSOInvalidState when reusing a state", err)
+	}
+}
+
+func TestSSOCompleteRejectsExpiredState(t *testing.T) {
+	f := newSSOFixture(t, SSOProvider{AutoCreate: true}, 50*time.Millisecond)
+
+	code, state := f.begin(t)
+	time.Sleep(100 * time.Millisecond)
+	if _, err := f.complete(code, state, state); !errors.Is(err, ErrSSOInvalidState) {
+		t.Fatalf("err = %v, want ErrSSOInvalidState for an expired state", err)
+	}
+}
+
+func TestSSOCompleteRejectsNonceMismatch(t *testing.T) {
+	f := newSSOFixture(t, SSOProvider{AutoCreate: true}, 0)
+	f.idp.Mutate = func(claims jwt.MapClaims) { claims["nonce"] = "replayed-nonce" }
+
+	code, state := f.begin(t)
+	if _, err := f.complete(code, state, state); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Fatalf("err = %v, want ErrInvalidIDToken for a nonce mismatch", err)
+	}
+	if len(f.identities.created) != 0 || len(f.sessions.sessions) != 0 {
+		t.Fatal("no user or session should be created for an invalid ID token")
+	}
+}
+
+func TestSSOLinkByEmail(t *testing.T) {
+	tests := []struct {
+		name          string
+		provider      SSOProvider
+		emailVerified bool
+		wantUserID    uint
+		wantErr       error
+	}{
+		{name: "verified email links existing user", provider: SSOProvider{LinkByEmail: true}, emailVerified: true, wantUserID: 42},
+		{name: "unverified email is not linked", provider: SSOProvider{LinkByEmail: true}, emailVerified: false, wantErr: ErrSSOAccountNotLinked},
+		{name: "unverified email creates a new user", provider: SSOProvider{LinkByEmail: true, AutoCreate: true}, emailVerified: false, wantUserID: 101},
+		{name: "linking disabled", provider: SSOProvider{}, emailVerified: true, wantErr: ErrSSOAccountNotLinked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newSSOFixture(t, tt.provider, 0)
+			f.idp.EmailVerified = tt.emailVerified
+			f.identities.emails[f.idp.Email] = 42
+
+			code, state := f.begin(t)
+			tokens, err := f.complete(code, state, state)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				if _, linked, _ := f.identities.GetUserIDByIdentity("test", f.idp.Subject); linked {
+					t.Fatal("identity should not be linked")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tokens.UserID != tt.wantUserID {
+				t.Fatalf("logged in as user %d, want %d", tokens.UserID, tt.wantUserID)
+			}
+			if userID, _, _ := f.identities.GetUserIDByIdentity("test", f.idp.Subject); userID != tt.wantUserID {
+				t.Fatalf("identity linked to user %d, want %d", userID, tt.wantUserID)
+			}
+		})
+	}
+}
@@ -1,15 +1,126 @@
-package service
-
-// Storage 定义聊天数据的存储接口
-type Storage interface {
-	// 会话管理
-	CreateConversation(userID uint, title string) (*Conversation, error)
-	GetConversation(id string) (*Conversation, error)
-	GetConversationsByUserID(userID uint) ([]*Conversation, error)
-	UpdateConversationTitle(id string, title string) error
-	DeleteConversation(id string) error
-
-	// 消息管理
-	AddMessage(conversationID, role, content string) (*Message, error)
-	GetMessagesByConversationID(conversationID string) ([]*Message, error)
-}
+package service
+
+import "time"
+
+// Storage 定义聊天数据的存储接口
+type Storage interface {
+	// 会话管理，orgID为0表示个人空间，会话不属于orgID时视为不存在
+	CreateConversation(userID, orgID uint, title string) (*Conversation, error)
+	GetConversation(orgID uint, id string) (*Conversation, error)
+	// GetConversationUnscoped 获取会话，不按组织过滤，仅用于管理员滥用审查
+	GetConversationUnscoped(id string) (*Conversation, error)
+	GetConversationsByUserID(userID, orgID uint) ([]*Conversation, error)
+	UpdateConversationTitle(orgID uint, id string, title string) error
+	UpdateConversationTools(orgID uint, id string, persona string, tools []string) error
+	DeleteConversation(orgID uint, id string) error
+
+	// 会话成员，删除会话时一并删除
+	// GetSharedConversations 获取组织内共享给用户的会话
+	GetSharedConversations(userID, orgID uint) ([]*Conversation, error)
+	// GetConversationMember 获取用户在会话中的成员身份，不是成员时返回错误
+	GetConversationMember(conversationID string, userID uint) (*ConversationMember, error)
+	ListConversationMembers(conversationID string) ([]*ConversationMember, error)
+	// SaveConversationMember 添加成员，成员已存在时修改角色
+	SaveConversationMember(member *ConversationMember) error
+	DeleteConversationMember(conversationID string, userID uint) error
+
+	// 消息管理
+	AddMessage(conversationID, role, content string) (*Message, error)
+	SaveMessage(msg *Message) (*Message, error)
+	GetMessagesByConversationID(conversationID string) ([]*Message, error)
+
+	// 用量统计
+	RecordUsage(userID, orgID uint, model string, usage *TokenUsage) error
+	GetUsageStats(userID, orgID uint, since time.Time) ([]*UsageStat, error)
+}
+
+// KnowledgeStorage 定义知识库文档的存储接口，查询都按组织过滤
+type KnowledgeStorage interface {
+	CreateDocument(doc *KnowledgeDocument, chunks []*KnowledgeChunk) error
+	GetDocument(orgID uint, id string) (*KnowledgeDocument, error)
+	ListDocuments(orgID uint) ([]*KnowledgeDocument, error)
+	DeleteDocument(orgID uint, id string) error
+	GetChunksByDocumentID(orgID uint, documentID string) ([]*KnowledgeChunk, error)
+	GetChunks(orgID uint, ids []string) ([]*KnowledgeChunk, error)
+}
+
+// IdentityStorage 定义外部身份关联的存储接口
+type IdentityStorage interface {
+	// GetUserIDByIdentity 获取外部身份关联的用户，未关联时返回false，同时更新最近登录时间
+	GetUserIDByIdentity(provider, subject string) (uint, bool, error)
+	// FindUserIDByEmail 通过邮箱查找邮箱已验证的本地用户，不存在时返回false
+	FindUserIDByEmail(email string) (uint, bool, error)
+	LinkIdentity(identity *ExternalIdentity) error
+	// CreateUserWithIdentity 创建无本地密码的用户并关联外部身份，用户名冲突时自动添加后缀
+	CreateUserWithIdentity(username, email string, identity *ExternalIdentity) (uint, error)
+}
+
+// OrganizationStorage 定义组织及成员的存储接口
+type OrganizationStorage interface {
+	// CreateOrganization 创建组织并将ownerID设为所有者
+	CreateOrganization(org *Organization, ownerID uint) error
+	GetOrganization(id uint) (*Organization, error)
+	ListOrganizations() ([]*Organization, error)
+	UpdateOrganizationSettings(id uint, settings OrgSettings) error
+	// GetMembership 获取用户在组织中的成员身份，不是成员时返回错误
+	GetMembership(orgID, userID uint) (*OrgMembership, error)
+	ListMembers(orgID uint) ([]*OrgMembership, error)
+	// ListUserMemberships 获取用户加入的所有组织
+	ListUserMemberships(userID uint) ([]*OrgMembership, error)
+	// SaveMembership 添加成员，成员已存在时修改角色
+	SaveMembership(membership *OrgMembership) error
+	DeleteMembership(orgID, userID uint) error
+}
+
+// APIKeyStorage 定义API密钥的存储接口
+type APIKeyStorage interface {
+	CreateAPIKey(key *APIKey) error
+	GetAPIKeyByHash(hash string) (*APIKey, error)
+	// ListUserAPIKeys 获取用户所有未撤销的密钥，按创建时间倒序
+	ListUserAPIKeys(userID uint) ([]*APIKey, error)
+	// RevokeAPIKey 撤销用户的密钥，密钥不存在或不属于该用户时返回错误
+	RevokeAPIKey(userID uint, id string) error
+	TouchAPIKey(id string, at time.Time) error
+}
+
+// SessionStorage 定义登录会话及刷新令牌的存储接口
+type SessionStorage interface {
+	CreateSession(session *Session) error
+	GetSession(id string) (*Session, error)
+	// ListUserSessions 获取用户所有未撤销的会话，按最近活跃时间倒序
+	ListUserSessions(userID uint) ([]*Session, error)
+	TouchSession(id, ip string, at time.Time) error
+	// UpdateSessionOrg 修改会话当前所在的组织
+	UpdateSessionOrg(id string, orgID uint) error
+	RevokeSession(id string) error
+	// RevokeUserSessions 撤销用户所有未撤销的会话，返回被撤销的会话ID
+	RevokeUserSessions(userID uint) ([]string, error)
+	CreateRefreshToken(token *RefreshToken) error
+	GetRefreshTokenByHash(hash string) (*RefreshToken, error)
+	// UseRefreshToken 将刷新令牌标记为已使用，令牌已被使用过时返回false
+	UseRefreshToken(id string) (bool, error)
+}
+
+// ModerationStorage 定义审核队列的存储接口
+type ModerationStorage interface {
+	ListModeratedMessages(status string, offset, limit int) ([]*Message, int64, error)
+	UpdateMessageReview(messageID string, review *ModerationReview) error
+}
+
+// AuditStorage 定义审计事件的存储接口，事件只能追加不能修改
+type AuditStorage interface {
+	SaveAuditEvent(event *AuditEvent) error
+	ListAuditEvents(filter AuditFilter, offset, limit int) ([]*AuditEvent, int64, error)
+	// ScanAuditEvents 按写入顺序分批读取符合条件的事件并依次回调
+	ScanAuditEvents(filter AuditFilter, fn func(event *AuditEvent) error) error
+}
+
+// AttachmentStorage 定义聊天附件的存储接口
+type AttachmentStorage interface {
+	CreateAttachment(attachment *Attachment) error
+	GetAttachment(id string) (*Attachment, error)
+	LinkAttachments(ids []string, conversationID, messageID string) error
+	GetAttachmentsByConversationID(conversationID string) ([]*Attachment, error)
+	DeleteAttachment(id string) error
+	DeleteAttachmentsByConversationID(conversationID string) error
+}
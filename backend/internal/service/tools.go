@@ -0,0 +1,357 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ToolHandler 工具的执行函数，args为符合参数Schema的JSON对象
+type ToolHandler func(ctx context.Context, args json.RawMessage) (string, error)
+
+// Tool 可供助手调用的工具
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"` // JSON Schema，顶层必须是object
+	Handler     ToolHandler     `json:"-"`
+}
+
+// ToolCall 模型发起的一次工具调用
+type ToolCall struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// ToolResult 工具调用的执行结果
+type ToolResult struct {
+	CallID    string `json:"call_id"`
+	Name      string `json:"name"`
+	Output    string `json:"output"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// 工具事件类型
+const (
+	ToolEventCall   = "tool_call"
+	ToolEventResult = "tool_result"
+)
+
+// ToolEvent 工具调用过程中产生的事件，用于向客户端实时推送
+type ToolEvent struct {
+	Type           string      `json:"type"`
+	ConversationID string      `json:"conversation_id"`
+	Call           *ToolCall   `json:"call,omitempty"`
+	Result         *ToolResult `json:"result,omitempty"`
+}
+
+// ToolEventHandler 接收工具事件的回调
+type ToolEventHandler func(event *ToolEvent)
+
+type toolEventHandlerKey struct{}
+
+// WithToolEventHandler 在上下文中设置工具事件回调，Chat执行工具时会依次回调
+func WithToolEventHandler(ctx context.Context, handler ToolEventHandler) context.Context {
+	return context.WithValue(ctx, toolEventHandlerKey{}, handler)
+}
+
+// emitToolEvent 向上下文中的回调发送工具事件
+func emitToolEvent(ctx context.Context, event *ToolEvent) {
+	if handler, ok := ctx.Value(toolEventHandlerKey{}).(ToolEventHandler); ok && handler != nil {
+		handler(event)
+	}
+}
+
+var toolNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// ToolRegistry 工具注册表
+type ToolRegistry struct {
+	tools map[string]*Tool
+	mutex sync.RWMutex
+}
+
+// NewToolRegistry 创建工具注册表
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		tools: make(map[string]*Tool),
+	}
+}
+
+// Register 注册工具，名称只能包含小写字母、数字和下划线
+func (r *ToolRegistry) Register(tool *Tool) error {
+	if !toolNamePattern.MatchString(tool.Name) {
+		return fmt.Errorf("无效的工具名称: %s", tool.Name)
+	}
+	if tool.Handler == nil {
+		return fmt.Errorf("工具%s缺少执行函数", tool.Name)
+	}
+
+	if len(tool.Parameters) == 0 {
+		tool.Parameters = json.RawMessage(`{"type":"object","properties":{}}`)
+	}
+	var schema jsonSchema
+	if err := json.Unmarshal(tool.Parameters, &schema); err != nil {
+		return fmt.Errorf("工具%s的参数Schema无效: %w", tool.Name, err)
+	}
+	if schema.Type != "object" {
+		return fmt.Errorf("工具%s的参数Schema顶层必须是object", tool.Name)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.tools[tool.Name]; exists {
+		return fmt.Errorf("工具已注册: %s", tool.Name)
+	}
+	r.tools[tool.Name] = tool
+
+	return nil
+}
+
+// Get 获取工具
+func (r *ToolRegistry) Get(name string) (*Tool, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	tool, exists := r.tools[name]
+	return tool, exists
+}
+
+// List 按名称顺序获取所有工具
+func (r *ToolRegistry) List() []*Tool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := make([]*Tool, 0, len(r.tools))
+	for _, tool := range r.tools {
+		result = append(result, tool)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
+// Execute 校验参数并在超时时间内执行工具，失败信息写入结果而不是返回错误，以便模型据此调整
+func (r *ToolRegistry) Execute(ctx context.Context, call *ToolCall, timeout time.Duration) *ToolResult {
+	result := &ToolResult{
+		CallID: call.ID,
+		Name:   call.Name,
+	}
+	start := time.Now()
+	defer func() {
+		result.LatencyMs = time.Since(start).Milliseconds()
+	}()
+
+	tool, exists := r.Get(call.Name)
+	if !exists {
+		result.Error = "未知的工具: " + call.Name
+		return result
+	}
+
+	args := call.Arguments
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	if err := validateArguments(tool.Parameters, args); err != nil {
+		result.Error = "参数错误: " + err.Error()
+		return result
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// 在独立协程中执行，避免不检查上下文的工具阻塞对话
+	type outcome struct {
+		output string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- outcome{err: fmt.Errorf("工具执行异常: %v", p)}
+			}
+		}()
+		output, err := tool.Handler(ctx, args)
+		done <- outcome{output: output, err: err}
+	}()
+
+	select {
+	case o := <-done:
+		if o.err != nil {
+			result.Error = o.err.Error()
+		} else {
+			result.Output = o.output
+		}
+	case <-ctx.Done():
+		result.Error = "工具执行超时"
+	}
+
+	return result
+}
+
+// toolCallPattern 模型输出中的工具调用
+var toolCallPattern = regexp.MustCompile(`(?s)<tool_call>\s*(.*?)\s*</tool_call>`)
+
+// ParseToolCalls 从模型输出中解析工具调用，返回去掉调用标记后的文本
+func ParseToolCalls(response string) (string, []*ToolCall) {
+	matches := toolCallPattern.FindAllStringSubmatch(response, -1)
+	if len(matches) == 0 {
+		return response, nil
+	}
+
+	var calls []*ToolCall
+	for _, match := range matches {
+		var call ToolCall
+		if err := json.Unmarshal([]byte(match[1]), &call); err != nil || call.Name == "" {
+			continue
+		}
+		call.ID = uuid.New().String()
+		calls = append(calls, &call)
+	}
+
+	text := strings.TrimSpace(toolCallPattern.ReplaceAllString(response, ""))
+	return text, calls
+}
+
+// formatToolCall 将工具调用还原为提示词中的调用标记
+func formatToolCall(call *ToolCall) string {
+	data, _ := json.Marshal(struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments,omitempty"`
+	}{call.Name, call.Arguments})
+	return "<tool_call>" + string(data) + "</tool_call>"
+}
+
+// BuildToolsPrompt 生成提示词中的工具说明及调用约定
+func BuildToolsPrompt(tools []*Tool) string {
+	if len(tools) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("可用工具:\n")
+	for _, tool := range tools {
+		fmt.Fprintf(&sb, "- %s: %s 参数: %s\n", tool.Name, tool.Description, string(tool.Parameters))
+	}
+	sb.WriteString("需要使用工具时，只输出 <tool_call>{\"name\": \"工具名\", \"arguments\": {参数}}</tool_call>，等待工具结果后再回答用户。\n")
+
+	return sb.String()
+}
+
+// jsonSchema 工具参数校验所需的JSON Schema子集
+type jsonSchema struct {
+	Type                 string                 `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	Enum                 []interface{}          `json:"enum"`
+	Items                *jsonSchema            `json:"items"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+}
+
+// validateArguments 按Schema校验工具参数
+func validateArguments(schemaData, args json.RawMessage) error {
+	var schema jsonSchema
+	if err := json.Unmarshal(schemaData, &schema); err != nil {
+		return err
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(args)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return errors.New("参数不是有效的JSON")
+	}
+
+	return schema.validate("arguments", value)
+}
+
+func (s *jsonSchema) validate(path string, value interface{}) error {
+	if len(s.Enum) > 0 {
+		matched := false
+		for _, option := range s.Enum {
+			if fmt.Sprint(option) == fmt.Sprint(value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s必须是%v之一", path, s.Enum)
+		}
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s必须是对象", path)
+		}
+		for _, name := range s.Required {
+			if _, exists := obj[name]; !exists {
+				return fmt.Errorf("缺少参数%s.%s", path, name)
+			}
+		}
+		for name, field := range obj {
+			property, exists := s.Properties[name]
+			if !exists {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("未知参数%s.%s", path, name)
+				}
+				continue
+			}
+			if err := property.validate(path+"."+name, field); err != nil {
+				return err
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s必须是数组", path)
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s必须是字符串", path)
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			return fmt.Errorf("%s必须是数字", path)
+		}
+	case "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s必须是整数", path)
+		}
+		if _, err := number.Int64(); err != nil {
+			return fmt.Errorf("%s必须是整数", path)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s必须是布尔值", path)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+// ErrInvalidTicket 连接凭证不存在、已使用或已过期
+var ErrInvalidTicket = errors.New("无效或已使用的连接凭证")
+
+// WebSocketTicket 建立WebSocket连接的一次性凭证，记录签发时请求的身份
+type WebSocketTicket struct {
+	UserID    uint      `json:"user_id"`
+	SessionID string    `json:"session_id,omitempty"` // API密钥签发的凭证为空
+	OrgID     uint      `json:"org_id"`
+	Scopes    []string  `json:"scopes,omitempty"` // API密钥签发时为密钥的权限范围，登录令牌签发时为nil
+	ExpiresAt time.Time `json:"expires_at"`       // 签发凭证所用令牌的过期时间，连接在此时关闭，零值表示不过期
+}
+
+// WebSocketTicketStore 保存一次性凭证，取出后即删除
+type WebSocketTicketStore interface {
+	Save(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Take(ctx context.Context, key string, dest interface{}) (bool, error)
+}
+
+// WebSocketTicketService 签发和兑换WebSocket连接凭证，避免在URL中传递访问令牌
+type WebSocketTicketService struct {
+	store WebSocketTicketStore
+	ttl   time.Duration
+}
+
+// NewWebSocketTicketService 创建连接凭证服务，凭证在ttl内有效且只能使用一次，ttl为0时默认30秒
+func NewWebSocketTicketService(store WebSocketTicketStore, ttl time.Duration) *WebSocketTicketService {
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	return &WebSocketTicketService{
+		store: store,
+		ttl:   ttl,
+	}
+}
+
+// TTL 返回凭证的有效期
+func (s *WebSocketTicketService) TTL() time.Duration {
+	return s.ttl
+}
+
+// Issue 签发连接凭证
+func (s *WebSocketTicketService) Issue(ctx context.Context, ticket *WebSocketTicket) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	id := base64.RawURLEncoding.EncodeToString(raw)
+
+	if err := s.store.Save(ctx, hashToken(id), ticket, s.ttl); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Redeem 兑换并作废连接凭证，签发所用的令牌已过期时同样无效
+func (s *WebSocketTicketService) Redeem(ctx context.Context, id string) (*WebSocketTicket, error) {
+	if id == "" {
+		return nil, ErrInvalidTicket
+	}
+
+	var ticket WebSocketTicket
+	found, err := s.store.Take(ctx, hashToken(id), &ticket)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrInvalidTicket
+	}
+	if !ticket.ExpiresAt.IsZero() && time.Now().After(ticket.ExpiresAt) {
+		return nil, ErrInvalidTicket
+	}
+
+	return &ticket, nil
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"chat-llama/internal/service"
+
+	"gorm.io/gorm"
+)
+
+// CreateAPIKey 保存API密钥
+func (s *MySQLStorage) CreateAPIKey(key *service.APIKey) error {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return err
+	}
+
+	record := &APIKey{
+		ID:        key.ID,
+		UserID:    key.UserID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		KeyHash:   key.KeyHash,
+		Scopes:    string(scopes),
+		OrgID:     key.OrgID,
+		ExpiresAt: key.ExpiresAt,
+		CreatedAt: key.CreatedAt,
+	}
+
+	return s.db.Create(record).Error
+}
+
+// GetAPIKeyByHash 通过哈希值获取API密钥
+func (s *MySQLStorage) GetAPIKeyByHash(hash string) (*service.APIKey, error) {
+	var key APIKey
+	if err := s.db.Where("key_hash = ?", hash).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("API密钥不存在")
+		}
+		return nil, err
+	}
+
+	return key.ToServiceModel(), nil
+}
+
+// ListUserAPIKeys 获取用户所有未撤销的密钥，按创建时间倒序
+func (s *MySQLStorage) ListUserAPIKeys(userID uint) ([]*service.APIKey, error) {
+	var keys []APIKey
+	if err := s.db.Where("user_id = ? AND revoked_at IS NULL", userID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]*service.APIKey, len(keys))
+	for i, key := range keys {
+		result[i] = key.ToServiceModel()
+	}
+
+	return result, nil
+}
+
+// RevokeAPIKey 撤销用户的密钥
+func (s *MySQLStorage) RevokeAPIKey(userID uint, id string) error {
+	result := s.db.Model(&APIKey{}).Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("API密钥不存在")
+	}
+
+	return nil
+}
+
+// TouchAPIKey 更新密钥的最近使用时间
+func (s *MySQLStorage) TouchAPIKey(id string, at time.Time) error {
+	return s.db.Model(&APIKey{}).Where("id = ?", id).Update("last_used_at", at).Error
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"chat-llama/internal/service"
+
+	"gorm.io/gorm"
+)
+
+// CreateAttachment 保存附件信息
+func (s *MySQLStorage) CreateAttachment(attachment *service.Attachment) error {
+	record := &Attachment{
+		ID:             attachment.ID,
+		UserID:         attachment.UserID,
+		ConversationID: attachment.ConversationID,
+		MessageID:      attachment.MessageID,
+		Filename:       attachment.Filename,
+		ContentType:    attachment.ContentType,
+		Size:           attachment.Size,
+		TextLength:     attachment.TextLength,
+		StorageKey:     attachment.StorageKey,
+		ExtractedText:  attachment.ExtractedText,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+
+	if err := s.db.Create(record).Error; err != nil {
+		return err
+	}
+
+	attachment.CreatedAt = record.CreatedAt
+	return nil
+}
+
+// GetAttachment 获取附件信息
+func (s *MySQLStorage) GetAttachment(id string) (*service.Attachment, error) {
+	var attachment Attachment
+	if err := s.db.Where("id = ?", id).First(&attachment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("附件不存在")
+		}
+		return nil, err
+	}
+
+	return attachment.ToServiceModel(), nil
+}
+
+// LinkAttachments 将尚未发送的附件关联到消息，任一附件已随其他消息发送或不存在时不做修改
+func (s *MySQLStorage) LinkAttachments(ids []string, conversationID, messageID string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// 只更新尚未发送的附件，避免并发发送时同一附件被关联到两条消息
+		result := tx.Model(&Attachment{}).Where("id IN ? AND message_id = ''", ids).Updates(map[string]interface{}{
+			"conversation_id": conversationID,
+			"message_id":      messageID,
+			"updated_at":      time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < int64(len(ids)) {
+			return service.ErrInvalidAttachment
+		}
+		return nil
+	})
+}
+
+// GetAttachmentsByConversationID 获取会话中已发送的附件
+func (s *MySQLStorage) GetAttachmentsByConversationID(conversationID string) ([]*service.Attachment, error) {
+	var attachments []Attachment
+	if err := s.db.Where("conversation_id = ?", conversationID).Order("created_at ASC").Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]*service.Attachment, len(attachments))
+	for i, attachment := range attachments {
+		result[i] = attachment.ToServiceModel()
+	}
+
+	return result, nil
+}
+
+// DeleteAttachmentsByConversationID 删除会话中的所有附件信息
+func (s *MySQLStorage) DeleteAttachmentsByConversationID(conversationID string) error {
+	return s.db.Where("conversation_id = ?", conversationID).Delete(&Attachment{}).Error
+}
+
+// DeleteAttachment 删除附件信息
+func (s *MySQLStorage) DeleteAttachment(id string) error {
+	result := s.db.Where("id = ?", id).Delete(&Attachment{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("附件不存在")
+	}
+
+	return nil
+}
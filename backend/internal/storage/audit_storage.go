@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"encoding/json"
+
+	"chat-llama/internal/service"
+
+	"gorm.io/gorm"
+)
+
+// auditScanBatch 导出审计事件时每批读取的条数
+const auditScanBatch = 500
+
+// SaveAuditEvent 追加审计事件
+func (s *MySQLStorage) SaveAuditEvent(event *service.AuditEvent) error {
+	record := AuditEvent{
+		Action:     event.Action,
+		ActorID:    event.ActorID,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		IP:         event.IP,
+		UserAgent:  event.UserAgent,
+		RequestID:  event.RequestID,
+		CreatedAt:  event.CreatedAt,
+	}
+	if len(event.Detail) > 0 {
+		data, err := json.Marshal(event.Detail)
+		if err != nil {
+			return err
+		}
+		record.Detail = string(data)
+	}
+
+	if err := s.db.Create(&record).Error; err != nil {
+		return err
+	}
+	event.ID = record.ID
+
+	return nil
+}
+
+// ListAuditEvents 按条件分页获取审计事件，按时间倒序
+func (s *MySQLStorage) ListAuditEvents(filter service.AuditFilter, offset, limit int) ([]*service.AuditEvent, int64, error) {
+	query := auditQuery(s.db.Model(&AuditEvent{}), filter)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var records []AuditEvent
+	if err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&records).Error; err != nil {
+		return nil, 0, err
+	}
+
+	result := make([]*service.AuditEvent, len(records))
+	for i, record := range records {
+		result[i] = record.ToServiceModel()
+	}
+
+	return result, total, nil
+}
+
+// ScanAuditEvents 按写入顺序分批读取符合条件的审计事件
+func (s *MySQLStorage) ScanAuditEvents(filter service.AuditFilter, fn func(event *service.AuditEvent) error) error {
+	var records []AuditEvent
+	return auditQuery(s.db.Model(&AuditEvent{}), filter).FindInBatches(&records, auditScanBatch, func(tx *gorm.DB, batch int) error {
+		for _, record := range records {
+			if err := fn(record.ToServiceModel()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// auditQuery 添加审计事件的过滤条件
+func auditQuery(query *gorm.DB, filter service.AuditFilter) *gorm.DB {
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+	return query
+}
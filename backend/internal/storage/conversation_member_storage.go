@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"chat-llama/internal/service"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetSharedConversations 获取组织内共享给用户的会话，按更新时间倒序
+func (s *MySQLStorage) GetSharedConversations(userID, orgID uint) ([]*service.Conversation, error) {
+	var conversations []Conversation
+	if err := s.db.
+		Joins("JOIN conversation_members ON conversation_members.conversation_id = conversations.id").
+		Where("conversation_members.user_id = ? AND conversations.org_id = ?", userID, orgID).
+		Order("conversations.updated_at DESC").
+		Find(&conversations).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]*service.Conversation, len(conversations))
+	for i, conv := range conversations {
+		result[i] = conv.ToServiceModel()
+	}
+
+	return result, nil
+}
+
+// GetConversationMember 获取用户在会话中的成员身份
+func (s *MySQLStorage) GetConversationMember(conversationID string, userID uint) (*service.ConversationMember, error) {
+	var member ConversationMember
+	if err := s.db.Where("conversation_id = ? AND user_id = ?", conversationID, userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("不是会话成员")
+		}
+		return nil, err
+	}
+
+	return member.ToServiceModel(), nil
+}
+
+// ListConversationMembers 获取会话的所有成员及其用户名
+func (s *MySQLStorage) ListConversationMembers(conversationID string) ([]*service.ConversationMember, error) {
+	var rows []struct {
+		ConversationMember
+		Username string
+	}
+	if err := s.db.Model(&ConversationMember{}).
+		Select("conversation_members.*, users.username").
+		Joins("LEFT JOIN users ON users.id = conversation_members.user_id").
+		Where("conversation_members.conversation_id = ?", conversationID).
+		Order("conversation_members.user_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]*service.ConversationMember, len(rows))
+	for i, row := range rows {
+		result[i] = row.ConversationMember.ToServiceModel()
+		result[i].Username = row.Username
+	}
+
+	return result, nil
+}
+
+// SaveConversationMember 添加会话成员，成员已存在时修改角色
+func (s *MySQLStorage) SaveConversationMember(member *service.ConversationMember) error {
+	now := time.Now()
+	record := &ConversationMember{
+		ConversationID: member.ConversationID,
+		UserID:         member.UserID,
+		Role:           member.Role,
+		AddedBy:        member.AddedBy,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	return s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "conversation_id"}, {Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"role":       member.Role,
+			"updated_at": now,
+		}),
+	}).Create(record).Error
+}
+
+// DeleteConversationMember 移除会话成员
+func (s *MySQLStorage) DeleteConversationMember(conversationID string, userID uint) error {
+	result := s.db.Where("conversation_id = ? AND user_id = ?", conversationID, userID).Delete(&ConversationMember{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("不是会话成员")
+	}
+	return nil
+}
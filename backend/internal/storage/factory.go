@@ -1,22 +1,57 @@
-package storage
-
-import (
-	"chat-llama/internal/service"
-	"chat-llama/pkg/db"
-)
-
-// 创建存储接口的工厂方法
-func NewStorage() service.Storage {
-	// 检查数据库连接
-	if db.DB == nil {
-		panic("数据库未初始化")
-	}
-
-	// 初始化数据库表
-	if err := InitTables(db.DB); err != nil {
-		panic("初始化数据库表失败: " + err.Error())
-	}
-
-	// 返回MySQL存储实现
-	return NewMySQLStorage()
-}
+package storage
+
+import (
+	"chat-llama/internal/service"
+	"chat-llama/pkg/db"
+)
+
+// 创建存储接口的工厂方法
+func NewStorage() service.Storage {
+	// 检查数据库连接
+	if db.DB == nil {
+		panic("数据库未初始化")
+	}
+
+	// 初始化数据库表
+	if err := InitTables(db.DB); err != nil {
+		panic("初始化数据库表失败: " + err.Error())
+	}
+
+	// 返回MySQL存储实现
+	return NewMySQLStorage()
+}
+
+// 创建知识库存储，需在NewStorage之后调用
+func NewKnowledgeStorage() service.KnowledgeStorage {
+	return NewMySQLStorage()
+}
+
+// 创建附件存储，需在NewStorage之后调用
+func NewAttachmentStorage() service.AttachmentStorage {
+	return NewMySQLStorage()
+}
+
+// 创建审核队列存储，需在NewStorage之后调用
+func NewModerationStorage() service.ModerationStorage {
+	return NewMySQLStorage()
+}
+
+// 创建API密钥存储，需在NewStorage之后调用
+func NewAPIKeyStorage() service.APIKeyStorage {
+	return NewMySQLStorage()
+}
+
+// 创建登录会话存储，需在NewStorage之后调用
+func NewSessionStorage() service.SessionStorage {
+	return NewMySQLStorage()
+}
+
+// 创建组织存储，需在NewStorage之后调用
+func NewOrganizationStorage() service.OrganizationStorage {
+	return NewMySQLStorage()
+}
+
+// 创建审计事件存储，需在NewStorage之后调用
+func NewAuditStorage() service.AuditStorage {
+	return NewMySQLStorage()
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"chat-llama/internal/service"
+	"chat-llama/pkg/cache"
+
+	"gorm.io/gorm"
+)
+
+// GetUserIDByIdentity 获取外部身份关联的用户，同时更新最近登录时间
+func (s *UserStorage) GetUserIDByIdentity(provider, subject string) (uint, bool, error) {
+	var identity ExternalIdentity
+	if err := s.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	s.db.Model(&identity).Update("last_login_at", time.Now())
+
+	return identity.UserID, true, nil
+}
+
+// FindUserIDByEmail 通过邮箱查找邮箱已验证的本地用户，注册时任意填写的邮箱不会被匹配
+func (s *UserStorage) FindUserIDByEmail(email string) (uint, bool, error) {
+	var user User
+	if err := s.db.Select("id").Where("email = ? AND email_verified_at IS NOT NULL", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	return user.ID, true, nil
+}
+
+// LinkIdentity 将外部身份关联到已有用户
+func (s *UserStorage) LinkIdentity(identity *service.ExternalIdentity) error {
+	return s.db.Create(identityRecord(identity)).Error
+}
+
+// CreateUserWithIdentity 创建无本地密码的用户并关联外部身份，用户名冲突时添加数字后缀
+func (s *UserStorage) CreateUserWithIdentity(username, email string, identity *service.ExternalIdentity) (uint, error) {
+	if runes := []rune(username); len(runes) > 44 {
+		username = string(runes[:44])
+	}
+
+	var user *User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		name := username
+		for i := 2; ; i++ {
+			var count int64
+			if err := tx.Unscoped().Model(&User{}).Where("username = ?", name).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				break
+			}
+			if i > 100 {
+				return errors.New("无法生成唯一的用户名")
+			}
+			name = fmt.Sprintf("%s_%d", username, i)
+		}
+
+		// 密码为空时无法通过密码登录，用户可以通过重置密码设置本地密码。
+		// 调用方只传入身份提供方已验证的邮箱，因此同时标记为已验证
+		now := time.Now()
+		user = &User{
+			Username:  name,
+			Email:     email,
+			Role:      service.RoleUser,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if email != "" {
+			user.EmailVerifiedAt = &now
+		}
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		identity.UserID = user.ID
+		return tx.Create(identityRecord(identity)).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// 清除可能存在的缓存
+	cache.Delete(context.Background(), userByUsernameKey(user.Username))
+
+	return user.ID, nil
+}
+
+// identityRecord 转换为数据库模型
+func identityRecord(identity *service.ExternalIdentity) *ExternalIdentity {
+	return &ExternalIdentity{
+		UserID:      identity.UserID,
+		Provider:    identity.Provider,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		CreatedAt:   identity.CreatedAt,
+		LastLoginAt: identity.LastLoginAt,
+	}
+}
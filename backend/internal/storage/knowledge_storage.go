@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"chat-llama/internal/service"
+
+	"gorm.io/gorm"
+)
+
+// CreateDocument 保存知识库文档及其片段
+func (s *MySQLStorage) CreateDocument(doc *service.KnowledgeDocument, chunks []*service.KnowledgeChunk) error {
+	document := &KnowledgeDocument{
+		ID:         doc.ID,
+		OrgID:      doc.OrgID,
+		Title:      doc.Title,
+		Source:     doc.Source,
+		Format:     doc.Format,
+		Size:       doc.Size,
+		ChunkCount: doc.ChunkCount,
+		CreatedBy:  doc.CreatedBy,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+
+	records := make([]*KnowledgeChunk, len(chunks))
+	for i, chunk := range chunks {
+		records[i] = &KnowledgeChunk{
+			ID:         chunk.ID,
+			OrgID:      doc.OrgID,
+			DocumentID: chunk.DocumentID,
+			Index:      chunk.Index,
+			Content:    chunk.Content,
+			CreatedAt:  time.Now(),
+		}
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(document).Error; err != nil {
+			return err
+		}
+		if len(records) > 0 {
+			return tx.CreateInBatches(records, 100).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	doc.CreatedAt = document.CreatedAt
+	return nil
+}
+
+// GetDocument 获取组织内的知识库文档
+func (s *MySQLStorage) GetDocument(orgID uint, id string) (*service.KnowledgeDocument, error) {
+	var document KnowledgeDocument
+	if err := s.db.Where("id = ? AND org_id = ?", id, orgID).First(&document).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("文档不存在")
+		}
+		return nil, err
+	}
+
+	return document.ToServiceModel(), nil
+}
+
+// ListDocuments 获取组织的所有知识库文档
+func (s *MySQLStorage) ListDocuments(orgID uint) ([]*service.KnowledgeDocument, error) {
+	var documents []KnowledgeDocument
+	if err := s.db.Where("org_id = ?", orgID).Order("created_at DESC").Find(&documents).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]*service.KnowledgeDocument, len(documents))
+	for i, document := range documents {
+		result[i] = document.ToServiceModel()
+	}
+
+	return result, nil
+}
+
+// DeleteDocument 删除组织内的知识库文档及其片段
+func (s *MySQLStorage) DeleteDocument(orgID uint, id string) error {
+	var document KnowledgeDocument
+	if err := s.db.Where("id = ? AND org_id = ?", id, orgID).First(&document).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("文档不存在")
+		}
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ? AND org_id = ?", id, orgID).Delete(&KnowledgeChunk{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&document).Error
+	})
+}
+
+// GetChunksByDocumentID 获取组织内文档的所有片段
+func (s *MySQLStorage) GetChunksByDocumentID(orgID uint, documentID string) ([]*service.KnowledgeChunk, error) {
+	var chunks []KnowledgeChunk
+	if err := s.db.Where("document_id = ? AND org_id = ?", documentID, orgID).Order("chunk_index ASC").Find(&chunks).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]*service.KnowledgeChunk, len(chunks))
+	for i, chunk := range chunks {
+		result[i] = chunk.ToServiceModel()
+	}
+
+	return result, nil
+}
+
+// GetChunks 按ID批量获取组织内的片段，不存在或属于其他组织的ID会被忽略
+func (s *MySQLStorage) GetChunks(orgID uint, ids []string) ([]*service.KnowledgeChunk, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var chunks []KnowledgeChunk
+	if err := s.db.Where("id IN ? AND org_id = ?", ids, orgID).Find(&chunks).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]*service.KnowledgeChunk, len(chunks))
+	for i, chunk := range chunks {
+		result[i] = chunk.ToServiceModel()
+	}
+
+	return result, nil
+}
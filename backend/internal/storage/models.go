@@ -1,78 +1,530 @@
-package storage
-
-import (
-	"chat-llama/internal/service"
-	"time"
-
-	"gorm.io/gorm"
-)
-
-// User 用户模型
-type User struct {
-	ID        uint           `gorm:"primarykey" json:"id"`
-	Username  string         `gorm:"size:50;not null;unique" json:"username"`
-	Password  string         `gorm:"size:255;not null" json:"-"` // 密码不输出到JSON
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-}
-
-// Conversation 会话模型
-type Conversation struct {
-	ID        string         `gorm:"primarykey;type:varchar(36)" json:"id"`
-	UserID    uint           `gorm:"index;not null" json:"user_id"`
-	Title     string         `gorm:"type:text CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;not null" json:"title"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-}
-
-// Message 消息模型
-type Message struct {
-	ID             string         `gorm:"primarykey;type:varchar(36)" json:"id"`
-	ConversationID string         `gorm:"index;type:varchar(36);not null" json:"conversation_id"`
-	Role           string         `gorm:"size:20;not null" json:"role"` // "user" 或 "assistant"
-	Content        string         `gorm:"type:text CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;not null" json:"content"`
-	CreatedAt      time.Time      `json:"created_at"`
-	UpdatedAt      time.Time      `json:"updated_at"`
-	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
-}
-
-// TableName 表名设置
-func (User) TableName() string {
-	return "users"
-}
-
-func (Conversation) TableName() string {
-	return "conversations"
-}
-
-func (Message) TableName() string {
-	return "messages"
-}
-
-// 初始化数据库表
-func InitTables(db *gorm.DB) error {
-	return db.AutoMigrate(&User{}, &Conversation{}, &Message{})
-}
-
-// 数据库模型转换为服务层模型
-func (c *Conversation) ToServiceModel() *service.Conversation {
-	return &service.Conversation{
-		ID:        c.ID,
-		UserID:    c.UserID,
-		Title:     c.Title,
-		CreatedAt: c.CreatedAt,
-		UpdatedAt: c.UpdatedAt,
-	}
-}
-
-func (m *Message) ToServiceModel() *service.Message {
-	return &service.Message{
-		ID:             m.ID,
-		ConversationID: m.ConversationID,
-		Role:           m.Role,
-		Content:        m.Content,
-		CreatedAt:      m.CreatedAt,
-	}
-}
+package storage
+
+import (
+	"chat-llama/internal/service"
+	"encoding/json"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// User 用户模型
+type User struct {
+	ID              uint           `gorm:"primarykey" json:"id"`
+	Username        string         `gorm:"size:50;not null;unique" json:"username"`
+	Password        string         `gorm:"size:255;not null" json:"-"`                  // 密码不输出到JSON
+	Email           string         `gorm:"size:255;index" json:"email"`                 // 用于找回密码，可以为空
+	EmailVerifiedAt *time.Time     `json:"email_verified_at"`                           // 不为空时已确认用户能接收该邮箱的邮件，注册时填写的邮箱未经验证
+	Plan            string         `gorm:"size:20;not null;default:''" json:"plan"`     // 配额套餐，为空时使用默认套餐
+	Role            string         `gorm:"size:20;not null;default:'user'" json:"role"` // user、moderator 或 admin
+	DisabledAt      *time.Time     `json:"disabled_at"`                                 // 不为空时账号已停用
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// PasswordResetToken 重置密码令牌模型，只保存令牌的SHA-256哈希
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	TokenHash string     `gorm:"type:char(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedBy uint       `json:"created_by"` // 管理员发起时为管理员ID，用户自助找回时为0
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Conversation 会话模型
+type Conversation struct {
+	ID        string         `gorm:"primarykey;type:varchar(36)" json:"id"`
+	UserID    uint           `gorm:"index;not null" json:"user_id"`
+	OrgID     uint           `gorm:"index;not null;default:0" json:"org_id"` // 所属组织，0表示个人空间
+	Title     string         `gorm:"type:text CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;not null" json:"title"`
+	Persona   string         `gorm:"size:50" json:"persona"`
+	Tools     string         `gorm:"type:text" json:"tools"` // JSON格式的工具列表，为空时按角色设定
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Message 消息模型
+type Message struct {
+	ID               string         `gorm:"primarykey;type:varchar(36)" json:"id"`
+	ConversationID   string         `gorm:"index;type:varchar(36);not null" json:"conversation_id"`
+	Role             string         `gorm:"size:20;not null" json:"role"` // "user"、"assistant" 或 "tool"
+	Content          string         `gorm:"type:text CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;not null" json:"content"`
+	Model            string         `gorm:"size:100" json:"model"`
+	PromptTokens     int32          `json:"prompt_tokens"`
+	CompletionTokens int32          `json:"completion_tokens"`
+	LatencyMs        int64          `json:"latency_ms"`
+	Citations        string         `gorm:"type:text" json:"citations"`  // JSON格式的知识库引用
+	ToolCalls        string         `gorm:"type:text" json:"tool_calls"` // JSON格式的工具调用
+	ToolCallID       string         `gorm:"type:varchar(36)" json:"tool_call_id"`
+	ToolName         string         `gorm:"size:64" json:"tool_name"`
+	ModerationAction string         `gorm:"size:10" json:"moderation_action"`
+	Moderation       string         `gorm:"type:text" json:"moderation"`        // JSON格式的审核结果
+	ReviewStatus     string         `gorm:"size:20;index" json:"review_status"` // 人工审核状态，未命中审核规则时为空
+	SafetyRules      string         `gorm:"size:255" json:"safety_rules"`       // JSON格式的命中的医疗安全规则
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// UsageStat 按用户、模型和日期汇总的用量
+type UsageStat struct {
+	ID               uint      `gorm:"primarykey" json:"id"`
+	UserID           uint      `gorm:"uniqueIndex:idx_usage_user_org_model_date;not null" json:"user_id"`
+	OrgID            uint      `gorm:"uniqueIndex:idx_usage_user_org_model_date;not null;default:0" json:"org_id"` // 产生用量的组织，0表示个人空间
+	Model            string    `gorm:"uniqueIndex:idx_usage_user_org_model_date;size:100;not null" json:"model"`
+	Date             string    `gorm:"uniqueIndex:idx_usage_user_org_model_date;type:char(10);not null" json:"date"`
+	Requests         int64     `gorm:"not null;default:0" json:"requests"`
+	PromptTokens     int64     `gorm:"not null;default:0" json:"prompt_tokens"`
+	CompletionTokens int64     `gorm:"not null;default:0" json:"completion_tokens"`
+	TotalTokens      int64     `gorm:"not null;default:0" json:"total_tokens"`
+	TotalLatencyMs   int64     `gorm:"not null;default:0" json:"total_latency_ms"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// KnowledgeDocument 知识库文档模型
+type KnowledgeDocument struct {
+	ID         string         `gorm:"primarykey;type:varchar(36)" json:"id"`
+	OrgID      uint           `gorm:"index;not null;default:0" json:"org_id"` // 所属组织，0表示个人空间
+	Title      string         `gorm:"type:varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;not null" json:"title"`
+	Source     string         `gorm:"size:255" json:"source"`
+	Format     string         `gorm:"size:20;not null" json:"format"`
+	Size       int            `json:"size"`
+	ChunkCount int            `json:"chunk_count"`
+	CreatedBy  uint           `gorm:"index" json:"created_by"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// KnowledgeChunk 知识库文档片段模型
+type KnowledgeChunk struct {
+	ID         string    `gorm:"primarykey;type:varchar(36)" json:"id"`
+	OrgID      uint      `gorm:"index;not null;default:0" json:"org_id"`
+	DocumentID string    `gorm:"index;type:varchar(36);not null" json:"document_id"`
+	Index      int       `gorm:"column:chunk_index;not null" json:"index"`
+	Content    string    `gorm:"type:text CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;not null" json:"content"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Attachment 聊天附件模型
+type Attachment struct {
+	ID             string         `gorm:"primarykey;type:varchar(36)" json:"id"`
+	UserID         uint           `gorm:"index;not null" json:"user_id"`
+	ConversationID string         `gorm:"index;type:varchar(36)" json:"conversation_id"`
+	MessageID      string         `gorm:"index;type:varchar(36)" json:"message_id"`
+	Filename       string         `gorm:"type:varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;not null" json:"filename"`
+	ContentType    string         `gorm:"size:100" json:"content_type"`
+	Size           int64          `json:"size"`
+	TextLength     int            `json:"text_length"`
+	StorageKey     string         `gorm:"size:255;not null" json:"storage_key"`
+	ExtractedText  string         `gorm:"type:mediumtext CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci" json:"extracted_text"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Session 登录会话模型
+type Session struct {
+	ID         string     `gorm:"primarykey;type:varchar(36)" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	UserAgent  string     `gorm:"size:255" json:"user_agent"`
+	IP         string     `gorm:"size:45" json:"ip"`
+	OrgID      uint       `gorm:"not null;default:0" json:"org_id"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// ExternalIdentity 外部身份关联模型，同一身份提供方的用户标识只能关联一个本地用户
+type ExternalIdentity struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	UserID      uint      `gorm:"index;not null" json:"user_id"`
+	Provider    string    `gorm:"size:50;not null;uniqueIndex:idx_provider_subject" json:"provider"`
+	Subject     string    `gorm:"size:255;not null;uniqueIndex:idx_provider_subject" json:"subject"`
+	Email       string    `gorm:"size:255" json:"email"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
+
+// APIKey API密钥模型，只保存密钥的SHA-256哈希
+type APIKey struct {
+	ID         string     `gorm:"primarykey;type:varchar(36)" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	Prefix     string     `gorm:"size:16" json:"prefix"`
+	KeyHash    string     `gorm:"type:char(64);uniqueIndex;not null" json:"-"`
+	Scopes     string     `gorm:"type:text" json:"scopes"` // JSON格式的权限范围列表
+	OrgID      uint       `gorm:"not null;default:0" json:"org_id"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// RefreshToken 刷新令牌模型，只保存令牌的SHA-256哈希
+type RefreshToken struct {
+	ID        string     `gorm:"primarykey;type:varchar(36)" json:"id"`
+	SessionID string     `gorm:"index;type:varchar(36);not null" json:"session_id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	TokenHash string     `gorm:"type:char(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Organization 组织模型
+type Organization struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Name      string    `gorm:"type:varchar(100) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;not null" json:"name"`
+	Settings  string    `gorm:"type:text" json:"settings"` // JSON格式的组织设置
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OrgMembership 组织成员模型，同一用户在一个组织中只有一个角色
+type OrgMembership struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	OrgID     uint      `gorm:"not null;uniqueIndex:idx_org_user" json:"org_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_org_user;index" json:"user_id"`
+	Role      string    `gorm:"size:20;not null" json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ConversationMember 会话成员模型，同一用户在一个会话中只有一个角色
+type ConversationMember struct {
+	ID             uint      `gorm:"primarykey" json:"id"`
+	ConversationID string    `gorm:"type:varchar(36);not null;uniqueIndex:idx_conversation_user" json:"conversation_id"`
+	UserID         uint      `gorm:"not null;uniqueIndex:idx_conversation_user;index" json:"user_id"`
+	Role           string    `gorm:"size:20;not null" json:"role"`
+	AddedBy        uint      `gorm:"not null" json:"added_by"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// AuditEvent 审计事件模型，只追加不修改
+type AuditEvent struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	Action     string    `gorm:"size:50;not null;index" json:"action"`
+	ActorID    uint      `gorm:"not null;index" json:"actor_id"`
+	TargetType string    `gorm:"size:30;not null;default:'';index:idx_audit_target" json:"target_type"`
+	TargetID   string    `gorm:"size:64;not null;default:'';index:idx_audit_target" json:"target_id"`
+	IP         string    `gorm:"size:45" json:"ip"`
+	UserAgent  string    `gorm:"size:255" json:"user_agent"`
+	RequestID  string    `gorm:"size:64;index" json:"request_id"`
+	Detail     string    `gorm:"type:text" json:"detail"` // JSON格式的事件详情
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName 表名设置
+func (User) TableName() string {
+	return "users"
+}
+
+func (Conversation) TableName() string {
+	return "conversations"
+}
+
+func (Message) TableName() string {
+	return "messages"
+}
+
+func (UsageStat) TableName() string {
+	return "usage_stats"
+}
+
+func (KnowledgeDocument) TableName() string {
+	return "knowledge_documents"
+}
+
+func (KnowledgeChunk) TableName() string {
+	return "knowledge_chunks"
+}
+
+func (Attachment) TableName() string {
+	return "attachments"
+}
+
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
+
+func (Session) TableName() string {
+	return "sessions"
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+func (ExternalIdentity) TableName() string {
+	return "external_identities"
+}
+
+func (Organization) TableName() string {
+	return "organizations"
+}
+
+func (OrgMembership) TableName() string {
+	return "org_memberships"
+}
+
+func (ConversationMember) TableName() string {
+	return "conversation_members"
+}
+
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
+
+// 初始化数据库表
+func InitTables(db *gorm.DB) error {
+	// 用量统计的唯一索引加入了组织，旧索引不删除时不同组织的用量会累加到同一行
+	if db.Migrator().HasTable(&UsageStat{}) && db.Migrator().HasIndex(&UsageStat{}, "idx_usage_user_model_date") {
+		if err := db.Migrator().DropIndex(&UsageStat{}, "idx_usage_user_model_date"); err != nil {
+			return err
+		}
+	}
+
+	return db.AutoMigrate(&User{}, &Conversation{}, &Message{}, &UsageStat{}, &KnowledgeDocument{}, &KnowledgeChunk{}, &Attachment{}, &Session{}, &RefreshToken{}, &PasswordResetToken{}, &APIKey{}, &ExternalIdentity{}, &Organization{}, &OrgMembership{}, &ConversationMember{}, &AuditEvent{})
+}
+
+// 数据库模型转换为服务层模型
+func (c *Conversation) ToServiceModel() *service.Conversation {
+	conv := &service.Conversation{
+		ID:        c.ID,
+		UserID:    c.UserID,
+		OrgID:     c.OrgID,
+		Title:     c.Title,
+		Persona:   c.Persona,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+	}
+
+	if c.Tools != "" {
+		if err := json.Unmarshal([]byte(c.Tools), &conv.Tools); err != nil {
+			log.Printf("解析会话工具失败: %v", err)
+		}
+	}
+
+	return conv
+}
+
+func (m *Message) ToServiceModel() *service.Message {
+	msg := &service.Message{
+		ID:             m.ID,
+		ConversationID: m.ConversationID,
+		Role:           m.Role,
+		Content:        m.Content,
+		Model:          m.Model,
+		ToolCallID:     m.ToolCallID,
+		ToolName:       m.ToolName,
+		CreatedAt:      m.CreatedAt,
+	}
+
+	if m.Citations != "" {
+		if err := json.Unmarshal([]byte(m.Citations), &msg.Citations); err != nil {
+			log.Printf("解析消息引用失败: %v", err)
+		}
+	}
+
+	if m.ToolCalls != "" {
+		if err := json.Unmarshal([]byte(m.ToolCalls), &msg.ToolCalls); err != nil {
+			log.Printf("解析工具调用失败: %v", err)
+		}
+	}
+
+	if m.SafetyRules != "" {
+		if err := json.Unmarshal([]byte(m.SafetyRules), &msg.SafetyRules); err != nil {
+			log.Printf("解析安全规则失败: %v", err)
+		}
+	}
+
+	if m.Moderation != "" {
+		if err := json.Unmarshal([]byte(m.Moderation), &msg.Moderation); err != nil {
+			log.Printf("解析审核结果失败: %v", err)
+		}
+	}
+
+	if m.PromptTokens > 0 || m.CompletionTokens > 0 {
+		msg.Usage = &service.TokenUsage{
+			PromptTokens:     m.PromptTokens,
+			CompletionTokens: m.CompletionTokens,
+			TotalTokens:      m.PromptTokens + m.CompletionTokens,
+			LatencyMs:        m.LatencyMs,
+		}
+	}
+
+	return msg
+}
+
+func (u *UsageStat) ToServiceModel() *service.UsageStat {
+	return &service.UsageStat{
+		UserID:           u.UserID,
+		OrgID:            u.OrgID,
+		Model:            u.Model,
+		Date:             u.Date,
+		Requests:         u.Requests,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+		TotalLatencyMs:   u.TotalLatencyMs,
+	}
+}
+
+func (d *KnowledgeDocument) ToServiceModel() *service.KnowledgeDocument {
+	return &service.KnowledgeDocument{
+		ID:         d.ID,
+		OrgID:      d.OrgID,
+		Title:      d.Title,
+		Source:     d.Source,
+		Format:     d.Format,
+		Size:       d.Size,
+		ChunkCount: d.ChunkCount,
+		CreatedBy:  d.CreatedBy,
+		CreatedAt:  d.CreatedAt,
+	}
+}
+
+func (c *KnowledgeChunk) ToServiceModel() *service.KnowledgeChunk {
+	return &service.KnowledgeChunk{
+		ID:         c.ID,
+		OrgID:      c.OrgID,
+		DocumentID: c.DocumentID,
+		Index:      c.Index,
+		Content:    c.Content,
+	}
+}
+
+func (a *Attachment) ToServiceModel() *service.Attachment {
+	return &service.Attachment{
+		ID:             a.ID,
+		UserID:         a.UserID,
+		ConversationID: a.ConversationID,
+		MessageID:      a.MessageID,
+		Filename:       a.Filename,
+		ContentType:    a.ContentType,
+		Size:           a.Size,
+		TextLength:     a.TextLength,
+		StorageKey:     a.StorageKey,
+		ExtractedText:  a.ExtractedText,
+		CreatedAt:      a.CreatedAt,
+	}
+}
+
+func (s *Session) ToServiceModel() *service.Session {
+	return &service.Session{
+		ID:         s.ID,
+		UserID:     s.UserID,
+		UserAgent:  s.UserAgent,
+		IP:         s.IP,
+		OrgID:      s.OrgID,
+		LastSeenAt: s.LastSeenAt,
+		CreatedAt:  s.CreatedAt,
+		RevokedAt:  s.RevokedAt,
+	}
+}
+
+func (t *RefreshToken) ToServiceModel() *service.RefreshToken {
+	return &service.RefreshToken{
+		ID:        t.ID,
+		SessionID: t.SessionID,
+		UserID:    t.UserID,
+		TokenHash: t.TokenHash,
+		ExpiresAt: t.ExpiresAt,
+		UsedAt:    t.UsedAt,
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+func (k *APIKey) ToServiceModel() *service.APIKey {
+	key := &service.APIKey{
+		ID:         k.ID,
+		UserID:     k.UserID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		KeyHash:    k.KeyHash,
+		OrgID:      k.OrgID,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+		CreatedAt:  k.CreatedAt,
+	}
+
+	if k.Scopes != "" {
+		if err := json.Unmarshal([]byte(k.Scopes), &key.Scopes); err != nil {
+			log.Printf("解析API密钥权限范围失败: %v", err)
+		}
+	}
+
+	return key
+}
+
+func (o *Organization) ToServiceModel() *service.Organization {
+	org := &service.Organization{
+		ID:        o.ID,
+		Name:      o.Name,
+		CreatedAt: o.CreatedAt,
+		UpdatedAt: o.UpdatedAt,
+	}
+
+	if o.Settings != "" {
+		if err := json.Unmarshal([]byte(o.Settings), &org.Settings); err != nil {
+			log.Printf("解析组织设置失败: %v", err)
+		}
+	}
+
+	return org
+}
+
+func (m *OrgMembership) ToServiceModel() *service.OrgMembership {
+	return &service.OrgMembership{
+		OrgID:     m.OrgID,
+		UserID:    m.UserID,
+		Role:      m.Role,
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+func (m *ConversationMember) ToServiceModel() *service.ConversationMember {
+	return &service.ConversationMember{
+		ConversationID: m.ConversationID,
+		UserID:         m.UserID,
+		Role:           m.Role,
+		AddedBy:        m.AddedBy,
+		CreatedAt:      m.CreatedAt,
+	}
+}
+
+func (e *AuditEvent) ToServiceModel() *service.AuditEvent {
+	event := &service.AuditEvent{
+		ID:         e.ID,
+		Action:     e.Action,
+		ActorID:    e.ActorID,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		IP:         e.IP,
+		UserAgent:  e.UserAgent,
+		RequestID:  e.RequestID,
+		CreatedAt:  e.CreatedAt,
+	}
+
+	if e.Detail != "" {
+		if err := json.Unmarshal([]byte(e.Detail), &event.Detail); err != nil {
+			log.Printf("解析审计事件详情失败: %v", err)
+		}
+	}
+
+	return event
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"chat-llama/internal/service"
+	"chat-llama/pkg/cache"
+
+	"gorm.io/gorm"
+)
+
+// ListModeratedMessages 分页获取命中审核规则的消息，按时间倒序
+func (s *MySQLStorage) ListModeratedMessages(status string, offset, limit int) ([]*service.Message, int64, error) {
+	query := s.db.Model(&Message{})
+	if status != "" {
+		query = query.Where("review_status = ?", status)
+	} else {
+		query = query.Where("review_status <> ''")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var messages []Message
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&messages).Error; err != nil {
+		return nil, 0, err
+	}
+
+	result := make([]*service.Message, len(messages))
+	for i, msg := range messages {
+		result[i] = msg.ToServiceModel()
+	}
+
+	return result, total, nil
+}
+
+// UpdateMessageReview 更新消息的人工审核结果
+func (s *MySQLStorage) UpdateMessageReview(messageID string, review *service.ModerationReview) error {
+	var message Message
+	if err := s.db.Where("id = ?", messageID).First(&message).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("消息不存在")
+		}
+		return err
+	}
+	if message.Moderation == "" {
+		return errors.New("消息未命中审核规则")
+	}
+
+	var moderation service.ModerationResult
+	if err := json.Unmarshal([]byte(message.Moderation), &moderation); err != nil {
+		return err
+	}
+	moderation.Review = review
+
+	data, err := json.Marshal(&moderation)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Model(&message).Updates(map[string]interface{}{
+		"moderation":    string(data),
+		"review_status": review.Status,
+	}).Error; err != nil {
+		return err
+	}
+
+	// 清除缓存
+	cache.Delete(context.Background(), conversationMessagesKey(message.ConversationID))
+
+	return nil
+}
@@ -1,283 +1,441 @@
-package storage
-
-import (
-	"context"
-	"errors"
-	"fmt"
-	"time"
-
-	"chat-llama/internal/service"
-	"chat-llama/pkg/cache"
-	"chat-llama/pkg/db"
-
-	"github.com/google/uuid"
-	"gorm.io/gorm"
-)
-
-// MySQLStorage 提供MySQL数据库实现
-type MySQLStorage struct {
-	db *gorm.DB
-}
-
-// NewMySQLStorage 创建新的MySQL存储
-func NewMySQLStorage() *MySQLStorage {
-	return &MySQLStorage{
-		db: db.DB,
-	}
-}
-
-// 缓存键生成函数
-func conversationKey(id string) string {
-	return fmt.Sprintf("conversation:%s", id)
-}
-
-func userConversationsKey(userID uint) string {
-	return fmt.Sprintf("user:%d:conversations", userID)
-}
-
-func conversationMessagesKey(conversationID string) string {
-	return fmt.Sprintf("conversation:%s:messages", conversationID)
-}
-
-// CreateConversation 创建新会话
-func (s *MySQLStorage) CreateConversation(userID uint, title string) (*service.Conversation, error) {
-	ctx := context.Background()
-
-	// 生成UUID
-	id := uuid.New().String()
-
-	// 创建会话记录
-	conversation := &Conversation{
-		ID:        id,
-		UserID:    userID,
-		Title:     title,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-
-	// 保存到数据库
-	if err := s.db.Create(conversation).Error; err != nil {
-		return nil, err
-	}
-
-	// 缓存会话
-	serviceConv := conversation.ToServiceModel()
-	cache.Set(ctx, conversationKey(id), serviceConv, time.Hour)
-
-	// 清除用户会话列表缓存
-	cache.Delete(ctx, userConversationsKey(userID))
-
-	return serviceConv, nil
-}
-
-// GetConversation 获取会话
-func (s *MySQLStorage) GetConversation(id string) (*service.Conversation, error) {
-	ctx := context.Background()
-
-	// 尝试从缓存获取
-	var serviceConv service.Conversation
-	found, err := cache.Get(ctx, conversationKey(id), &serviceConv)
-	if err != nil {
-		return nil, err
-	}
-
-	if found {
-		return &serviceConv, nil
-	}
-
-	// 缓存未命中，从数据库获取
-	var conversation Conversation
-	if err := s.db.Where("id = ?", id).First(&conversation).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("会话不存在")
-		}
-		return nil, err
-	}
-
-	// 更新缓存
-	serviceConv = *conversation.ToServiceModel()
-	cache.Set(ctx, conversationKey(id), serviceConv, time.Hour)
-
-	return &serviceConv, nil
-}
-
-// GetConversationsByUserID 获取用户的所有会话
-func (s *MySQLStorage) GetConversationsByUserID(userID uint) ([]*service.Conversation, error) {
-	ctx := context.Background()
-
-	// 尝试从缓存获取
-	var serviceConvs []*service.Conversation
-	found, err := cache.Get(ctx, userConversationsKey(userID), &serviceConvs)
-	if err != nil {
-		return nil, err
-	}
-
-	if found {
-		return serviceConvs, nil
-	}
-
-	// 缓存未命中，从数据库获取
-	var conversations []Conversation
-	if err := s.db.Where("user_id = ?", userID).Order("updated_at DESC").Find(&conversations).Error; err != nil {
-		return nil, err
-	}
-
-	// 转换为服务层模型
-	serviceConvs = make([]*service.Conversation, len(conversations))
-	for i, conv := range conversations {
-		serviceConvs[i] = conv.ToServiceModel()
-	}
-
-	// 更新缓存
-	cache.Set(ctx, userConversationsKey(userID), serviceConvs, time.Hour)
-
-	return serviceConvs, nil
-}
-
-// UpdateConversationTitle 更新会话标题
-func (s *MySQLStorage) UpdateConversationTitle(id string, title string) error {
-	ctx := context.Background()
-
-	// 获取会话以检查存在性
-	var conversation Conversation
-	if err := s.db.Where("id = ?", id).First(&conversation).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("会话不存在")
-		}
-		return err
-	}
-
-	// 更新会话
-	conversation.Title = title
-	conversation.UpdatedAt = time.Now()
-
-	if err := s.db.Save(&conversation).Error; err != nil {
-		return err
-	}
-
-	// 清除缓存
-	cache.Delete(ctx, conversationKey(id))
-	cache.Delete(ctx, userConversationsKey(conversation.UserID))
-
-	return nil
-}
-
-// DeleteConversation 删除会话
-func (s *MySQLStorage) DeleteConversation(id string) error {
-	ctx := context.Background()
-
-	// 先查询会话以获取用户ID
-	var conversation Conversation
-	if err := s.db.Where("id = ?", id).First(&conversation).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("会话不存在")
-		}
-		return err
-	}
-
-	// 开始事务
-	tx := s.db.Begin()
-
-	// 删除会话中的所有消息
-	if err := tx.Where("conversation_id = ?", id).Delete(&Message{}).Error; err != nil {
-		tx.Rollback()
-		return err
-	}
-
-	// 删除会话
-	if err := tx.Delete(&conversation).Error; err != nil {
-		tx.Rollback()
-		return err
-	}
-
-	// 提交事务
-	if err := tx.Commit().Error; err != nil {
-		return err
-	}
-
-	// 清除缓存
-	cache.Delete(ctx, conversationKey(id))
-	cache.Delete(ctx, userConversationsKey(conversation.UserID))
-	cache.Delete(ctx, conversationMessagesKey(id))
-
-	return nil
-}
-
-// AddMessage 添加消息
-func (s *MySQLStorage) AddMessage(conversationID, role, content string) (*service.Message, error) {
-	ctx := context.Background()
-
-	// 验证会话是否存在
-	var conversation Conversation
-	if err := s.db.Where("id = ?", conversationID).First(&conversation).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("会话不存在")
-		}
-		return nil, err
-	}
-
-	// 生成UUID
-	id := uuid.New().String()
-
-	// 创建消息
-	message := &Message{
-		ID:             id,
-		ConversationID: conversationID,
-		Role:           role,
-		Content:        content,
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
-	}
-
-	// 保存消息
-	if err := s.db.Create(message).Error; err != nil {
-		return nil, err
-	}
-
-	// 更新会话的最后更新时间
-	conversation.UpdatedAt = time.Now()
-	if err := s.db.Save(&conversation).Error; err != nil {
-		return nil, err
-	}
-
-	// 清除缓存
-	cache.Delete(ctx, conversationMessagesKey(conversationID))
-	cache.Delete(ctx, conversationKey(conversationID))
-	cache.Delete(ctx, userConversationsKey(conversation.UserID))
-
-	// 返回服务层消息模型
-	return message.ToServiceModel(), nil
-}
-
-// GetMessagesByConversationID 获取会话的所有消息
-func (s *MySQLStorage) GetMessagesByConversationID(conversationID string) ([]*service.Message, error) {
-	ctx := context.Background()
-
-	// 尝试从缓存获取
-	var serviceMessages []*service.Message
-	found, err := cache.Get(ctx, conversationMessagesKey(conversationID), &serviceMessages)
-	if err != nil {
-		return nil, err
-	}
-
-	if found {
-		return serviceMessages, nil
-	}
-
-	// 缓存未命中，从数据库获取
-	var messages []Message
-	if err := s.db.Where("conversation_id = ?", conversationID).Order("created_at ASC").Find(&messages).Error; err != nil {
-		return nil, err
-	}
-
-	// 转换为服务层模型
-	serviceMessages = make([]*service.Message, len(messages))
-	for i, msg := range messages {
-		serviceMessages[i] = msg.ToServiceModel()
-	}
-
-	// 更新缓存
-	cache.Set(ctx, conversationMessagesKey(conversationID), serviceMessages, time.Hour)
-
-	return serviceMessages, nil
-}
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"chat-llama/internal/service"
+	"chat-llama/pkg/cache"
+	"chat-llama/pkg/db"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MySQLStorage 提供MySQL数据库实现
+type MySQLStorage struct {
+	db *gorm.DB
+}
+
+// NewMySQLStorage 创建新的MySQL存储
+func NewMySQLStorage() *MySQLStorage {
+	return &MySQLStorage{
+		db: db.DB,
+	}
+}
+
+// 缓存键生成函数
+func conversationKey(id string) string {
+	return fmt.Sprintf("conversation:%s", id)
+}
+
+func userConversationsKey(userID, orgID uint) string {
+	return fmt.Sprintf("user:%d:org:%d:conversations", userID, orgID)
+}
+
+func conversationMessagesKey(conversationID string) string {
+	return fmt.Sprintf("conversation:%s:messages", conversationID)
+}
+
+// CreateConversation 在组织内创建新会话
+func (s *MySQLStorage) CreateConversation(userID, orgID uint, title string) (*service.Conversation, error) {
+	ctx := context.Background()
+
+	// 生成UUID
+	id := uuid.New().String()
+
+	// 创建会话记录
+	conversation := &Conversation{
+		ID:        id,
+		UserID:    userID,
+		OrgID:     orgID,
+		Title:     title,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	// 保存到数据库
+	if err := s.db.Create(conversation).Error; err != nil {
+		return nil, err
+	}
+
+	// 缓存会话
+	serviceConv := conversation.ToServiceModel()
+	cache.Set(ctx, conversationKey(id), serviceConv, time.Hour)
+
+	// 清除用户会话列表缓存
+	cache.Delete(ctx, userConversationsKey(userID, orgID))
+
+	return serviceConv, nil
+}
+
+// GetConversation 获取组织内的会话，会话属于其他组织时视为不存在
+func (s *MySQLStorage) GetConversation(orgID uint, id string) (*service.Conversation, error) {
+	conv, err := s.GetConversationUnscoped(id)
+	if err != nil {
+		return nil, err
+	}
+	if conv.OrgID != orgID {
+		return nil, errors.New("会话不存在")
+	}
+
+	return conv, nil
+}
+
+// GetConversationUnscoped 获取会话，不按组织过滤
+func (s *MySQLStorage) GetConversationUnscoped(id string) (*service.Conversation, error) {
+	ctx := context.Background()
+
+	// 尝试从缓存获取
+	var serviceConv service.Conversation
+	found, err := cache.Get(ctx, conversationKey(id), &serviceConv)
+	if err != nil {
+		return nil, err
+	}
+
+	if found {
+		return &serviceConv, nil
+	}
+
+	// 缓存未命中，从数据库获取
+	var conversation Conversation
+	if err := s.db.Where("id = ?", id).First(&conversation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("会话不存在")
+		}
+		return nil, err
+	}
+
+	// 更新缓存
+	serviceConv = *conversation.ToServiceModel()
+	cache.Set(ctx, conversationKey(id), serviceConv, time.Hour)
+
+	return &serviceConv, nil
+}
+
+// GetConversationsByUserID 获取用户在组织内的所有会话
+func (s *MySQLStorage) GetConversationsByUserID(userID, orgID uint) ([]*service.Conversation, error) {
+	ctx := context.Background()
+
+	// 尝试从缓存获取
+	var serviceConvs []*service.Conversation
+	found, err := cache.Get(ctx, userConversationsKey(userID, orgID), &serviceConvs)
+	if err != nil {
+		return nil, err
+	}
+
+	if found {
+		return serviceConvs, nil
+	}
+
+	// 缓存未命中，从数据库获取
+	var conversations []Conversation
+	if err := s.db.Where("user_id = ? AND org_id = ?", userID, orgID).Order("updated_at DESC").Find(&conversations).Error; err != nil {
+		return nil, err
+	}
+
+	// 转换为服务层模型
+	serviceConvs = make([]*service.Conversation, len(conversations))
+	for i, conv := range conversations {
+		serviceConvs[i] = conv.ToServiceModel()
+	}
+
+	// 更新缓存
+	cache.Set(ctx, userConversationsKey(userID, orgID), serviceConvs, time.Hour)
+
+	return serviceConvs, nil
+}
+
+// UpdateConversationTitle 更新会话标题
+func (s *MySQLStorage) UpdateConversationTitle(orgID uint, id string, title string) error {
+	ctx := context.Background()
+
+	// 获取会话以检查存在性
+	conversation, err := s.scopedConversation(orgID, id)
+	if err != nil {
+		return err
+	}
+
+	// 更新会话
+	conversation.Title = title
+	conversation.UpdatedAt = time.Now()
+
+	if err := s.db.Save(conversation).Error; err != nil {
+		return err
+	}
+
+	// 清除缓存
+	cache.Delete(ctx, conversationKey(id))
+	cache.Delete(ctx, userConversationsKey(conversation.UserID, conversation.OrgID))
+
+	return nil
+}
+
+// UpdateConversationTools 更新会话的角色及启用的工具
+func (s *MySQLStorage) UpdateConversationTools(orgID uint, id string, persona string, tools []string) error {
+	ctx := context.Background()
+
+	// 获取会话以检查存在性
+	conversation, err := s.scopedConversation(orgID, id)
+	if err != nil {
+		return err
+	}
+
+	// 工具列表为nil时清空，表示按角色设定
+	conversation.Persona = persona
+	conversation.Tools = ""
+	if tools != nil {
+		data, err := json.Marshal(tools)
+		if err != nil {
+			return err
+		}
+		conversation.Tools = string(data)
+	}
+	conversation.UpdatedAt = time.Now()
+
+	if err := s.db.Save(conversation).Error; err != nil {
+		return err
+	}
+
+	// 清除缓存
+	cache.Delete(ctx, conversationKey(id))
+	cache.Delete(ctx, userConversationsKey(conversation.UserID, conversation.OrgID))
+
+	return nil
+}
+
+// DeleteConversation 删除会话
+func (s *MySQLStorage) DeleteConversation(orgID uint, id string) error {
+	ctx := context.Background()
+
+	// 先查询会话以获取用户ID
+	conversation, err := s.scopedConversation(orgID, id)
+	if err != nil {
+		return err
+	}
+
+	// 开始事务
+	tx := s.db.Begin()
+
+	// 删除会话中的所有消息
+	if err := tx.Where("conversation_id = ?", id).Delete(&Message{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// 删除会话成员
+	if err := tx.Where("conversation_id = ?", id).Delete(&ConversationMember{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// 删除会话
+	if err := tx.Delete(conversation).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	// 清除缓存
+	cache.Delete(ctx, conversationKey(id))
+	cache.Delete(ctx, userConversationsKey(conversation.UserID, conversation.OrgID))
+	cache.Delete(ctx, conversationMessagesKey(id))
+
+	return nil
+}
+
+// scopedConversation 从数据库获取属于orgID的会话
+func (s *MySQLStorage) scopedConversation(orgID uint, id string) (*Conversation, error) {
+	var conversation Conversation
+	if err := s.db.Where("id = ? AND org_id = ?", id, orgID).First(&conversation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("会话不存在")
+		}
+		return nil, err
+	}
+
+	return &conversation, nil
+}
+
+// AddMessage 添加消息
+func (s *MySQLStorage) AddMessage(conversationID, role, content string) (*service.Message, error) {
+	return s.SaveMessage(&service.Message{
+		ConversationID: conversationID,
+		Role:           role,
+		Content:        content,
+	})
+}
+
+// SaveMessage 保存完整的消息
+func (s *MySQLStorage) SaveMessage(msg *service.Message) (*service.Message, error) {
+	ctx := context.Background()
+
+	// 验证会话是否存在
+	var conversation Conversation
+	if err := s.db.Where("id = ?", msg.ConversationID).First(&conversation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("会话不存在")
+		}
+		return nil, err
+	}
+
+	// 生成UUID
+	id := msg.ID
+	if id == "" {
+		id = uuid.New().String()
+	}
+
+	// 创建消息
+	message := &Message{
+		ID:             id,
+		ConversationID: msg.ConversationID,
+		Role:           msg.Role,
+		Content:        msg.Content,
+		Model:          msg.Model,
+		ToolCallID:     msg.ToolCallID,
+		ToolName:       msg.ToolName,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	if msg.Usage != nil {
+		message.PromptTokens = msg.Usage.PromptTokens
+		message.CompletionTokens = msg.Usage.CompletionTokens
+		message.LatencyMs = msg.Usage.LatencyMs
+	}
+	if len(msg.Citations) > 0 {
+		citations, err := json.Marshal(msg.Citations)
+		if err != nil {
+			return nil, err
+		}
+		message.Citations = string(citations)
+	}
+	if len(msg.ToolCalls) > 0 {
+		toolCalls, err := json.Marshal(msg.ToolCalls)
+		if err != nil {
+			return nil, err
+		}
+		message.ToolCalls = string(toolCalls)
+	}
+	if len(msg.SafetyRules) > 0 {
+		safetyRules, err := json.Marshal(msg.SafetyRules)
+		if err != nil {
+			return nil, err
+		}
+		message.SafetyRules = string(safetyRules)
+	}
+	if msg.Moderation != nil {
+		moderation, err := json.Marshal(msg.Moderation)
+		if err != nil {
+			return nil, err
+		}
+		message.Moderation = string(moderation)
+		message.ModerationAction = msg.Moderation.Action
+		if msg.Moderation.Review != nil {
+			message.ReviewStatus = msg.Moderation.Review.Status
+		}
+	}
+
+	// 保存消息
+	if err := s.db.Create(message).Error; err != nil {
+		return nil, err
+	}
+
+	// 更新会话的最后更新时间
+	conversation.UpdatedAt = time.Now()
+	if err := s.db.Save(&conversation).Error; err != nil {
+		return nil, err
+	}
+
+	// 清除缓存
+	cache.Delete(ctx, conversationMessagesKey(msg.ConversationID))
+	cache.Delete(ctx, conversationKey(msg.ConversationID))
+	cache.Delete(ctx, userConversationsKey(conversation.UserID, conversation.OrgID))
+
+	// 返回服务层消息模型
+	return message.ToServiceModel(), nil
+}
+
+// GetMessagesByConversationID 获取会话的所有消息
+func (s *MySQLStorage) GetMessagesByConversationID(conversationID string) ([]*service.Message, error) {
+	ctx := context.Background()
+
+	// 尝试从缓存获取
+	var serviceMessages []*service.Message
+	found, err := cache.Get(ctx, conversationMessagesKey(conversationID), &serviceMessages)
+	if err != nil {
+		return nil, err
+	}
+
+	if found {
+		return serviceMessages, nil
+	}
+
+	// 缓存未命中，从数据库获取
+	var messages []Message
+	if err := s.db.Where("conversation_id = ?", conversationID).Order("created_at ASC").Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	// 转换为服务层模型
+	serviceMessages = make([]*service.Message, len(messages))
+	for i, msg := range messages {
+		serviceMessages[i] = msg.ToServiceModel()
+	}
+
+	// 更新缓存
+	cache.Set(ctx, conversationMessagesKey(conversationID), serviceMessages, time.Hour)
+
+	return serviceMessages, nil
+}
+
+// RecordUsage 累加用户当天在组织内指定模型上的用量
+func (s *MySQLStorage) RecordUsage(userID, orgID uint, model string, usage *service.TokenUsage) error {
+	stat := &UsageStat{
+		UserID:           userID,
+		OrgID:            orgID,
+		Model:            model,
+		Date:             time.Now().Format("2006-01-02"),
+		Requests:         1,
+		PromptTokens:     int64(usage.PromptTokens),
+		CompletionTokens: int64(usage.CompletionTokens),
+		TotalTokens:      int64(usage.TotalTokens),
+		TotalLatencyMs:   usage.LatencyMs,
+	}
+
+	// 同一用户、组织、模型和日期的记录已存在时累加
+	return s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "org_id"}, {Name: "model"}, {Name: "date"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"requests":          gorm.Expr("requests + ?", stat.Requests),
+			"prompt_tokens":     gorm.Expr("prompt_tokens + ?", stat.PromptTokens),
+			"completion_tokens": gorm.Expr("completion_tokens + ?", stat.CompletionTokens),
+			"total_tokens":      gorm.Expr("total_tokens + ?", stat.TotalTokens),
+			"total_latency_ms":  gorm.Expr("total_latency_ms + ?", stat.TotalLatencyMs),
+			"updated_at":        time.Now(),
+		}),
+	}).Create(stat).Error
+}
+
+// GetUsageStats 获取用户自指定时间以来在组织内的每日用量
+func (s *MySQLStorage) GetUsageStats(userID, orgID uint, since time.Time) ([]*service.UsageStat, error) {
+	var stats []UsageStat
+	if err := s.db.Where("user_id = ? AND org_id = ? AND date >= ?", userID, orgID, since.Format("2006-01-02")).
+		Order("date ASC, model ASC").Find(&stats).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]*service.UsageStat, len(stats))
+	for i, stat := range stats {
+		result[i] = stat.ToServiceModel()
+	}
+
+	return result, nil
+}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"chat-llama/internal/service"
+	"chat-llama/pkg/cache"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 组织缓存键，每次对话都会读取组织设置
+func organizationKey(id uint) string {
+	return fmt.Sprintf("organization:%d", id)
+}
+
+// CreateOrganization 创建组织并将ownerID设为所有者
+func (s *MySQLStorage) CreateOrganization(org *service.Organization, ownerID uint) error {
+	settings, err := json.Marshal(org.Settings)
+	if err != nil {
+		return err
+	}
+
+	record := &Organization{
+		Name:      org.Name,
+		Settings:  string(settings),
+		CreatedAt: org.CreatedAt,
+		UpdatedAt: org.UpdatedAt,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrgMembership{
+			OrgID:     record.ID,
+			UserID:    ownerID,
+			Role:      service.OrgRoleOwner,
+			CreatedAt: org.CreatedAt,
+			UpdatedAt: org.CreatedAt,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	org.ID = record.ID
+	return nil
+}
+
+// GetOrganization 获取组织
+func (s *MySQLStorage) GetOrganization(id uint) (*service.Organization, error) {
+	ctx := context.Background()
+
+	var org service.Organization
+	found, err := cache.Get(ctx, organizationKey(id), &org)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return &org, nil
+	}
+
+	var record Organization
+	if err := s.db.First(&record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("组织不存在")
+		}
+		return nil, err
+	}
+
+	org = *record.ToServiceModel()
+	cache.Set(ctx, organizationKey(id), org, time.Hour)
+
+	return &org, nil
+}
+
+// ListOrganizations 获取所有组织
+func (s *MySQLStorage) ListOrganizations() ([]*service.Organization, error) {
+	var records []Organization
+	if err := s.db.Order("id").Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]*service.Organization, len(records))
+	for i, record := range records {
+		result[i] = record.ToServiceModel()
+	}
+
+	return result, nil
+}
+
+// UpdateOrganizationSettings 修改组织设置
+func (s *MySQLStorage) UpdateOrganizationSettings(id uint, settings service.OrgSettings) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	result := s.db.Model(&Organization{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"settings":   string(data),
+		"updated_at": time.Now(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("组织不存在")
+	}
+
+	cache.Delete(context.Background(), organizationKey(id))
+	return nil
+}
+
+// GetMembership 获取用户在组织中的成员身份
+func (s *MySQLStorage) GetMembership(orgID, userID uint) (*service.OrgMembership, error) {
+	var membership OrgMembership
+	if err := s.db.Where("org_id = ? AND user_id = ?", orgID, userID).First(&membership).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("不是组织成员")
+		}
+		return nil, err
+	}
+
+	return membership.ToServiceModel(), nil
+}
+
+// ListMembers 获取组织的所有成员及其用户名
+func (s *MySQLStorage) ListMembers(orgID uint) ([]*service.OrgMembership, error) {
+	var rows []struct {
+		OrgMembership
+		Username string
+	}
+	if err := s.db.Model(&OrgMembership{}).
+		Select("org_memberships.*, users.username").
+		Joins("LEFT JOIN users ON users.id = org_memberships.user_id").
+		Where("org_memberships.org_id = ?", orgID).
+		Order("org_memberships.user_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]*service.OrgMembership, len(rows))
+	for i, row := range rows {
+		result[i] = row.OrgMembership.ToServiceModel()
+		result[i].Username = row.Username
+	}
+
+	return result, nil
+}
+
+// ListUserMemberships 获取用户加入的所有组织及组织名称
+func (s *MySQLStorage) ListUserMemberships(userID uint) ([]*service.OrgMembership, error) {
+	var rows []struct {
+		OrgMembership
+		OrgName string
+	}
+	if err := s.db.Model(&OrgMembership{}).
+		Select("org_memberships.*, organizations.name AS org_name").
+		Joins("JOIN organizations ON organizations.id = org_memberships.org_id").
+		Where("org_memberships.user_id = ?", userID).
+		Order("org_memberships.org_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]*service.OrgMembership, len(rows))
+	for i, row := range rows {
+		result[i] = row.OrgMembership.ToServiceModel()
+		result[i].OrgName = row.OrgName
+	}
+
+	return result, nil
+}
+
+// SaveMembership 添加成员，成员已存在时修改角色
+func (s *MySQLStorage) SaveMembership(membership *service.OrgMembership) error {
+	now := time.Now()
+	record := &OrgMembership{
+		OrgID:     membership.OrgID,
+		UserID:    membership.UserID,
+		Role:      membership.Role,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	return s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "org_id"}, {Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"role":       membership.Role,
+			"updated_at": now,
+		}),
+	}).Create(record).Error
+}
+
+// DeleteMembership 移除组织成员
+func (s *MySQLStorage) DeleteMembership(orgID, userID uint) error {
+	result := s.db.Where("org_id = ? AND user_id = ?", orgID, userID).Delete(&OrgMembership{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("不是组织成员")
+	}
+	return nil
+}
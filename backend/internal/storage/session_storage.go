@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"chat-llama/internal/service"
+
+	"gorm.io/gorm"
+)
+
+// CreateSession 创建登录会话
+func (s *MySQLStorage) CreateSession(session *service.Session) error {
+	record := &Session{
+		ID:         session.ID,
+		UserID:     session.UserID,
+		UserAgent:  session.UserAgent,
+		IP:         session.IP,
+		OrgID:      session.OrgID,
+		LastSeenAt: session.LastSeenAt,
+		CreatedAt:  session.CreatedAt,
+		UpdatedAt:  session.CreatedAt,
+	}
+
+	return s.db.Create(record).Error
+}
+
+// GetSession 获取登录会话
+func (s *MySQLStorage) GetSession(id string) (*service.Session, error) {
+	var session Session
+	if err := s.db.Where("id = ?", id).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("会话不存在")
+		}
+		return nil, err
+	}
+
+	return session.ToServiceModel(), nil
+}
+
+// ListUserSessions 获取用户所有未撤销的会话，按最近活跃时间倒序
+func (s *MySQLStorage) ListUserSessions(userID uint) ([]*service.Session, error) {
+	var sessions []Session
+	if err := s.db.Where("user_id = ? AND revoked_at IS NULL", userID).Order("last_seen_at DESC").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]*service.Session, len(sessions))
+	for i, session := range sessions {
+		result[i] = session.ToServiceModel()
+	}
+
+	return result, nil
+}
+
+// TouchSession 更新会话的最近活跃时间和IP
+func (s *MySQLStorage) TouchSession(id, ip string, at time.Time) error {
+	updates := map[string]interface{}{
+		"last_seen_at": at,
+	}
+	if ip != "" {
+		updates["ip"] = ip
+	}
+
+	return s.db.Model(&Session{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// UpdateSessionOrg 修改会话当前所在的组织
+func (s *MySQLStorage) UpdateSessionOrg(id string, orgID uint) error {
+	return s.db.Model(&Session{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"org_id":     orgID,
+		"updated_at": time.Now(),
+	}).Error
+}
+
+// RevokeSession 撤销登录会话
+func (s *MySQLStorage) RevokeSession(id string) error {
+	now := time.Now()
+	return s.db.Model(&Session{}).Where("id = ? AND revoked_at IS NULL", id).Updates(map[string]interface{}{
+		"revoked_at": now,
+		"updated_at": now,
+	}).Error
+}
+
+// RevokeUserSessions 撤销用户所有未撤销的会话
+func (s *MySQLStorage) RevokeUserSessions(userID uint) ([]string, error) {
+	var revoked []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Session{}).Where("user_id = ? AND revoked_at IS NULL", userID).Pluck("id", &revoked).Error; err != nil {
+			return err
+		}
+		if len(revoked) == 0 {
+			return nil
+		}
+
+		now := time.Now()
+		return tx.Model(&Session{}).Where("id IN ?", revoked).Updates(map[string]interface{}{
+			"revoked_at": now,
+			"updated_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return revoked, nil
+}
+
+// CreateRefreshToken 保存刷新令牌
+func (s *MySQLStorage) CreateRefreshToken(token *service.RefreshToken) error {
+	record := &RefreshToken{
+		ID:        token.ID,
+		SessionID: token.SessionID,
+		UserID:    token.UserID,
+		TokenHash: token.TokenHash,
+		ExpiresAt: token.ExpiresAt,
+		CreatedAt: token.CreatedAt,
+	}
+
+	return s.db.Create(record).Error
+}
+
+// GetRefreshTokenByHash 通过哈希值获取刷新令牌
+func (s *MySQLStorage) GetRefreshTokenByHash(hash string) (*service.RefreshToken, error) {
+	var token RefreshToken
+	if err := s.db.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("刷新令牌不存在")
+		}
+		return nil, err
+	}
+
+	return token.ToServiceModel(), nil
+}
+
+// UseRefreshToken 将刷新令牌标记为已使用，依赖条件更新保证并发刷新时只有一个请求成功
+func (s *MySQLStorage) UseRefreshToken(id string) (bool, error) {
+	result := s.db.Model(&RefreshToken{}).Where("id = ? AND used_at IS NULL", id).Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
@@ -1,170 +1,385 @@
-package storage
-
-import (
-	"context"
-	"errors"
-	"fmt"
-	"log"
-	"time"
-
-	"chat-llama/pkg/cache"
-	"chat-llama/pkg/db"
-
-	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
-)
-
-// UserStorage 用户存储实现
-type UserStorage struct {
-	db *gorm.DB
-}
-
-// NewUserStorage 创建用户存储
-func NewUserStorage() *UserStorage {
-	return &UserStorage{
-		db: db.DB,
-	}
-}
-
-// 用户缓存键
-func userKey(id uint) string {
-	return fmt.Sprintf("user:%d", id)
-}
-
-func userByUsernameKey(username string) string {
-	return fmt.Sprintf("user:name:%s", username)
-}
-
-// CreateUser 创建用户
-func (s *UserStorage) CreateUser(username, password string) (*User, error) {
-	ctx := context.Background()
-
-	// 检查用户名是否已存在
-	var count int64
-	s.db.Model(&User{}).Where("username = ?", username).Count(&count)
-	if count > 0 {
-		return nil, errors.New("用户名已存在")
-	}
-
-	// 哈希密码
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return nil, err
-	}
-
-	// 创建用户
-	user := &User{
-		Username:  username,
-		Password:  string(hashedPassword),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-
-	if err := s.db.Create(user).Error; err != nil {
-		return nil, err
-	}
-
-	// 清除可能存在的缓存
-	cache.Delete(ctx, userByUsernameKey(username))
-
-	return user, nil
-}
-
-// GetUserByID 通过ID获取用户
-func (s *UserStorage) GetUserByID(id uint) (*User, error) {
-	ctx := context.Background()
-
-	// 尝试从缓存获取
-	var user User
-	found, err := cache.Get(ctx, userKey(id), &user)
-	if err != nil {
-		return nil, err
-	}
-
-	if found {
-		return &user, nil
-	}
-
-	// 从数据库获取
-	if err := s.db.First(&user, id).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("用户不存在")
-		}
-		return nil, err
-	}
-
-	// 更新缓存
-	cache.Set(ctx, userKey(id), user, time.Hour*24)
-
-	return &user, nil
-}
-
-// GetUserByUsername 通过用户名获取用户
-func (s *UserStorage) GetUserByUsername(username string) (*User, error) {
-	ctx := context.Background()
-
-	log.Printf("尝试获取用户: %s", username)
-
-	// 尝试从缓存获取
-	var user User
-	found, err := cache.Get(ctx, userByUsernameKey(username), &user)
-	if err != nil {
-		log.Printf("获取缓存失败: %v", err)
-		// 继续从数据库获取
-	} else if found {
-		log.Printf("从缓存获取用户: %s 成功", username)
-		return &user, nil
-	}
-
-	// 从数据库获取
-	if err := s.db.Where("username = ?", username).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			log.Printf("用户 %s 不存在", username)
-			return nil, errors.New("用户不存在")
-		}
-		log.Printf("数据库错误: %v", err)
-		return nil, err
-	}
-
-	log.Printf("从数据库获取用户: %s 成功, ID=%d", username, user.ID)
-
-	// 更新缓存
-	cache.Set(ctx, userByUsernameKey(username), user, time.Hour*24)
-	cache.Set(ctx, userKey(user.ID), user, time.Hour*24)
-
-	return &user, nil
-}
-
-// VerifyPassword 验证用户密码
-func (s *UserStorage) VerifyPassword(username, password string) (*User, error) {
-	log.Printf("验证用户密码: %s", username)
-
-	// 获取用户
-	user, err := s.GetUserByUsername(username)
-	if err != nil {
-		log.Printf("获取用户失败: %v", err)
-		return nil, err
-	}
-
-	// 验证密码
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
-	if err != nil {
-		// 密码验证失败，清除缓存后再尝试从数据库获取新数据
-		ctx := context.Background()
-		cache.Delete(ctx, userByUsernameKey(username))
-		cache.Delete(ctx, userKey(user.ID))
-
-		// 重新从数据库获取用户信息
-		if err := s.db.Where("username = ?", username).First(user).Error; err != nil {
-			return nil, errors.New("用户不存在")
-		}
-
-		// 再次验证密码
-		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
-		if err != nil {
-			return nil, errors.New("密码错误")
-		}
-	}
-
-	return user, nil
-}
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"chat-llama/internal/service"
+	"chat-llama/pkg/cache"
+	"chat-llama/pkg/db"
+	"chat-llama/pkg/pii"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// UserStorage 用户存储实现
+type UserStorage struct {
+	db *gorm.DB
+}
+
+// NewUserStorage 创建用户存储
+func NewUserStorage() *UserStorage {
+	return &UserStorage{
+		db: db.DB,
+	}
+}
+
+// dummyPasswordHash 用户不存在时用于比较的哈希，使响应时间与密码错误时一致
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// 用户缓存键
+func userKey(id uint) string {
+	return fmt.Sprintf("user:%d", id)
+}
+
+func userByUsernameKey(username string) string {
+	return fmt.Sprintf("user:name:%s", username)
+}
+
+// CreateUser 创建用户，email可以为空
+func (s *UserStorage) CreateUser(username, password, email string) (*User, error) {
+	ctx := context.Background()
+
+	// 检查用户名是否已存在
+	var count int64
+	s.db.Model(&User{}).Where("username = ?", username).Count(&count)
+	if count > 0 {
+		return nil, errors.New("用户名已存在")
+	}
+
+	// 哈希密码
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建用户
+	user := &User{
+		Username:  username,
+		Password:  string(hashedPassword),
+		Email:     email,
+		Role:      service.RoleUser,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	if err := s.db.Create(user).Error; err != nil {
+		return nil, err
+	}
+
+	// 清除可能存在的缓存
+	cache.Delete(ctx, userByUsernameKey(username))
+
+	return user, nil
+}
+
+// GetUserByID 通过ID获取用户
+func (s *UserStorage) GetUserByID(id uint) (*User, error) {
+	ctx := context.Background()
+
+	// 尝试从缓存获取
+	var user User
+	found, err := cache.Get(ctx, userKey(id), &user)
+	if err != nil {
+		return nil, err
+	}
+
+	if found {
+		return &user, nil
+	}
+
+	// 从数据库获取
+	if err := s.db.First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, err
+	}
+
+	// 更新缓存
+	cache.Set(ctx, userKey(id), user, time.Hour*24)
+
+	return &user, nil
+}
+
+// GetUserByUsername 通过用户名获取用户
+func (s *UserStorage) GetUserByUsername(username string) (*User, error) {
+	ctx := context.Background()
+
+	log.Printf("尝试获取用户: %s", pii.ForLog(username))
+
+	// 尝试从缓存获取
+	var user User
+	found, err := cache.Get(ctx, userByUsernameKey(username), &user)
+	if err != nil {
+		log.Printf("获取缓存失败: %v", err)
+		// 继续从数据库获取
+	} else if found {
+		log.Printf("从缓存获取用户: %s 成功", pii.ForLog(username))
+		return &user, nil
+	}
+
+	// 从数据库获取
+	if err := s.db.Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("用户 %s 不存在", pii.ForLog(username))
+			return nil, errors.New("用户不存在")
+		}
+		log.Printf("数据库错误: %v", err)
+		return nil, err
+	}
+
+	log.Printf("从数据库获取用户: %s 成功, ID=%d", pii.ForLog(username), user.ID)
+
+	// 更新缓存
+	cache.Set(ctx, userByUsernameKey(username), user, time.Hour*24)
+	cache.Set(ctx, userKey(user.ID), user, time.Hour*24)
+
+	return &user, nil
+}
+
+// VerifyPassword 验证用户密码
+func (s *UserStorage) VerifyPassword(username, password string) (*User, error) {
+	log.Printf("验证用户密码: %s", pii.ForLog(username))
+
+	// 获取用户
+	user, err := s.GetUserByUsername(username)
+	if err != nil {
+		log.Printf("获取用户失败: %v", err)
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, err
+	}
+
+	// 验证密码
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	if err != nil {
+		// 密码验证失败，清除缓存后再尝试从数据库获取新数据
+		ctx := context.Background()
+		cache.Delete(ctx, userByUsernameKey(username))
+		cache.Delete(ctx, userKey(user.ID))
+
+		// 重新从数据库获取用户信息
+		if err := s.db.Where("username = ?", username).First(user).Error; err != nil {
+			return nil, errors.New("用户不存在")
+		}
+
+		// 再次验证密码
+		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+		if err != nil {
+			return nil, errors.New("密码错误")
+		}
+	}
+
+	return user, nil
+}
+
+// GetUserPlan 获取用户的配额套餐
+func (s *UserStorage) GetUserPlan(userID uint) (string, error) {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return "", err
+	}
+	return user.Plan, nil
+}
+
+// GetUserByEmail 通过邮箱获取用户
+func (s *UserStorage) GetUserByEmail(email string) (*User, error) {
+	var user User
+	if err := s.db.Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// UpdatePassword 更新用户密码
+func (s *UserStorage) UpdatePassword(userID uint, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	return s.updateUser(userID, map[string]interface{}{
+		"password": string(hashedPassword),
+	})
+}
+
+// CreatePasswordResetToken 保存重置密码令牌的哈希
+func (s *UserStorage) CreatePasswordResetToken(userID uint, tokenHash string, expiresAt time.Time, createdBy uint) error {
+	return s.db.Create(&PasswordResetToken{
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}).Error
+}
+
+// GetPasswordResetToken 校验重置密码令牌但不使用，返回令牌所属用户，令牌不存在、已过期或已使用时返回错误
+func (s *UserStorage) GetPasswordResetToken(tokenHash string) (uint, error) {
+	var token PasswordResetToken
+	if err := s.db.Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, errors.New("重置令牌无效")
+		}
+		return 0, err
+	}
+	if token.UsedAt != nil {
+		return 0, errors.New("重置令牌已使用")
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return 0, errors.New("重置令牌已过期")
+	}
+
+	return token.UserID, nil
+}
+
+// ResetPasswordWithToken 在同一事务中使用重置密码令牌并更新令牌所属用户的密码，
+// 令牌在此期间已被使用或已过期时不修改密码
+func (s *UserStorage) ResetPasswordWithToken(tokenHash, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	var user User
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var token PasswordResetToken
+		if err := tx.Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("重置令牌无效")
+			}
+			return err
+		}
+
+		// 条件更新保证令牌只能使用一次
+		now := time.Now()
+		result := tx.Model(&PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", token.ID, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("重置令牌已使用或已过期")
+		}
+
+		if err := tx.First(&user, token.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("用户不存在")
+			}
+			return err
+		}
+		updates := map[string]interface{}{
+			"password":   string(hashedPassword),
+			"updated_at": now,
+		}
+		// 用户自助找回时令牌只发送到用户的邮箱，能使用令牌说明用户可以接收该邮箱的邮件
+		if token.CreatedBy == 0 && user.Email != "" && user.EmailVerifiedAt == nil {
+			updates["email_verified_at"] = now
+		}
+		return tx.Model(&user).Updates(updates).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// 清除缓存
+	ctx := context.Background()
+	cache.Delete(ctx, userKey(user.ID))
+	cache.Delete(ctx, userByUsernameKey(user.Username))
+
+	return nil
+}
+
+// GetUserAccess 获取用户的角色及账号状态
+func (s *UserStorage) GetUserAccess(userID uint) (*service.UserAccess, error) {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	role := user.Role
+	if role == "" {
+		role = service.RoleUser
+	}
+
+	return &service.UserAccess{
+		Role:     role,
+		Disabled: user.DisabledAt != nil,
+	}, nil
+}
+
+// ListUsers 分页查询用户，query匹配用户名或邮箱，role为空时不按角色过滤
+func (s *UserStorage) ListUsers(query, role string, offset, limit int) ([]*User, int64, error) {
+	db := s.db.Model(&User{})
+	if query != "" {
+		like := "%" + query + "%"
+		db = db.Where("username LIKE ? OR email LIKE ?", like, like)
+	}
+	if role != "" {
+		db = db.Where("role = ?", role)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []*User
+	if err := db.Order("id").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// UpdateUserRole 修改用户角色
+func (s *UserStorage) UpdateUserRole(userID uint, role string) error {
+	return s.updateUser(userID, map[string]interface{}{
+		"role": role,
+	})
+}
+
+// SetUserDisabled 停用或启用账号
+func (s *UserStorage) SetUserDisabled(userID uint, disabled bool) error {
+	var disabledAt *time.Time
+	if disabled {
+		now := time.Now()
+		disabledAt = &now
+	}
+
+	return s.updateUser(userID, map[string]interface{}{
+		"disabled_at": disabledAt,
+	})
+}
+
+// updateUser 更新用户字段并清除缓存
+func (s *UserStorage) updateUser(userID uint, updates map[string]interface{}) error {
+	var user User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("用户不存在")
+		}
+		return err
+	}
+
+	updates["updated_at"] = time.Now()
+	if err := s.db.Model(&user).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	// 清除缓存
+	ctx := context.Background()
+	cache.Delete(ctx, userKey(user.ID))
+	cache.Delete(ctx, userByUsernameKey(user.Username))
+
+	return nil
+}
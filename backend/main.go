@@ -1,76 +1,385 @@
-package main
-
-import (
-	"log"
-
-	"chat-llama/config"
-	"chat-llama/internal/api"
-	"chat-llama/internal/model"
-	"chat-llama/internal/service"
-	"chat-llama/internal/storage"
-	"chat-llama/pkg/cache"
-	"chat-llama/pkg/db"
-)
-
-func main() {
-	// 加载配置
-	cfg, err := config.LoadConfig("")
-	if err != nil {
-		log.Fatalf("加载配置失败: %v", err)
-	}
-
-	// 初始化日志目录
-	if err := cfg.Log.InitLogDir(); err != nil {
-		log.Fatalf("初始化日志目录失败: %v", err)
-	}
-
-	// 初始化数据库连接
-	dbConfig := db.Config{
-		Host:     cfg.Database.Host,
-		Port:     cfg.Database.Port,
-		User:     cfg.Database.User,
-		Password: cfg.Database.Password,
-		DBName:   cfg.Database.DBName,
-	}
-
-	if err := db.InitMySQL(dbConfig); err != nil {
-		log.Fatalf("初始化数据库失败: %v", err)
-	}
-	defer db.Close()
-
-	// 初始化Redis连接
-	redisConfig := cache.Config{
-		Host:     cfg.Redis.Host,
-		Port:     cfg.Redis.Port,
-		Password: cfg.Redis.Password,
-		DB:       cfg.Redis.DB,
-	}
-
-	if err := cache.InitRedis(redisConfig); err != nil {
-		log.Fatalf("初始化Redis失败: %v", err)
-	}
-
-	// 初始化LLM客户端
-	llmClient, err := model.NewLLMClient(cfg.LLM.GetAddr())
-	if err != nil {
-		log.Fatalf("初始化LLM客户端失败: %v", err)
-	}
-	defer llmClient.Close()
-
-	// 初始化存储
-	store := storage.NewStorage()
-	userStorage := storage.NewUserStorage()
-
-	// 初始化服务
-	chatService := service.NewChatService(llmClient, store)
-
-	// 初始化路由
-	router := api.NewRouter(chatService, userStorage)
-	handler := router.Setup()
-
-	// 创建并启动服务器
-	server := api.NewServer(handler)
-	if err := server.Start(); err != nil {
-		log.Fatalf("服务器错误: %v", err)
-	}
-}
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"chat-llama/config"
+	"chat-llama/internal/api"
+	"chat-llama/internal/model"
+	"chat-llama/internal/service"
+	"chat-llama/internal/storage"
+	"chat-llama/pkg/blobstore"
+	"chat-llama/pkg/cache"
+	"chat-llama/pkg/db"
+	"chat-llama/pkg/mail"
+	"chat-llama/pkg/metrics"
+	"chat-llama/pkg/oidc"
+	"chat-llama/pkg/pii"
+	"chat-llama/pkg/ratelimit"
+	"chat-llama/pkg/vectorstore"
+)
+
+func main() {
+	// 加载配置
+	cfg, err := config.LoadConfig("")
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+
+	// 初始化日志目录
+	if err := cfg.Log.InitLogDir(); err != nil {
+		log.Fatalf("初始化日志目录失败: %v", err)
+	}
+
+	// 初始化数据库连接
+	dbConfig := db.Config{
+		Host:     cfg.Database.Host,
+		Port:     cfg.Database.Port,
+		User:     cfg.Database.User,
+		Password: cfg.Database.Password,
+		DBName:   cfg.Database.DBName,
+	}
+
+	if err := db.InitMySQL(dbConfig); err != nil {
+		log.Fatalf("初始化数据库失败: %v", err)
+	}
+	defer db.Close()
+
+	// 初始化Redis连接
+	redisConfig := cache.Config{
+		Host:     cfg.Redis.Host,
+		Port:     cfg.Redis.Port,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	}
+
+	if err := cache.InitRedis(redisConfig); err != nil {
+		log.Fatalf("初始化Redis失败: %v", err)
+	}
+
+	// 初始化个人信息屏蔽
+	redactor, err := newRedactor(cfg.Redaction)
+	if err != nil {
+		log.Fatalf("初始化个人信息屏蔽失败: %v", err)
+	}
+	if cfg.Redaction.Logs {
+		pii.SetLogRedactor(redactor)
+	}
+
+	// 初始化LLM客户端
+	llmClient, err := model.NewLLMClient(cfg.LLM.GetAddr())
+	if err != nil {
+		log.Fatalf("初始化LLM客户端失败: %v", err)
+	}
+	defer llmClient.Close()
+
+	// 初始化存储
+	store := storage.NewStorage()
+	userStorage := storage.NewUserStorage()
+
+	// 配置中的管理员在启动时授予管理员角色，之后通过管理接口调整角色
+	for _, userID := range cfg.Server.AdminUserIDs {
+		if err := userStorage.UpdateUserRole(userID, service.RoleAdmin); err != nil {
+			log.Printf("授予用户 %d 管理员角色失败: %v", userID, err)
+		}
+	}
+	// 审计事件写入只追加的audit_events表
+	auditLog := service.NewAuditLog(storage.NewAuditStorage())
+
+	// 初始化组织，组织设置只能选择已配置的套餐和角色
+	orgStorage := storage.NewOrganizationStorage()
+	orgOptions := service.OrganizationOptions{}
+	for name := range cfg.Quota.Plans {
+		orgOptions.Plans = append(orgOptions.Plans, name)
+	}
+	for name := range cfg.Tools.Personas {
+		orgOptions.Personas = append(orgOptions.Personas, name)
+	}
+	orgService := service.NewOrganizationService(orgStorage, orgOptions)
+
+	// 初始化登录认证，会话撤销记录保存在Redis中
+	authService := service.NewAuthService(storage.NewSessionStorage(), cache.NewRevocationList(cache.RedisClient, "session"), userStorage, orgStorage, cfg.Server.JWTSecret, service.AuthOptions{
+		AccessTokenTTL:  cfg.Auth.AccessTokenTTL,
+		RefreshTokenTTL: cfg.Auth.RefreshTokenTTL,
+	})
+	apiKeyService := service.NewAPIKeyService(storage.NewAPIKeyStorage(), userStorage, orgStorage)
+	var ssoService *service.SSOService
+	if cfg.SSO.Enabled {
+		providers := make([]*service.SSOProvider, len(cfg.SSO.Providers))
+		for i, provider := range cfg.SSO.Providers {
+			providers[i] = &service.SSOProvider{
+				Name:        provider.Name,
+				DisplayName: provider.DisplayName,
+				Client: oidc.NewProvider(oidc.Config{
+					Issuer:       provider.Issuer,
+					ClientID:     provider.ClientID,
+					ClientSecret: provider.ClientSecret,
+					RedirectURL:  provider.RedirectURL,
+					Scopes:       provider.Scopes,
+				}),
+				AutoCreate:  provider.AutoCreate,
+				LinkByEmail: provider.LinkByEmail,
+			}
+		}
+		ssoService = service.NewSSOService(userStorage, cache.NewStateStore(cache.RedisClient, "sso"), authService, providers, cfg.SSO.StateTTL)
+	}
+
+	// 初始化邮件发送
+	var mailSender mail.Sender
+	switch cfg.Mail.Sender {
+	case "smtp":
+		mailSender = mail.NewSMTPSender(mail.SMTPConfig{
+			Host:     cfg.Mail.SMTP.Host,
+			Port:     cfg.Mail.SMTP.Port,
+			Username: cfg.Mail.SMTP.Username,
+			Password: cfg.Mail.SMTP.Password,
+			From:     cfg.Mail.SMTP.From,
+		})
+	case "file":
+		mailSender, err = mail.NewFileSender(cfg.Mail.Dir)
+		if err != nil {
+			log.Fatalf("初始化邮件发送失败: %v", err)
+		}
+	default:
+		mailSender = mail.NewLogSender()
+	}
+
+	// 初始化限流与配额，Redis不可用时降级到内存计数
+	counterStore := ratelimit.NewFallbackStore(ratelimit.NewRedisStore(cache.RedisClient), ratelimit.NewMemoryStore())
+	var limiter *ratelimit.Limiter
+	if cfg.RateLimit.Enabled {
+		limiter = ratelimit.NewLimiter(counterStore, "user", cfg.RateLimit.RequestsPerMinute, time.Minute)
+	}
+	quotaService := service.NewQuotaService(counterStore, cfg.Quota, userStorage, orgService)
+	var loginGuard *service.LoginGuard
+	if cfg.Auth.LoginProtection.Enabled {
+		loginGuard = service.NewLoginGuard(counterStore, auditLog, service.LoginGuardOptions{
+			MaxUserFailures: cfg.Auth.LoginProtection.MaxUserFailures,
+			MaxIPFailures:   cfg.Auth.LoginProtection.MaxIPFailures,
+			FailureWindow:   cfg.Auth.LoginProtection.FailureWindow,
+			BaseDelay:       cfg.Auth.LoginProtection.BaseDelay,
+			MaxDelay:        cfg.Auth.LoginProtection.MaxDelay,
+			LockoutDuration: cfg.Auth.LoginProtection.LockoutDuration,
+		})
+	}
+
+	// 初始化服务
+	chatOptions := []service.ChatServiceOption{
+		service.WithQuotaService(quotaService),
+		service.WithOrganizationService(orgService),
+		service.WithModelName(cfg.LLM.Model),
+		service.WithRedactionPolicy(service.RedactionPolicy{
+			Redactor: redactor,
+			Storage:  cfg.Redaction.Storage,
+			Exports:  cfg.Redaction.Exports,
+		}),
+	}
+	if cfg.ResponseCache.Enabled {
+		responseCache := cache.NewResponseCache(cfg.ResponseCache.TTL, cfg.ResponseCache.MaxTemperature)
+		chatOptions = append(chatOptions, service.WithResponseCache(responseCache))
+	}
+	if cfg.SemanticCache.Enabled {
+		var vectorStore vectorstore.Store = vectorstore.NewMemoryStore()
+		if cfg.SemanticCache.Store == "redis" {
+			vectorStore = vectorstore.NewRedisStore(cache.RedisClient, "semantic_cache")
+		}
+		semanticCache := service.NewSemanticCache(llmClient, vectorStore, cfg.SemanticCache.Threshold, cfg.SemanticCache.TTL, cfg.SemanticCache.MaxEntries)
+		chatOptions = append(chatOptions, service.WithSemanticCache(semanticCache))
+	}
+	var knowledgeService *service.KnowledgeService
+	if cfg.Knowledge.Enabled {
+		var vectorStore vectorstore.Store = vectorstore.NewMemoryStore()
+		if cfg.Knowledge.Store == "redis" {
+			vectorStore = vectorstore.NewRedisStore(cache.RedisClient, "knowledge")
+		}
+		knowledgeService = service.NewKnowledgeService(storage.NewKnowledgeStorage(), llmClient, vectorStore, service.KnowledgeOptions{
+			ChunkSize:    cfg.Knowledge.ChunkSize,
+			ChunkOverlap: cfg.Knowledge.ChunkOverlap,
+			TopK:         cfg.Knowledge.TopK,
+			MinScore:     cfg.Knowledge.MinScore,
+		})
+		chatOptions = append(chatOptions, service.WithKnowledgeService(knowledgeService))
+	}
+	var attachmentService *service.AttachmentService
+	if cfg.Attachments.Enabled {
+		var blobStore blobstore.Store
+		if cfg.Attachments.Store == "s3" {
+			blobStore, err = blobstore.NewS3Store(blobstore.S3Config{
+				Endpoint:     cfg.Attachments.S3.Endpoint,
+				Region:       cfg.Attachments.S3.Region,
+				Bucket:       cfg.Attachments.S3.Bucket,
+				AccessKey:    cfg.Attachments.S3.AccessKey,
+				SecretKey:    cfg.Attachments.S3.SecretKey,
+				UsePathStyle: cfg.Attachments.S3.UsePathStyle,
+			})
+		} else {
+			blobStore, err = blobstore.NewLocalStore(cfg.Attachments.LocalPath)
+		}
+		if err != nil {
+			log.Fatalf("初始化附件存储失败: %v", err)
+		}
+		attachmentService = service.NewAttachmentService(storage.NewAttachmentStorage(), blobStore, service.AttachmentOptions{
+			MaxFileSize:    cfg.Attachments.MaxFileSize,
+			MaxPromptChars: cfg.Attachments.MaxPromptChars,
+		})
+		chatOptions = append(chatOptions, service.WithAttachmentService(attachmentService))
+	}
+	if cfg.Tools.Enabled {
+		location, err := time.LoadLocation(cfg.Tools.TimeZone)
+		if err != nil {
+			log.Fatalf("加载时区失败: %v", err)
+		}
+		toolRegistry := service.NewToolRegistry()
+		if err := service.RegisterBuiltinTools(toolRegistry, location); err != nil {
+			log.Fatalf("注册内置工具失败: %v", err)
+		}
+		chatOptions = append(chatOptions, service.WithToolRegistry(toolRegistry, service.ToolOptions{
+			MaxIterations: cfg.Tools.MaxIterations,
+			Timeout:       cfg.Tools.Timeout,
+			Personas:      cfg.Tools.Personas,
+		}))
+	}
+	var moderationService *service.ModerationService
+	if cfg.Moderation.Enabled {
+		checkers, err := newModerationCheckers(cfg.Moderation, llmClient)
+		if err != nil {
+			log.Fatalf("初始化内容审核失败: %v", err)
+		}
+		moderationService = service.NewModerationService(storage.NewModerationStorage(), checkers, service.ModerationOptions{
+			FailOpen:       cfg.Moderation.FailOpen,
+			RefusalMessage: cfg.Moderation.RefusalMessage,
+		})
+		chatOptions = append(chatOptions, service.WithModerationService(moderationService))
+	}
+	if cfg.Safety.Enabled {
+		rules := make([]service.SafetyRule, len(cfg.Safety.Rules))
+		for i, rule := range cfg.Safety.Rules {
+			rules[i] = service.SafetyRule{
+				Name:     rule.Name,
+				Kind:     rule.Kind,
+				Keywords: rule.Keywords,
+				Message:  rule.Message,
+			}
+		}
+		safetyRules, err := service.NewSafetyRules(rules)
+		if err != nil {
+			log.Fatalf("加载医疗安全规则失败: %v", err)
+		}
+		chatOptions = append(chatOptions, service.WithSafetyRules(safetyRules))
+	}
+
+	// 会话事件实时推送给所有者及成员的所有设备，事件带有每个用户递增的序号用于断线重连后补发
+	// 多实例部署时事件经Redis转发，序号和缓冲区也保存在Redis中
+	var backplane service.EventBackplane
+	var eventLog service.EventLog
+	if cfg.Realtime.Backplane {
+		backplane = cache.NewBroadcaster(cache.RedisClient, cfg.Realtime.Channel)
+	}
+	if cfg.Realtime.ReplayBuffer > 0 {
+		if cfg.Realtime.Backplane {
+			eventLog = service.NewRedisEventLog(cache.NewSequenceLog(cache.RedisClient, cfg.Realtime.Channel, cfg.Realtime.ReplayBuffer, cfg.Realtime.ReplayTTL))
+		} else {
+			eventLog = service.NewMemoryEventLog(cfg.Realtime.ReplayBuffer)
+		}
+	}
+	eventHub := service.NewEventHub(backplane, eventLog)
+	go eventHub.Run(context.Background())
+	metrics.NewGaugeFunc("chat_llama_event_queue_depth", "等待推送给WebSocket客户端的事件数", func() float64 {
+		return float64(eventHub.QueueDepth())
+	})
+	chatOptions = append(chatOptions, service.WithEventPublisher(eventHub))
+	wsTickets := service.NewWebSocketTicketService(cache.NewStateStore(cache.RedisClient, "ws_ticket"), cfg.Realtime.TicketTTL)
+	chatService := service.NewChatService(llmClient, store, chatOptions...)
+
+	// 初始化路由
+	router := api.NewRouter(chatService, eventHub, wsTickets, userStorage, authService, apiKeyService, ssoService, orgService, quotaService, knowledgeService, attachmentService, moderationService, mailSender, loginGuard, auditLog, limiter)
+	handler := router.Setup()
+
+	// 创建并启动服务器
+	server := api.NewServer(handler)
+	if err := server.Start(); err != nil {
+		log.Fatalf("服务器错误: %v", err)
+	}
+}
+
+// newModerationCheckers 根据配置创建内容检查器
+func newModerationCheckers(cfg config.ModerationConfig, llmClient *model.LLMClient) ([]service.ModerationChecker, error) {
+	var checkers []service.ModerationChecker
+
+	for _, blocklist := range cfg.Blocklists {
+		patterns, err := service.LoadPatternFile(blocklist.File)
+		if err != nil {
+			return nil, fmt.Errorf("读取黑名单%s失败: %w", blocklist.File, err)
+		}
+
+		var checker *service.PatternChecker
+		switch blocklist.Type {
+		case "keyword":
+			checker, err = service.NewKeywordChecker(blocklist.Category, blocklist.Action, patterns)
+		case "regex":
+			checker, err = service.NewRegexChecker(blocklist.Category, blocklist.Action, patterns)
+		default:
+			err = fmt.Errorf("不支持的黑名单类型: %s", blocklist.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("加载黑名单%s失败: %w", blocklist.File, err)
+		}
+		checkers = append(checkers, checker)
+	}
+
+	if len(cfg.PII) > 0 {
+		checker, err := service.NewPIIChecker(cfg.PII)
+		if err != nil {
+			return nil, err
+		}
+		checkers = append(checkers, checker)
+	}
+
+	if cfg.Classifier.Enabled {
+		checker, err := service.NewClassifierChecker(llmClient, cfg.Classifier.Threshold, cfg.Classifier.Action, cfg.Classifier.Labels)
+		if err != nil {
+			return nil, err
+		}
+		checkers = append(checkers, checker)
+	}
+
+	return checkers, nil
+}
+
+// newRedactor 根据配置创建个人信息屏蔽器
+func newRedactor(cfg config.RedactionConfig) (*pii.Redactor, error) {
+	enabled := make(map[string]bool, len(cfg.Types))
+	for _, typ := range cfg.Types {
+		if typ != pii.TypeName {
+			if _, err := pii.NewDetector(typ); err != nil {
+				return nil, err
+			}
+		}
+		enabled[typ] = true
+	}
+
+	var detectors []pii.Detector
+	for _, typ := range pii.PatternTypes {
+		if enabled[typ] {
+			detector, _ := pii.NewDetector(typ)
+			detectors = append(detectors, detector)
+		}
+	}
+	if enabled[pii.TypeName] {
+		names, err := service.LoadPatternFile(cfg.NameDictionary)
+		if err != nil {
+			return nil, fmt.Errorf("读取姓名词典失败: %w", err)
+		}
+		detectors = append(detectors, pii.NewNameDetector(names))
+	}
+
+	mask := pii.MaskPlaceholder
+	if cfg.Mask == "partial" {
+		mask = pii.MaskPartial
+	}
+
+	return pii.NewRedactor(mask, detectors...), nil
+}
@@ -0,0 +1,20 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("对象不存在")
+
+// Store 二进制对象存储接口
+type Store interface {
+	// Put 写入对象，已存在时覆盖
+	Put(ctx context.Context, key string, data io.Reader, size int64, contentType string) error
+	// Get 读取对象，调用方负责关闭返回的Reader
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除对象，对象不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+}
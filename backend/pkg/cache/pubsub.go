@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Broadcaster 基于Redis发布订阅在多个实例间广播消息
+type Broadcaster struct {
+	client  *redis.Client
+	channel string
+}
+
+// NewBroadcaster 创建广播器，channel用于区分不同用途
+func NewBroadcaster(client *redis.Client, channel string) *Broadcaster {
+	return &Broadcaster{
+		client:  client,
+		channel: "pubsub:" + channel,
+	}
+}
+
+// Publish 向所有订阅的实例广播消息，包括当前实例
+func (b *Broadcaster) Publish(ctx context.Context, payload []byte) error {
+	return b.client.Publish(ctx, b.channel, payload).Err()
+}
+
+// Subscribe 接收广播的消息并依次回调，阻塞直到ctx取消或连接失败
+func (b *Broadcaster) Subscribe(ctx context.Context, handler func(payload []byte)) error {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	defer pubsub.Close()
+
+	// 等待订阅确认，Redis不可用时立即返回错误
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			handler([]byte(msg.Payload))
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// SamplingParams 影响生成结果的采样参数
+type SamplingParams struct {
+	Temperature  float32
+	MaxNewTokens int32
+	TopK         int32
+}
+
+// CachedResponse 缓存的模型回复
+type CachedResponse struct {
+	Response string    `json:"response"`
+	Model    string    `json:"model"`
+	CachedAt time.Time `json:"cached_at"`
+}
+
+// ResponseCacheStats 响应缓存命中统计
+type ResponseCacheStats struct {
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// ResponseCache 对完全相同的提示词和采样参数缓存模型回复
+type ResponseCache struct {
+	ttl            time.Duration
+	maxTemperature float32
+	hits           atomic.Int64
+	misses         atomic.Int64
+}
+
+// NewResponseCache 创建响应缓存，只有温度不高于maxTemperature的请求才会被缓存
+func NewResponseCache(ttl time.Duration, maxTemperature float32) *ResponseCache {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+
+	return &ResponseCache{
+		ttl:            ttl,
+		maxTemperature: maxTemperature,
+	}
+}
+
+// responseCacheKey 生成缓存键，提示词以哈希形式参与计算，不同组织的缓存互不可见
+func responseCacheKey(orgID uint, model, prompt string, params SamplingParams) string {
+	promptHash := sha256.Sum256([]byte(prompt))
+	return fmt.Sprintf("respcache:%d:%s:%s:%.2f:%d:%d",
+		orgID,
+		model,
+		hex.EncodeToString(promptHash[:]),
+		params.Temperature,
+		params.MaxNewTokens,
+		params.TopK,
+	)
+}
+
+// Cacheable 判断采样参数是否足够确定以允许缓存
+func (c *ResponseCache) Cacheable(params SamplingParams) bool {
+	return params.Temperature <= c.maxTemperature
+}
+
+// Lookup 查找组织内缓存的回复，orgID为0表示个人空间
+func (c *ResponseCache) Lookup(ctx context.Context, orgID uint, model, prompt string, params SamplingParams) (*CachedResponse, bool) {
+	var cached CachedResponse
+	found, err := Get(ctx, responseCacheKey(orgID, model, prompt, params), &cached)
+	if err != nil || !found {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	c.hits.Add(1)
+	return &cached, true
+}
+
+// Store 在组织内缓存模型回复
+func (c *ResponseCache) Store(ctx context.Context, orgID uint, model, prompt string, params SamplingParams, response string) error {
+	cached := CachedResponse{
+		Response: response,
+		Model:    model,
+		CachedAt: time.Now(),
+	}
+
+	return Set(ctx, responseCacheKey(orgID, model, prompt, params), cached, c.ttl)
+}
+
+// Stats 获取命中统计
+func (c *ResponseCache) Stats() ResponseCacheStats {
+	hits := c.hits.Load()
+	misses := c.misses.Load()
+
+	stats := ResponseCacheStats{
+		Hits:   hits,
+		Misses: misses,
+	}
+	if total := hits + misses; total > 0 {
+		stats.HitRate = float64(hits) / float64(total)
+	}
+
+	return stats
+}
@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RevocationList 基于Redis的撤销列表，条目在ttl后自动过期
+type RevocationList struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRevocationList 创建撤销列表，prefix用于区分不同用途的列表
+func NewRevocationList(client *redis.Client, prefix string) *RevocationList {
+	return &RevocationList{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (l *RevocationList) key(id string) string {
+	return "revoked:" + l.prefix + ":" + id
+}
+
+// Revoke 将id加入撤销列表
+func (l *RevocationList) Revoke(ctx context.Context, id string, ttl time.Duration) error {
+	return l.client.Set(ctx, l.key(id), 1, ttl).Err()
+}
+
+// IsRevoked 检查id是否已撤销
+func (l *RevocationList) IsRevoked(ctx context.Context, id string) (bool, error) {
+	count, err := l.client.Exists(ctx, l.key(id)).Result()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
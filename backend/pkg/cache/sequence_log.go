@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SequenceEntry 序列日志中的一条记录
+type SequenceEntry struct {
+	Seq     uint64
+	Payload []byte
+}
+
+// SequenceLog 基于Redis的有界序列日志，每个键的序号单调递增，只保留最近的若干条记录
+type SequenceLog struct {
+	client *redis.Client
+	prefix string
+	maxLen int64
+	ttl    time.Duration
+}
+
+// NewSequenceLog 创建序列日志，每个键最多保留maxLen条记录，ttl内没有新记录时整体过期，ttl为0时默认1小时
+func NewSequenceLog(client *redis.Client, prefix string, maxLen int, ttl time.Duration) *SequenceLog {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &SequenceLog{
+		client: client,
+		prefix: prefix,
+		maxLen: int64(maxLen),
+		ttl:    ttl,
+	}
+}
+
+func (l *SequenceLog) seqKey(key string) string {
+	return "seqlog:" + l.prefix + ":" + key + ":seq"
+}
+
+func (l *SequenceLog) entriesKey(key string) string {
+	return "seqlog:" + l.prefix + ":" + key + ":entries"
+}
+
+// appendScript 原子地分配序号、追加记录并裁剪到最大长度，成员以序号为前缀避免相同内容被合并
+var appendScript = redis.NewScript(`
+local seq = redis.call('INCR', KEYS[1])
+redis.call('ZADD', KEYS[2], seq, seq .. ':' .. ARGV[1])
+redis.call('ZREMRANGEBYRANK', KEYS[2], 0, -tonumber(ARGV[2]) - 1)
+redis.call('EXPIRE', KEYS[1], ARGV[3])
+redis.call('EXPIRE', KEYS[2], ARGV[3])
+return seq
+`)
+
+// Append 分配下一个序号并追加记录
+func (l *SequenceLog) Append(ctx context.Context, key string, payload []byte) (uint64, error) {
+	seq, err := appendScript.Run(ctx, l.client,
+		[]string{l.seqKey(key), l.entriesKey(key)},
+		payload, l.maxLen, int64(l.ttl.Seconds()),
+	).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(seq), nil
+}
+
+// Since 获取序号大于after的记录及当前最新的序号
+func (l *SequenceLog) Since(ctx context.Context, key string, after uint64) ([]SequenceEntry, uint64, error) {
+	latest, err := l.client.Get(ctx, l.seqKey(key)).Uint64()
+	if err != nil && err != redis.Nil {
+		return nil, 0, err
+	}
+
+	values, err := l.client.ZRangeByScoreWithScores(ctx, l.entriesKey(key), &redis.ZRangeBy{
+		Min: "(" + strconv.FormatUint(after, 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries := make([]SequenceEntry, 0, len(values))
+	for _, value := range values {
+		member, _ := value.Member.(string)
+		if i := strings.IndexByte(member, ':'); i >= 0 {
+			member = member[i+1:]
+		}
+		entries = append(entries, SequenceEntry{
+			Seq:     uint64(value.Score),
+			Payload: []byte(member),
+		})
+	}
+
+	return entries, latest, nil
+}
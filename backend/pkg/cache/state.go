@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// StateStore 基于Redis的一次性状态存储，取出后即删除
+type StateStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewStateStore 创建一次性状态存储，prefix用于区分不同用途
+func NewStateStore(client *redis.Client, prefix string) *StateStore {
+	return &StateStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (s *StateStore) key(key string) string {
+	return "state:" + s.prefix + ":" + key
+}
+
+// Save 保存状态，ttl后自动过期
+func (s *StateStore) Save(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.key(key), data, ttl).Err()
+}
+
+// Take 取出并删除状态，不存在或已过期时返回false
+func (s *StateStore) Take(ctx context.Context, key string, dest interface{}) (bool, error) {
+	data, err := s.client.GetDel(ctx, s.key(key)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if err := json.Unmarshal(data, dest); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"database/sql"
+
+	"chat-llama/pkg/metrics"
+)
+
+// registerPoolMetrics 注册连接池指标，每次输出时读取连接池的当前状态
+func registerPoolMetrics(sqlDB *sql.DB) {
+	gauge := func(name, help string, value func(stats sql.DBStats) float64) {
+		metrics.NewGaugeFunc(name, help, func() float64 {
+			return value(sqlDB.Stats())
+		})
+	}
+	counter := func(name, help string, value func(stats sql.DBStats) float64) {
+		metrics.NewCounterFunc(name, help, func() float64 {
+			return value(sqlDB.Stats())
+		})
+	}
+
+	gauge("chat_llama_mysql_max_open_connections", "连接池允许的最大连接数", func(s sql.DBStats) float64 {
+		return float64(s.MaxOpenConnections)
+	})
+	gauge("chat_llama_mysql_open_connections", "已建立的连接数", func(s sql.DBStats) float64 {
+		return float64(s.OpenConnections)
+	})
+	gauge("chat_llama_mysql_in_use_connections", "正在使用的连接数", func(s sql.DBStats) float64 {
+		return float64(s.InUse)
+	})
+	gauge("chat_llama_mysql_idle_connections", "空闲的连接数", func(s sql.DBStats) float64 {
+		return float64(s.Idle)
+	})
+	counter("chat_llama_mysql_wait_count_total", "等待空闲连接的总次数", func(s sql.DBStats) float64 {
+		return float64(s.WaitCount)
+	})
+	counter("chat_llama_mysql_wait_duration_seconds_total", "等待空闲连接的总耗时", func(s sql.DBStats) float64 {
+		return s.WaitDuration.Seconds()
+	})
+	counter("chat_llama_mysql_max_idle_closed_total", "因超过最大空闲连接数而关闭的连接数", func(s sql.DBStats) float64 {
+		return float64(s.MaxIdleClosed)
+	})
+	counter("chat_llama_mysql_max_lifetime_closed_total", "因超过最长存活时间而关闭的连接数", func(s sql.DBStats) float64 {
+		return float64(s.MaxLifetimeClosed)
+	})
+}
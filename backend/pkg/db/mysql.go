@@ -1,75 +1,76 @@
-package db
-
-import (
-	"fmt"
-	"log"
-	"time"
-
-	"gorm.io/driver/mysql"
-	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
-	"gorm.io/gorm/schema"
-)
-
-var DB *gorm.DB
-
-// Config 数据库配置
-type Config struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
-}
-
-// InitMySQL 初始化数据库连接
-func InitMySQL(config Config) error {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		config.User,
-		config.Password,
-		config.Host,
-		config.Port,
-		config.DBName,
-	)
-
-	gormConfig := &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-		NamingStrategy: schema.NamingStrategy{
-			SingularTable: true, // 使用单数表名
-		},
-	}
-
-	var err error
-	DB, err = gorm.Open(mysql.New(mysql.Config{
-		DSN:                      dsn,
-		DefaultStringSize:        256,  // string 类型字段的默认长度
-		DisableDatetimePrecision: true, // 禁用 datetime 精度
-		DontSupportRenameIndex:   true, // 重命名索引时采用删除并新建的方式
-		DontSupportRenameColumn:  true, // 用 `change` 重命名列
-	}), gormConfig)
-	if err != nil {
-		return fmt.Errorf("连接数据库失败: %v", err)
-	}
-
-	// 设置连接池参数
-	sqlDB, err := DB.DB()
-	if err != nil {
-		return fmt.Errorf("获取数据库实例失败: %v", err)
-	}
-
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(100)
-	sqlDB.SetConnMaxLifetime(time.Hour)
-
-	log.Println("数据库连接成功")
-	return nil
-}
-
-// Close 关闭数据库连接
-func Close() error {
-	sqlDB, err := DB.DB()
-	if err != nil {
-		return err
-	}
-	return sqlDB.Close()
-}
+package db
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+var DB *gorm.DB
+
+// Config 数据库配置
+type Config struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	DBName   string
+}
+
+// InitMySQL 初始化数据库连接
+func InitMySQL(config Config) error {
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		config.User,
+		config.Password,
+		config.Host,
+		config.Port,
+		config.DBName,
+	)
+
+	gormConfig := &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Info),
+		NamingStrategy: schema.NamingStrategy{
+			SingularTable: true, // 使用单数表名
+		},
+	}
+
+	var err error
+	DB, err = gorm.Open(mysql.New(mysql.Config{
+		DSN:                      dsn,
+		DefaultStringSize:        256,  // string 类型字段的默认长度
+		DisableDatetimePrecision: true, // 禁用 datetime 精度
+		DontSupportRenameIndex:   true, // 重命名索引时采用删除并新建的方式
+		DontSupportRenameColumn:  true, // 用 `change` 重命名列
+	}), gormConfig)
+	if err != nil {
+		return fmt.Errorf("连接数据库失败: %v", err)
+	}
+
+	// 设置连接池参数
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return fmt.Errorf("获取数据库实例失败: %v", err)
+	}
+
+	sqlDB.SetMaxIdleConns(10)
+	sqlDB.SetMaxOpenConns(100)
+	sqlDB.SetConnMaxLifetime(time.Hour)
+	registerPoolMetrics(sqlDB)
+
+	log.Println("数据库连接成功")
+	return nil
+}
+
+// Close 关闭数据库连接
+func Close() error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
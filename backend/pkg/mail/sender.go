@@ -0,0 +1,127 @@
+package mail
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"chat-llama/pkg/pii"
+
+	"github.com/google/uuid"
+)
+
+// Message 待发送的邮件
+type Message struct {
+	To      string
+	Subject string
+	Body    string // 纯文本
+}
+
+// Sender 邮件发送接口
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// LogSender 只把邮件写入日志，适用于开发环境，收件人按日志策略屏蔽
+type LogSender struct{}
+
+// NewLogSender 创建日志邮件发送器
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+// Send 将邮件内容写入日志
+func (s *LogSender) Send(ctx context.Context, msg *Message) error {
+	log.Printf("发送邮件 - 收件人: %s, 主题: %s\n%s", pii.ForLog(msg.To), msg.Subject, msg.Body)
+	return nil
+}
+
+// FileSender 将邮件保存为目录中的.eml文件，适用于开发和测试环境
+type FileSender struct {
+	dir string
+}
+
+// NewFileSender 创建文件邮件发送器，目录不存在时自动创建
+func NewFileSender(dir string) (*FileSender, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建邮件目录失败: %w", err)
+	}
+
+	return &FileSender{
+		dir: dir,
+	}, nil
+}
+
+// Send 将邮件写入文件
+func (s *FileSender) Send(ctx context.Context, msg *Message) error {
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405"), uuid.New().String()[:8])
+	return os.WriteFile(filepath.Join(s.dir, name), buildMessage("", msg), 0600)
+}
+
+// SMTPConfig SMTP服务器配置
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPSender 通过SMTP服务器发送邮件，服务器支持时使用STARTTLS
+type SMTPSender struct {
+	config SMTPConfig
+}
+
+// NewSMTPSender 创建SMTP邮件发送器
+func NewSMTPSender(config SMTPConfig) *SMTPSender {
+	return &SMTPSender{
+		config: config,
+	}
+}
+
+// Send 发送邮件
+func (s *SMTPSender) Send(ctx context.Context, msg *Message) error {
+	var auth smtp.Auth
+	if s.config.Username != "" {
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+	}
+
+	addr := s.config.Host + ":" + s.config.Port
+	return smtp.SendMail(addr, auth, s.config.From, []string{msg.To}, buildMessage(s.config.From, msg))
+}
+
+// buildMessage 生成UTF-8编码的纯文本邮件
+func buildMessage(from string, msg *Message) []byte {
+	var sb strings.Builder
+	if from != "" {
+		fmt.Fprintf(&sb, "From: %s\r\n", from)
+	}
+	fmt.Fprintf(&sb, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&sb, "Subject: =?UTF-8?B?%s?=\r\n", base64Encode(msg.Subject))
+	fmt.Fprintf(&sb, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	sb.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+
+	// 正文按76字符换行
+	body := base64Encode(msg.Body)
+	for len(body) > 76 {
+		sb.WriteString(body[:76])
+		sb.WriteString("\r\n")
+		body = body[76:]
+	}
+	sb.WriteString(body)
+	sb.WriteString("\r\n")
+
+	return []byte(sb.String())
+}
+
+func base64Encode(text string) string {
+	return base64.StdEncoding.EncodeToString([]byte(text))
+}
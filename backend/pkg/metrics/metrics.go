@@ -0,0 +1,349 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets 默认的直方图分桶，单位秒，适用于请求耗时
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// collector 可以输出为Prometheus文本格式的指标
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+var (
+	registry      []collector
+	registryMutex sync.RWMutex
+)
+
+// register 注册指标，名称重复时panic，应在包初始化阶段调用
+func register(c collector) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	for _, existing := range registry {
+		if existing.name() == c.name() {
+			panic("metrics: 重复注册指标 " + c.name())
+		}
+	}
+	registry = append(registry, c)
+}
+
+// Handler 返回以Prometheus文本格式输出所有指标的处理程序
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteTo(w)
+	})
+}
+
+// WriteTo 按注册顺序输出所有指标
+func WriteTo(w io.Writer) {
+	registryMutex.RLock()
+	collectors := append([]collector(nil), registry...)
+	registryMutex.RUnlock()
+
+	buf := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(buf)
+	}
+	buf.Flush()
+}
+
+// desc 指标的名称、说明和标签
+type desc struct {
+	metricName string
+	help       string
+	labels     []string
+}
+
+func (d *desc) name() string {
+	return d.metricName
+}
+
+// writeHeader 输出HELP和TYPE行
+func (d *desc) writeHeader(w io.Writer, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.metricName, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.metricName, metricType)
+}
+
+// key 把标签值拼接为序列的键，标签值数量必须与标签数量一致
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: 指标 %s 需要 %d 个标签值，实际 %d 个", d.metricName, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelPairs 输出 {a="x",b="y"} 形式的标签，extra为附加的标签如le
+func (d *desc) labelPairs(values []string, extra ...string) string {
+	if len(d.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, label := range d.labels {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `%s="%s"`, label, escapeLabel(values[i]))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if sb.Len() > 1 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `%s="%s"`, extra[i], escapeLabel(extra[i+1]))
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// sortedKeys 按键排序，保证输出稳定
+func sortedKeys[V any](series map[string]V) []string {
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// splitKey 把序列的键还原为标签值
+func (d *desc) splitKey(key string) []string {
+	if len(d.labels) == 0 {
+		return nil
+	}
+	return strings.Split(key, "\xff")
+}
+
+// valueSeries 计数器和仪表盘共用的带标签数值序列
+type valueSeries struct {
+	desc
+	metricType string
+	values     map[string]float64
+	mutex      sync.Mutex
+}
+
+func newValueSeries(metricType, name, help string, labels []string) *valueSeries {
+	return &valueSeries{
+		desc:       desc{metricName: name, help: help, labels: labels},
+		metricType: metricType,
+		values:     make(map[string]float64),
+	}
+}
+
+func (s *valueSeries) add(delta float64, labelValues []string) {
+	key := s.key(labelValues)
+	s.mutex.Lock()
+	s.values[key] += delta
+	s.mutex.Unlock()
+}
+
+func (s *valueSeries) set(value float64, labelValues []string) {
+	key := s.key(labelValues)
+	s.mutex.Lock()
+	s.values[key] = value
+	s.mutex.Unlock()
+}
+
+func (s *valueSeries) write(w io.Writer) {
+	s.writeHeader(w, s.metricType)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// 没有标签的指标即使未记录过也输出0
+	if len(s.labels) == 0 && len(s.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", s.metricName)
+		return
+	}
+	for _, key := range sortedKeys(s.values) {
+		fmt.Fprintf(w, "%s%s %s\n", s.metricName, s.labelPairs(s.splitKey(key)), formatFloat(s.values[key]))
+	}
+}
+
+// Counter 只增不减的计数器
+type Counter struct {
+	series *valueSeries
+}
+
+// NewCounter 创建并注册计数器，labels为标签名
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{series: newValueSeries("counter", name, help, labels)}
+	register(c.series)
+	return c
+}
+
+// Inc 计数加1，labelValues与创建时的标签一一对应
+func (c *Counter) Inc(labelValues ...string) {
+	c.series.add(1, labelValues)
+}
+
+// Add 计数增加delta，delta为负数时忽略
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.series.add(delta, labelValues)
+}
+
+// Gauge 可增可减的仪表盘
+type Gauge struct {
+	series *valueSeries
+}
+
+// NewGauge 创建并注册仪表盘，labels为标签名
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{series: newValueSeries("gauge", name, help, labels)}
+	register(g.series)
+	return g
+}
+
+// Set 设置当前值
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.series.set(value, labelValues)
+}
+
+// Inc 当前值加1
+func (g *Gauge) Inc(labelValues ...string) {
+	g.series.add(1, labelValues)
+}
+
+// Dec 当前值减1
+func (g *Gauge) Dec(labelValues ...string) {
+	g.series.add(-1, labelValues)
+}
+
+// funcMetric 输出时调用函数取值的无标签指标
+type funcMetric struct {
+	desc
+	metricType string
+	fn         func() float64
+}
+
+func (m *funcMetric) write(w io.Writer) {
+	m.writeHeader(w, m.metricType)
+	fmt.Fprintf(w, "%s %s\n", m.metricName, formatFloat(m.fn()))
+}
+
+// NewGaugeFunc 注册在输出时调用fn取值的仪表盘，适用于连接池大小等由其他组件维护的数值
+func NewGaugeFunc(name, help string, fn func() float64) {
+	register(&funcMetric{desc: desc{metricName: name, help: help}, metricType: "gauge", fn: fn})
+}
+
+// NewCounterFunc 注册在输出时调用fn取值的计数器，fn返回的值应单调递增
+func NewCounterFunc(name, help string, fn func() float64) {
+	register(&funcMetric{desc: desc{metricName: name, help: help}, metricType: "counter", fn: fn})
+}
+
+// histogramSeries 单个标签组合的直方图数据
+type histogramSeries struct {
+	counts []uint64 // 各分桶的计数，不累加
+	sum    float64
+	count  uint64
+}
+
+// Histogram 分桶统计观测值的直方图
+type Histogram struct {
+	desc
+	buckets []float64
+	series  map[string]*histogramSeries
+	mutex   sync.Mutex
+}
+
+// NewHistogram 创建并注册直方图，buckets为升序的分桶上限，为空时使用DefaultBuckets
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	h := &Histogram{
+		desc:    desc{metricName: name, help: help, labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+	register(h)
+	return h
+}
+
+// Observe 记录一个观测值
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	s, exists := h.series[key]
+	if !exists {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, upper := range h.buckets {
+		if value <= upper {
+			s.counts[i]++
+			break
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.writeHeader(w, "histogram")
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		values := h.splitKey(key)
+
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelPairs(values, "le", formatFloat(upper)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelPairs(values, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.labelPairs(values), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.labelPairs(values), s.count)
+	}
+}
+
+// formatFloat 按Prometheus文本格式输出数值
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,228 @@
+// Package oidctest 提供用于测试的本地OIDC身份提供方
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"chat-llama/pkg/oidc"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// ClientID 模拟身份提供方接受的客户端ID
+const ClientID = "chat-llama-test"
+
+// authRequest 授权请求中与换取令牌相关的参数
+type authRequest struct {
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+}
+
+// IdP 模拟的OIDC身份提供方，支持发现文档、JWKS、授权码和S256方式的PKCE
+type IdP struct {
+	*httptest.Server
+
+	// Subject、Email和EmailVerified 为签发的ID令牌中的用户信息
+	Subject       string
+	Email         string
+	EmailVerified bool
+	// Mutate 不为nil时在签名前修改ID令牌的声明，用于构造无效的令牌
+	Mutate func(claims jwt.MapClaims)
+
+	// DiscoveryHits、JWKSHits 发现文档和JWKS被请求的次数
+	DiscoveryHits atomic.Int64
+	JWKSHits      atomic.Int64
+
+	mutex sync.Mutex
+	kid   string
+	key   *rsa.PrivateKey
+	codes map[string]*authRequest
+}
+
+// NewIdP 启动模拟的身份提供方，使用完毕后需要调用Close
+func NewIdP() *IdP {
+	idp := &IdP{
+		Subject:       "user-1",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		codes:         make(map[string]*authRequest),
+	}
+	idp.RotateKey("key-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.handleDiscovery)
+	mux.HandleFunc("/jwks", idp.handleJWKS)
+	mux.HandleFunc("/token", idp.handleToken)
+	idp.Server = httptest.NewServer(mux)
+
+	return idp
+}
+
+// Issuer 返回签发者地址
+func (idp *IdP) Issuer() string {
+	return idp.URL
+}
+
+// Config 返回连接到该身份提供方的客户端配置
+func (idp *IdP) Config() oidc.Config {
+	return oidc.Config{
+		Issuer:      idp.URL,
+		ClientID:    ClientID,
+		RedirectURL: "http://localhost/api/auth/sso/test/callback",
+	}
+}
+
+// RotateKey 生成新的签名密钥并以kid发布，旧密钥不再出现在JWKS中
+func (idp *IdP) RotateKey(kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	idp.mutex.Lock()
+	defer idp.mutex.Unlock()
+	idp.kid = kid
+	idp.key = key
+}
+
+// Authorize 模拟用户在身份提供方完成登录，返回回调中的授权码
+func (idp *IdP) Authorize(authURL string) (string, error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	if query.Get("response_type") != "code" {
+		return "", fmt.Errorf("不支持的response_type: %s", query.Get("response_type"))
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		return "", fmt.Errorf("缺少S256方式的PKCE")
+	}
+
+	code, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+
+	idp.mutex.Lock()
+	defer idp.mutex.Unlock()
+	idp.codes[code] = &authRequest{
+		clientID:      query.Get("client_id"),
+		redirectURI:   query.Get("redirect_uri"),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+	}
+
+	return code, nil
+}
+
+// IDToken 使用当前密钥签发ID令牌，默认声明对ClientID有效且一小时后过期
+func (idp *IdP) IDToken(nonce string) string {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            idp.URL,
+		"aud":            ClientID,
+		"sub":            idp.Subject,
+		"email":          idp.Email,
+		"email_verified": idp.EmailVerified,
+		"nonce":          nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	}
+	if idp.Mutate != nil {
+		idp.Mutate(claims)
+	}
+	return idp.Sign(claims)
+}
+
+// Sign 使用当前密钥签名任意声明
+func (idp *IdP) Sign(claims jwt.MapClaims) string {
+	idp.mutex.Lock()
+	kid, key := idp.kid, idp.key
+	idp.mutex.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+func (idp *IdP) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	idp.DiscoveryHits.Add(1)
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 idp.URL,
+		"authorization_endpoint": idp.URL + "/authorize",
+		"token_endpoint":         idp.URL + "/token",
+		"jwks_uri":               idp.URL + "/jwks",
+	})
+}
+
+func (idp *IdP) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	idp.JWKSHits.Add(1)
+
+	idp.mutex.Lock()
+	kid, key := idp.kid, idp.key
+	idp.mutex.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": kid,
+			"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+		}},
+	})
+}
+
+// handleToken 授权码只能使用一次，且PKCE验证码必须与授权请求中的挑战码匹配
+func (idp *IdP) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	idp.mutex.Lock()
+	req, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	idp.mutex.Unlock()
+
+	if !ok || req.clientID != r.PostForm.Get("client_id") || req.redirectURI != r.PostForm.Get("redirect_uri") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	if oidc.CodeChallenge(r.PostForm.Get("code_verifier")) != req.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"id_token":     idp.IDToken(req.nonce),
+		"expires_in":   3600,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,220 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"chat-llama/pkg/oidc"
+	"chat-llama/pkg/oidc/oidctest"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestCodeChallengeS256(t *testing.T) {
+	// 期望值由独立的SHA-256实现计算：base64url(sha256(verifier))，不带填充
+	verifier := "dBjftJeZ4CVP-mJ92K9cyfIdIrqyzRZMeIdkMAKw0nM"
+	if got, want := oidc.CodeChallenge(verifier), "22JdaJlB05lujzcpgLHh4QMkchsxp1NNQsLLRCWOdD0"; got != want {
+		t.Fatalf("CodeChallenge = %s, want %s", got, want)
+	}
+
+	random, err := oidc.RandomString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(random) != 43 || strings.ContainsAny(random, "+/=") {
+		t.Fatalf("RandomString = %q, want 43 URL-safe characters", random)
+	}
+}
+
+func TestDiscoveryAndAuthCodeURL(t *testing.T) {
+	idp := oidctest.NewIdP()
+	defer idp.Close()
+	provider := oidc.NewProvider(idp.Config())
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(authURL, idp.URL+"/authorize?") {
+		t.Fatalf("authURL = %s, want the discovered authorization endpoint", authURL)
+	}
+
+	query := mustQuery(t, authURL)
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             oidctest.ClientID,
+		"state":                 "state-1",
+		"nonce":                 "nonce-1",
+		"scope":                 "openid profile email",
+		"code_challenge":        oidc.CodeChallenge("verifier-1"),
+		"code_challenge_method": "S256",
+	}
+	for key, value := range want {
+		if got := query.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+	if query.Get("code_verifier") != "" {
+		t.Error("code_verifier must not be sent to the authorization endpoint")
+	}
+
+	// 发现文档只读取一次
+	if _, err := provider.AuthCodeURL(ctx, "state-2", "nonce-2", "verifier-2"); err != nil {
+		t.Fatal(err)
+	}
+	if hits := idp.DiscoveryHits.Load(); hits != 1 {
+		t.Fatalf("discovery fetched %d times, want 1", hits)
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	idp := oidctest.NewIdP()
+	defer idp.Close()
+	config := idp.Config()
+	config.Issuer = idp.URL + "/"
+	provider := oidc.NewProvider(config)
+
+	if _, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier"); err == nil {
+		t.Fatal("expected an error when the discovered issuer differs from the configured one")
+	}
+}
+
+func TestExchangeWithPKCE(t *testing.T) {
+	idp := oidctest.NewIdP()
+	defer idp.Close()
+	provider := oidc.NewProvider(idp.Config())
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 验证码与挑战码不匹配时身份提供方拒绝，且授权码随之作废
+	code, err := idp.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Exchange(ctx, code, "wrong-verifier"); err == nil {
+		t.Fatal("expected the exchange to fail with a wrong code verifier")
+	}
+	if _, err := provider.Exchange(ctx, code, "verifier-1"); err == nil {
+		t.Fatal("expected a used authorization code to be rejected")
+	}
+
+	code, err = idp.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := provider.Exchange(ctx, code, "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := provider.VerifyIDToken(ctx, token.IDToken, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != idp.Subject || claims.Email != idp.Email || !claims.EmailVerified {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestVerifyIDTokenRejectsInvalidTokens(t *testing.T) {
+	idp := oidctest.NewIdP()
+	defer idp.Close()
+	provider := oidc.NewProvider(idp.Config())
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		nonce  string
+		mutate func(claims jwt.MapClaims)
+	}{
+		{name: "nonce mismatch", nonce: "other-nonce"},
+		{name: "wrong audience", nonce: "nonce", mutate: func(c jwt.MapClaims) { c["aud"] = "another-client" }},
+		{name: "wrong issuer", nonce: "nonce", mutate: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{name: "expired", nonce: "nonce", mutate: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }},
+		{name: "missing expiry", nonce: "nonce", mutate: func(c jwt.MapClaims) { delete(c, "exp") }},
+		{name: "missing subject", nonce: "nonce", mutate: func(c jwt.MapClaims) { delete(c, "sub") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp.Mutate = tt.mutate
+			defer func() { idp.Mutate = nil }()
+
+			_, err := provider.VerifyIDToken(ctx, idp.IDToken("nonce"), tt.nonce)
+			if !errors.Is(err, oidc.ErrInvalidIDToken) {
+				t.Fatalf("err = %v, want ErrInvalidIDToken", err)
+			}
+		})
+	}
+
+	// 受众为数组且包含客户端ID时有效
+	idp.Mutate = func(c jwt.MapClaims) { c["aud"] = []string{"another-client", oidctest.ClientID} }
+	defer func() { idp.Mutate = nil }()
+	if _, err := provider.VerifyIDToken(ctx, idp.IDToken("nonce"), "nonce"); err != nil {
+		t.Fatalf("array audience containing the client ID should be accepted: %v", err)
+	}
+}
+
+func TestVerifyIDTokenRefetchesJWKSForUnknownKid(t *testing.T) {
+	idp := oidctest.NewIdP()
+	defer idp.Close()
+	provider := oidc.NewProvider(idp.Config())
+	ctx := context.Background()
+
+	if _, err := provider.VerifyIDToken(ctx, idp.IDToken("nonce"), "nonce"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.VerifyIDToken(ctx, idp.IDToken("nonce"), "nonce"); err != nil {
+		t.Fatal(err)
+	}
+	if hits := idp.JWKSHits.Load(); hits != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1 while the kid is cached", hits)
+	}
+
+	// 密钥轮换后出现未知的kid，重新读取JWKS
+	idp.RotateKey("key-2")
+	if _, err := provider.VerifyIDToken(ctx, idp.IDToken("nonce"), "nonce"); err != nil {
+		t.Fatalf("token signed with the rotated key should verify after refetching JWKS: %v", err)
+	}
+	if hits := idp.JWKSHits.Load(); hits != 2 {
+		t.Fatalf("JWKS fetched %d times, want 2 after key rotation", hits)
+	}
+
+	// JWKS中也不存在的kid被拒绝
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "x"})
+	token.Header["kid"] = "unknown"
+	forged, err := token.SignedString(mustKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.VerifyIDToken(ctx, forged, "nonce"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Fatalf("err = %v, want ErrInvalidIDToken for an unknown kid", err)
+	}
+}
+
+func mustQuery(t *testing.T, rawURL string) url.Values {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Query()
+}
+
+func mustKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
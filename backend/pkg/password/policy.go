@@ -0,0 +1,63 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Policy 密码强度策略
+type Policy struct {
+	MinLength        int  // 最少字符数
+	RequireLetter    bool // 必须包含字母
+	RequireDigit     bool // 必须包含数字
+	RequireSymbol    bool // 必须包含符号
+	DisallowUsername bool // 不能包含用户名
+}
+
+// ErrWeakPassword 密码不符合强度策略
+var ErrWeakPassword = errors.New("密码强度不足")
+
+// Validate 检查密码是否符合策略，不符合时返回包装了ErrWeakPassword的错误
+func (p Policy) Validate(password, username string) error {
+	minLength := p.MinLength
+	if minLength <= 0 {
+		minLength = 1
+	}
+	if utf8.RuneCountInString(password) < minLength {
+		return fmt.Errorf("%w: 至少需要%d个字符", ErrWeakPassword, minLength)
+	}
+	// bcrypt只使用前72字节
+	if len(password) > 72 {
+		return fmt.Errorf("%w: 密码不能超过72字节", ErrWeakPassword)
+	}
+
+	var hasLetter, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.RequireLetter && !hasLetter {
+		return fmt.Errorf("%w: 需要包含字母", ErrWeakPassword)
+	}
+	if p.RequireDigit && !hasDigit {
+		return fmt.Errorf("%w: 需要包含数字", ErrWeakPassword)
+	}
+	if p.RequireSymbol && !hasSymbol {
+		return fmt.Errorf("%w: 需要包含符号", ErrWeakPassword)
+	}
+	if p.DisallowUsername && username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return fmt.Errorf("%w: 不能包含用户名", ErrWeakPassword)
+	}
+
+	return nil
+}
@@ -0,0 +1,343 @@
+package pii
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// 个人信息类型
+const (
+	TypeIDCard   = "id_card"
+	TypePhone    = "phone"
+	TypeEmail    = "email"
+	TypeBankCard = "bank_card"
+	TypeName     = "name"
+)
+
+// Match 文本中检测到的个人信息，Start和End为字节偏移
+type Match struct {
+	Type  string
+	Start int
+	End   int
+	Value string
+}
+
+// Detector 个人信息检测器
+type Detector interface {
+	Type() string
+	Find(text string) []Match
+}
+
+// patternDetector 基于正则的检测器，匹配结果前后不能紧接字母或数字
+type patternDetector struct {
+	typ      string
+	pattern  *regexp.Regexp
+	validate func(value string) bool
+}
+
+func (d *patternDetector) Type() string {
+	return d.typ
+}
+
+func (d *patternDetector) Find(text string) []Match {
+	var matches []Match
+	for _, loc := range d.pattern.FindAllStringIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		if start > 0 && isASCIIAlnum(text[start-1]) {
+			continue
+		}
+		if end < len(text) && isASCIIAlnum(text[end]) {
+			continue
+		}
+
+		value := text[start:end]
+		if d.validate != nil && !d.validate(value) {
+			continue
+		}
+
+		matches = append(matches, Match{
+			Type:  d.typ,
+			Start: start,
+			End:   end,
+			Value: value,
+		})
+	}
+	return matches
+}
+
+func isASCIIAlnum(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// NewIDCardDetector 创建18位居民身份证号检测器，校验出生日期格式和校验码
+func NewIDCardDetector() Detector {
+	return &patternDetector{
+		typ:      TypeIDCard,
+		pattern:  regexp.MustCompile(`[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]`),
+		validate: validIDCardChecksum,
+	}
+}
+
+// idCardWeights 身份证号前17位的加权因子
+var idCardWeights = [17]int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+
+// idCardCheckCodes 加权和对11取余后对应的校验码
+const idCardCheckCodes = "10X98765432"
+
+func validIDCardChecksum(value string) bool {
+	if len(value) != 18 {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < 17; i++ {
+		sum += int(value[i]-'0') * idCardWeights[i]
+	}
+
+	return idCardCheckCodes[sum%11] == strings.ToUpper(value[17:])[0]
+}
+
+// NewPhoneDetector 创建中国大陆手机号检测器，支持+86前缀及空格、短横线分隔
+func NewPhoneDetector() Detector {
+	return &patternDetector{
+		typ:     TypePhone,
+		pattern: regexp.MustCompile(`(?:\+?86[- ]?)?1[3-9]\d(?:[- ]?\d{4}){2}`),
+	}
+}
+
+// NewEmailDetector 创建电子邮箱检测器
+func NewEmailDetector() Detector {
+	return &patternDetector{
+		typ:     TypeEmail,
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`),
+	}
+}
+
+// NewBankCardDetector 创建16至19位银行卡号检测器，支持每4位以空格或短横线分隔，校验Luhn校验位
+func NewBankCardDetector() Detector {
+	return &patternDetector{
+		typ:      TypeBankCard,
+		pattern:  regexp.MustCompile(`[1-9]\d{3}(?:[- ]?\d{4}){3}(?:[- ]?\d{1,3})?`),
+		validate: validLuhn,
+	}
+}
+
+func validLuhn(value string) bool {
+	var digits []int
+	for i := 0; i < len(value); i++ {
+		if value[i] >= '0' && value[i] <= '9' {
+			digits = append(digits, int(value[i]-'0'))
+		}
+	}
+	if len(digits) < 16 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		digit := digits[i]
+		if (len(digits)-1-i)%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+
+	return sum%10 == 0
+}
+
+// NewNameDetector 创建基于词典的姓名检测器，names为空时返回nil
+func NewNameDetector(names []string) Detector {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			quoted = append(quoted, regexp.QuoteMeta(name))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+
+	// 较长的姓名优先，避免"张三丰"只匹配到"张三"
+	sort.Slice(quoted, func(i, j int) bool {
+		return len(quoted[i]) > len(quoted[j])
+	})
+
+	return &patternDetector{
+		typ:     TypeName,
+		pattern: regexp.MustCompile(strings.Join(quoted, "|")),
+	}
+}
+
+// PatternTypes 可由NewDetector创建的类型，按优先级排列，身份证号与银行卡号重叠时优先识别为身份证号
+var PatternTypes = []string{TypeIDCard, TypePhone, TypeEmail, TypeBankCard}
+
+// NewDetector 按类型创建检测器，姓名检测器需要词典，请使用NewNameDetector
+func NewDetector(typ string) (Detector, error) {
+	switch typ {
+	case TypeIDCard:
+		return NewIDCardDetector(), nil
+	case TypePhone:
+		return NewPhoneDetector(), nil
+	case TypeEmail:
+		return NewEmailDetector(), nil
+	case TypeBankCard:
+		return NewBankCardDetector(), nil
+	default:
+		return nil, fmt.Errorf("不支持的个人信息类型: %s", typ)
+	}
+}
+
+// FindAll 使用多个检测器检测文本，结果按位置排序，重叠的匹配只保留先出现的较长者，
+// 位置和长度都相同时保留排在前面的检测器的结果
+func FindAll(text string, detectors ...Detector) []Match {
+	var matches []Match
+	for _, detector := range detectors {
+		matches = append(matches, detector.Find(text)...)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Start != matches[j].Start {
+			return matches[i].Start < matches[j].Start
+		}
+		return matches[i].End > matches[j].End
+	})
+
+	result := matches[:0]
+	lastEnd := -1
+	for _, match := range matches {
+		if match.Start < lastEnd {
+			continue
+		}
+		result = append(result, match)
+		lastEnd = match.End
+	}
+
+	return result
+}
+
+// Redact 将匹配内容替换为mask的返回值，matches需按位置排序且互不重叠
+func Redact(text string, matches []Match, mask func(Match) string) string {
+	if len(matches) == 0 {
+		return text
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, match := range matches {
+		sb.WriteString(text[last:match.Start])
+		sb.WriteString(mask(match))
+		last = match.End
+	}
+	sb.WriteString(text[last:])
+
+	return sb.String()
+}
+
+// typeLabels 各类个人信息的中文名称
+var typeLabels = map[string]string{
+	TypeIDCard:   "身份证号",
+	TypePhone:    "手机号",
+	TypeEmail:    "邮箱",
+	TypeBankCard: "银行卡号",
+	TypeName:     "姓名",
+}
+
+// Label 获取个人信息类型的中文名称
+func Label(typ string) string {
+	if label, exists := typeLabels[typ]; exists {
+		return label
+	}
+	return typ
+}
+
+// MaskPlaceholder 以类型占位符替换，如[手机号]
+func MaskPlaceholder(match Match) string {
+	return "[" + Label(match.Type) + "]"
+}
+
+// MaskPartial 保留部分字符以便辨认，如138****5678、张*
+func MaskPartial(match Match) string {
+	value := match.Value
+	switch match.Type {
+	case TypeEmail:
+		at := strings.LastIndex(value, "@")
+		first, _ := utf8.DecodeRuneInString(value)
+		return string(first) + "***" + value[at:]
+	case TypeName:
+		first, size := utf8.DecodeRuneInString(value)
+		return string(first) + strings.Repeat("*", utf8.RuneCountInString(value[size:]))
+	}
+
+	// 号码类只保留数字首尾，分隔符一并去掉
+	var digits []byte
+	for i := 0; i < len(value); i++ {
+		if isASCIIAlnum(value[i]) {
+			digits = append(digits, value[i])
+		}
+	}
+	keepHead, keepTail := 3, 4
+	switch match.Type {
+	case TypePhone:
+		// 去掉+86前缀
+		digits = digits[len(digits)-11:]
+	case TypeIDCard:
+		keepHead = 6
+	case TypeBankCard:
+		keepHead = 0
+	}
+	if len(digits) <= keepHead+keepTail {
+		return strings.Repeat("*", len(digits))
+	}
+
+	return string(digits[:keepHead]) + strings.Repeat("*", len(digits)-keepHead-keepTail) + string(digits[len(digits)-keepTail:])
+}
+
+// Redactor 使用一组检测器屏蔽文本中的个人信息
+type Redactor struct {
+	detectors []Detector
+	mask      func(Match) string
+}
+
+// NewRedactor 创建屏蔽器，mask为nil时使用MaskPlaceholder，nil检测器会被忽略
+func NewRedactor(mask func(Match) string, detectors ...Detector) *Redactor {
+	if mask == nil {
+		mask = MaskPlaceholder
+	}
+
+	r := &Redactor{mask: mask}
+	for _, detector := range detectors {
+		if detector != nil {
+			r.detectors = append(r.detectors, detector)
+		}
+	}
+
+	return r
+}
+
+// Redact 屏蔽文本中的个人信息，r为nil时原样返回
+func (r *Redactor) Redact(text string) string {
+	if r == nil || text == "" {
+		return text
+	}
+	return Redact(text, FindAll(text, r.detectors...), r.mask)
+}
+
+// logRedactor 写日志前使用的屏蔽器，进程内全局生效
+var logRedactor atomic.Pointer[Redactor]
+
+// SetLogRedactor 设置写日志前使用的屏蔽器，nil表示日志不做屏蔽
+func SetLogRedactor(r *Redactor) {
+	logRedactor.Store(r)
+}
+
+// ForLog 按日志策略屏蔽文本中的个人信息，写入日志的用户内容都应经过此函数
+func ForLog(text string) string {
+	return logRedactor.Load().Redact(text)
+}
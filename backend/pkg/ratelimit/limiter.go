@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Result 限流检查结果
+type Result struct {
+	Allowed   bool          `json:"allowed"`
+	Limit     int64         `json:"limit"`
+	Remaining int64         `json:"remaining"`
+	ResetIn   time.Duration `json:"reset_in"`
+}
+
+// Limiter 固定窗口限流器
+type Limiter struct {
+	store  Store
+	prefix string
+	limit  int64
+	window time.Duration
+}
+
+// NewLimiter 创建限流器，limit为每个窗口内允许的请求数
+func NewLimiter(store Store, prefix string, limit int64, window time.Duration) *Limiter {
+	return &Limiter{
+		store:  store,
+		prefix: prefix,
+		limit:  limit,
+		window: window,
+	}
+}
+
+// windowKey 生成当前窗口的计数键
+func (l *Limiter) windowKey(key string) string {
+	windowStart := time.Now().UnixNano() / int64(l.window)
+	return fmt.Sprintf("ratelimit:%s:%s:%d", l.prefix, key, windowStart)
+}
+
+// Allow 记录一次请求并返回是否允许通过
+func (l *Limiter) Allow(ctx context.Context, key string) (*Result, error) {
+	if l.limit <= 0 {
+		return &Result{Allowed: true}, nil
+	}
+
+	windowKey := l.windowKey(key)
+	count, err := l.store.IncrBy(ctx, windowKey, 1, l.window)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := l.limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	resetIn, _ := l.store.TTL(ctx, windowKey)
+
+	return &Result{
+		Allowed:   count <= l.limit,
+		Limit:     l.limit,
+		Remaining: remaining,
+		ResetIn:   resetIn,
+	}, nil
+}
+
+// Limit 返回每个窗口内允许的请求数
+func (l *Limiter) Limit() int64 {
+	return l.limit
+}
@@ -0,0 +1,235 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Store 计数存储接口
+type Store interface {
+	// IncrBy 将计数增加n并返回增加后的值，键首次创建时设置过期时间
+	IncrBy(ctx context.Context, key string, n int64, ttl time.Duration) (int64, error)
+	// Get 获取当前计数，键不存在时返回0
+	Get(ctx context.Context, key string) (int64, error)
+	// TTL 获取键的剩余过期时间
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	// Delete 删除计数
+	Delete(ctx context.Context, key string) error
+}
+
+// RedisStore 基于Redis的计数存储
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore 创建Redis计数存储
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{
+		client: client,
+	}
+}
+
+// incrByScript 原子地增加计数并为没有过期时间的键设置过期时间，
+// 分两条命令执行时设置过期时间失败会留下永不过期的键，调用方重试还会重复计数
+var incrByScript = redis.NewScript(`
+local val = redis.call('INCRBY', KEYS[1], ARGV[1])
+if redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return val
+`)
+
+// IncrBy 增加计数
+func (s *RedisStore) IncrBy(ctx context.Context, key string, n int64, ttl time.Duration) (int64, error) {
+	if s.client == nil {
+		return 0, errors.New("Redis未初始化")
+	}
+
+	return incrByScript.Run(ctx, s.client, []string{key}, n, ttl.Milliseconds()).Int64()
+}
+
+// Get 获取计数
+func (s *RedisStore) Get(ctx context.Context, key string) (int64, error) {
+	if s.client == nil {
+		return 0, errors.New("Redis未初始化")
+	}
+
+	val, err := s.client.Get(ctx, key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return strconv.ParseInt(val, 10, 64)
+}
+
+// TTL 获取剩余过期时间
+func (s *RedisStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if s.client == nil {
+		return 0, errors.New("Redis未初始化")
+	}
+
+	ttl, err := s.client.TTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+// Delete 删除计数
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	if s.client == nil {
+		return errors.New("Redis未初始化")
+	}
+
+	return s.client.Del(ctx, key).Err()
+}
+
+// memoryItem 内存计数项
+type memoryItem struct {
+	value     int64
+	expiresAt time.Time
+}
+
+// MemoryStore 基于内存的计数存储，仅在单实例内有效
+type MemoryStore struct {
+	items map[string]*memoryItem
+	mutex sync.Mutex
+}
+
+// NewMemoryStore 创建内存计数存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		items: make(map[string]*memoryItem),
+	}
+}
+
+// IncrBy 增加计数
+func (s *MemoryStore) IncrBy(ctx context.Context, key string, n int64, ttl time.Duration) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	item, exists := s.items[key]
+	if !exists || now.After(item.expiresAt) {
+		// 顺便清理过期的键，避免无限增长
+		s.cleanup(now)
+		item = &memoryItem{expiresAt: now.Add(ttl)}
+		s.items[key] = item
+	}
+
+	item.value += n
+	return item.value, nil
+}
+
+// Get 获取计数
+func (s *MemoryStore) Get(ctx context.Context, key string) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	item, exists := s.items[key]
+	if !exists || time.Now().After(item.expiresAt) {
+		return 0, nil
+	}
+
+	return item.value, nil
+}
+
+// TTL 获取剩余过期时间
+func (s *MemoryStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	item, exists := s.items[key]
+	if !exists {
+		return 0, nil
+	}
+
+	ttl := time.Until(item.expiresAt)
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+// Delete 删除计数
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.items, key)
+	return nil
+}
+
+// cleanup 清理过期的键，调用方需持有锁
+func (s *MemoryStore) cleanup(now time.Time) {
+	for key, item := range s.items {
+		if now.After(item.expiresAt) {
+			delete(s.items, key)
+		}
+	}
+}
+
+// FallbackStore 优先使用主存储，主存储不可用时降级到备用存储
+type FallbackStore struct {
+	primary  Store
+	fallback Store
+}
+
+// NewFallbackStore 创建带降级的计数存储
+func NewFallbackStore(primary, fallback Store) *FallbackStore {
+	return &FallbackStore{
+		primary:  primary,
+		fallback: fallback,
+	}
+}
+
+// IncrBy 增加计数
+func (s *FallbackStore) IncrBy(ctx context.Context, key string, n int64, ttl time.Duration) (int64, error) {
+	val, err := s.primary.IncrBy(ctx, key, n, ttl)
+	if err != nil {
+		log.Printf("计数存储不可用，降级到内存: %v", err)
+		return s.fallback.IncrBy(ctx, key, n, ttl)
+	}
+	return val, nil
+}
+
+// Get 获取计数
+func (s *FallbackStore) Get(ctx context.Context, key string) (int64, error) {
+	val, err := s.primary.Get(ctx, key)
+	if err != nil {
+		log.Printf("计数存储不可用，降级到内存: %v", err)
+		return s.fallback.Get(ctx, key)
+	}
+	return val, nil
+}
+
+// TTL 获取剩余过期时间
+func (s *FallbackStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.primary.TTL(ctx, key)
+	if err != nil {
+		return s.fallback.TTL(ctx, key)
+	}
+	return ttl, nil
+}
+
+// Delete 删除计数，两个存储中的计数都会删除
+func (s *FallbackStore) Delete(ctx context.Context, key string) error {
+	if err := s.fallback.Delete(ctx, key); err != nil {
+		return err
+	}
+	return s.primary.Delete(ctx, key)
+}
@@ -0,0 +1,86 @@
+package vectorstore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore 基于内存的向量存储，检索时暴力计算相似度，适用于开发和小规模数据
+type MemoryStore struct {
+	records map[string]*Record
+	mutex   sync.RWMutex
+}
+
+// NewMemoryStore 创建内存向量存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: make(map[string]*Record),
+	}
+}
+
+// Upsert 新增或覆盖记录
+func (s *MemoryStore) Upsert(ctx context.Context, records ...*Record) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, record := range records {
+		if record.CreatedAt.IsZero() {
+			record.CreatedAt = time.Now()
+		}
+		s.records[record.ID] = record
+	}
+
+	return nil
+}
+
+// Search 相似度检索
+func (s *MemoryStore) Search(ctx context.Context, vector []float32, topK int, filter Filter) ([]*Match, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return bruteForceSearch(s.snapshot(), vector, topK, filter), nil
+}
+
+// Get 获取单条记录
+func (s *MemoryStore) Get(ctx context.Context, id string) (*Record, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	record, exists := s.records[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	return record, nil
+}
+
+// Delete 删除记录
+func (s *MemoryStore) Delete(ctx context.Context, ids ...string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, id := range ids {
+		delete(s.records, id)
+	}
+
+	return nil
+}
+
+// List 分页列出记录
+func (s *MemoryStore) List(ctx context.Context, offset, limit int, filter Filter) ([]*Record, int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	records, total := paginate(s.snapshot(), offset, limit, filter)
+	return records, total, nil
+}
+
+// snapshot 返回所有记录的切片，调用方需持有锁
+func (s *MemoryStore) snapshot() []*Record {
+	records := make([]*Record, 0, len(s.records))
+	for _, record := range s.records {
+		records = append(records, record)
+	}
+	return records
+}
@@ -0,0 +1,114 @@
+package vectorstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore 基于Redis哈希的向量存储，所有记录保存在同一个哈希中，
+// 检索时取出全部记录暴力计算相似度，多实例部署时可共享数据
+type RedisStore struct {
+	client *redis.Client
+	key    string
+}
+
+// NewRedisStore 创建Redis向量存储，namespace用于区分不同用途的向量集合
+func NewRedisStore(client *redis.Client, namespace string) *RedisStore {
+	return &RedisStore{
+		client: client,
+		key:    fmt.Sprintf("vectors:%s", namespace),
+	}
+}
+
+// Upsert 新增或覆盖记录
+func (s *RedisStore) Upsert(ctx context.Context, records ...*Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	values := make([]interface{}, 0, len(records)*2)
+	for _, record := range records {
+		if record.CreatedAt.IsZero() {
+			record.CreatedAt = time.Now()
+		}
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		values = append(values, record.ID, data)
+	}
+
+	return s.client.HSet(ctx, s.key, values...).Err()
+}
+
+// Search 相似度检索
+func (s *RedisStore) Search(ctx context.Context, vector []float32, topK int, filter Filter) ([]*Match, error) {
+	records, err := s.all(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return bruteForceSearch(records, vector, topK, filter), nil
+}
+
+// Get 获取单条记录
+func (s *RedisStore) Get(ctx context.Context, id string) (*Record, error) {
+	data, err := s.client.HGet(ctx, s.key, id).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// Delete 删除记录
+func (s *RedisStore) Delete(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.client.HDel(ctx, s.key, ids...).Err()
+}
+
+// List 分页列出记录
+func (s *RedisStore) List(ctx context.Context, offset, limit int, filter Filter) ([]*Record, int, error) {
+	records, err := s.all(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	page, total := paginate(records, offset, limit, filter)
+	return page, total, nil
+}
+
+// all 读取全部记录，无法解析的记录会被跳过
+func (s *RedisStore) all(ctx context.Context) ([]*Record, error) {
+	values, err := s.client.HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*Record, 0, len(values))
+	for _, value := range values {
+		var record Record
+		if err := json.Unmarshal([]byte(value), &record); err != nil {
+			continue
+		}
+		records = append(records, &record)
+	}
+
+	return records, nil
+}
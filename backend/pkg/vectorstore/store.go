@@ -0,0 +1,123 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+// ErrNotFound 记录不存在
+var ErrNotFound = errors.New("向量记录不存在")
+
+// Record 向量记录
+type Record struct {
+	ID        string            `json:"id"`
+	Vector    []float32         `json:"vector"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Match 相似度检索结果
+type Match struct {
+	*Record
+	Score float32 `json:"score"`
+}
+
+// Filter 按元数据精确匹配过滤，为空时不过滤
+type Filter map[string]string
+
+// Store 向量存储接口
+type Store interface {
+	// Upsert 新增或覆盖记录
+	Upsert(ctx context.Context, records ...*Record) error
+	// Search 返回与vector余弦相似度最高的topK条记录，按相似度降序
+	Search(ctx context.Context, vector []float32, topK int, filter Filter) ([]*Match, error)
+	// Get 获取单条记录
+	Get(ctx context.Context, id string) (*Record, error)
+	// Delete 删除记录，不存在的ID会被忽略
+	Delete(ctx context.Context, ids ...string) error
+	// List 按创建时间倒序分页列出记录，同时返回总数
+	List(ctx context.Context, offset, limit int, filter Filter) ([]*Record, int, error)
+}
+
+// CosineSimilarity 计算两个向量的余弦相似度，维度不一致时返回0
+func CosineSimilarity(a, b []float32) float32 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
+
+// matches 判断记录是否满足过滤条件
+func (f Filter) matches(record *Record) bool {
+	for key, value := range f {
+		if record.Metadata[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// bruteForceSearch 对候选记录逐一计算相似度并取topK
+func bruteForceSearch(records []*Record, vector []float32, topK int, filter Filter) []*Match {
+	var result []*Match
+	for _, record := range records {
+		if !filter.matches(record) {
+			continue
+		}
+		result = append(result, &Match{
+			Record: record,
+			Score:  CosineSimilarity(vector, record.Vector),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Score > result[j].Score
+	})
+
+	if topK > 0 && len(result) > topK {
+		result = result[:topK]
+	}
+
+	return result
+}
+
+// paginate 按创建时间倒序过滤并分页
+func paginate(records []*Record, offset, limit int, filter Filter) ([]*Record, int) {
+	var filtered []*Record
+	for _, record := range records {
+		if filter.matches(record) {
+			filtered = append(filtered, record)
+		}
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return filtered[i].CreatedAt.After(filtered[j].CreatedAt)
+	})
+
+	total := len(filtered)
+	if offset >= total {
+		return []*Record{}, total
+	}
+
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+
+	return filtered[offset:end], total
+}
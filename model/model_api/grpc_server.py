@@ -4,6 +4,7 @@ path_add2 = r'D:\baby-llama\baby-llama2-chinese\model'
 sys.path.append(path_add)
 sys.path.append(path_add2)
 import os
+import time
 import grpc
 from concurrent import futures
 import torch
@@ -27,6 +28,14 @@ class LLMServicer(llm_service_pb2_grpc.LLMServiceServicer):
         
         # 加载模型
         self.load_model()
+        
+        # 可选的内容安全分类模型，未配置时Classify返回UNIMPLEMENTED
+        self.classifier = None
+        classifier_path = os.environ.get('MODERATION_CLASSIFIER_MODEL')
+        if classifier_path:
+            from transformers import pipeline
+            self.classifier = pipeline('text-classification', model=classifier_path, top_k=None, device=0 if self.device_type == 'cuda' else -1)
+            self.classifier_name = os.path.basename(classifier_path.rstrip('/\\'))
     
     def load_model(self):
         # 模型参数
@@ -57,6 +66,8 @@ class LLMServicer(llm_service_pb2_grpc.LLMServiceServicer):
         torch.backends.cudnn.allow_tf32 = True
         
         # 加载检查点
+        self.model_name = 'baby-llama'
+        self.max_seq_len = max_seq_len
         ckpt_path = '../model_para/baby_llama.pth'
         state_dict = torch.load(ckpt_path, map_location=self.device)
         
@@ -87,9 +98,11 @@ class LLMServicer(llm_service_pb2_grpc.LLMServiceServicer):
         temperature = request.temperature
         max_new_tokens = request.max_new_tokens
         top_k = request.top_k
+        start = time.perf_counter()
         
         # 生成回答
         x = self.tokenizer.encode(prompt, add_special_tokens=False) + [self.tokenizer.special_tokens['<bos>']]
+        prompt_tokens = len(x)
         x = (torch.tensor(x, dtype=torch.long, device=self.device)[None, ...])
         
         with torch.no_grad():
@@ -98,7 +111,55 @@ class LLMServicer(llm_service_pb2_grpc.LLMServiceServicer):
                 answer = self.tokenizer.decode(y[0].tolist())
                 answer = answer.replace(prompt, '')
         
-        return llm_service_pb2.GenerateResponse(response=answer)
+        # 统计token用量和耗时
+        completion_tokens = max(len(y[0]) - prompt_tokens, 0)
+        latency_ms = int((time.perf_counter() - start) * 1000)
+        
+        return llm_service_pb2.GenerateResponse(
+            response=answer,
+            prompt_tokens=prompt_tokens,
+            completion_tokens=completion_tokens,
+            latency_ms=latency_ms,
+            model=self.model_name,
+        )
+
+    def Embed(self, request, context):
+        embeddings = []
+        
+        with torch.no_grad():
+            with self.ctx:
+                for text in request.texts:
+                    x = self.tokenizer.encode(text, add_special_tokens=False)[-self.max_seq_len:]
+                    if not x:
+                        x = [self.tokenizer.special_tokens['<bos>']]
+                    x = torch.tensor(x, dtype=torch.long, device=self.device)[None, ...]
+                    
+                    # 取最后一层隐状态的均值作为文本向量，并做L2归一化
+                    h = self.model.tok_embeddings(x)
+                    seqlen = x.shape[1]
+                    freqs_cos = self.model.freqs_cos[:seqlen]
+                    freqs_sin = self.model.freqs_sin[:seqlen]
+                    for layer in self.model.layers:
+                        h = layer(h, freqs_cos, freqs_sin)
+                    h = self.model.norm(h)
+                    vector = torch.nn.functional.normalize(h.mean(dim=1)[0].float(), dim=0)
+                    
+                    embeddings.append(llm_service_pb2.Embedding(values=vector.tolist()))
+        
+        dimension = len(embeddings[0].values) if embeddings else 0
+        return llm_service_pb2.EmbedResponse(embeddings=embeddings, dimension=dimension, model=self.model_name)
+
+    def Classify(self, request, context):
+        if self.classifier is None:
+            context.abort(grpc.StatusCode.UNIMPLEMENTED, '未配置内容分类模型')
+        
+        results = self.classifier(request.text, truncation=True)
+        # 不同版本的transformers对单条输入可能多包一层列表
+        if results and isinstance(results[0], list):
+            results = results[0]
+        
+        labels = [llm_service_pb2.ClassifyLabel(label=r['label'], score=float(r['score'])) for r in results]
+        return llm_service_pb2.ClassifyResponse(labels=labels, model=self.classifier_name)
 
 def serve():
     # 创建 gRPC 服务器
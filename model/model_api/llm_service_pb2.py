@@ -14,7 +14,7 @@ _sym_db = _symbol_database.Default()
 
 
 
-DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x11llm_service.proto\x12\x03llm\"]\n\x0fGenerateRequest\x12\x0e\n\x06prompt\x18\x01 \x01(\t\x12\x13\n\x0btemperature\x18\x02 \x01(\x02\x12\x16\n\x0emax_new_tokens\x18\x03 \x01(\x05\x12\r\n\x05top_k\x18\x04 \x01(\x05\"$\n\x10GenerateResponse\x12\x10\n\x08response\x18\x01 \x01(\t2G\n\nLLMService\x12\x39\n\x08Generate\x12\x14.llm.GenerateRequest\x1a\x15.llm.GenerateResponse\"\x00\x62\x06proto3')
+DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x11llm_service.proto\x12\x03llm\"]\n\x0fGenerateRequest\x12\x0e\n\x06prompt\x18\x01 \x01(\t\x12\x13\n\x0btemperature\x18\x02 \x01(\x02\x12\x16\n\x0emax_new_tokens\x18\x03 \x01(\x05\x12\r\n\x05top_k\x18\x04 \x01(\x05\"y\n\x10GenerateResponse\x12\x10\n\x08response\x18\x01 \x01(\t\x12\x15\n\rprompt_tokens\x18\x02 \x01(\x05\x12\x19\n\x11\x63ompletion_tokens\x18\x03 \x01(\x05\x12\x12\n\nlatency_ms\x18\x04 \x01(\x03\x12\r\n\x05model\x18\x05 \x01(\t\"\x1d\n\x0c\x45mbedRequest\x12\r\n\x05texts\x18\x01 \x03(\t\"\x1b\n\tEmbedding\x12\x0e\n\x06values\x18\x01 \x03(\x02\"U\n\rEmbedResponse\x12\"\n\nembeddings\x18\x01 \x03(\x0b\x32\x0e.llm.Embedding\x12\x11\n\tdimension\x18\x02 \x01(\x05\x12\r\n\x05model\x18\x03 \x01(\t\"\x1f\n\x0f\x43lassifyRequest\x12\x0c\n\x04text\x18\x01 \x01(\t\"-\n\rClassifyLabel\x12\r\n\x05label\x18\x01 \x01(\t\x12\r\n\x05score\x18\x02 \x01(\x02\"E\n\x10\x43lassifyResponse\x12\"\n\x06labels\x18\x01 \x03(\x0b\x32\x12.llm.ClassifyLabel\x12\r\n\x05model\x18\x02 \x01(\t2\xb4\x01\n\nLLMService\x12\x39\n\x08Generate\x12\x14.llm.GenerateRequest\x1a\x15.llm.GenerateResponse\"\x00\x12\x30\n\x05\x45mbed\x12\x11.llm.EmbedRequest\x1a\x12.llm.EmbedResponse\"\x00\x12\x39\n\x08\x43lassify\x12\x14.llm.ClassifyRequest\x1a\x15.llm.ClassifyResponse\"\x00\x62\x06proto3')
 
 _globals = globals()
 _builder.BuildMessageAndEnumDescriptors(DESCRIPTOR, _globals)
@@ -24,7 +24,19 @@ if _descriptor._USE_C_DESCRIPTORS == False:
   _globals['_GENERATEREQUEST']._serialized_start=26
   _globals['_GENERATEREQUEST']._serialized_end=119
   _globals['_GENERATERESPONSE']._serialized_start=121
-  _globals['_GENERATERESPONSE']._serialized_end=157
-  _globals['_LLMSERVICE']._serialized_start=159
-  _globals['_LLMSERVICE']._serialized_end=230
+  _globals['_GENERATERESPONSE']._serialized_end=242
+  _globals['_EMBEDREQUEST']._serialized_start=244
+  _globals['_EMBEDREQUEST']._serialized_end=273
+  _globals['_EMBEDDING']._serialized_start=275
+  _globals['_EMBEDDING']._serialized_end=302
+  _globals['_EMBEDRESPONSE']._serialized_start=304
+  _globals['_EMBEDRESPONSE']._serialized_end=389
+  _globals['_CLASSIFYREQUEST']._serialized_start=391
+  _globals['_CLASSIFYREQUEST']._serialized_end=422
+  _globals['_CLASSIFYLABEL']._serialized_start=424
+  _globals['_CLASSIFYLABEL']._serialized_end=469
+  _globals['_CLASSIFYRESPONSE']._serialized_start=471
+  _globals['_CLASSIFYRESPONSE']._serialized_end=540
+  _globals['_LLMSERVICE']._serialized_start=543
+  _globals['_LLMSERVICE']._serialized_end=723
 # @@protoc_insertion_point(module_scope)
@@ -20,6 +20,16 @@ class LLMServiceStub(object):
                 request_serializer=llm__service__pb2.GenerateRequest.SerializeToString,
                 response_deserializer=llm__service__pb2.GenerateResponse.FromString,
                 )
+        self.Embed = channel.unary_unary(
+                '/llm.LLMService/Embed',
+                request_serializer=llm__service__pb2.EmbedRequest.SerializeToString,
+                response_deserializer=llm__service__pb2.EmbedResponse.FromString,
+                )
+        self.Classify = channel.unary_unary(
+                '/llm.LLMService/Classify',
+                request_serializer=llm__service__pb2.ClassifyRequest.SerializeToString,
+                response_deserializer=llm__service__pb2.ClassifyResponse.FromString,
+                )
 
 
 class LLMServiceServicer(object):
@@ -32,6 +42,20 @@ class LLMServiceServicer(object):
         context.set_details('Method not implemented!')
         raise NotImplementedError('Method not implemented!')
 
+    def Embed(self, request, context):
+        """计算文本向量，用于语义缓存和检索
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def Classify(self, request, context):
+        """对文本做内容安全分类，用于内容审核
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
 
 def add_LLMServiceServicer_to_server(servicer, server):
     rpc_method_handlers = {
@@ -40,6 +64,16 @@ def add_LLMServiceServicer_to_server(servicer, server):
                     request_deserializer=llm__service__pb2.GenerateRequest.FromString,
                     response_serializer=llm__service__pb2.GenerateResponse.SerializeToString,
             ),
+            'Embed': grpc.unary_unary_rpc_method_handler(
+                    servicer.Embed,
+                    request_deserializer=llm__service__pb2.EmbedRequest.FromString,
+                    response_serializer=llm__service__pb2.EmbedResponse.SerializeToString,
+            ),
+            'Classify': grpc.unary_unary_rpc_method_handler(
+                    servicer.Classify,
+                    request_deserializer=llm__service__pb2.ClassifyRequest.FromString,
+                    response_serializer=llm__service__pb2.ClassifyResponse.SerializeToString,
+            ),
     }
     generic_handler = grpc.method_handlers_generic_handler(
             'llm.LLMService', rpc_method_handlers)
@@ -67,3 +101,37 @@ class LLMService(object):
             llm__service__pb2.GenerateResponse.FromString,
             options, channel_credentials,
             insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def Embed(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/llm.LLMService/Embed',
+            llm__service__pb2.EmbedRequest.SerializeToString,
+            llm__service__pb2.EmbedResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def Classify(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/llm.LLMService/Classify',
+            llm__service__pb2.ClassifyRequest.SerializeToString,
+            llm__service__pb2.ClassifyResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
@@ -2,23 +2,32 @@ import React, { useState, useEffect } from 'react';
 import { BrowserRouter as Router, Route, Switch, Redirect } from 'react-router-dom';
 import Login from './components/Login';
 import Chat from './components/Chat';
+import { authFetch, saveTokens, clearTokens } from './api';
 import './App.css';
 
 function App() {
   const [isAuthenticated, setIsAuthenticated] = useState(false);
   const [user, setUser] = useState(null);
   const [loading, setLoading] = useState(true);
+  const [ssoError, setSsoError] = useState('');
 
   useEffect(() => {
+    // 单点登录回调，令牌在URL片段中
+    if (window.location.pathname === '/sso/callback') {
+      const params = new URLSearchParams(window.location.hash.slice(1));
+      if (params.get('token')) {
+        saveTokens(params.get('token'), params.get('refresh_token'));
+      } else {
+        setSsoError(params.get('error') || '单点登录失败');
+      }
+      window.history.replaceState(null, '', '/');
+    }
+
     // 检查本地存储中是否有令牌
     const token = localStorage.getItem('token');
     if (token) {
       // 验证令牌
-      fetch('/api/user/profile', {
-        headers: {
-          'Authorization': `Bearer ${token}`
-        }
-      })
+      authFetch('/api/user/profile')
       .then(response => {
         if (response.ok) {
           return response.json();
@@ -30,11 +39,11 @@ function App() {
           setUser(data.data);
           setIsAuthenticated(true);
         } else {
-          localStorage.removeItem('token');
+          clearTokens();
         }
       })
       .catch(() => {
-        localStorage.removeItem('token');
+        clearTokens();
       })
       .finally(() => {
         setLoading(false);
@@ -44,14 +53,19 @@ function App() {
     }
   }, []);
 
-  const handleLogin = (userData, token) => {
-    localStorage.setItem('token', token);
+  const handleLogin = (userData, token, refreshToken) => {
+    saveTokens(token, refreshToken);
     setUser(userData);
     setIsAuthenticated(true);
   };
 
-  const handleLogout = () => {
-    localStorage.removeItem('token');
+  const handleLogout = async () => {
+    try {
+      await authFetch('/api/auth/logout', { method: 'POST' });
+    } catch (err) {
+      console.error('退出登录失败', err);
+    }
+    clearTokens();
     setUser(null);
     setIsAuthenticated(false);
   };
@@ -65,7 +79,7 @@ function App() {
       <div className="app">
         <Switch>
           <Route path="/login">
-            {isAuthenticated ? <Redirect to="/" /> : <Login onLogin={handleLogin} />}
+            {isAuthenticated ? <Redirect to="/" /> : <Login onLogin={handleLogin} initialError={ssoError} />}
           </Route>
           <Route path="/">
             {isAuthenticated ? (
@@ -0,0 +1,68 @@
+// 带登录令牌的请求，访问令牌过期时使用刷新令牌换取新令牌后重试一次
+let refreshing = null;
+
+const refreshTokens = async () => {
+  const refreshToken = localStorage.getItem('refresh_token');
+  if (!refreshToken) {
+    return false;
+  }
+
+  try {
+    const response = await fetch('/api/auth/refresh', {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({ refresh_token: refreshToken }),
+    });
+    const data = await response.json();
+    if (data.code === 200) {
+      localStorage.setItem('token', data.data.token);
+      localStorage.setItem('refresh_token', data.data.refresh_token);
+      return true;
+    }
+  } catch (err) {
+    console.error('刷新令牌失败', err);
+  }
+
+  localStorage.removeItem('token');
+  localStorage.removeItem('refresh_token');
+  return false;
+};
+
+const withToken = (options) => ({
+  ...options,
+  headers: {
+    ...(options.headers || {}),
+    'Authorization': `Bearer ${localStorage.getItem('token')}`,
+  },
+});
+
+export const authFetch = async (url, options = {}) => {
+  const response = await fetch(url, withToken(options));
+  if (response.status !== 401) {
+    return response;
+  }
+
+  // 多个请求同时过期时只刷新一次
+  if (!refreshing) {
+    refreshing = refreshTokens().finally(() => {
+      refreshing = null;
+    });
+  }
+  if (!(await refreshing)) {
+    return response;
+  }
+
+  return fetch(url, withToken(options));
+};
+
+export const saveTokens = (token, refreshToken) => {
+  localStorage.setItem('token', token);
+  localStorage.setItem('refresh_token', refreshToken);
+};
+
+export const clearTokens = () => {
+  localStorage.removeItem('token');
+  localStorage.removeItem('refresh_token');
+};
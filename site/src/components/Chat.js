@@ -1,6 +1,7 @@
 import React, { useState, useEffect, useRef } from 'react';
 import Sidebar from './Sidebar';
 import Message from './Message';
+import { authFetch, saveTokens } from '../api';
 import './Chat.css';
 
 function Chat({ user, onLogout }) {
@@ -10,18 +11,15 @@ function Chat({ user, onLogout }) {
   const [inputMessage, setInputMessage] = useState('');
   const [loading, setLoading] = useState(false);
   const [mobileSidebarOpen, setMobileSidebarOpen] = useState(false);
+  const [organizations, setOrganizations] = useState([]);
+  const [currentOrgId, setCurrentOrgId] = useState(0);
   
   const messageEndRef = useRef(null);
-  const token = localStorage.getItem('token');
 
   // 获取所有会话
   const fetchConversations = async () => {
     try {
-      const response = await fetch('/api/conversations', {
-        headers: {
-          'Authorization': `Bearer ${token}`
-        }
-      });
+      const response = await authFetch('/api/conversations');
       const data = await response.json();
       if (data.code === 200) {
         setConversations(data.data);
@@ -31,17 +29,51 @@ function Chat({ user, onLogout }) {
     }
   };
 
-  // 获取会话历史
-  const fetchMessages = async (conversationId) => {
+  // 获取加入的组织及当前所在的组织
+  const fetchOrganizations = async () => {
+    try {
+      const response = await authFetch('/api/orgs');
+      const data = await response.json();
+      if (data.code === 200) {
+        setOrganizations(data.data.organizations || []);
+        setCurrentOrgId(data.data.current || 0);
+      }
+    } catch (err) {
+      console.error('获取组织列表失败', err);
+    }
+  };
+
+  // 切换组织，0表示个人空间，切换后会话列表只包含该组织的会话
+  const switchOrganization = async (orgId) => {
     try {
-      const response = await fetch(`/api/conversations/${conversationId}/messages`, {
+      const response = await authFetch('/api/orgs/switch', {
+        method: 'POST',
         headers: {
-          'Authorization': `Bearer ${token}`
-        }
+          'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({ org_id: orgId }),
       });
       const data = await response.json();
       if (data.code === 200) {
-        setMessages(data.data);
+        saveTokens(data.data.token, data.data.refresh_token);
+        setCurrentOrgId(orgId);
+        setCurrentConversation(null);
+        setMessages([]);
+        await fetchConversations();
+      }
+    } catch (err) {
+      console.error('切换组织失败', err);
+    }
+  };
+
+  // 获取会话历史
+  const fetchMessages = async (conversationId) => {
+    try {
+      const response = await authFetch(`/api/conversations/${conversationId}/messages`);
+      const data = await response.json();
+      if (data.code === 200) {
+        // 工具调用的中间过程不展示给用户
+        setMessages(data.data.filter(m => m.role !== 'tool' && (m.content || !m.tool_calls)));
       }
     } catch (err) {
       console.error('获取消息历史失败', err);
@@ -65,11 +97,8 @@ function Chat({ user, onLogout }) {
   // 删除会话
   const deleteConversation = async (conversationId) => {
     try {
-      const response = await fetch(`/api/conversations/${conversationId}`, {
-        method: 'DELETE',
-        headers: {
-          'Authorization': `Bearer ${token}`
-        }
+      const response = await authFetch(`/api/conversations/${conversationId}`, {
+        method: 'DELETE'
       });
       
       if (response.ok) {
@@ -104,11 +133,10 @@ function Chat({ user, onLogout }) {
     setLoading(true);
     
     try {
-      const response = await fetch('/api/chat', {
+      const response = await authFetch('/api/chat', {
         method: 'POST',
         headers: {
-          'Content-Type': 'application/json',
-          'Authorization': `Bearer ${token}`
+          'Content-Type': 'application/json'
         },
         body: JSON.stringify({
           conversation_id: currentConversation ? currentConversation.id : '',
@@ -155,6 +183,7 @@ function Chat({ user, onLogout }) {
   // 初始加载
   useEffect(() => {
     fetchConversations();
+    fetchOrganizations();
   }, []);
 
   // 消息滚动到底部
@@ -172,6 +201,9 @@ function Chat({ user, onLogout }) {
           onDeleteConversation={deleteConversation}
           currentConversationId={currentConversation?.id}
           onLogout={onLogout}
+          organizations={organizations}
+          currentOrgId={currentOrgId}
+          onSwitchOrganization={switchOrganization}
         />
       </div>
       
@@ -1,12 +1,25 @@
-import React, { useState } from 'react';
+import React, { useState, useEffect } from 'react';
 import './Login.css';
 
-function Login({ onLogin }) {
+function Login({ onLogin, initialError }) {
   const [isLogin, setIsLogin] = useState(true);
   const [username, setUsername] = useState('');
   const [password, setPassword] = useState('');
-  const [error, setError] = useState('');
+  const [error, setError] = useState(initialError || '');
   const [loading, setLoading] = useState(false);
+  const [providers, setProviders] = useState([]);
+
+  useEffect(() => {
+    // 获取可用的单点登录方式
+    fetch('/api/auth/sso/providers')
+      .then(response => response.json())
+      .then(data => {
+        if (data.code === 200) {
+          setProviders(data.data || []);
+        }
+      })
+      .catch(() => {});
+  }, []);
 
   const handleSubmit = async (e) => {
     e.preventDefault();
@@ -28,7 +41,7 @@ function Login({ onLogin }) {
       if (data.code === 200) {
         if (isLogin) {
           // 登录成功
-          onLogin(data.data.user, data.data.token);
+          onLogin(data.data.user, data.data.token, data.data.refresh_token);
         } else {
           // 注册成功，切换到登录
           setIsLogin(true);
@@ -85,6 +98,20 @@ function Login({ onLogin }) {
             {loading ? '处理中...' : isLogin ? '登录' : '注册'}
           </button>
         </form>
+
+        {isLogin && providers.length > 0 && (
+          <div className="login-sso">
+            {providers.map(provider => (
+              <a
+                key={provider.name}
+                className="login-button login-sso-button"
+                href={`/api/auth/sso/${encodeURIComponent(provider.name)}/login`}
+              >
+                使用{provider.display_name || provider.name}登录
+              </a>
+            ))}
+          </div>
+        )}
         
         <div className="login-switch">
           {isLogin ? (
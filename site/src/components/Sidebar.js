@@ -7,7 +7,10 @@ function Sidebar({
   onNewChat, 
   onDeleteConversation,
   currentConversationId,
-  onLogout
+  onLogout,
+  organizations = [],
+  currentOrgId = 0,
+  onSwitchOrganization
 }) {
   return (
     <>
@@ -18,6 +21,19 @@ function Sidebar({
           </svg>
           新对话
         </button>
+        {organizations.length > 0 && (
+          <select
+            className="org-select"
+            value={currentOrgId}
+            onChange={(e) => onSwitchOrganization(Number(e.target.value))}
+            aria-label="切换组织"
+          >
+            <option value={0}>个人空间</option>
+            {organizations.map(org => (
+              <option key={org.org_id} value={org.org_id}>{org.org_name}</option>
+            ))}
+          </select>
+        )}
       </div>
       
       <div className="conversations-list">